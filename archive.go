@@ -0,0 +1,150 @@
+package httpsim
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Archive tells a step to unpack its archive response (zip, tar or tar.gz) and run
+// its KeysOutput against one of the member files, e.g. exports delivered as zipped CSV.
+// The unpacked files count towards the step's MaxBodySize and the flow's
+// MaxDecompressionRatio.
+type Archive struct {
+	// Member is the path of the file to extract from in the archive. It may be a
+	// pattern (path.Match) in which case the first matching file is used.
+	Member string
+	// Dir, if not empty, is the directory the whole archive is unpacked to,
+	// otherwise everything happens in memory
+	Dir string
+}
+
+// archiveFile is a regular file of an archive
+type archiveFile struct {
+	name string
+	read func() ([]byte, error)
+}
+
+// Unpack unpacks the archive (writing it to Dir if set) and returns the content of Member
+func (a *Archive) Unpack(body []byte) ([]byte, error) {
+	return a.unpack(body, &decompressionLimit{left: -1})
+}
+
+// unpack unpacks the archive, decompressing up to the limit
+func (a *Archive) unpack(body []byte, limit *decompressionLimit) ([]byte, error) {
+	files, err := archiveFiles(body, limit)
+	if err != nil {
+		return nil, err
+	}
+	var member []byte
+	found := false
+	for _, f := range files {
+		matched, err := path.Match(a.Member, f.name)
+		if err != nil {
+			return nil, err
+		}
+		if !matched && a.Dir == "" {
+			continue
+		}
+		content, err := f.read()
+		if err != nil {
+			return nil, err
+		}
+		if a.Dir != "" {
+			if err := writeArchiveFile(a.Dir, f.name, content); err != nil {
+				return nil, err
+			}
+		}
+		if matched && !found {
+			member, found = content, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no file '%s' in archive", a.Member)
+	}
+	return member, nil
+}
+
+// archiveFiles lists the regular files of a zip, tar or gzipped tar archive
+func archiveFiles(body []byte, limit *decompressionLimit) ([]archiveFile, error) {
+	switch {
+	case bytes.HasPrefix(body, []byte("PK\x03\x04")), bytes.HasPrefix(body, []byte("PK\x05\x06")):
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			return nil, err
+		}
+		var files []archiveFile
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			f := f
+			files = append(files, archiveFile{name: f.Name, read: func() ([]byte, error) {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return limit.read(rc)
+			}})
+		}
+		return files, nil
+	case bytes.HasPrefix(body, []byte("\x1f\x8b")):
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		tarball, err := limit.read(gr)
+		if err != nil {
+			return nil, err
+		}
+		// the tarball's files are already decompressed
+		return archiveFiles(tarball, &decompressionLimit{left: -1})
+	case len(body) > 262 && string(body[257:262]) == "ustar":
+		var files []archiveFile
+		tr := tar.NewReader(bytes.NewReader(body))
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				return files, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if h.Typeflag != tar.TypeReg {
+				continue
+			}
+			// tar can only be read sequentially
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, archiveFile{name: h.Name, read: func() ([]byte, error) {
+				return content, nil
+			}})
+		}
+	}
+	return nil, errors.New("response isn't a zip, tar or tar.gz archive")
+}
+
+// writeArchiveFile writes the file under dir, refusing paths escaping it (zip slip)
+func writeArchiveFile(dir, name string, content []byte) error {
+	dest := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return fmt.Errorf("archive file '%s' is outside of the destination", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, content, 0644)
+}
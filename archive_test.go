@@ -0,0 +1,138 @@
+package httpsim
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testZip(files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func testTarGz(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestArchive_Unpack(t *testing.T) {
+	files := map[string]string{
+		"export/readme.txt":        "hello",
+		"export/transactions.csv":  "ref,amount\nAB12,10.00\n",
+		"export/nested/other.json": `{"a":1}`,
+	}
+	for _, body := range [][]byte{testZip(files), testTarGz(files)} {
+		a := &Archive{Member: "export/*.csv"}
+		member, err := a.Unpack(body)
+		assert.Nil(t, err)
+		assert.Equal(t, "ref,amount\nAB12,10.00\n", string(member))
+
+		a.Member = "missing.csv"
+		_, err = a.Unpack(body)
+		assert.EqualError(t, err, "no file 'missing.csv' in archive")
+	}
+
+	_, err := (&Archive{Member: "x"}).Unpack([]byte("<html>"))
+	assert.EqualError(t, err, "response isn't a zip, tar or tar.gz archive")
+}
+
+func TestArchive_UnpackToDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpsim")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	a := &Archive{Member: "a.txt", Dir: dir}
+	_, err = a.Unpack(testZip(map[string]string{"a.txt": "a", "sub/b.txt": "b"}))
+	assert.Nil(t, err)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "sub", "b.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "b", string(b))
+
+	_, err = a.Unpack(testZip(map[string]string{"a.txt": "a", "../evil.txt": "evil"}))
+	assert.EqualError(t, err, "archive file '../evil.txt' is outside of the destination")
+
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer os.Chdir(wd)
+	assert.Nil(t, os.Chdir(dir))
+	a.Dir = "."
+	_, err = a.Unpack(testZip(map[string]string{"a.txt": "a", "sub/c.txt": "c"}))
+	assert.Nil(t, err)
+	b, err = ioutil.ReadFile(filepath.Join(dir, "sub", "c.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "c", string(b))
+	_, err = a.Unpack(testZip(map[string]string{"a.txt": "a", "../evil.txt": "evil"}))
+	assert.EqualError(t, err, "archive file '../evil.txt' is outside of the destination")
+}
+
+func TestFlow_ExecuteArchive(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(testZip(map[string]string{"statement.csv": "ref,amount\nAB12,10.00\n"}))
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:       "export",
+		Request:    Request{URL: srv.URL, Method: "GET"},
+		Archive:    &Archive{Member: "statement.csv"},
+		KeysOutput: []Extracter{CSVExtractable{Name: "amount", Header: "amount"}},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "10.00", f.Values["amount"])
+}
+
+func TestFlow_ExecuteArchiveLimits(t *testing.T) {
+	bomb := strings.Repeat("0", 1<<20)
+	bodies := map[string][]byte{
+		"/zip":   testZip(map[string]string{"a.txt": "a", "bomb.txt": bomb}),
+		"/targz": testTarGz(map[string]string{"a.txt": "a", "bomb.txt": bomb}),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bodies[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	for path := range bodies {
+		f := Flow{MaxBodySize: 1 << 19, Steps: []Step{{
+			Name:    "export",
+			Request: Request{URL: srv.URL + path, Method: "GET"},
+			Archive: &Archive{Member: "bomb.txt"},
+		}}}
+		assert.EqualError(t, f.Execute(nil),
+			"Step 0.'export' failed because couldn't unpack archive: decompressed size exceeds 524288 bytes", path)
+
+		f.MaxBodySize = 0
+		f.MaxDecompressionRatio = 10
+		assert.EqualError(t, f.Execute(nil),
+			"Step 0.'export' failed because couldn't unpack archive: decompression ratio exceeds 10", path)
+
+		f.MaxDecompressionRatio = 0
+		assert.Nil(t, f.Execute(nil), path)
+	}
+}
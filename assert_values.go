@@ -0,0 +1,148 @@
+package httpsim
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ValueMatcher matches a value of the flow, see Flow.AssertValues
+type ValueMatcher interface {
+	// Match returns why the value doesn't match, "" when it does
+	Match(values map[string]interface{}, name string) string
+}
+
+// ValueMatcherFunc is an adapter to use an ordinary function as a ValueMatcher
+type ValueMatcherFunc func(values map[string]interface{}, name string) string
+
+// Match calls f(values, name)
+func (f ValueMatcherFunc) Match(values map[string]interface{}, name string) string {
+	return f(values, name)
+}
+
+// MatchRegexp matches the values matching the regexp
+func MatchRegexp(pattern string) ValueMatcher {
+	return ValueMatcherFunc(func(values map[string]interface{}, name string) string {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return "invalid regexp: " + err.Error()
+		}
+		v, ok := values[name]
+		if !ok {
+			return "missing"
+		}
+		if s := fmt.Sprint(v); !re.MatchString(s) {
+			return fmt.Sprintf("%q doesn't match %s", s, pattern)
+		}
+		return ""
+	})
+}
+
+// MatchNumber matches the numbers (see NumberValue) within tolerance of want
+func MatchNumber(want, tolerance float64) ValueMatcher {
+	return ValueMatcherFunc(func(values map[string]interface{}, name string) string {
+		n, err := NumberValue(values, name)
+		if err != nil {
+			return err.Error()
+		}
+		if math.Abs(n-want) > tolerance {
+			return fmt.Sprintf("%v isn't within %v of %v", n, tolerance, want)
+		}
+		return ""
+	})
+}
+
+// MatchNonEmpty matches the values present and not empty
+func MatchNonEmpty() ValueMatcher {
+	return ValueMatcherFunc(func(values map[string]interface{}, name string) string {
+		if v, ok := values[name]; !ok || v == nil || v == "" {
+			return "missing or empty"
+		}
+		return ""
+	})
+}
+
+// AssertOptions are the options of Flow.AssertValues
+type AssertOptions struct {
+	// Tolerance is the tolerance of the numbers expected as is, 0 for exact
+	Tolerance float64
+	// NoExtra fails on the values extracted by the steps that aren't expected
+	NoExtra bool
+}
+
+// ValueMismatch is a value not matching its expectation
+type ValueMismatch struct {
+	Name   string
+	Reason string
+}
+
+// ValuesError lists all the values not matching their expectations, see
+// Flow.AssertValues
+type ValuesError []ValueMismatch
+
+func (e ValuesError) Error() string {
+	msgs := make([]string, len(e))
+	for i, m := range e {
+		msgs[i] = fmt.Sprintf("value '%s' %s", m.Name, m.Reason)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// AssertValues checks the flow's Values against the expected ones at once, e.g.
+// in tests after Execute. The expected values are ValueMatchers, or compared as
+// is (numbers with the options' Tolerance). It returns a ValuesError listing
+// all the mismatches, nil when all match.
+func (f *Flow) AssertValues(expected map[string]interface{}, opts AssertOptions) error {
+	var errs ValuesError
+	for _, name := range sortedKeys(expected) {
+		if reason := matchValue(f.Values, name, expected[name], opts); reason != "" {
+			errs = append(errs, ValueMismatch{Name: name, Reason: reason})
+		}
+	}
+	if opts.NoExtra {
+		var extra []string
+		for name, p := range f.Provenance {
+			if _, ok := expected[name]; !ok && !p.Given {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		for _, name := range extra {
+			errs = append(errs, ValueMismatch{Name: name, Reason: fmt.Sprintf("%v isn't expected", f.Values[name])})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// matchValue returns why the value doesn't match the expected one, "" when it
+// does
+func matchValue(values map[string]interface{}, name string, want interface{}, opts AssertOptions) string {
+	if m, ok := want.(ValueMatcher); ok {
+		return m.Match(values, name)
+	}
+	got, ok := values[name]
+	if !ok {
+		return "missing"
+	}
+	switch want.(type) {
+	case float64, float32, int, int64:
+		n, err := NumberValue(values, name)
+		if err != nil {
+			return err.Error()
+		}
+		w := reflect.ValueOf(want).Convert(reflect.TypeOf(float64(0))).Float()
+		if math.Abs(n-w) > opts.Tolerance {
+			return fmt.Sprintf("is %v, expected %v", got, want)
+		}
+		return ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Sprintf("is %v, expected %v", got, want)
+	}
+	return ""
+}
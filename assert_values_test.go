@@ -0,0 +1,40 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_AssertValues(t *testing.T) {
+	f := Flow{
+		Values: map[string]interface{}{"user": "bob", "order": "A-123", "total": "10.004", "count": 3, "token": "", "extra": "x"},
+		Provenance: map[string]ValueProvenance{"user": {Given: true, Step: -1}, "order": {Step: 0}, "total": {Step: 1},
+			"count": {Step: 1}, "token": {Step: 1}, "extra": {Step: 2}},
+	}
+	assert.Nil(t, f.AssertValues(map[string]interface{}{
+		"user":  "bob",
+		"order": MatchRegexp(`^A-\d+$`),
+		"total": 10.0,
+		"count": 3,
+	}, AssertOptions{Tolerance: 0.01}))
+
+	err := f.AssertValues(map[string]interface{}{
+		"user":    "alice",
+		"order":   MatchRegexp(`^B-`),
+		"total":   MatchNumber(11, 0.5),
+		"count":   4,
+		"token":   MatchNonEmpty(),
+		"missing": "y",
+	}, AssertOptions{NoExtra: true})
+	assert.Equal(t, ValuesError{
+		{Name: "count", Reason: "is 3, expected 4"},
+		{Name: "missing", Reason: "missing"},
+		{Name: "order", Reason: `"A-123" doesn't match ^B-`},
+		{Name: "token", Reason: "missing or empty"},
+		{Name: "total", Reason: "10.004 isn't within 0.5 of 11"},
+		{Name: "user", Reason: "is bob, expected alice"},
+		{Name: "extra", Reason: "x isn't expected"},
+	}, err)
+	assert.Contains(t, err.Error(), "value 'count' is 3, expected 4\nvalue 'missing' missing\n")
+}
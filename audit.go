@@ -0,0 +1,139 @@
+package httpsim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redacted replaces the secrets in the audit log
+const redacted = "REDACTED"
+
+// AuditEntry is a request made by a flow, as written in the audit log
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Step     int       `json:"step"`
+	StepName string    `json:"step_name"`
+	Method   string    `json:"method"`
+	URL      string    `json:"url"`
+	// Status is 0 when the request failed
+	Status       int `json:"status"`
+	RequestBytes int `json:"request_bytes"`
+	// ResponseBytes is the length of the (decompressed) body, the Content-Length
+	// when streamed (-1 if unknown)
+	ResponseBytes int64 `json:"response_bytes"`
+	// BodySHA256 is the hash of the response body, empty when streamed
+	BodySHA256 string `json:"body_sha256,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// AuditLog is an append-only log, in JSON lines, of the requests made by flows.
+// The SensitiveValues of the flows are redacted. It's safe for concurrent use.
+type AuditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewAuditLog creates an audit log writing to w
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+// OpenAuditLog opens (or creates) the audit log file at path, for appending
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditLog(f), nil
+}
+
+// Close closes the underlying writer if it's an io.Closer
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Write appends the entry to the log
+func (l *AuditLog) Write(entry AuditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
+// audit writes the step's request to the flow's audit log, if any
+func (f *Flow) audit(i int, step *Step, resp *http.Response, body []byte, reqErr error) error {
+	if f.Audit == nil {
+		return nil
+	}
+	entry := AuditEntry{
+		Time:          time.Now().UTC(),
+		Step:          i,
+		StepName:      step.Name,
+		Method:        step.Request.Method,
+		URL:           f.redact(step.Request.URL),
+		RequestBytes:  bodyLen(step.Request.Body),
+		ResponseBytes: int64(len(body)),
+	}
+	if resp != nil {
+		entry.Status = resp.StatusCode
+	}
+	if step.StreamBody && resp != nil {
+		entry.ResponseBytes = resp.ContentLength
+	} else if body != nil {
+		sum := sha256.Sum256(body)
+		entry.BodySHA256 = hex.EncodeToString(sum[:])
+	}
+	if reqErr != nil {
+		entry.Error = f.redact(reqErr.Error())
+	}
+	if err := f.Audit.Write(entry); err != nil {
+		return fmt.Errorf("Step %d.'%s' couldn't write audit log: %s", i, step.Name, err.Error())
+	}
+	return nil
+}
+
+// redact replaces the SensitiveValues, raw or URL encoded, in s and scrubs it
+func (f *Flow) redact(s string) string {
+	s = f.Scrubber.ScrubString(s)
+	for _, k := range f.SensitiveValues {
+		v, ok := f.Values[k].(string)
+		if !ok || v == "" {
+			continue
+		}
+		for _, secret := range []string{v, url.QueryEscape(v), url.PathEscape(v)} {
+			s = strings.Replace(s, secret, redacted, -1)
+		}
+	}
+	return s
+}
+
+// bodyLen returns the length of the request body as sent
+func bodyLen(v interface{}) int {
+	switch t := v.(type) {
+	case []byte:
+		return len(t)
+	case string:
+		return len(t)
+	case url.Values:
+		return len(t.Encode())
+	}
+	return 0
+}
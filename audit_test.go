@@ -0,0 +1,97 @@
+package httpsim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteAudit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("welcome"))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	f := Flow{
+		RequiredValues:  []string{"user", "password"},
+		SensitiveValues: []string{"password"},
+		Audit:           NewAuditLog(&buf),
+		Steps: []Step{{
+			Name:      "login",
+			Request:   Request{URL: srv.URL + "/login?u={{.user}}&p={{.password}}", Method: "POST", Body: []byte("p={{.password}}")},
+			KeysInput: []string{"user", "password", "password"},
+		}},
+	}
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "bob", "password": "s3cr&t"}))
+
+	var entry AuditEntry
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, 0, entry.Step)
+	assert.Equal(t, "login", entry.StepName)
+	assert.Equal(t, "POST", entry.Method)
+	assert.Equal(t, srv.URL+"/login?u=bob&p="+redacted, entry.URL)
+	assert.Equal(t, 200, entry.Status)
+	assert.Equal(t, len("p=s3cr&t"), entry.RequestBytes)
+	assert.EqualValues(t, len("welcome"), entry.ResponseBytes)
+	assert.Len(t, entry.BodySHA256, 64)
+	assert.False(t, entry.Time.IsZero())
+	assert.NotContains(t, buf.String(), "s3cr")
+}
+
+func TestFlow_ExecuteAuditRequestError(t *testing.T) {
+	var buf bytes.Buffer
+	f := Flow{
+		RequiredValues:  []string{"token"},
+		SensitiveValues: []string{"token"},
+		Audit:           NewAuditLog(&buf),
+		Steps: []Step{{
+			Name:      "down",
+			Request:   Request{URL: "http://127.0.0.1:1/{{.token}}", Method: "GET"},
+			KeysInput: []string{"token"},
+		}},
+	}
+	assert.NotNil(t, f.Execute(map[string]interface{}{"token": "abc def"}))
+
+	var entry AuditEntry
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, 0, entry.Status)
+	assert.NotEmpty(t, entry.Error)
+	assert.Equal(t, "", entry.BodySHA256)
+	assert.NotContains(t, buf.String(), "abc")
+	assert.NotContains(t, buf.String(), url.PathEscape("abc def"))
+}
+
+func TestOpenAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	// appends across opens
+	for i := 0; i < 2; i++ {
+		l, err := OpenAuditLog(path)
+		assert.Nil(t, err)
+		assert.Nil(t, l.Write(AuditEntry{Step: i, Method: "GET"}))
+		assert.Nil(t, l.Close())
+	}
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(string(b)))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"step":1`)
+}
@@ -0,0 +1,50 @@
+package httpsim
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// BinaryExtracter is an Extracter that also knows how to extract values out of
+// binary responses (images, PDFs, archives...). Binary responses are only given
+// to BinaryExtracters, feeding them to string extracters is an error.
+type BinaryExtracter interface {
+	Extracter
+	ExtractBinary(body []byte, contentType string, values map[string]interface{}) (name, value string, err error)
+}
+
+// sniffContentType returns the media type of the response, sniffing the body when
+// the server didn't say (or only said application/octet-stream)
+func sniffContentType(header http.Header, body []byte) string {
+	ct := header.Get("Content-Type")
+	if ct == "" || strings.HasPrefix(ct, "application/octet-stream") {
+		if len(body) == 0 {
+			return "text/plain"
+		}
+		ct = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
+	}
+	return mediaType
+}
+
+// isBinary returns whether the media type isn't some kind of text
+func isBinary(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.Contains(mediaType, "javascript"),
+		strings.Contains(mediaType, "ecmascript"):
+		return false
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded",
+		"application/graphql", "application/x-ndjson", "application/csv":
+		return false
+	}
+	return true
+}
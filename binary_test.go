@@ -0,0 +1,53 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniffContentType(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "text/html; charset=UTF-8")
+	assert.Equal(t, "text/html", sniffContentType(h, nil))
+	assert.False(t, isBinary(sniffContentType(h, nil)))
+
+	h.Set("Content-Type", "application/octet-stream")
+	assert.Equal(t, "application/pdf", sniffContentType(h, []byte("%PDF-1.4\n...")))
+	assert.True(t, isBinary("application/pdf"))
+
+	assert.Equal(t, "image/png", sniffContentType(http.Header{}, []byte("\x89PNG\x0D\x0A\x1A\x0A....")))
+	assert.False(t, isBinary(sniffContentType(http.Header{}, []byte(`{"a": 1}`))))
+	assert.False(t, isBinary("application/vnd.api+json"))
+}
+
+type lengthExtracter struct{}
+
+func (lengthExtracter) Extract(body string, v map[string]interface{}) (string, string, error) {
+	return "kind", "text", nil
+}
+
+func (lengthExtracter) ExtractBinary(body []byte, ct string, v map[string]interface{}) (string, string, error) {
+	return "kind", ct, nil
+}
+
+func TestFlow_ExecuteBinary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG\x0D\x0A\x1A\x0A...."))
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:       "logo",
+		Request:    Request{URL: srv.URL, Method: "GET"},
+		KeysOutput: []Extracter{lengthExtracter{}},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "image/png", f.Values["kind"])
+
+	f.Steps[0].KeysOutput = []Extracter{Extractable{AfterThis: "P", BeforeThis: "G", Name: "x"}}
+	err := f.Execute(nil)
+	assert.EqualError(t, err, "Step 0.'logo' failed because response is binary (image/png) and httpsim.Extractable isn't a BinaryExtracter")
+}
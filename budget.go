@@ -0,0 +1,190 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Budget are the hard caps of an execution, e.g. against a third party: the
+// execution is aborted with a *BudgetError once one is exceeded. All the
+// requests count, including redirects, preflights and resumed downloads.
+type Budget struct {
+	// MaxRequests is the max number of requests sent, no limit when 0
+	MaxRequests int
+	// MaxBytes is the max number of bytes of response bodies received (before
+	// decompression), no limit when 0
+	MaxBytes int64
+	// MaxDuration is the max wall-clock time of the execution, no limit when 0
+	MaxDuration time.Duration
+}
+
+// The budgets of a BudgetError
+const (
+	BudgetRequests = "requests"
+	BudgetBytes    = "bytes"
+	BudgetDuration = "duration"
+)
+
+// BudgetError is the error returned when an execution exceeds its Budget
+type BudgetError struct {
+	Step     int
+	StepName string
+	// Budget is the budget exceeded, see BudgetRequests...
+	Budget string
+	// Limit is the budget's limit, in nanoseconds for BudgetDuration
+	Limit int64
+}
+
+func (e *BudgetError) Error() string {
+	limit := fmt.Sprintf("%d %s", e.Limit, e.Budget)
+	if e.Budget == BudgetDuration {
+		limit = time.Duration(e.Limit).String()
+	}
+	return fmt.Sprintf("Step %d.'%s' failed because the run exceeded its budget of %s", e.Step, e.StepName, limit)
+}
+
+// budgetState is what an execution spent of its Budget
+type budgetState struct {
+	Budget
+	run      *runState
+	deadline time.Time
+
+	mu       sync.Mutex
+	requests int
+	bytes    int64
+}
+
+// newBudget starts spending the budget, nil when there's none
+func newBudget(b *Budget, run *runState) *budgetState {
+	if b == nil {
+		return nil
+	}
+	state := &budgetState{Budget: *b, run: run}
+	if b.MaxDuration > 0 {
+		state.deadline = time.Now().Add(b.MaxDuration)
+	}
+	return state
+}
+
+// exceeded returns the error of the budget exceeded by the current step
+func (b *budgetState) exceeded(budget string, limit int64) *BudgetError {
+	err := &BudgetError{Step: b.run.index, Budget: budget, Limit: limit}
+	if b.run.current != nil {
+		err.StepName = b.run.current.Name
+	}
+	return err
+}
+
+// overtime returns the duration budget's error once it's exceeded, else nil
+func (b *budgetState) overtime() error {
+	if b.MaxDuration > 0 && !time.Now().Before(b.deadline) {
+		return b.exceeded(BudgetDuration, int64(b.MaxDuration))
+	}
+	return nil
+}
+
+// spendRequest counts a request, failing once the budget is exceeded
+func (b *budgetState) spendRequest() error {
+	if err := b.overtime(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.MaxRequests > 0 && b.requests >= b.MaxRequests {
+		return b.exceeded(BudgetRequests, int64(b.MaxRequests))
+	}
+	b.requests++
+	return nil
+}
+
+// spendBytes counts the bytes received, failing once the budget is exceeded
+func (b *budgetState) spendBytes(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes += int64(n)
+	if b.MaxBytes > 0 && b.bytes > b.MaxBytes {
+		return b.exceeded(BudgetBytes, b.MaxBytes)
+	}
+	return nil
+}
+
+// wrap makes the client's requests spend the budget
+func (b *budgetState) wrap(client *http.Client) {
+	if b == nil {
+		return
+	}
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	client.Transport = &budgetTransport{rt: rt, b: b}
+}
+
+// budgetTransport refuses the requests past the budget, and stops the ones in
+// flight when the execution runs out of time
+type budgetTransport struct {
+	rt http.RoundTripper
+	b  *budgetState
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.b.spendRequest(); err != nil {
+		return nil, err
+	}
+	cancel := context.CancelFunc(func() {})
+	if t.b.MaxDuration > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithDeadline(req.Context(), t.b.deadline)
+		req = req.WithContext(ctx)
+	}
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		cancel()
+		if overtime := t.b.overtime(); overtime != nil {
+			return nil, overtime
+		}
+		return nil, err
+	}
+	resp.Body = &budgetBody{ReadCloser: resp.Body, b: t.b, cancel: cancel}
+	return resp, nil
+}
+
+// budgetBody counts the bytes of a response body
+type budgetBody struct {
+	io.ReadCloser
+	b      *budgetState
+	cancel context.CancelFunc
+}
+
+func (r *budgetBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if berr := r.b.spendBytes(n); berr != nil {
+		return 0, berr
+	}
+	if err != nil && err != io.EOF {
+		if overtime := r.b.overtime(); overtime != nil {
+			return n, overtime
+		}
+	}
+	return n, err
+}
+
+func (r *budgetBody) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// budgetError returns the *BudgetError the step's error wraps, if any, so the
+// execution returns it as is
+func budgetError(err error) error {
+	var berr *BudgetError
+	if errors.As(err, &berr) {
+		return berr
+	}
+	return err
+}
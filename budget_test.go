@@ -0,0 +1,48 @@
+package httpsim
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Budget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+	get := func(name string) Step {
+		return Step{Name: name, Request: Request{URL: srv.URL + "/" + name, Method: "GET"}}
+	}
+	f := Flow{Steps: []Step{get("a"), get("b"), get("c")}, Budget: &Budget{MaxRequests: 3, MaxBytes: 300}}
+	assert.Nil(t, f.Execute(nil))
+
+	f.Budget = &Budget{MaxRequests: 2}
+	err := f.Execute(nil)
+	assert.Equal(t, &BudgetError{Step: 2, StepName: "c", Budget: BudgetRequests, Limit: 2}, err)
+	assert.EqualError(t, err, "Step 2.'c' failed because the run exceeded its budget of 2 requests")
+	assert.Equal(t, PhaseRequest, f.Failure().Phase)
+
+	f.Budget = &Budget{MaxBytes: 150}
+	err = f.Execute(nil)
+	assert.Equal(t, &BudgetError{Step: 1, StepName: "b", Budget: BudgetBytes, Limit: 150}, err)
+
+	f.Steps[1] = get("slow")
+	f.Budget = &Budget{MaxDuration: 50 * time.Millisecond}
+	started := time.Now()
+	err = f.Execute(nil)
+	var berr *BudgetError
+	assert.True(t, errors.As(err, &berr))
+	assert.Equal(t, 1, berr.Step)
+	assert.Equal(t, BudgetDuration, berr.Budget)
+	assert.EqualError(t, err, "Step 1.'slow' failed because the run exceeded its budget of 50ms")
+	assert.Less(t, time.Since(started), 200*time.Millisecond)
+}
@@ -0,0 +1,52 @@
+package httpsim
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+const (
+	// maxPresize bounds the body pre-sized from the Content-Length, which may lie
+	maxPresize = 8 << 20
+	// maxPooledBuffer is the size of the largest buffers put back in the pool, so
+	// a few huge bodies don't stay in memory
+	maxPooledBuffer = 4 << 20
+)
+
+// bodyBuffers are the buffers the bodies of unknown size are read into, reused
+// across steps and runs to reduce allocations under load (see Runner)
+var bodyBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// readBody reads the whole body. With the Content-Length (-1 when unknown), it's
+// read in a body allocated once, otherwise in a pooled buffer then copied to a
+// body of the exact size.
+func readBody(r io.Reader, contentLength int64) ([]byte, error) {
+	if contentLength >= 0 && contentLength <= maxPresize {
+		// one more byte so reading io.EOF doesn't grow the body
+		body := make([]byte, 0, contentLength+1)
+		for {
+			if len(body) == cap(body) {
+				body = append(body, 0)[:len(body)]
+			}
+			n, err := r.Read(body[len(body):cap(body)])
+			body = body[:len(body)+n]
+			if err == io.EOF {
+				return body, nil
+			}
+			if err != nil {
+				return body, err
+			}
+		}
+	}
+
+	buf := bodyBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			bodyBuffers.Put(buf)
+		}
+	}()
+	_, err := buf.ReadFrom(r)
+	return append([]byte(nil), buf.Bytes()...), err
+}
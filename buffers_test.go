@@ -0,0 +1,59 @@
+package httpsim
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBody(t *testing.T) {
+	body := strings.Repeat("abcdefgh", 1000)
+	for _, size := range []int64{-1, 0, 10, int64(len(body)), int64(len(body)) + 100, maxPresize + 1} {
+		b, err := readBody(iotest.HalfReader(strings.NewReader(body)), size)
+		assert.Nil(t, err)
+		assert.Equal(t, body, string(b))
+	}
+
+	b, err := readBody(strings.NewReader(""), -1)
+	assert.Nil(t, err)
+	assert.Empty(t, b)
+
+	// the pooled buffer isn't shared with the body returned
+	b1, _ := readBody(strings.NewReader("first"), -1)
+	b2, _ := readBody(strings.NewReader("second"), -1)
+	assert.Equal(t, "first", string(b1))
+	assert.Equal(t, "second", string(b2))
+
+	fail := errors.New("reset")
+	_, err = readBody(io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(fail)), 3)
+	assert.Equal(t, fail, err)
+	_, err = readBody(io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(fail)), -1)
+	assert.Equal(t, fail, err)
+}
+
+func BenchmarkReadBody(b *testing.B) {
+	body := bytes.Repeat([]byte("<p>hello</p>"), 4096)
+	b.Run("ContentLength", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			readBody(bytes.NewReader(body), int64(len(body)))
+		}
+	})
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			readBody(bytes.NewReader(body), -1)
+		}
+	})
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			io.ReadAll(bytes.NewReader(body))
+		}
+	})
+}
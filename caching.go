@@ -0,0 +1,110 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// cached is what's known of an earlier response to a GET of the same URL
+type cached struct {
+	step         int
+	name         string
+	etag         string
+	lastModified string
+	bodyHash     string
+}
+
+// cacheDirectives parses the Cache-Control header into its lowercase directives
+func cacheDirectives(h http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, v := range h["Cache-Control"] {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			k, val := d, ""
+			if eq := strings.IndexByte(d, '='); eq != -1 {
+				k, val = d[:eq], strings.Trim(d[eq+1:], `"`)
+			}
+			directives[strings.ToLower(k)] = val
+		}
+	}
+	return directives
+}
+
+// checkCaching adds warnings about the caching misconfigurations of the response:
+// incoherent Cache-Control, Vary, and validators (ETag, Last-Modified) across
+// repeated GETs of the same URL
+func (f *Flow) checkCaching(run *runState, i int, step *Step, reqHeader http.Header, resp *http.Response,
+	body []byte) {
+	if !f.CheckCaching {
+		return
+	}
+	warn := func(msg string) {
+		f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name, Message: "caching: " + msg})
+	}
+
+	cc := cacheDirectives(resp.Header)
+	_, noStore := cc["no-store"]
+	_, noCache := cc["no-cache"]
+	_, public := cc["public"]
+	_, private := cc["private"]
+	if maxAge, ok := cc["max-age"]; ok && maxAge != "0" && (noStore || noCache) {
+		warn("Cache-Control has max-age=" + maxAge + " with no-store or no-cache")
+	}
+	if public && private {
+		warn("Cache-Control is both public and private")
+	}
+	if public && len(resp.Header["Set-Cookie"]) != 0 {
+		warn("Cache-Control public response sets cookies, shared caches may serve them to others")
+	}
+	if strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		warn("Vary: * makes the response uncacheable")
+	}
+	etag := resp.Header.Get("ETag")
+	if etag != "" && noStore {
+		warn("ETag is useless with Cache-Control no-store")
+	}
+
+	if step.Request.Method != http.MethodGet || resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotModified {
+		return
+	}
+	key := resp.Request.URL.String()
+	prev, repeated := run.cached[key]
+	if resp.StatusCode == http.StatusOK && !step.StreamBody {
+		if run.cached == nil {
+			run.cached = map[string]cached{}
+		}
+		run.cached[key] = cached{step: i, name: step.Name, etag: etag,
+			lastModified: resp.Header.Get("Last-Modified"), bodyHash: BodySHA256(body, false)}
+	}
+	if !repeated {
+		return
+	}
+	since := func(format string, a ...interface{}) {
+		warn(fmt.Sprintf(format, a...) + fmt.Sprintf(" (previous GET at step %d.'%s')", prev.step, prev.name))
+	}
+
+	if inm := reqHeader.Get("If-None-Match"); inm != "" && inm == prev.etag && etag == prev.etag &&
+		resp.StatusCode == http.StatusOK {
+		since("conditional GET with the current ETag got 200 instead of 304")
+	}
+	if ims := reqHeader.Get("If-Modified-Since"); ims != "" && ims == prev.lastModified &&
+		resp.Header.Get("Last-Modified") == prev.lastModified && resp.StatusCode == http.StatusOK {
+		since("conditional GET with the current Last-Modified got 200 instead of 304")
+	}
+	if resp.StatusCode != http.StatusOK || step.StreamBody {
+		return
+	}
+	hash := BodySHA256(body, false)
+	switch {
+	case etag != "" && etag == prev.etag && hash != prev.bodyHash:
+		since("ETag %s unchanged but the body changed", etag)
+	case etag != "" && prev.etag != "" && etag != prev.etag && hash == prev.bodyHash:
+		since("ETag changed for an identical body")
+	case prev.etag != "" && etag == "":
+		since("ETag disappeared")
+	}
+}
@@ -0,0 +1,81 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteCheckCaching(t *testing.T) {
+	hits := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path]++
+		n := hits[r.URL.Path]
+		switch r.URL.Path {
+		case "/good":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "max-age=60")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("same"))
+		case "/ignores":
+			// never answers 304
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("same"))
+		case "/stale":
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprintf(w, "content %d", n)
+		case "/random":
+			w.Header().Set("ETag", fmt.Sprintf(`"%d"`, n))
+			w.Write([]byte("same"))
+		case "/headers":
+			w.Header().Set("Cache-Control", "public, private, no-store, max-age=3600")
+			w.Header().Set("ETag", `"x"`)
+			w.Header().Set("Vary", "*")
+			http.SetCookie(w, &http.Cookie{Name: "s", Value: "1"})
+		}
+	}))
+	defer srv.Close()
+
+	get := func(name, path string, header http.Header) Step {
+		return Step{Name: name, Request: Request{URL: srv.URL + path, Method: "GET", Header: header}, ExpectStatus: []string{"200", "304"}}
+	}
+	conditional := http.Header{"If-None-Match": {`"v1"`}}
+	f := Flow{
+		CheckCaching: true,
+		Steps: []Step{
+			get("good", "/good", nil), get("good again", "/good", conditional),
+			get("ignores", "/ignores", nil), get("ignores again", "/ignores", conditional),
+			get("stale", "/stale", nil), get("stale again", "/stale", nil),
+			get("random", "/random", nil), get("random again", "/random", nil),
+			get("headers", "/headers", nil),
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	var got []string
+	for _, w := range f.Warnings {
+		got = append(got, w.String())
+	}
+	assert.Equal(t, []string{
+		`Step 3.'ignores again' caching: conditional GET with the current ETag got 200 instead of 304 (previous GET at step 2.'ignores')`,
+		`Step 5.'stale again' caching: ETag "v1" unchanged but the body changed (previous GET at step 4.'stale')`,
+		`Step 7.'random again' caching: ETag changed for an identical body (previous GET at step 6.'random')`,
+		`Step 8.'headers' caching: Cache-Control has max-age=3600 with no-store or no-cache`,
+		`Step 8.'headers' caching: Cache-Control is both public and private`,
+		`Step 8.'headers' caching: Cache-Control public response sets cookies, shared caches may serve them to others`,
+		`Step 8.'headers' caching: Vary: * makes the response uncacheable`,
+		`Step 8.'headers' caching: ETag is useless with Cache-Control no-store`,
+	}, got, strings.Join(got, "\n"))
+	assert.Equal(t, http.StatusNotModified, f.Steps[1].Response.Raw.StatusCode)
+
+	f.CheckCaching = false
+	hits = map[string]int{}
+	assert.Nil(t, f.Execute(nil))
+	assert.Len(t, f.Warnings, 0)
+}
@@ -0,0 +1,133 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Chaos degrades the live executions on purpose, to check that the monitoring
+// and alerting built on the flows react sanely. What it did is added to the
+// Warnings, prefixed with "chaos:". It may be shared by concurrent executions.
+type Chaos struct {
+	// DropRate is the share (0 to 1) of the Optional steps not executed
+	DropRate float64
+	// Delay is waited before each step, plus a random duration up to Jitter
+	Delay  time.Duration
+	Jitter time.Duration
+	// Shuffle executes the consecutive Unordered steps in a random order
+	Shuffle bool
+	// Rand decides, seeded with the time when nil
+	Rand *rand.Rand
+
+	mu sync.Mutex
+}
+
+// random calls fn with the chaos' Rand, locked
+func (c *Chaos) random(fn func(r *rand.Rand)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Rand == nil {
+		c.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	fn(c.Rand)
+}
+
+// chaosOrder returns the indexes of the flow's steps in the order they're executed,
+// without the dropped ones
+func (f *Flow) chaosOrder() []int {
+	order := make([]int, 0, len(f.Steps))
+	for i := range f.Steps {
+		order = append(order, i)
+	}
+	c := f.Chaos
+	if c == nil {
+		return order
+	}
+	c.random(func(r *rand.Rand) {
+		if c.Shuffle {
+			for start := 0; start < len(order); start++ {
+				end := start
+				for end < len(order) && f.Steps[end].Unordered {
+					end++
+				}
+				group := order[start:end]
+				r.Shuffle(len(group), func(a, b int) { group[a], group[b] = group[b], group[a] })
+				start = end
+			}
+		}
+		kept := order[:0]
+		for _, i := range order {
+			if f.Steps[i].Optional && r.Float64() < c.DropRate {
+				f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: f.Steps[i].Name, Message: "chaos: dropped the step"})
+				continue
+			}
+			kept = append(kept, i)
+		}
+		order = kept
+	})
+	for n, i := range order {
+		if n != 0 && i < order[n-1] {
+			f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: f.Steps[i].Name,
+				Message: fmt.Sprintf("chaos: executed after step %d.'%s'", order[n-1], f.Steps[order[n-1]].Name)})
+		}
+	}
+	return order
+}
+
+// chaosDelay waits for the chaos' delay before a step, or for ctx to be done
+func (f *Flow) chaosDelay(ctx context.Context) {
+	c := f.Chaos
+	if c == nil || c.Delay+c.Jitter <= 0 {
+		return
+	}
+	d := c.Delay
+	if c.Jitter > 0 {
+		c.random(func(r *rand.Rand) {
+			d += time.Duration(r.Int63n(int64(c.Jitter)))
+		})
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// lintChaos warns about the Optional steps whose outputs are used by other
+// steps, and the Unordered steps using the outputs of their group's
+func (f *Flow) lintChaos() []LintWarning {
+	var warnings []LintWarning
+	outputs := map[string]int{}
+	group := -1
+	for i, step := range f.Steps {
+		if !step.Unordered {
+			group = -1
+		} else if group == -1 {
+			group = i
+		}
+		for _, k := range step.KeysInput {
+			j, ok := outputs[k]
+			if !ok {
+				continue
+			}
+			if f.Steps[j].Optional {
+				warnings = append(warnings, LintWarning{Step: i, StepName: step.Name, Message: fmt.Sprintf(
+					"uses '%s' output by the optional step %d.'%s'", k, j, f.Steps[j].Name)})
+			}
+			if group != -1 && j >= group {
+				warnings = append(warnings, LintWarning{Step: i, StepName: step.Name, Message: fmt.Sprintf(
+					"is unordered but uses '%s' output by step %d.'%s' of its group", k, j, f.Steps[j].Name)})
+			}
+		}
+		for _, e := range step.KeysOutput {
+			if k := extracterName(e); k != "" {
+				outputs[k] = i
+			}
+		}
+	}
+	return warnings
+}
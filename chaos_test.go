@@ -0,0 +1,81 @@
+package httpsim
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaos(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	get := func(name string) Step {
+		return Step{Name: name, Request: Request{URL: server.URL + "/" + name, Method: "GET"}}
+	}
+	f := Flow{Steps: []Step{get("login"), get("a"), get("b"), get("c"), get("beacon"), get("logout")}}
+	for i := 1; i <= 3; i++ {
+		f.Steps[i].Unordered = true
+	}
+	f.Steps[4].Optional = true
+
+	f.Chaos = &Chaos{Rand: rand.New(rand.NewSource(1))}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{"/login", "/a", "/b", "/c", "/beacon", "/logout"}, paths)
+	assert.Empty(t, f.Warnings)
+
+	f.Chaos = &Chaos{DropRate: 1, Shuffle: true, Rand: rand.New(rand.NewSource(1))}
+	shuffled := false
+	for n := 0; n < 10 && !shuffled; n++ {
+		paths = nil
+		assert.Nil(t, f.Execute(nil))
+		assert.Len(t, paths, 5)
+		assert.Equal(t, "/login", paths[0])
+		assert.ElementsMatch(t, []string{"/a", "/b", "/c"}, paths[1:4])
+		assert.Equal(t, "/logout", paths[4])
+		assert.Equal(t, LintWarning{Step: 4, StepName: "beacon", Message: "chaos: dropped the step"}, f.Warnings[0])
+		shuffled = strings.Join(paths[1:4], "") != "/a/b/c"
+		if shuffled {
+			assert.Contains(t, f.Warnings[1].Message, "chaos: executed after step")
+		}
+	}
+	assert.True(t, shuffled)
+
+	f.Chaos = &Chaos{Delay: 20 * time.Millisecond, Jitter: 10 * time.Millisecond}
+	started := time.Now()
+	assert.Nil(t, f.Execute(nil))
+	assert.GreaterOrEqual(t, time.Since(started), 120*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	f.Chaos = &Chaos{Delay: time.Hour}
+	err := f.ExecuteContext(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLintChaos(t *testing.T) {
+	id := Extractable{Name: "id", AfterThis: "<i>", BeforeThis: "</i>", MaxLength: -1, MinLength: -1}
+	token := Extractable{Name: "token", AfterThis: "<t>", BeforeThis: "</t>", MaxLength: -1, MinLength: -1}
+	f := Flow{Steps: []Step{
+		{Name: "probe", Optional: true, KeysOutput: []Extracter{token}},
+		{Name: "list", Unordered: true, KeysOutput: []Extracter{id}},
+		{Name: "item", Unordered: true, KeysInput: []string{"id", "token"}},
+		{Name: "next", KeysInput: []string{"id"}},
+	}}
+	assert.Equal(t, []LintWarning{
+		{Step: 2, StepName: "item", Message: "is unordered but uses 'id' output by step 1.'list' of its group"},
+		{Step: 2, StepName: "item", Message: "uses 'token' output by the optional step 0.'probe'"},
+	}, f.lintChaos())
+}
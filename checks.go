@@ -0,0 +1,148 @@
+package httpsim
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusError is the error returned when a step's response status isn't expected
+type StatusError struct {
+	Step       int
+	Name       string
+	StatusCode int
+	Expected   []string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because of unexpected status %d (expected %s)",
+		e.Step, e.Name, e.StatusCode, strings.Join(e.Expected, ", "))
+}
+
+// expectedStatus returns the statuses considered a success for the step
+func (s *Step) expectedStatus() []string {
+	if len(s.ExpectStatus) != 0 {
+		return s.ExpectStatus
+	}
+	if s.Request.IgnoreRedirects {
+		return []string{"2xx", "3xx"}
+	}
+	return []string{"2xx"}
+}
+
+// statusMatches returns whether the status code matches the code or class ("2xx")
+func statusMatches(expected string, code int) (bool, error) {
+	if len(expected) == 3 && strings.HasSuffix(strings.ToLower(expected), "xx") {
+		class, err := strconv.Atoi(expected[:1])
+		if err != nil {
+			return false, fmt.Errorf("invalid status class '%s'", expected)
+		}
+		return code/100 == class, nil
+	}
+	exp, err := strconv.Atoi(expected)
+	if err != nil {
+		return false, fmt.Errorf("invalid status '%s'", expected)
+	}
+	return code == exp, nil
+}
+
+// CheckStatus returns a *StatusError if the status code isn't one of the expected ones
+func (s *Step) CheckStatus(statusCode, stepNb int) error {
+	if s.AnyStatus {
+		return nil
+	}
+	expected := s.expectedStatus()
+	for _, e := range expected {
+		ok, err := statusMatches(e, statusCode)
+		if err != nil {
+			return fmt.Errorf("Step %d.'%s' %s", stepNb, s.Name, err.Error())
+		}
+		if ok {
+			return nil
+		}
+	}
+	return &StatusError{Step: stepNb, Name: s.Name, StatusCode: statusCode, Expected: expected}
+}
+
+// excerptRadius is the number of bytes kept around a match in error excerpts
+const excerptRadius = 40
+
+// excerpt returns the body around [start:end] on a single line
+func excerpt(body []byte, start, end int) string {
+	from, to := start-excerptRadius, end+excerptRadius
+	if from < 0 {
+		from = 0
+	}
+	if to > len(body) {
+		to = len(body)
+	}
+	ex := strings.Join(strings.Fields(string(body[from:to])), " ")
+	if from > 0 {
+		ex = "..." + ex
+	}
+	if to < len(body) {
+		ex += "..."
+	}
+	return ex
+}
+
+// CheckForbidden returns an error if the body contains one of the Forbid strings
+// or matches one of the ForbidRegexp
+func (s *Step) CheckForbidden(body []byte, stepNb int) error {
+	for _, f := range s.Forbid {
+		if i := strings.Index(string(body), f); i != -1 {
+			return fmt.Errorf("Step %d.'%s' failed because response contains forbidden '%s': %s",
+				stepNb, s.Name, f, excerpt(body, i, i+len(f)))
+		}
+	}
+	for _, f := range s.ForbidRegexp {
+		re, err := compileRegexp(f)
+		if err != nil {
+			return fmt.Errorf("Step %d.'%s' has an invalid forbid regexp: %s", stepNb, s.Name, err.Error())
+		}
+		if loc := re.FindIndex(body); loc != nil {
+			return fmt.Errorf("Step %d.'%s' failed because response matches forbidden '%s': %s",
+				stepNb, s.Name, f, excerpt(body, loc[0], loc[1]))
+		}
+	}
+	return nil
+}
+
+// BodyHashError is the error returned when a step's body doesn't have the expected hash
+type BodyHashError struct {
+	Step     int
+	Name     string
+	Expected string
+	Got      string
+}
+
+func (e *BodyHashError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because body SHA-256 is %s (expected %s)",
+		e.Step, e.Name, e.Got, e.Expected)
+}
+
+// BodySHA256 returns the hex SHA-256 of the body, with its whitespace normalized
+// if normalize is set: runs are replaced by a single space, leading and trailing
+// ones removed
+func BodySHA256(body []byte, normalize bool) string {
+	if normalize {
+		body = bytes.Join(bytes.Fields(body), []byte(" "))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckBodyHash returns a *BodyHashError if the body doesn't have the expected hash
+func (s *Step) CheckBodyHash(body []byte, stepNb int) error {
+	if s.ExpectBodySHA256 == "" || s.StreamBody || s.noResponseBody() {
+		return nil
+	}
+	got := BodySHA256(body, s.NormalizeBodyHash)
+	if !strings.EqualFold(got, s.ExpectBodySHA256) {
+		return &BodyHashError{Step: stepNb, Name: s.Name, Expected: s.ExpectBodySHA256, Got: got}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep_CheckForbidden(t *testing.T) {
+	s := Step{
+		Name:         "login",
+		Forbid:       []string{"incorrect password"},
+		ForbidRegexp: []string{`Exception in thread "\w+"`},
+	}
+	assert.Nil(t, s.CheckForbidden([]byte("<h1>Welcome back</h1>"), 1))
+
+	err := s.CheckForbidden([]byte("<div class=\"error\">\n  Sorry, incorrect password\n</div>"), 1)
+	assert.EqualError(t, err, "Step 1.'login' failed because response contains forbidden 'incorrect password': "+
+		"<div class=\"error\"> Sorry, incorrect password </div>")
+
+	err = s.CheckForbidden([]byte(`Exception in thread "main" java.lang.NullPointerException`), 1)
+	assert.EqualError(t, err, `Step 1.'login' failed because response matches forbidden 'Exception in thread "\w+"': `+
+		`Exception in thread "main" java.lang.NullPointerException`)
+}
+
+func TestFlow_ExecuteForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>Your session has expired</p>")
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:    "home",
+		Request: Request{URL: srv.URL, Method: "GET"},
+		Forbid:  []string{"session has expired"},
+	}}}
+	err := f.Execute(nil)
+	assert.EqualError(t, err, "Step 0.'home' failed because response contains forbidden 'session has expired': "+
+		"<p>Your session has expired</p>")
+	assert.NotNil(t, f.Steps[0].Response)
+}
+
+func TestStep_CheckStatus(t *testing.T) {
+	s := Step{Name: "home"}
+	assert.Nil(t, s.CheckStatus(200, 0))
+	assert.Nil(t, s.CheckStatus(204, 0))
+	assert.EqualError(t, s.CheckStatus(302, 0), "Step 0.'home' failed because of unexpected status 302 (expected 2xx)")
+	assert.IsType(t, &StatusError{}, s.CheckStatus(500, 0))
+
+	s.Request.IgnoreRedirects = true
+	assert.Nil(t, s.CheckStatus(302, 0))
+
+	s.ExpectStatus = []string{"401", "403"}
+	assert.Nil(t, s.CheckStatus(403, 0))
+	assert.EqualError(t, s.CheckStatus(200, 0), "Step 0.'home' failed because of unexpected status 200 (expected 401, 403)")
+
+	s.ExpectStatus = []string{"2x"}
+	assert.EqualError(t, s.CheckStatus(200, 0), "Step 0.'home' invalid status '2x'")
+
+	s.AnyStatus = true
+	assert.Nil(t, s.CheckStatus(500, 0))
+}
+
+func TestFlow_ExecuteStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}}}}
+	err := f.Execute(nil)
+	assert.IsType(t, &StatusError{}, err)
+	assert.Equal(t, 500, err.(*StatusError).StatusCode)
+
+	f.Steps[0].AnyStatus = true
+	assert.Nil(t, f.Execute(nil))
+}
+
+func TestStep_CheckBodyHash(t *testing.T) {
+	body := []byte("var a = 1;\r\nvar b = 2;\n")
+	// sha256 of "var a = 1; var b = 2;"
+	normalized := BodySHA256([]byte("var a = 1; var b = 2;"), false)
+
+	s := Step{Name: "bundle"}
+	assert.Nil(t, s.CheckBodyHash(body, 0))
+
+	s.ExpectBodySHA256 = BodySHA256(body, false)
+	assert.Nil(t, s.CheckBodyHash(body, 0))
+	err := s.CheckBodyHash([]byte("var a = 1;\nvar b = 2;\n"), 1)
+	assert.IsType(t, &BodyHashError{}, err)
+	assert.Contains(t, err.Error(), "Step 1.'bundle' failed because body SHA-256 is ")
+
+	s.ExpectBodySHA256 = normalized
+	s.NormalizeBodyHash = true
+	assert.Nil(t, s.CheckBodyHash(body, 0))
+	assert.Nil(t, s.CheckBodyHash([]byte("  var a = 1;  var b = 2;"), 0))
+	assert.NotNil(t, s.CheckBodyHash([]byte("var a = 1; var b = 3;"), 0))
+
+	s.StreamBody = true
+	assert.Nil(t, s.CheckBodyHash(nil, 0))
+}
+
+func TestFlow_ExecuteBodyHash(t *testing.T) {
+	content := "console.log('hi')"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:             "bundle",
+		Request:          Request{URL: srv.URL, Method: "GET"},
+		ExpectBodySHA256: "ABCDEF",
+	}}}
+	assert.IsType(t, &BodyHashError{}, f.Execute(nil))
+
+	// case insensitive
+	f.Steps[0].ExpectBodySHA256 = strings.ToUpper(BodySHA256([]byte(content), false))
+	assert.Nil(t, f.Execute(nil))
+}
@@ -0,0 +1,377 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Collection is a request collection imported as a flow, its requests being the
+// steps in order. The collection's variables ({{name}}) become the flow's values.
+type Collection struct {
+	Flow Flow
+	// Environments are the collection's value sets by name, to Execute the flow with
+	Environments map[string]map[string]interface{}
+	// Correlations are the values of recorded responses reused by later requests,
+	// extracted and templated in the steps (see ImportHAR)
+	Correlations []Correlation
+}
+
+// collectionVar matches the variables of Insomnia ({{ _.name }}) and Bruno ({{name}})
+var collectionVar = regexp.MustCompile(`\{\{\s*(?:_\.)?([A-Za-z_$][\w.$-]*)\s*\}\}`)
+
+// collectionBuilder turns the requests of a collection into steps
+type collectionBuilder struct {
+	c    Collection
+	used map[string]bool
+}
+
+func newCollectionBuilder() *collectionBuilder {
+	return &collectionBuilder{c: Collection{Environments: map[string]map[string]interface{}{}}, used: map[string]bool{}}
+}
+
+// template converts the collection's variables of s to templated values, adding
+// them to the step's inputs
+func (b *collectionBuilder) template(step *Step, s string) string {
+	return collectionVar.ReplaceAllStringFunc(s, func(m string) string {
+		name := collectionVar.FindStringSubmatch(m)[1]
+		found := false
+		for _, k := range step.KeysInput {
+			found = found || k == name
+		}
+		if !found {
+			step.KeysInput = append(step.KeysInput, name)
+		}
+		b.used[name] = true
+		return templatePlaceholder(name)
+	})
+}
+
+func (b *collectionBuilder) add(step Step) {
+	if len(step.Request.Header) == 0 {
+		step.Request.Header = nil
+	}
+	b.c.Flow.Steps = append(b.c.Flow.Steps, step)
+}
+
+func (b *collectionBuilder) collection() *Collection {
+	for k := range b.used {
+		b.c.Flow.RequiredValues = append(b.c.Flow.RequiredValues, k)
+	}
+	sort.Strings(b.c.Flow.RequiredValues)
+	sort.Strings(b.c.Flow.SensitiveValues)
+	return &b.c
+}
+
+// flattenValues flattens the nested objects of the environment, {"a": {"b": 1}}
+// being the value "a.b"
+func flattenValues(prefix string, data map[string]interface{}, into map[string]interface{}) {
+	for k, v := range data {
+		if m, ok := v.(map[string]interface{}); ok {
+			flattenValues(prefix+k+".", m, into)
+			continue
+		}
+		into[prefix+k] = v
+	}
+}
+
+// ImportInsomnia imports an Insomnia export (format 4, JSON). Its requests are
+// named after their folders e.g. "Users/Get user", its sub-environments are
+// merged with the base one. Template tags ({% ... %}) aren't converted.
+func ImportInsomnia(data []byte) (*Collection, error) {
+	var export struct {
+		Format    int `json:"__export_format"`
+		Resources []struct {
+			ID       string                 `json:"_id"`
+			Type     string                 `json:"_type"`
+			ParentID string                 `json:"parentId"`
+			Name     string                 `json:"name"`
+			URL      string                 `json:"url"`
+			Method   string                 `json:"method"`
+			Data     map[string]interface{} `json:"data"`
+			Body     struct {
+				MimeType string          `json:"mimeType"`
+				Text     string          `json:"text"`
+				Params   []insomniaParam `json:"params"`
+			} `json:"body"`
+			Headers    []insomniaParam `json:"headers"`
+			Parameters []insomniaParam `json:"parameters"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid Insomnia export: %s", err.Error())
+	}
+	if export.Format != 4 {
+		return nil, fmt.Errorf("unsupported Insomnia export format %d", export.Format)
+	}
+
+	b := newCollectionBuilder()
+	folders := map[string]string{}
+	envs := map[string]map[string]interface{}{}
+	parents := map[string]string{}
+	for _, r := range export.Resources {
+		switch r.Type {
+		case "request_group":
+			folders[r.ID] = r.Name
+			parents[r.ID] = r.ParentID
+		case "environment":
+			envs[r.ID] = r.Data
+			parents[r.ID] = r.ParentID
+		}
+	}
+	for _, r := range export.Resources {
+		switch r.Type {
+		case "environment":
+			base, isSub := envs[r.ParentID]
+			if !isSub && len(envs) > 1 {
+				// only the sub-environments are value sets, when there are
+				continue
+			}
+			values := map[string]interface{}{}
+			flattenValues("", base, values)
+			flattenValues("", r.Data, values)
+			b.c.Environments[r.Name] = values
+		case "request":
+			name := r.Name
+			for p := r.ParentID; folders[p] != ""; p = parents[p] {
+				name = folders[p] + "/" + name
+			}
+			step := Step{Name: name, Request: Request{Method: strings.ToUpper(r.Method), Header: http.Header{}}}
+			u := b.template(&step, r.URL)
+			var query []string
+			for _, p := range r.Parameters {
+				if !p.Disabled {
+					query = append(query, b.template(&step, p.Name)+"="+b.template(&step, p.Value))
+				}
+			}
+			if len(query) != 0 {
+				sep := "?"
+				if strings.Contains(u, "?") {
+					sep = "&"
+				}
+				u += sep + strings.Join(query, "&")
+			}
+			step.Request.URL = u
+			for _, h := range r.Headers {
+				if !h.Disabled {
+					step.Request.Header.Add(h.Name, b.template(&step, h.Value))
+				}
+			}
+			switch {
+			case r.Body.MimeType == "application/x-www-form-urlencoded":
+				form := url.Values{}
+				for _, p := range r.Body.Params {
+					if !p.Disabled {
+						form.Add(p.Name, b.template(&step, p.Value))
+					}
+				}
+				step.Request.Body = form
+			case r.Body.Text != "":
+				step.Request.Body = b.template(&step, r.Body.Text)
+			}
+			if r.Body.MimeType != "" && step.Request.Header.Get("Content-Type") == "" && step.Request.Body != nil {
+				step.Request.Header.Set("Content-Type", r.Body.MimeType)
+			}
+			b.add(step)
+		}
+	}
+	return b.collection(), nil
+}
+
+// insomniaParam is a header or parameter of an Insomnia request
+type insomniaParam struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// bruFile is a parsed .bru file: its dictionary blocks, its lists and its text
+// blocks (bodies)
+type bruFile struct {
+	dicts map[string][][2]string
+	lists map[string][]string
+	texts map[string]string
+}
+
+// parseBru parses the Bruno markup language
+func parseBru(data []byte) (*bruFile, error) {
+	f := &bruFile{dicts: map[string][][2]string{}, lists: map[string][]string{}, texts: map[string]string{}}
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		if line == "" {
+			continue
+		}
+		var name, end string
+		switch {
+		case strings.HasSuffix(line, " {"):
+			name, end = strings.TrimSuffix(line, " {"), "}"
+		case strings.HasSuffix(line, " ["):
+			name, end = strings.TrimSuffix(line, " ["), "]"
+		default:
+			return nil, fmt.Errorf("line %d: expected a block, got '%s'", i+1, line)
+		}
+		var content []string
+		for i++; i < len(lines) && strings.TrimRight(lines[i], " \t") != end; i++ {
+			content = append(content, strings.TrimPrefix(lines[i], "  "))
+		}
+		if i == len(lines) {
+			return nil, fmt.Errorf("block '%s' isn't closed", name)
+		}
+
+		switch {
+		case end == "]":
+			for _, c := range content {
+				if c = strings.TrimSuffix(strings.TrimSpace(c), ","); c != "" {
+					f.lists[name] = append(f.lists[name], c)
+				}
+			}
+		case strings.HasPrefix(name, "body:") && name != "body:form-urlencoded" && name != "body:multipart-form":
+			f.texts[name] = strings.Join(content, "\n")
+		default:
+			for _, c := range content {
+				c = strings.TrimSpace(c)
+				if c == "" {
+					continue
+				}
+				kv := strings.SplitN(c, ":", 2)
+				if len(kv) != 2 {
+					return nil, fmt.Errorf("block '%s': expected 'key: value', got '%s'", name, c)
+				}
+				f.dicts[name] = append(f.dicts[name], [2]string{strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])})
+			}
+		}
+	}
+	return f, nil
+}
+
+// get returns the value of the key of the dictionary block
+func (f *bruFile) get(block, key string) string {
+	for _, kv := range f.dicts[block] {
+		if kv[0] == key {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// ImportBruno imports the Bruno collection in dir: its requests (.bru files)
+// ordered by folder and seq, named after their folders, and its environments
+// (environments/*.bru), whose secret variables are sensitive values. Only the
+// bearer auth is imported.
+func ImportBruno(dir string) (*Collection, error) {
+	type request struct {
+		folder string
+		seq    int
+		file   *bruFile
+	}
+	var requests []request
+	b := newCollectionBuilder()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if info.IsDir() || filepath.Ext(path) != ".bru" {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f, err := parseBru(data)
+		if err != nil {
+			return fmt.Errorf("%s: %s", rel, err.Error())
+		}
+		folder := filepath.ToSlash(filepath.Dir(rel))
+		switch {
+		case folder == "environments":
+			values := map[string]interface{}{}
+			for _, kv := range f.dicts["vars"] {
+				if !strings.HasPrefix(kv[0], "~") {
+					values[kv[0]] = kv[1]
+				}
+			}
+			for _, k := range f.lists["vars:secret"] {
+				if !strings.HasPrefix(k, "~") {
+					b.c.Flow.SensitiveValues = append(b.c.Flow.SensitiveValues, k)
+				}
+			}
+			b.c.Environments[strings.TrimSuffix(filepath.Base(rel), ".bru")] = values
+		case f.get("meta", "type") == "http":
+			seq, _ := strconv.Atoi(f.get("meta", "seq"))
+			requests = append(requests, request{folder: folder, seq: seq, file: f})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't import Bruno collection: %s", err.Error())
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		if requests[i].folder != requests[j].folder {
+			return requests[i].folder < requests[j].folder
+		}
+		return requests[i].seq < requests[j].seq
+	})
+	// secrets are listed in every environment
+	sensitive := map[string]bool{}
+	var uniq []string
+	for _, k := range b.c.Flow.SensitiveValues {
+		if !sensitive[k] {
+			sensitive[k] = true
+			uniq = append(uniq, k)
+		}
+	}
+	b.c.Flow.SensitiveValues = uniq
+
+	for _, r := range requests {
+		f := r.file
+		name := f.get("meta", "name")
+		if r.folder != "." {
+			name = r.folder + "/" + name
+		}
+		step := Step{Name: name, Request: Request{Header: http.Header{}}}
+		var bodyType string
+		for _, m := range []string{"get", "post", "put", "patch", "delete", "options", "head"} {
+			if _, ok := f.dicts[m]; ok {
+				step.Request.Method = strings.ToUpper(m)
+				step.Request.URL = b.template(&step, f.get(m, "url"))
+				bodyType = f.get(m, "body")
+			}
+		}
+		for _, kv := range f.dicts["headers"] {
+			if !strings.HasPrefix(kv[0], "~") {
+				step.Request.Header.Add(kv[0], b.template(&step, kv[1]))
+			}
+		}
+		if token := f.get("auth:bearer", "token"); token != "" {
+			step.Request.Header.Set("Authorization", "Bearer "+b.template(&step, token))
+		}
+		contentTypes := map[string]string{"json": "application/json", "xml": "application/xml", "text": "text/plain"}
+		switch bodyType {
+		case "json", "xml", "text":
+			step.Request.Body = b.template(&step, f.texts["body:"+bodyType])
+			if step.Request.Header.Get("Content-Type") == "" {
+				step.Request.Header.Set("Content-Type", contentTypes[bodyType])
+			}
+		case "formUrlEncoded":
+			form := url.Values{}
+			for _, kv := range f.dicts["body:form-urlencoded"] {
+				if !strings.HasPrefix(kv[0], "~") {
+					form.Add(kv[0], b.template(&step, kv[1]))
+				}
+			}
+			step.Request.Body = form
+		}
+		b.add(step)
+	}
+	return b.collection(), nil
+}
@@ -0,0 +1,134 @@
+package httpsim
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportInsomnia(t *testing.T) {
+	c, err := ImportInsomnia([]byte(`{
+  "_type": "export",
+  "__export_format": 4,
+  "resources": [
+    {"_id": "wrk", "_type": "workspace", "name": "Shop"},
+    {"_id": "env", "_type": "environment", "parentId": "wrk", "name": "Base", "data": {"base": "https://shop.example.com", "api": {"version": "v2"}}},
+    {"_id": "env_prod", "_type": "environment", "parentId": "env", "name": "Prod", "data": {"user": "bob"}},
+    {"_id": "fld", "_type": "request_group", "parentId": "wrk", "name": "Auth"},
+    {"_id": "req1", "_type": "request", "parentId": "fld", "name": "Login", "method": "post",
+     "url": "{{ _.base }}/login",
+     "body": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "{{ _.user }}"}, {"name": "x", "value": "1", "disabled": true}]}},
+    {"_id": "req2", "_type": "request", "parentId": "wrk", "name": "Items", "method": "GET",
+     "url": "{{ base }}/{{ _.api.version }}/items",
+     "parameters": [{"name": "page", "value": "1"}],
+     "headers": [{"name": "Accept", "value": "application/json"}, {"name": "X-Debug", "value": "1", "disabled": true}]}
+  ]
+}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"Prod": {"base": "https://shop.example.com", "api.version": "v2", "user": "bob"},
+	}, c.Environments)
+	assert.Equal(t, []string{"api.version", "base", "user"}, c.Flow.RequiredValues)
+	assert.Len(t, c.Flow.Steps, 2)
+
+	login := c.Flow.Steps[0]
+	assert.Equal(t, "Auth/Login", login.Name)
+	assert.Equal(t, "POST", login.Request.Method)
+	assert.Equal(t, "{{.base}}/login", login.Request.URL)
+	assert.Equal(t, url.Values{"user": {"{{.user}}"}}, login.Request.Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", login.Request.Header.Get("Content-Type"))
+	assert.Equal(t, []string{"base", "user"}, login.KeysInput)
+
+	items := c.Flow.Steps[1]
+	assert.Equal(t, `{{.base}}/{{index . "api.version"}}/items?page=1`, items.Request.URL)
+	assert.Equal(t, http.Header{"Accept": {"application/json"}}, items.Request.Header)
+	assert.Nil(t, items.Request.Body)
+
+	_, err = ImportInsomnia([]byte(`{"__export_format": 3}`))
+	assert.EqualError(t, err, "unsupported Insomnia export format 3")
+}
+
+func TestImportBruno(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bruno")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	write("bruno.json", `{"name": "shop"}`)
+	write("environments/Prod.bru", `vars {
+  baseUrl: https://shop.example.com
+  ~old: x
+}
+vars:secret [
+  token
+]
+`)
+	write("Items/List.bru", `meta {
+  name: List items
+  type: http
+  seq: 2
+}
+
+get {
+  url: {{baseUrl}}/items
+  body: none
+  auth: bearer
+}
+
+auth:bearer {
+  token: {{token}}
+}
+`)
+	write("Items/Create.bru", `meta {
+  name: Create item
+  type: http
+  seq: 1
+}
+
+post {
+  url: {{baseUrl}}/items
+  body: json
+  auth: none
+}
+
+headers {
+  X-Client: test
+  ~X-Debug: 1
+}
+
+body:json {
+  {
+    "name": "{{name}}"
+  }
+}
+`)
+	c, err := ImportBruno(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{"Prod": {"baseUrl": "https://shop.example.com"}}, c.Environments)
+	assert.Equal(t, []string{"token"}, c.Flow.SensitiveValues)
+	assert.Equal(t, []string{"baseUrl", "name", "token"}, c.Flow.RequiredValues)
+	assert.Len(t, c.Flow.Steps, 2)
+
+	create := c.Flow.Steps[0]
+	assert.Equal(t, "Items/Create item", create.Name)
+	assert.Equal(t, "POST", create.Request.Method)
+	assert.Equal(t, "{\n  \"name\": \"{{.name}}\"\n}", create.Request.Body)
+	assert.Equal(t, http.Header{"X-Client": {"test"}, "Content-Type": {"application/json"}}, create.Request.Header)
+
+	list := c.Flow.Steps[1]
+	assert.Equal(t, "GET", list.Request.Method)
+	assert.Equal(t, "{{.baseUrl}}/items", list.Request.URL)
+	assert.Equal(t, "Bearer {{.token}}", list.Request.Header.Get("Authorization"))
+	assert.Equal(t, []string{"baseUrl", "token"}, list.KeysInput)
+
+	_, err = parseBru([]byte("meta {\n  name: x\n"))
+	assert.EqualError(t, err, "block 'meta' isn't closed")
+}
@@ -0,0 +1,86 @@
+package httpsim
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Deprecation is a use of a deprecated API, reported to the deprecation
+// handler (see SetDeprecationHandler) to tell what a program has left to
+// migrate
+type Deprecation struct {
+	// API is the deprecated API e.g. "Flow.Execute"
+	API string
+	// Replacement is what to use instead
+	Replacement string
+	// Caller is the file:line using it
+	Caller string
+}
+
+func (d Deprecation) String() string {
+	return fmt.Sprintf("httpsim: %s is deprecated, use %s (%s)", d.API, d.Replacement, d.Caller)
+}
+
+var deprecations struct {
+	mu      sync.Mutex
+	handler func(Deprecation)
+	seen    map[string]bool
+}
+
+func init() {
+	// HTTPSIM_DEPRECATIONS logs the deprecations without changing the program
+	if os.Getenv("HTTPSIM_DEPRECATIONS") != "" {
+		SetDeprecationHandler(func(d Deprecation) { log.Print(d) })
+	}
+}
+
+// SetDeprecationHandler enables the deprecation telemetry: the handler is
+// called the first time each deprecated API is used from a call site, e.g.
+// with log.Print. nil disables it. Setting the HTTPSIM_DEPRECATIONS
+// environment variable logs them from the start.
+func SetDeprecationHandler(handler func(Deprecation)) {
+	deprecations.mu.Lock()
+	defer deprecations.mu.Unlock()
+	deprecations.handler = handler
+	deprecations.seen = map[string]bool{}
+}
+
+// deprecated reports the use of the deprecated API by the caller of the
+// function calling it
+func deprecated(api, replacement string) {
+	deprecations.mu.Lock()
+	handler := deprecations.handler
+	deprecations.mu.Unlock()
+	if handler == nil {
+		return
+	}
+	caller := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = fmt.Sprintf("%s:%d", file, line)
+	}
+	deprecations.mu.Lock()
+	seen := deprecations.seen[api+caller]
+	deprecations.seen[api+caller] = true
+	deprecations.mu.Unlock()
+	if !seen {
+		handler(Deprecation{API: api, Replacement: replacement, Caller: caller})
+	}
+}
+
+// CompatV1 restores the v1 behaviors the flow relies on: its steps without
+// ExpectStatus accept any status, as they did before non-2xx statuses failed
+// the steps. It's reported as a deprecation, set the steps' ExpectStatus (or
+// AnyStatus) instead.
+func CompatV1(f *Flow) {
+	deprecated("CompatV1", "Step.ExpectStatus or Step.AnyStatus")
+	for _, steps := range [][]Step{f.Steps, f.Teardown} {
+		for i := range steps {
+			if len(steps[i].ExpectStatus) == 0 {
+				steps[i].AnyStatus = true
+			}
+		}
+	}
+}
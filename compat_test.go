@@ -0,0 +1,49 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	var reported []Deprecation
+	SetDeprecationHandler(func(d Deprecation) { reported = append(reported, d) })
+	defer SetDeprecationHandler(nil)
+
+	f := Flow{Steps: []Step{{Name: "page", Request: Request{URL: srv.URL, Method: "GET"}}}}
+	for i := 0; i < 2; i++ {
+		// reported once for the call site
+		assert.NotNil(t, f.Execute(nil))
+	}
+	assert.NotNil(t, f.Execute(nil))
+	resp, err := f.Steps[0].Request.Do(http.Client{})
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Len(t, reported, 3)
+	assert.Equal(t, "Flow.Execute", reported[0].API)
+	assert.Equal(t, "Flow.ExecuteContext", reported[0].Replacement)
+	assert.True(t, strings.Contains(reported[0].Caller, "compat_test.go:"))
+	assert.NotEqual(t, reported[0].Caller, reported[1].Caller)
+	assert.Equal(t, "Request.Do", reported[2].API)
+	assert.True(t, strings.HasPrefix(reported[2].String(), "httpsim: Request.Do is deprecated, use Request.DoContext (/"))
+
+	// v1 flows accept any status
+	CompatV1(&f)
+	assert.True(t, f.Steps[0].AnyStatus)
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "CompatV1", reported[3].API)
+
+	SetDeprecationHandler(nil)
+	f.Execute(nil)
+	assert.Len(t, reported, 5)
+}
@@ -0,0 +1,253 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// maxCached bounds the compiled regexps and templates caches: patterns rendered
+// from values would otherwise grow them forever
+const maxCached = 4096
+
+// cache is a bounded concurrency-safe cache, emptied when full
+type cache struct {
+	mu sync.RWMutex
+	m  map[string]interface{}
+}
+
+func (c *cache) get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.m[key]
+	return v, ok
+}
+
+func (c *cache) put(key string, v interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil || len(c.m) >= maxCached {
+		c.m = map[string]interface{}{}
+	}
+	c.m[key] = v
+}
+
+var (
+	regexpCache   cache
+	templateCache cache
+)
+
+// compileRegexp compiles the pattern once and returns it from cache afterwards
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.get(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.put(pattern, re)
+	return re, nil
+}
+
+// anchorRegexp makes the pattern match the whole string
+func anchorRegexp(pattern string) string {
+	if pattern[0] != '^' {
+		pattern = "^" + pattern
+	}
+	if pattern[len(pattern)-1] != '$' {
+		pattern += "$"
+	}
+	return pattern
+}
+
+// Delims are the left and right delimiters of the request templates, e.g.
+// [[ ]] for requests containing literal {{ }} (Angular, Vue payloads...). The
+// zero value is {{ }}.
+type Delims struct {
+	Left, Right string
+}
+
+// left returns the left delimiter
+func (d Delims) left() string {
+	if d.Left == "" {
+		return "{{"
+	}
+	return d.Left
+}
+
+// parseTemplate parses the template once and returns it from cache afterwards.
+// Templates are safe to execute concurrently.
+func parseTemplate(text string) (*template.Template, error) {
+	return Delims{}.parse(text)
+}
+
+// parse parses the template with the delimiters, see parseTemplate
+func (d Delims) parse(text string) (*template.Template, error) {
+	key := text
+	if d != (Delims{}) {
+		key = d.Left + "\x00" + d.Right + "\x00" + text
+	}
+	if tpl, ok := templateCache.get(key); ok {
+		return tpl.(*template.Template), nil
+	}
+	tpl, err := template.New("replacement").Delims(d.Left, d.Right).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	templateCache.put(key, tpl)
+	return tpl, nil
+}
+
+// Compile checks and precompiles the templates and regexps of the flow so they
+// aren't compiled during the first Execute. Patterns that are themselves templates
+// are compiled once rendered.
+func (f *Flow) Compile() error {
+	if err := f.Policy.compile(); err != nil {
+		return fmt.Errorf("Flow invalid policy regexp: %s", err.Error())
+	}
+	if _, err := url.Parse(f.Proxy); err != nil {
+		return fmt.Errorf("Flow invalid proxy URL: %s", err.Error())
+	}
+	if f.UserAgents != nil && len(f.UserAgents.Pool) == 0 {
+		return errors.New("Flow invalid user agents: the pool is empty")
+	}
+	for i, step := range f.Steps {
+		fail := func(what string, err error) error {
+			return fmt.Errorf("Step %d.'%s' invalid %s: %s", i, step.Name, what, err.Error())
+		}
+		delims := f.delims(&step)
+		if _, err := url.Parse(step.Proxy); err != nil {
+			return fail("proxy URL", err)
+		}
+
+		if _, err := delims.parse(step.Request.URL); err != nil {
+			return fail("URL template", err)
+		}
+		for k := range step.Request.Header {
+			if _, err := delims.parse(step.Request.Header.Get(k)); err != nil {
+				return fail("header template", err)
+			}
+		}
+		if _, err := delims.parse(step.CacheKey); err != nil {
+			return fail("cache key template", err)
+		}
+		if _, err := delims.parse(step.Condition); err != nil {
+			return fail("condition template", err)
+		}
+		for _, c := range step.Request.Cookies {
+			if _, err := delims.parse(c.Value); err != nil {
+				return fail("cookie template", err)
+			}
+		}
+		var bodies []string
+		switch t := step.Request.Body.(type) {
+		case string:
+			bodies = append(bodies, t)
+		case []byte:
+			bodies = append(bodies, string(t))
+		case url.Values:
+			for k, v := range t {
+				bodies = append(bodies, k, v[len(v)-1])
+			}
+		}
+		if !step.templatedBody() {
+			bodies = nil
+		}
+		for _, b := range bodies {
+			if _, err := delims.parse(b); err != nil {
+				return fail("body template", err)
+			}
+		}
+
+		for _, pattern := range step.ForbidRegexp {
+			if _, err := compileRegexp(pattern); err != nil {
+				return fail("forbid regexp", err)
+			}
+		}
+		extracters := append([]Extracter(nil), step.KeysOutput...)
+		for _, e := range step.Recovery {
+			extracters = append(extracters, e)
+		}
+		for _, e := range extracters {
+			if err := compileExtracter(e, delims); err != nil {
+				return fail("extracter", err)
+			}
+		}
+	}
+	return nil
+}
+
+// delims returns the delimiters of the step's templates: its own, or the flow's
+func (f *Flow) delims(step *Step) Delims {
+	if step.Delims != (Delims{}) {
+		return step.Delims
+	}
+	return f.Delims
+}
+
+// compileExtracter precompiles the patterns and selectors of the extracters it
+// knows, the templated ones (see Extractable.Templated) are compiled once rendered
+func compileExtracter(e Extracter, delims Delims) error {
+	var patterns []string
+	switch t := e.(type) {
+	case Extractable:
+		for a := &t; a != nil; a = a.Again {
+			if a.MatchRegexp != "" && !(a.Templated && strings.Contains(a.MatchRegexp, delims.left())) {
+				patterns = append(patterns, anchorRegexp(a.MatchRegexp))
+			}
+		}
+	case PDFTextExtractable:
+		if err := compileExtracter(t.Extractable, delims); err != nil {
+			return err
+		}
+		patterns = append(patterns, t.Regexp)
+	case ImageExtractable:
+		if t.Then != nil {
+			return compileExtracter(*t.Then, delims)
+		}
+	case HTMLExtractable:
+		if !(t.Templated && strings.Contains(t.Selector, delims.left())) {
+			_, err := compileSelector(t.Selector)
+			return err
+		}
+	}
+	for _, p := range patterns {
+		if p == "" {
+			continue
+		}
+		if _, err := compileRegexp(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompiledFlow is a checked and compiled flow definition, run concurrently without
+// sharing any state between runs
+type CompiledFlow struct {
+	def Flow
+}
+
+// CompileFlow compiles the flow (see Compile) and copies its definition: changes
+// made to f afterwards don't affect the compiled flow
+func (f *Flow) CompileFlow() (*CompiledFlow, error) {
+	if err := f.Compile(); err != nil {
+		return nil, err
+	}
+	return &CompiledFlow{def: f.CompleteCopy()}, nil
+}
+
+// Run executes a copy of the flow with a copy of the values, and returns it with
+// its Values, Responses and Warnings filled. It's safe for concurrent use: each run
+// has its own cookie jar, values, responses and sessionStorage. The flow's
+// Credentials, Snapshots, UserAgents, Audit and localStorage are shared and must
+// be safe for concurrent use, as the provided ones are.
+func (c *CompiledFlow) Run(values map[string]interface{}) (*Flow, error) {
+	return c.RunContext(context.Background(), values)
+}
@@ -0,0 +1,212 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRegexpCached(t *testing.T) {
+	re1, err := compileRegexp(`[a-z]+`)
+	assert.Nil(t, err)
+	re2, err := compileRegexp(`[a-z]+`)
+	assert.Nil(t, err)
+	assert.True(t, re1 == re2)
+
+	_, err = compileRegexp(`[a-z`)
+	assert.NotNil(t, err)
+}
+
+func TestExtractable_ExtractInvalidRegexp(t *testing.T) {
+	ex := Extractable{AfterThis: "[", BeforeThis: "]", Iterate: true, MaxLength: -1, MinLength: -1, MatchRegexp: "[a-z"}
+	_, _, err := ex.Extract("[a] [b]", nil)
+	assert.EqualError(t, err, "error parsing regexp: missing closing ]: `[a-z$`")
+	assert.Equal(t, "[a-z", ex.MatchRegexp)
+}
+
+func TestFlow_Compile(t *testing.T) {
+	f := Flow{Steps: []Step{{
+		Name: "login",
+		Request: Request{
+			URL:    "https://example.com/{{.path}}",
+			Header: http.Header{"X-Token": {"{{.token}}"}},
+			Body:   "user={{.user}}",
+		},
+		KeysOutput: []Extracter{
+			Extractable{Name: "a", MatchRegexp: "[0-9]+", Again: &Extractable{MatchRegexp: "[0-9]{2}"}},
+			Extractable{Name: "b", MatchRegexp: "{{.pattern}}", Templated: true},
+		},
+		ForbidRegexp: []string{"[Ee]rror"},
+	}}}
+	assert.Nil(t, f.Compile())
+
+	f.Steps[0].Request.Body = "user={{.user}"
+	err := f.Compile()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Step 0.'login' invalid body template: ")
+
+	f.Steps[0].Request.Body = nil
+	f.Steps[0].KeysOutput[0] = Extractable{Name: "a", Again: &Extractable{MatchRegexp: "[0-9"}}
+	assert.EqualError(t, f.Compile(), "Step 0.'login' invalid extracter: error parsing regexp: missing closing ]: `[0-9$`")
+
+	f.Steps[0].KeysOutput[0] = HTMLExtractable{Name: "a", Selector: "input[name=csrf"}
+	assert.EqualError(t, f.Compile(), "Step 0.'login' invalid extracter: invalid selector 'input[name=csrf': unclosed [")
+}
+
+func TestCompiledFlow_RunConcurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			b, _ := ioutil.ReadAll(r.Body)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: string(b)})
+			http.Redirect(w, r, "/home", http.StatusFound)
+		case "/home":
+			c, err := r.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, "<b>%s</b>", c.Value)
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		RequiredValues: []string{"user"},
+		Steps: []Step{
+			{
+				Name:         "login",
+				Request:      Request{URL: srv.URL + "/login", Method: "POST", Body: "{{.user}}", IgnoreRedirects: true},
+				KeysInput:    []string{"user"},
+				ExpectStatus: []string{"302"},
+			},
+			{
+				Name:       "home",
+				Request:    Request{URL: srv.URL + "/home", Method: "GET", Header: http.Header{"X-User": {"{{.user}}"}}},
+				KeysInput:  []string{"user"},
+				KeysOutput: []Extracter{Extractable{Name: "who", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}},
+			},
+		},
+	}
+	compiled, err := f.CompileFlow()
+	assert.Nil(t, err)
+	// the definition is copied
+	f.Steps[0].Request.URL = "http://127.0.0.1:1"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := fmt.Sprintf("user%d", i)
+			values := map[string]interface{}{"user": user}
+			run, err := compiled.Run(values)
+			assert.Nil(t, err)
+			assert.Equal(t, user, run.Values["who"])
+			assert.Equal(t, fmt.Sprintf("<b>%s</b>", user), string(run.Steps[1].Response.Body))
+			// the given values aren't written to
+			assert.Len(t, values, 1)
+		}(i)
+	}
+	wg.Wait()
+	assert.Nil(t, compiled.def.Steps[1].Response)
+	assert.Equal(t, "{{.user}}", compiled.def.Steps[1].Request.Header.Get("X-User"))
+
+	f.Steps[0].Request.Body = "user={{.user}"
+	_, err = f.CompileFlow()
+	assert.NotNil(t, err)
+}
+
+func TestRequest_DoBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	for _, body := range []interface{}{"a=1", []byte("a=1"), map[string][]string{"a": {"1"}}, nil} {
+		r := Request{URL: srv.URL, Method: "POST", Body: body}
+		if m, ok := body.(map[string][]string); ok {
+			r.Body = url.Values(m)
+		}
+		resp, err := r.Do(http.Client{})
+		assert.Nil(t, err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if body == nil {
+			assert.Equal(t, "", string(b))
+		} else {
+			assert.Equal(t, "a=1", string(b))
+		}
+	}
+}
+
+func TestFlow_Delims(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r.URL.Path+" "+r.Header.Get("X-Token")+" "+string(b))
+	}))
+	defer srv.Close()
+
+	vue := `<div v-if="ok">{{ message }}</div>`
+	f := Flow{Delims: Delims{Left: "[[", Right: "]]"}, Steps: []Step{
+		{Name: "component", Request: Request{URL: srv.URL + "/[[.page]]", Method: "POST",
+			Header: http.Header{"X-Token": []string{"[[.token]]"}}, Body: `{"template": "` + vue + `", "id": "[[.id]]"}`},
+			KeysInput: []string{"page", "token", "id"}, Condition: `[[if .id]]true[[end]]`},
+		{Name: "default", Request: Request{URL: srv.URL + "/{{.page}}", Method: "GET"}, KeysInput: []string{"page"},
+			Delims: Delims{Left: "{{", Right: "}}"}},
+	}}
+	assert.Nil(t, f.Compile())
+	vals := map[string]interface{}{"page": "components", "token": "abc", "id": "7"}
+	assert.Nil(t, f.ExecuteContext(context.Background(), vals))
+	assert.Equal(t, []string{
+		`/components abc {"template": "` + vue + `", "id": "7"}`,
+		"/components  ",
+	}, got)
+
+	fields, err := templateFields(f.Delims, f.Steps[0].Request.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"page"}, fields)
+
+	f.Delims = Delims{}
+	assert.NotNil(t, f.Compile())
+}
+
+func TestFlow_DelimsExtracters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Write([]byte(`{"{{ id }}": "literal", "items": {"a": "1", "b": "2"}}`))
+			return
+		}
+		w.Write([]byte(`<p id="{{ id }}">literal</p><p id="p1">templated</p><span>{{ message }}</span>`))
+	}))
+	defer srv.Close()
+
+	f := Flow{Delims: Delims{Left: "[[", Right: "]]"}, Steps: []Step{
+		{Name: "json", Request: Request{URL: srv.URL + "/json", Method: "GET"}, KeysOutput: []Extracter{
+			JSONExtractable{Name: "literal", Path: "{{ id }}"},
+			JSONExtractable{Name: "templated", Path: "items.[[.key]]", Templated: true},
+		}},
+		{Name: "html", Request: Request{URL: srv.URL + "/html", Method: "GET"}, KeysOutput: []Extracter{
+			HTMLExtractable{Name: "literal_p", Selector: `p[id="{{ id }}"]`},
+			HTMLExtractable{Name: "templated_p", Selector: "#[[.p]]", Templated: true},
+			Extractable{Name: "message", AfterThis: "<span>{{ ", BeforeThis: " }}", Templated: true, MaxLength: -1, MinLength: -1},
+		}},
+	}}
+	assert.Nil(t, f.Compile())
+	assert.Nil(t, f.Execute(map[string]interface{}{"key": "b", "p": "p1"}))
+	assert.Equal(t, "literal", f.Values["literal"])
+	assert.Equal(t, "2", f.Values["templated"])
+	assert.Equal(t, "literal", f.Values["literal_p"])
+	assert.Equal(t, "templated", f.Values["templated_p"])
+	// the step's delimiters aren't {{ }}, the marker is literal
+	assert.Equal(t, "message", f.Values["message"])
+}
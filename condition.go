@@ -0,0 +1,27 @@
+package httpsim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// skipStep returns whether the step's conditions skip it, its Response is then
+// nil. A skipped step's inputs aren't needed.
+func (f *Flow) skipStep(i int, def *Step) (bool, error) {
+	if def.OnlyIf != nil && !def.OnlyIf(f.Values) || def.SkipIf != nil && def.SkipIf(f.Values) {
+		def.Response = nil
+		return true, nil
+	}
+	if def.Condition == "" {
+		return false, nil
+	}
+	out, err := f.delims(def).replace(f.templateValues(def), def.Condition)
+	if err != nil {
+		return false, fmt.Errorf("Step %d.'%s' failed because its condition failed: %s", i, def.Name, err.Error())
+	}
+	if strings.TrimSpace(out) != "true" {
+		def.Response = nil
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,62 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ConditionalSteps(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/login" {
+			fmt.Fprintf(w, "<mfa>%s</mfa>", r.URL.Query().Get("user"))
+		}
+	}))
+	defer srv.Close()
+
+	mfa := Extractable{Name: "mfa", AfterThis: "<mfa>", BeforeThis: "</mfa>", MaxLength: -1, MinLength: -1}
+	f := Flow{Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/login?user={{.user}}", Method: "GET"}, KeysInput: []string{"user"},
+			KeysOutput: []Extracter{mfa}},
+		{Name: "2fa", Request: Request{URL: srv.URL + "/2fa?code={{.code}}", Method: "GET"}, KeysInput: []string{"code"},
+			Condition: `{{eq .mfa "required"}}`},
+		{Name: "welcome", Request: Request{URL: srv.URL + "/welcome", Method: "GET"},
+			SkipIf: func(values map[string]interface{}) bool { return values["user"] == "bot" }},
+		{Name: "sms", Request: Request{URL: srv.URL + "/sms", Method: "GET"},
+			OnlyIf: func(values map[string]interface{}) bool { return values["mfa"] == "sms" }},
+	}}
+
+	// the 2FA step's code isn't needed when it's skipped
+	assert.Nil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"user": "none"}))
+	assert.Equal(t, []string{"/login", "/welcome"}, paths)
+	assert.Nil(t, f.Steps[1].Response)
+	assert.NotNil(t, f.Steps[2].Response)
+
+	paths = nil
+	assert.Nil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"user": "required", "code": "123456"}))
+	assert.Equal(t, []string{"/login", "/2fa", "/welcome"}, paths)
+	assert.NotNil(t, f.Steps[1].Response)
+
+	paths = nil
+	assert.Nil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"user": "sms"}))
+	assert.Equal(t, []string{"/login", "/welcome", "/sms"}, paths)
+
+	paths = nil
+	f.Steps[0].KeysOutput = nil
+	assert.Nil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"user": "bot"}))
+	assert.Equal(t, []string{"/login"}, paths)
+
+	f.Steps[1].Condition = `{{.mfa.missing}}`
+	err := f.ExecuteContext(context.Background(), map[string]interface{}{"user": "bot", "mfa": "x"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Step 1.'2fa' failed because its condition failed: ")
+
+	f.Steps[1].Condition = `{{eq .mfa "required"}`
+	assert.Contains(t, f.Compile().Error(), "Step 1.'2fa' invalid condition template: ")
+}
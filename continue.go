@@ -0,0 +1,87 @@
+package httpsim
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ContinueInfo is how the server answered a request sent with Expect: 100-continue
+type ContinueInfo struct {
+	// Received is whether the server sent the interim 100 response, Wait is the
+	// time from the request's headers being written to it
+	Received bool
+	Wait     time.Duration
+	// BodyDelay is the time from the headers being written to the body being
+	// written: the Wait, or the transport's ExpectContinueTimeout when the server
+	// didn't answer
+	BodyDelay time.Duration
+	// Rejected is whether the server answered 417 Expectation Failed, the request
+	// was then sent again without the expectation
+	Rejected bool
+}
+
+// expectsContinue returns whether a body of that size is sent with Expect: 100-continue
+func (r *Request) expectsContinue(size int) bool {
+	return r.ExpectContinue > 0 && int64(size) >= r.ExpectContinue
+}
+
+// retryExpectation sends the request again without its expectation when the
+// server rejected it, like clients do
+func retryExpectation(client http.Client, req *http.Request, resp *http.Response, body []byte) (*http.Response, error) {
+	if resp.StatusCode != http.StatusExpectationFailed {
+		return resp, nil
+	}
+	resp.Body.Close()
+	retry := req.Clone(req.Context())
+	retry.Header.Del("Expect")
+	retry.Body = io.NopCloser(bytes.NewReader(body))
+	return client.Do(retry)
+}
+
+// continueTrace times the interim response of a request. The body may be written
+// once the response is read, the info is locked.
+type continueTrace struct {
+	mu      sync.Mutex
+	headers time.Time
+	info    ContinueInfo
+}
+
+// context returns the context tracing the request
+func (t *continueTrace) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteHeaders: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.headers = time.Now()
+		},
+		Got100Continue: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.info.Received, t.info.Wait = true, time.Since(t.headers)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.info.BodyDelay == 0 {
+				t.info.BodyDelay = time.Since(t.headers)
+			}
+		},
+	})
+}
+
+// result returns the traced info, nil when the request wasn't sent expecting
+func (t *continueTrace) result(r *Request, resp *http.Response) *ContinueInfo {
+	if !r.expectsContinue(len(requestBody(r.Body))) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := t.info
+	info.Rejected = resp.Request != nil && resp.Request.Header.Get("Expect") == ""
+	return &info
+}
@@ -0,0 +1,49 @@
+package httpsim
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_ExpectContinue(t *testing.T) {
+	var expects []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expects = append(expects, r.Header.Get("Expect"))
+		if r.URL.Path == "/picky" && r.Header.Get("Expect") != "" {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.Write([]byte(strings.ToUpper(string(b[:1]))))
+	}))
+	defer srv.Close()
+	upload := strings.Repeat("a", 1024)
+	f := Flow{Steps: []Step{
+		{Name: "small", Request: Request{URL: srv.URL, Method: "POST", Body: "a", ExpectContinue: 1024}},
+		{Name: "large", Request: Request{URL: srv.URL, Method: "POST", Body: upload, ExpectContinue: 1024}},
+		{Name: "picky", Request: Request{URL: srv.URL + "/picky", Method: "PUT", Body: upload, ExpectContinue: 1024}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{"", "100-continue", "100-continue", ""}, expects)
+
+	assert.Nil(t, f.Steps[0].Response.Continue)
+	info := f.Steps[1].Response.Continue
+	assert.NotNil(t, info)
+	assert.True(t, info.Received)
+	assert.False(t, info.Rejected)
+	assert.True(t, info.Wait > 0)
+	assert.Equal(t, "A", string(f.Steps[1].Response.Body))
+
+	info = f.Steps[2].Response.Continue
+	assert.NotNil(t, info)
+	assert.False(t, info.Received)
+	assert.True(t, info.Rejected)
+	assert.Equal(t, 200, f.Steps[2].Response.Raw.StatusCode)
+	assert.Equal(t, "A", string(f.Steps[2].Response.Body))
+	assert.Empty(t, f.Steps[1].Request.Header.Get("Expect"))
+}
@@ -0,0 +1,117 @@
+// Package contrib is a library of maintained example flows for well-known
+// patterns: the OAuth 2.0 device authorization grant, SSO logins (OpenID
+// Connect and SAML POST binding) and a demo shop checkout, runnable against
+// NewDemoShop. They're executable documentation and starting points, to copy
+// and adapt to the sites simulated. The flows directory has their YAML
+// definitions, to load with httpsim.LoadFlow.
+package contrib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gee-m/httpsim"
+)
+
+// DeviceConfig configures the OAuth 2.0 device authorization grant (RFC 8628)
+type DeviceConfig struct {
+	// DeviceAuthorizationURL and TokenURL are the provider's endpoints, e.g.
+	// https://github.com/login/device/code and
+	// https://github.com/login/oauth/access_token
+	DeviceAuthorizationURL string
+	TokenURL               string
+	ClientID               string
+	Scope                  string
+	// Interval overrides the polling interval the provider asks for when not 0
+	Interval time.Duration
+}
+
+// formHeader is the header of the form requests to JSON APIs
+func formHeader() http.Header {
+	return http.Header{"Content-Type": {"application/x-www-form-urlencoded"}, "Accept": {"application/json"}}
+}
+
+// DeviceAuthorization requests the device and user codes, extracting
+// device_code, user_code, verification_uri and interval
+func DeviceAuthorization(cfg DeviceConfig) httpsim.Flow {
+	return httpsim.Flow{Steps: []httpsim.Step{{
+		Name: "device authorization",
+		Request: httpsim.Request{URL: cfg.DeviceAuthorizationURL, Method: "POST", Header: formHeader(),
+			Body: url.Values{"client_id": {cfg.ClientID}, "scope": {cfg.Scope}}},
+		KeysOutput: []httpsim.Extracter{
+			httpsim.JSONExtractable{Name: "device_code", Path: "device_code"},
+			httpsim.JSONExtractable{Name: "user_code", Path: "user_code"},
+			httpsim.JSONExtractable{Name: "verification_uri", Path: "verification_uri"},
+			httpsim.JSONExtractable{Name: "interval", Path: "interval", IgnoreNotFound: true},
+		},
+	}}}
+}
+
+// DeviceToken polls the token endpoint once with the device_code value. It
+// extracts access_token and refresh_token once the user approved, error (e.g.
+// "authorization_pending") until then.
+func DeviceToken(cfg DeviceConfig) httpsim.Flow {
+	return httpsim.Flow{
+		RequiredValues:  []string{"device_code"},
+		SensitiveValues: []string{"access_token", "refresh_token"},
+		Steps: []httpsim.Step{{
+			Name: "token",
+			Request: httpsim.Request{URL: cfg.TokenURL, Method: "POST", Header: formHeader(),
+				Body: url.Values{"client_id": {cfg.ClientID}, "device_code": {"{{.device_code}}"},
+					"grant_type": {"urn:ietf:params:oauth:grant-type:device_code"}}},
+			KeysInput: []string{"device_code"},
+			// the pending authorizations are 400s
+			AnyStatus: true,
+			KeysOutput: []httpsim.Extracter{
+				httpsim.JSONExtractable{Name: "error", Path: "error", IgnoreNotFound: true},
+				httpsim.JSONExtractable{Name: "access_token", Path: "access_token", IgnoreNotFound: true},
+				httpsim.JSONExtractable{Name: "refresh_token", Path: "refresh_token", IgnoreNotFound: true},
+			},
+		}},
+	}
+}
+
+// DeviceLogin runs the whole grant: it requests the codes, calls approve with
+// them (e.g. to drive the provider's approval page, or show them to a user),
+// then polls the token endpoint until the user approved. It returns the
+// values of the last poll, with the access_token.
+func DeviceLogin(ctx context.Context, cfg DeviceConfig, approve func(userCode, verificationURI string) error) (map[string]interface{}, error) {
+	auth := DeviceAuthorization(cfg)
+	if err := auth.ExecuteContext(ctx, nil); err != nil {
+		return nil, err
+	}
+	if err := approve(fmt.Sprint(auth.Values["user_code"]), fmt.Sprint(auth.Values["verification_uri"])); err != nil {
+		return nil, err
+	}
+	interval := cfg.Interval
+	if interval == 0 {
+		interval = 5 * time.Second
+		if s, err := strconv.Atoi(fmt.Sprint(auth.Values["interval"])); err == nil && s > 0 {
+			interval = time.Duration(s) * time.Second
+		}
+	}
+	for {
+		poll := DeviceToken(cfg)
+		if err := poll.ExecuteContext(ctx, map[string]interface{}{"device_code": auth.Values["device_code"]}); err != nil {
+			return nil, err
+		}
+		switch e := poll.Values["error"]; e {
+		case "":
+			return poll.Values, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return poll.Values, fmt.Errorf("device authorization failed: %s", e)
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
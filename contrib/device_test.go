@@ -0,0 +1,72 @@
+package contrib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceLogin(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		approved bool
+		polls    int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.ParseForm()
+		reply := func(status int, v map[string]interface{}) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(v)
+		}
+		switch r.URL.Path {
+		case "/device/code":
+			assert.Equal(t, "cli", r.PostForm.Get("client_id"))
+			reply(http.StatusOK, map[string]interface{}{"device_code": "dc1", "user_code": "WDJB-MJHT",
+				"verification_uri": "https://example.com/device", "interval": 5})
+		case "/token":
+			assert.Equal(t, "dc1", r.PostForm.Get("device_code"))
+			assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", r.PostForm.Get("grant_type"))
+			polls++
+			if polls == 2 {
+				approved = true
+			}
+			if !approved {
+				reply(http.StatusBadRequest, map[string]interface{}{"error": "authorization_pending"})
+				return
+			}
+			reply(http.StatusOK, map[string]interface{}{"access_token": "at1", "token_type": "bearer"})
+		}
+	}))
+	defer srv.Close()
+
+	cfg := DeviceConfig{DeviceAuthorizationURL: srv.URL + "/device/code", TokenURL: srv.URL + "/token",
+		ClientID: "cli", Scope: "repo", Interval: 10 * time.Millisecond}
+	var userCode string
+	values, err := DeviceLogin(context.Background(), cfg, func(code, uri string) error {
+		userCode = code
+		assert.Equal(t, "https://example.com/device", uri)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "WDJB-MJHT", userCode)
+	assert.Equal(t, "at1", values["access_token"])
+	assert.Equal(t, 2, polls)
+
+	// denied
+	mu.Lock()
+	approved, polls = false, -10
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err = DeviceLogin(ctx, cfg, func(string, string) error { return nil })
+	assert.NotNil(t, err)
+}
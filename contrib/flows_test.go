@@ -0,0 +1,115 @@
+package contrib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gee-m/httpsim"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadFlow loads the YAML example flow of the flows directory
+func loadFlow(t *testing.T, name string) *httpsim.Flow {
+	b, err := ioutil.ReadFile(filepath.Join("flows", name))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	f, err := httpsim.LoadFlow(b)
+	if !assert.Nil(t, err, name) {
+		t.FailNow()
+	}
+	return f
+}
+
+func TestFlows_Load(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join("flows", "*.yaml"))
+	assert.Nil(t, err)
+	assert.Len(t, names, 5)
+	for _, name := range names {
+		loadFlow(t, filepath.Base(name))
+	}
+}
+
+func TestFlows_Device(t *testing.T) {
+	approved := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		assert.Equal(t, "cli", r.PostForm.Get("client_id"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device/code":
+			assert.Equal(t, "repo", r.PostForm.Get("scope"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"device_code": "dc1", "user_code": "WDJB-MJHT",
+				"verification_uri": "https://example.com/device"})
+		case "/token":
+			assert.Equal(t, "dc1", r.PostForm.Get("device_code"))
+			if !approved {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": "authorization_pending"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "at1", "refresh_token": "rt1"})
+		}
+	}))
+	defer srv.Close()
+
+	auth := loadFlow(t, "device_authorization.yaml")
+	assert.Nil(t, auth.Execute(map[string]interface{}{"device_authorization_url": srv.URL + "/device/code",
+		"client_id": "cli", "scope": "repo"}))
+	assert.Equal(t, "WDJB-MJHT", auth.Values["user_code"])
+	assert.Equal(t, "https://example.com/device", auth.Values["verification_uri"])
+
+	values := map[string]interface{}{"token_url": srv.URL + "/token", "client_id": "cli", "device_code": auth.Values["device_code"]}
+	poll := loadFlow(t, "device_token.yaml")
+	assert.Nil(t, poll.Execute(values))
+	assert.Equal(t, "authorization_pending", poll.Values["error"])
+
+	approved = true
+	poll = loadFlow(t, "device_token.yaml")
+	assert.Nil(t, poll.Execute(values))
+	assert.Equal(t, "at1", poll.Values["access_token"])
+	assert.Equal(t, "rt1", poll.Values["refresh_token"])
+}
+
+func TestFlows_SSO(t *testing.T) {
+	oidc := newIdP(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/app/callback?code=c1&state=s1", http.StatusFound)
+	})
+	defer oidc.Close()
+	var saml *httptest.Server
+	saml = newIdP(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<form method="post" action="%s/app/callback">
+			<input type="hidden" name="SAMLResponse" value="PHNhbWw+"><input type="hidden" name="RelayState" value="/"></form>`, saml.URL)
+	})
+	defer saml.Close()
+
+	for name, srv := range map[string]*httptest.Server{"oidc_login.yaml": oidc, "saml_login.yaml": saml} {
+		f := loadFlow(t, name)
+		assert.Nil(t, f.Execute(map[string]interface{}{"login_url": srv.URL + "/app/login", "user": "bob", "password": "secret"}), name)
+		assert.Equal(t, "Welcome bob", string(f.Steps[len(f.Steps)-1].Response.Body), name)
+
+		f = loadFlow(t, name)
+		assert.NotNil(t, f.Execute(map[string]interface{}{"login_url": srv.URL + "/app/login", "user": "bob", "password": "wrong"}), name)
+	}
+}
+
+func TestFlows_ShopCheckout(t *testing.T) {
+	shop := NewDemoShop()
+	srv := httptest.NewServer(shop)
+	defer srv.Close()
+
+	f := loadFlow(t, "shop_checkout.yaml")
+	assert.Nil(t, f.Execute(map[string]interface{}{"site": srv.URL, "quantity": 2, "email": "ada@example.com",
+		"name": "Ada O'Brien"}))
+	orders := shop.Orders()
+	if assert.Len(t, orders, 1) {
+		assert.Equal(t, DemoOrder{ID: "o1", Email: "ada@example.com", Name: "Ada O'Brien", Total: 2500}, orders[0])
+	}
+	assert.Equal(t, "2500", f.Values["total"])
+	assert.Equal(t, f.Values["cart_total"], f.Values["total"])
+}
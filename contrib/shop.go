@@ -0,0 +1,180 @@
+package contrib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gee-m/httpsim"
+)
+
+// DemoShop is a small in-memory shop JSON API to run ShopCheckout against, in
+// examples and tests:
+//
+//	GET  /api/products   {"products": [{"id", "name", "price"}...]}
+//	POST /api/cart       adds {"product", "quantity"} to the session's cart
+//	POST /api/checkout   orders the cart for {"email", "name"}, returns {"order", "total"}
+//
+// Prices are in cents in the JSON, the session is the "cart" cookie.
+type DemoShop struct {
+	mu     sync.Mutex
+	carts  map[string]map[string]int
+	orders []DemoOrder
+}
+
+// DemoOrder is an order placed on the DemoShop
+type DemoOrder struct {
+	ID    string
+	Email string
+	Name  string
+	// Total is in cents
+	Total int
+}
+
+// demoProduct is a product of the DemoShop
+type demoProduct struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+var demoProducts = []demoProduct{{"mug", "Mug", 1250}, {"tee", "T-shirt", 1999}, {"cap", "Cap", 1500}}
+
+// NewDemoShop creates an empty demo shop
+func NewDemoShop() *DemoShop {
+	return &DemoShop{carts: map[string]map[string]int{}}
+}
+
+// Orders returns the orders placed
+func (s *DemoShop) Orders() []DemoOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DemoOrder(nil), s.orders...)
+}
+
+// ServeHTTP serves the shop's API
+func (s *DemoShop) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply := func(status int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(status int, msg string) {
+		reply(status, map[string]string{"error": msg})
+	}
+
+	var cart map[string]int
+	if c, err := r.Cookie("cart"); err == nil {
+		cart = s.carts[c.Value]
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/products":
+		reply(http.StatusOK, map[string]interface{}{"products": demoProducts})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/cart":
+		var item struct {
+			Product  string `json:"product"`
+			Quantity int    `json:"quantity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&item); err != nil || item.Quantity <= 0 || demoPrice(item.Product) == 0 {
+			fail(http.StatusBadRequest, "invalid item")
+			return
+		}
+		if cart == nil {
+			id := fmt.Sprintf("c%d", len(s.carts)+1)
+			cart = map[string]int{}
+			s.carts[id] = cart
+			http.SetCookie(w, &http.Cookie{Name: "cart", Value: id, Path: "/"})
+		}
+		cart[item.Product] += item.Quantity
+		reply(http.StatusOK, map[string]interface{}{"total": demoTotal(cart)})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/checkout":
+		var buyer struct {
+			Email string `json:"email"`
+			Name  string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&buyer); err != nil || !strings.Contains(buyer.Email, "@") || buyer.Name == "" {
+			fail(http.StatusBadRequest, "invalid buyer")
+			return
+		}
+		if len(cart) == 0 {
+			fail(http.StatusConflict, "empty cart")
+			return
+		}
+		order := DemoOrder{ID: fmt.Sprintf("o%d", len(s.orders)+1), Email: buyer.Email, Name: buyer.Name, Total: demoTotal(cart)}
+		s.orders = append(s.orders, order)
+		for k := range cart {
+			delete(cart, k)
+		}
+		reply(http.StatusOK, map[string]interface{}{"order": order.ID, "total": order.Total})
+	default:
+		fail(http.StatusNotFound, "not found")
+	}
+}
+
+// demoPrice returns the price of the product, 0 when there's none
+func demoPrice(id string) int {
+	for _, p := range demoProducts {
+		if p.ID == id {
+			return p.Price
+		}
+	}
+	return 0
+}
+
+// demoTotal returns the total of the cart in cents
+func demoTotal(cart map[string]int) int {
+	total := 0
+	for id, quantity := range cart {
+		total += demoPrice(id) * quantity
+	}
+	return total
+}
+
+// ShopCheckout buys a random quantity of the first product of the DemoShop at
+// baseURL, as a random buyer: the email, name and quantity values are
+// generated when not given (see Flow.Generators). It extracts the order and
+// its total, checked against the cart's.
+func ShopCheckout(baseURL string) httpsim.Flow {
+	header := http.Header{"Content-Type": {"application/json"}}
+	return httpsim.Flow{
+		Generators: map[string]httpsim.Generator{
+			"name":     httpsim.RandomName(),
+			"email":    httpsim.RandomEmail("", "name"),
+			"quantity": httpsim.RandomInt(1, 3),
+		},
+		Steps: []httpsim.Step{
+			{
+				Name:       "products",
+				Request:    httpsim.Request{URL: baseURL + "/api/products", Method: "GET"},
+				KeysOutput: []httpsim.Extracter{httpsim.JSONExtractable{Name: "product", Path: "products[0].id"}},
+			},
+			{
+				Name: "add to cart",
+				Request: httpsim.Request{URL: baseURL + "/api/cart", Method: "POST", Header: header,
+					Body: `{"product": "{{.product}}", "quantity": {{.quantity}}}`},
+				KeysInput:  []string{"product", "quantity"},
+				KeysOutput: []httpsim.Extracter{httpsim.JSONExtractable{Name: "cart_total", Path: "total"}},
+			},
+			{
+				Name: "checkout",
+				Request: httpsim.Request{URL: baseURL + "/api/checkout", Method: "POST", Header: header,
+					Body: `{"email": "{{.email}}", "name": {{printf "%q" .name}}}`},
+				KeysInput: []string{"email", "name"},
+				KeysOutput: []httpsim.Extracter{
+					httpsim.JSONExtractable{Name: "order", Path: "order"},
+					httpsim.JSONExtractable{Name: "total", Path: "total"},
+				},
+				Invariants: []httpsim.Invariant{{Name: "total == cart_total", Check: func(values map[string]interface{}) error {
+					if values["total"] != values["cart_total"] {
+						return fmt.Errorf("%v != %v", values["total"], values["cart_total"])
+					}
+					return nil
+				}}},
+			},
+		},
+	}
+}
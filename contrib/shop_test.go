@@ -0,0 +1,33 @@
+package contrib
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShopCheckout(t *testing.T) {
+	shop := NewDemoShop()
+	srv := httptest.NewServer(shop)
+	defer srv.Close()
+
+	f := ShopCheckout(srv.URL)
+	assert.Nil(t, f.Execute(nil))
+	orders := shop.Orders()
+	assert.Len(t, orders, 1)
+	assert.Equal(t, f.Values["order"], orders[0].ID)
+	assert.Equal(t, f.Values["email"], orders[0].Email)
+	assert.Equal(t, f.Values["name"], orders[0].Name)
+	assert.Contains(t, []string{"1250", "2500", "3750"}, f.Values["total"])
+
+	// a generated buyer is reproducible with the seed
+	again := ShopCheckout(srv.URL)
+	again.GeneratorSeed = f.GeneratedSeed
+	assert.Nil(t, again.Execute(map[string]interface{}{"name": "Ada O'Brien", "quantity": 2}))
+	orders = shop.Orders()
+	assert.Len(t, orders, 2)
+	assert.Equal(t, "Ada O'Brien", orders[1].Name)
+	assert.Regexp(t, `^ada\.obrien\.[0-9]{4}@example\.com$`, orders[1].Email)
+	assert.Equal(t, "2500", again.Values["total"])
+}
@@ -0,0 +1,96 @@
+package contrib
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gee-m/httpsim"
+)
+
+// SSOConfig configures the SSO logins through an identity provider's login form
+type SSOConfig struct {
+	// LoginURL is the app's page redirecting to the identity provider
+	LoginURL string
+	// Form is the CSS selector of the provider's login form, "form" when empty
+	Form string
+	// Action, if set, is the URL the form is posted to. The form's action,
+	// which must then be absolute (as Keycloak's), is used when empty.
+	Action string
+	// UserField and PasswordField are the names of the form's fields,
+	// "username" and "password" when empty
+	UserField     string
+	PasswordField string
+	// Failed are the messages of the provider's failed logins e.g. "Invalid
+	// username or password."
+	Failed []string
+}
+
+// signIn returns the steps getting the provider's login form and posting it
+// with the user and password values, with its hidden fields (state...)
+func (cfg SSOConfig) signIn() []httpsim.Step {
+	form, userField, passwordField := cfg.Form, cfg.UserField, cfg.PasswordField
+	if form == "" {
+		form = "form"
+	}
+	if userField == "" {
+		userField = "username"
+	}
+	if passwordField == "" {
+		passwordField = "password"
+	}
+	login := httpsim.Step{
+		Name:    "login page",
+		Request: httpsim.Request{URL: cfg.LoginURL, Method: "GET"},
+	}
+	signIn := httpsim.Step{
+		Name: "sign in",
+		Request: httpsim.Request{URL: cfg.Action, Method: "POST",
+			Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			Body:   url.Values{userField: {"{{.user}}"}, passwordField: {"{{.password}}"}}},
+		KeysInput:    []string{"user", "password"},
+		HiddenFields: true,
+		Forbid:       cfg.Failed,
+	}
+	if cfg.Action == "" {
+		login.KeysOutput = []httpsim.Extracter{httpsim.HTMLExtractable{Name: "login_action", Selector: form, Attr: "action"}}
+		signIn.Request.URL = "{{.login_action}}"
+		signIn.KeysInput = append(signIn.KeysInput, "login_action")
+	}
+	return []httpsim.Step{login, signIn}
+}
+
+// OIDCLogin logs the user and password values in an app through an OpenID
+// Connect provider (authorization code flow): the app redirects to the
+// provider's login form, which redirects back to the app's callback once
+// posted. The app's session cookies are then in the flow's CookieJar.
+func OIDCLogin(cfg SSOConfig) httpsim.Flow {
+	return httpsim.Flow{
+		RequiredValues:  []string{"user", "password"},
+		SensitiveValues: []string{"password"},
+		Steps:           cfg.signIn(),
+	}
+}
+
+// SAMLLogin logs the user and password values in an app through a SAML
+// identity provider (HTTP POST binding): once the login form is posted, the
+// provider's page auto-submits the SAMLResponse and RelayState to the app's
+// assertion consumer service, which the flow posts as a browser would.
+func SAMLLogin(cfg SSOConfig) httpsim.Flow {
+	steps := cfg.signIn()
+	steps[1].KeysOutput = []httpsim.Extracter{
+		httpsim.HTMLExtractable{Name: "acs_url", Selector: "form", Attr: "action"},
+	}
+	steps = append(steps, httpsim.Step{
+		Name: "assertion",
+		Request: httpsim.Request{URL: "{{.acs_url}}", Method: "POST",
+			Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}},
+		KeysInput: []string{"acs_url"},
+		// the SAMLResponse and RelayState are the form's hidden fields
+		HiddenFields: true,
+	})
+	return httpsim.Flow{
+		RequiredValues:  []string{"user", "password"},
+		SensitiveValues: []string{"password"},
+		Steps:           steps,
+	}
+}
@@ -0,0 +1,86 @@
+package contrib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newIdP serves an app logging in through an identity provider's login form,
+// returning the provider's page once the user is authenticated
+func newIdP(t *testing.T, authenticated func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/login":
+			http.Redirect(w, r, "/idp/auth?state=s1", http.StatusFound)
+		case "/idp/auth":
+			fmt.Fprintf(w, `<form method="post" action="%s/idp/login"><input type="hidden" name="state" value="%s">
+				<input name="username"><input type="password" name="password"></form>`, srv.URL, r.URL.Query().Get("state"))
+		case "/idp/login":
+			r.ParseForm()
+			if r.PostForm.Get("username") != "bob" || r.PostForm.Get("password") != "secret" || r.PostForm.Get("state") != "s1" {
+				fmt.Fprint(w, "Invalid username or password.")
+				return
+			}
+			authenticated(w, r)
+		case "/app/callback":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: r.FormValue("code") + r.FormValue("SAMLResponse"), Path: "/"})
+			fmt.Fprint(w, "Welcome bob")
+		case "/app/me":
+			c, err := r.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, c.Value)
+		}
+	}))
+	return srv
+}
+
+func TestOIDCLogin(t *testing.T) {
+	srv := newIdP(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/app/callback?code=c1&state=s1", http.StatusFound)
+	})
+	defer srv.Close()
+
+	f := OIDCLogin(SSOConfig{LoginURL: srv.URL + "/app/login", Failed: []string{"Invalid username or password."}})
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "bob", "password": "secret"}))
+	assert.Equal(t, "Welcome bob", string(f.Steps[1].Response.Body))
+	resp, err := (&http.Client{Jar: f.CookieJar}).Get(srv.URL + "/app/me")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	f = OIDCLogin(SSOConfig{LoginURL: srv.URL + "/app/login", Failed: []string{"Invalid username or password."}})
+	err = f.Execute(map[string]interface{}{"user": "bob", "password": "wrong"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, f.Failure().Step)
+
+	f = OIDCLogin(SSOConfig{LoginURL: srv.URL + "/app/login", Action: srv.URL + "/idp/login"})
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "bob", "password": "secret"}))
+	assert.Len(t, f.Steps[0].KeysOutput, 0)
+}
+
+func TestSAMLLogin(t *testing.T) {
+	var srv *httptest.Server
+	srv = newIdP(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<body onload="document.forms[0].submit()"><form method="post" action="%s/app/callback">
+			<input type="hidden" name="SAMLResponse" value="PHNhbWw+"><input type="hidden" name="RelayState" value="/"></form>`, srv.URL)
+	})
+	defer srv.Close()
+
+	f := SAMLLogin(SSOConfig{LoginURL: srv.URL + "/app/login"})
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "bob", "password": "secret"}))
+	assert.Equal(t, srv.URL+"/app/callback", f.Values["acs_url"])
+	assert.Equal(t, "Welcome bob", string(f.Steps[2].Response.Body))
+	assert.Empty(t, f.Warnings)
+	resp, err := (&http.Client{Jar: f.CookieJar}).Get(srv.URL + "/app/me")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
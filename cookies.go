@@ -0,0 +1,70 @@
+package httpsim
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// CookieTemplate is a cookie sent with a request, its Value is a template like
+// the URL's. It's merged with the cookies of the jar in the request's single
+// Cookie header, replacing the jar's cookie of the same name.
+type CookieTemplate struct {
+	Name  string
+	Value string
+}
+
+// ReplaceInCookies replaces the needed values in the request's cookies
+func (s *Step) ReplaceInCookies(vals map[string]interface{}, stepNb int) error {
+	if len(s.Request.Cookies) == 0 {
+		return nil
+	}
+	// Don't modify the step's definition for the next runs
+	cookies := make([]CookieTemplate, len(s.Request.Cookies))
+	for i, c := range s.Request.Cookies {
+		tpl, err := s.Delims.parse(c.Value)
+		if err != nil {
+			return fmt.Errorf("Step %d.'%s' cookie %s: %s", stepNb, s.Name, c.Name, err.Error())
+		}
+		var buffer bytes.Buffer
+		if err := tpl.Execute(&buffer, vals); err != nil {
+			return fmt.Errorf("Step %d.'%s' cookie %s: %s", stepNb, s.Name, c.Name, err.Error())
+		}
+		cookies[i] = CookieTemplate{Name: c.Name, Value: buffer.String()}
+	}
+	s.Request.Cookies = cookies
+	return nil
+}
+
+// overriddenJar is a jar whose cookies of the overridden names aren't sent
+type overriddenJar struct {
+	http.CookieJar
+	names map[string]bool
+}
+
+func (j overriddenJar) Cookies(u *url.URL) []*http.Cookie {
+	var cookies []*http.Cookie
+	for _, c := range j.CookieJar.Cookies(u) {
+		if !j.names[c.Name] {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+// addCookies adds the request's cookies to req, the client sending the jar's
+// other ones in the same Cookie header
+func (r *Request) addCookies(req *http.Request, client *http.Client) {
+	if len(r.Cookies) == 0 {
+		return
+	}
+	names := map[string]bool{}
+	for _, c := range r.Cookies {
+		names[c.Name] = true
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	if client.Jar != nil {
+		client.Jar = overriddenJar{CookieJar: client.Jar, names: names}
+	}
+}
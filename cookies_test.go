@@ -0,0 +1,61 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_Cookies(t *testing.T) {
+	var got [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header["Cookie"])
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			http.SetCookie(w, &http.Cookie{Name: "consent", Value: "no"})
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "GET"}},
+		{
+			Name: "page",
+			Request: Request{URL: srv.URL + "/page", Method: "GET",
+				Cookies: []CookieTemplate{{Name: "consent", Value: "{{.consent}}"}, {Name: "theme", Value: "dark"}}},
+			KeysInput: []string{"consent"},
+		},
+		{Name: "again", Request: Request{URL: srv.URL + "/page", Method: "GET"}},
+	}}
+	assert.Nil(t, f.Compile())
+	assert.Nil(t, f.Execute(map[string]interface{}{"consent": "yes"}))
+	assert.Len(t, got, 3)
+	assert.Nil(t, got[0])
+	// one header, the jar's consent replaced
+	assert.Equal(t, []string{"consent=yes; theme=dark; session=s1"}, got[1])
+	assert.Equal(t, []string{"session=s1; consent=no"}, got[2])
+	assert.Equal(t, "{{.consent}}", f.Steps[1].Request.Cookies[0].Value)
+
+	f.Steps[1].Request.Cookies[0].Value = "{{.consent"
+	assert.NotNil(t, f.Compile())
+}
+
+func TestStep_NoCookies(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Cookie"))
+		http.SetCookie(w, &http.Cookie{Name: r.URL.Path[1:], Value: "1"})
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/session", Method: "GET"}},
+		{Name: "cdn", Request: Request{URL: srv.URL + "/tracker", Method: "GET",
+			Cookies: []CookieTemplate{{Name: "explicit", Value: "1"}}}, NoCookies: true},
+		{Name: "page", Request: Request{URL: srv.URL + "/page", Method: "GET"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{"", "explicit=1", "session=1"}, got)
+}
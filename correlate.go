@@ -0,0 +1,188 @@
+package httpsim
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Correlation is a value of a recorded response that later requests reuse (a
+// token, a nonce, an ID): it's extracted from the response and templated in the
+// requests instead of being replayed
+type Correlation struct {
+	// Name is the name of the value, guessed from its context in the response
+	Name string
+	// Value is the recorded value
+	Value string
+	// Step is the step whose response has the value, Uses the steps using it
+	Step int
+	Uses []int
+	// LowConfidence flags the correlations to review, Reason tells why
+	LowConfidence bool
+	Reason        string
+}
+
+var (
+	// correlationToken matches the values that may be correlated
+	correlationToken = regexp.MustCompile(`[A-Za-z0-9_\-.~+/%]{6,}={0,2}`)
+	// correlationKey matches the key of the value the context ends with e.g.
+	// `"token": "`, `name="csrf" value="` or `id=`
+	correlationKey   = regexp.MustCompile(`(?:name=["']([\w.-]+)["'][^<>]*?value=["']|["']?([A-Za-z_][\w.-]*)["']?\s*[:=]\s*["']?)$`)
+	correlationDigit = regexp.MustCompile(`[0-9]`)
+	correlationAlpha = regexp.MustCompile(`[A-Za-z]`)
+)
+
+// correlatable returns whether the token looks like a generated value rather
+// than a word or a path
+func correlatable(token string) bool {
+	if strings.Count(token, "/") > 1 || strings.HasPrefix(token, "http") {
+		return false
+	}
+	return correlationDigit.MatchString(token) && correlationAlpha.MatchString(token) || len(token) >= 16
+}
+
+// harText returns the requests' text, where values may be reused
+func harText(e harEntry) string {
+	var b strings.Builder
+	b.WriteString(e.Request.URL)
+	for _, h := range e.Request.Headers {
+		b.WriteString("\n" + h.Value)
+	}
+	if e.Request.PostData != nil {
+		b.WriteString("\n" + e.Request.PostData.Text)
+	}
+	return b.String()
+}
+
+// harBody returns the recorded response body
+func harBody(e harEntry) string {
+	if e.Response.Content.Encoding == "base64" {
+		b, err := base64.StdEncoding.DecodeString(e.Response.Content.Text)
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	return e.Response.Content.Text
+}
+
+// containsValue returns whether the request text has the value, raw or escaped
+func containsValue(text, value string) bool {
+	return strings.Contains(text, value) || strings.Contains(text, url.QueryEscape(value))
+}
+
+// correlate detects the values of the responses that later requests reuse, and
+// extracts and templates them in the steps (one per entry)
+func correlate(entries []harEntry, steps []Step) []Correlation {
+	requests := make([]string, len(entries))
+	for i, e := range entries {
+		requests[i] = harText(e)
+	}
+
+	var found []Correlation
+	seen := map[string]bool{}
+	names := map[string]int{}
+	for i, e := range entries {
+		body := harBody(e)
+		for _, loc := range correlationToken.FindAllStringIndex(body, -1) {
+			value := body[loc[0]:loc[1]]
+			if seen[value] || !correlatable(value) {
+				continue
+			}
+			seen[value] = true
+			// the values sent before the response are inputs, not correlations
+			sent := false
+			for j := 0; j <= i && !sent; j++ {
+				sent = containsValue(requests[j], value)
+			}
+			if sent {
+				continue
+			}
+			c := Correlation{Value: value, Step: i}
+			for j := i + 1; j < len(entries); j++ {
+				if containsValue(requests[j], value) {
+					c.Uses = append(c.Uses, j)
+				}
+			}
+			if len(c.Uses) == 0 {
+				continue
+			}
+
+			before := body[:loc[0]]
+			if nl := strings.LastIndexByte(before, '\n'); nl != -1 {
+				before = before[nl+1:]
+			}
+			if len(before) > 32 {
+				before = before[len(before)-32:]
+			}
+			c.Name = "value"
+			if m := correlationKey.FindStringSubmatch(before); m != nil {
+				c.Name = m[1] + m[2]
+			}
+			if names[c.Name]++; names[c.Name] > 1 {
+				c.Name = fmt.Sprintf("%s%d", c.Name, names[c.Name])
+			}
+			after := ""
+			if loc[1] < len(body) {
+				after = body[loc[1] : loc[1]+1]
+			}
+			e := Extractable{Name: c.Name, AfterThis: before, BeforeThis: after, MaxLength: -1, MinLength: -1}
+
+			switch _, got, err := e.Extract(body, nil); {
+			case before == "" || after == "":
+				c.LowConfidence, c.Reason = true, "the value isn't delimited in the response"
+			case err != nil || got != value:
+				c.LowConfidence, c.Reason = true, "the delimiters extract another value first"
+			case len(value) < 8:
+				c.LowConfidence, c.Reason = true, "the value is short, it may be a coincidence"
+			}
+			found = append(found, c)
+			steps[i].KeysOutput = append(steps[i].KeysOutput, e)
+		}
+	}
+
+	// the longest values first, so shorter ones aren't replaced inside them
+	ordered := append([]Correlation(nil), found...)
+	sort.SliceStable(ordered, func(i, j int) bool { return len(ordered[i].Value) > len(ordered[j].Value) })
+	for _, c := range ordered {
+		for _, j := range c.Uses {
+			templateValue(&steps[j], c.Name, c.Value)
+		}
+	}
+	return found
+}
+
+// templateValue replaces the value in the step's request by the template of name
+func templateValue(step *Step, name, value string) {
+	placeholder := templatePlaceholder(name)
+	replace := func(s string) string {
+		s = strings.Replace(s, value, placeholder, -1)
+		if escaped := url.QueryEscape(value); escaped != value {
+			s = strings.Replace(s, escaped, placeholder, -1)
+		}
+		return s
+	}
+	step.Request.URL = replace(step.Request.URL)
+	header := http.Header{}
+	for k, vs := range step.Request.Header {
+		for _, v := range vs {
+			header.Add(k, replace(v))
+		}
+	}
+	if len(header) != 0 {
+		step.Request.Header = header
+	}
+	if body, ok := step.Request.Body.(string); ok && step.templatedBody() {
+		step.Request.Body = replace(body)
+	}
+	for _, k := range step.KeysInput {
+		if k == name {
+			return
+		}
+	}
+	step.KeysInput = append(step.KeysInput, name)
+}
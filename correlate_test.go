@@ -0,0 +1,65 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportHAR_Correlations(t *testing.T) {
+	c, err := ImportHAR([]byte(testHAR), &HostFilter{Block: NoiseHosts})
+	assert.Nil(t, err)
+	assert.Equal(t, []Correlation{
+		{Name: "csrf", Value: "c5rfT0ken42", Step: 0, Uses: []int{1}},
+		{Name: "session", Value: "s3ss10nId99", Step: 1, Uses: []int{2}},
+	}, c.Correlations)
+
+	steps := c.Flow.Steps
+	assert.Equal(t, []Extracter{Extractable{Name: "csrf", AfterThis: `<input name="csrf" value="`, BeforeThis: `"`,
+		MaxLength: -1, MinLength: -1}}, steps[0].KeysOutput)
+	assert.Equal(t, "user=bob&csrf={{.csrf}}", steps[1].Request.Body)
+	assert.Equal(t, []string{"csrf"}, steps[1].KeysInput)
+	assert.Equal(t, "https://shop.example.com/account?session={{.session}}", steps[2].Request.URL)
+	assert.Equal(t, []string{"session"}, steps[2].KeysInput)
+}
+
+func TestCorrelate_LowConfidence(t *testing.T) {
+	entries := make([]harEntry, 2)
+	entries[0].Response.Content.Text = `a=x1y2z3;b=x1y2z3;c=Tok3nValueXY`
+	entries[1].Request.URL = "https://example.com/?a=x1y2z3&c=Tok3nValueXY"
+	steps := make([]Step, 2)
+	steps[1].Request.URL = entries[1].Request.URL
+	found := correlate(entries, steps)
+	assert.Len(t, found, 2)
+	assert.True(t, found[0].LowConfidence)
+	assert.Equal(t, "the value is short, it may be a coincidence", found[0].Reason)
+	assert.True(t, found[1].LowConfidence)
+	assert.Equal(t, "the value isn't delimited in the response", found[1].Reason)
+	assert.Equal(t, "https://example.com/?a={{.a}}&c={{.c}}", steps[1].Request.URL)
+}
+
+func TestImportHAR_Replay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.Write([]byte(`{"token": "fresh7Token9"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer fresh7Token9" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	har := strings.Replace(`{"log": {"entries": [
+  {"request": {"method": "POST", "url": "URL/login"}, "response": {"status": 200, "content": {"text": "{\"token\": \"rec0rdedTok3n\"}"}}},
+  {"request": {"method": "GET", "url": "URL/me", "headers": [{"name": "Authorization", "value": "Bearer rec0rdedTok3n"}]},
+   "response": {"status": 200, "content": {}}}
+]}}`, "URL", srv.URL, -1)
+	c, err := ImportHAR([]byte(har), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Flow.Execute(nil))
+	assert.Equal(t, "fresh7Token9", c.Flow.Values["token"])
+}
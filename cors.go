@@ -0,0 +1,156 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// CORS makes a step behave like a browser's cross-origin request: a preflight
+// OPTIONS request is sent first and the CORS response headers are checked
+type CORS struct {
+	// Origin is the origin of the page making the request e.g. "https://app.example.com"
+	Origin string
+	// Credentials is whether the request is made with credentials (cookies), the
+	// server must then allow them and not use wildcards
+	Credentials bool
+	// PreflightOnly only sends the preflight, the step's response is the preflight's
+	PreflightOnly bool
+}
+
+// CORSError is the error returned when a server's CORS headers would make a
+// browser block the request
+type CORSError struct {
+	Step     int
+	StepName string
+	// Preflight is whether the preflight's response failed, rather than the request's
+	Preflight bool
+	Reason    string
+}
+
+func (e *CORSError) Error() string {
+	what := "request"
+	if e.Preflight {
+		what = "preflight"
+	}
+	return fmt.Sprintf("Step %d.'%s' failed because CORS %s failed: %s", e.Step, e.StepName, what, e.Reason)
+}
+
+// corsSafelisted are the request headers that don't need to be allowed
+var corsSafelisted = map[string]bool{"accept": true, "accept-language": true, "content-language": true,
+	"content-type": true, "origin": true}
+
+// preflight returns the preflight request of r
+func (c *CORS) preflight(r Request) Request {
+	var names []string
+	for k := range r.Header {
+		if k = strings.ToLower(k); !corsSafelisted[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	h := http.Header{}
+	for _, k := range []string{"User-Agent", "Accept-Language"} {
+		if v := r.Header.Get(k); v != "" {
+			h.Set(k, v)
+		}
+	}
+	h.Set("Origin", c.Origin)
+	h.Set("Access-Control-Request-Method", r.Method)
+	if len(names) != 0 {
+		h.Set("Access-Control-Request-Headers", strings.Join(names, ","))
+	}
+	return Request{URL: r.URL, Method: http.MethodOptions, Header: h, IgnoreRedirects: true}
+}
+
+// checkOrigin checks the Access-Control-Allow-Origin and -Credentials headers
+func (c *CORS) checkOrigin(h http.Header) string {
+	allowed := h.Get("Access-Control-Allow-Origin")
+	switch {
+	case allowed == "":
+		return "no Access-Control-Allow-Origin"
+	case allowed == "*" && c.Credentials:
+		return "Access-Control-Allow-Origin is * for a request with credentials"
+	case allowed != "*" && allowed != c.Origin:
+		return fmt.Sprintf("Access-Control-Allow-Origin is %s, not %s", allowed, c.Origin)
+	case c.Credentials && h.Get("Access-Control-Allow-Credentials") != "true":
+		return "credentials aren't allowed"
+	}
+	return ""
+}
+
+// checkPreflight checks the preflight's response allows the request
+func (c *CORS) checkPreflight(pre Request, resp *http.Response) string {
+	if resp.StatusCode/100 != 2 {
+		return fmt.Sprintf("status %d", resp.StatusCode)
+	}
+	if reason := c.checkOrigin(resp.Header); reason != "" {
+		return reason
+	}
+	allows := func(header, value string) bool {
+		for _, v := range strings.Split(resp.Header.Get(header), ",") {
+			v = strings.TrimSpace(v)
+			if strings.EqualFold(v, value) || v == "*" && !c.Credentials {
+				return true
+			}
+		}
+		return false
+	}
+	method := pre.Header.Get("Access-Control-Request-Method")
+	if method != http.MethodGet && method != http.MethodHead && method != http.MethodPost &&
+		!allows("Access-Control-Allow-Methods", method) {
+		return fmt.Sprintf("method %s isn't allowed", method)
+	}
+	if names := pre.Header.Get("Access-Control-Request-Headers"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			if !allows("Access-Control-Allow-Headers", name) {
+				return fmt.Sprintf("header %s isn't allowed", name)
+			}
+		}
+	}
+	return ""
+}
+
+// doPreflight sends the step's preflight and checks its response. With
+// PreflightOnly, the step's request is replaced by the preflight instead.
+func (f *Flow) doPreflight(run *runState, i int, step *Step) error {
+	c := step.CORS
+	pre := c.preflight(step.Request)
+	if c.PreflightOnly {
+		step.Request = pre
+		return nil
+	}
+	// browsers don't send cookies with preflights
+	client := run.client
+	client.Jar = nil
+	run.budget.wrap(&client)
+	resp, err := pre.DoContext(run.requestCtx, client)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if reason := c.checkPreflight(pre, resp); reason != "" {
+		return &CORSError{Step: i, StepName: step.Name, Preflight: true, Reason: reason}
+	}
+	step.Request.Header.Set("Origin", c.Origin)
+	return nil
+}
+
+// CheckCORS checks the response of the step's request, or preflight, would be
+// accepted by a browser
+func (s *Step) CheckCORS(resp *http.Response, stepNb int) error {
+	if s.CORS == nil {
+		return nil
+	}
+	if s.CORS.PreflightOnly {
+		if reason := s.CORS.checkPreflight(s.Request, resp); reason != "" {
+			return &CORSError{Step: stepNb, StepName: s.Name, Preflight: true, Reason: reason}
+		}
+		return nil
+	}
+	if reason := s.CORS.checkOrigin(resp.Header); reason != "" {
+		return &CORSError{Step: stepNb, StepName: s.Name, Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteCORS(t *testing.T) {
+	var preflight http.Header
+	var actualOrigin string
+	allowOrigin := "https://app.example.com"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		if r.Method == http.MethodOptions {
+			preflight = r.Header
+			w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+			w.Header().Set("Access-Control-Allow-Headers", "X-Token")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		actualOrigin = r.Header.Get("Origin")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name: "api",
+		Request: Request{URL: srv.URL + "/api", Method: "PUT", Body: []byte("{}"), Header: http.Header{
+			"X-Token": {"t"}, "Content-Type": {"application/json"},
+		}},
+		CORS: &CORS{Origin: "https://app.example.com", Credentials: true},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "https://app.example.com", preflight.Get("Origin"))
+	assert.Equal(t, "PUT", preflight.Get("Access-Control-Request-Method"))
+	assert.Equal(t, "x-token", preflight.Get("Access-Control-Request-Headers"))
+	assert.Equal(t, "https://app.example.com", actualOrigin)
+	assert.Equal(t, `{"ok":true}`, string(f.Steps[0].Response.Body))
+
+	// header not allowed
+	f.Steps[0].Request.Header.Set("X-Other", "o")
+	err := f.Execute(nil)
+	assert.EqualError(t, err, "Step 0.'api' failed because CORS preflight failed: header x-other isn't allowed")
+	f.Steps[0].Request.Header.Del("X-Other")
+
+	// method not allowed
+	f.Steps[0].Request.Method = "DELETE"
+	assert.EqualError(t, f.Execute(nil), "Step 0.'api' failed because CORS preflight failed: method DELETE isn't allowed")
+
+	// preflight only
+	actualOrigin = ""
+	f.Steps[0].Request.Method = "PUT"
+	f.Steps[0].CORS.PreflightOnly = true
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "", actualOrigin)
+	assert.Equal(t, http.StatusNoContent, f.Steps[0].Response.Raw.StatusCode)
+
+	// wildcard with credentials
+	allowOrigin = "*"
+	err = f.Execute(nil)
+	assert.IsType(t, &CORSError{}, err)
+	assert.EqualError(t, err, "Step 0.'api' failed because CORS preflight failed: Access-Control-Allow-Origin is * for a request with credentials")
+	f.Steps[0].CORS = &CORS{Origin: "https://app.example.com"}
+	assert.Nil(t, f.Execute(nil))
+
+	// actual response checked too
+	allowOrigin = "https://other.example.com"
+	f.Steps[0].Request.Method = "GET"
+	f.Steps[0].Request.Header = nil
+	f.Steps[0].Request.Body = nil
+	err = f.Execute(nil)
+	assert.EqualError(t, err, "Step 0.'api' failed because CORS preflight failed: Access-Control-Allow-Origin is https://other.example.com, not https://app.example.com")
+}
@@ -0,0 +1,92 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// CredentialProvider resolves sensitive values (passwords, tokens...) when a flow
+// is executed, so secrets don't have to live in process arguments or files.
+type CredentialProvider interface {
+	Credential(key string) (string, error)
+}
+
+// CredentialFunc is an adapter to use an ordinary function as a CredentialProvider
+type CredentialFunc func(key string) (string, error)
+
+// Credential calls f(key)
+func (f CredentialFunc) Credential(key string) (string, error) {
+	return f(key)
+}
+
+// CredentialHelper is a CredentialProvider that calls an external credential helper
+// following the docker-credential-helpers protocol: `Program get` is run with the
+// server URL on stdin and prints {"ServerURL", "Username", "Secret"} on stdout.
+// The secret never appears in the helper's arguments.
+type CredentialHelper struct {
+	// Program is the helper executable e.g. docker-credential-osxkeychain
+	Program string
+	// Prefix is prepended to the value key to form the server URL the secret
+	// is stored under e.g. "httpsim://mybank/" -> "httpsim://mybank/password"
+	Prefix string
+}
+
+// NewKeychainHelper returns the CredentialHelper for the OS keychain
+// (osxkeychain, secretservice or wincred helper depending on the platform)
+func NewKeychainHelper(prefix string) *CredentialHelper {
+	prog := "docker-credential-secretservice"
+	switch runtime.GOOS {
+	case "darwin":
+		prog = "docker-credential-osxkeychain"
+	case "windows":
+		prog = "docker-credential-wincred"
+	}
+	return &CredentialHelper{Program: prog, Prefix: prefix}
+}
+
+// Credential asks the helper for the secret stored for key
+func (h *CredentialHelper) Credential(key string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(h.Program, "get")
+	cmd.Stdin = strings.NewReader(h.Prefix + key)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// helpers print their error message (e.g. "credentials not found in native keychain")
+		if msg := strings.TrimSpace(stdout.String() + stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", h.Program, msg)
+		}
+		return "", fmt.Errorf("%s: %s", h.Program, err.Error())
+	}
+	var creds struct {
+		ServerURL string
+		Username  string
+		Secret    string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return "", fmt.Errorf("%s: invalid output: %s", h.Program, err.Error())
+	}
+	return creds.Secret, nil
+}
+
+// resolveCredentials fills the missing SensitiveValues using f.Credentials
+func (f *Flow) resolveCredentials(values map[string]interface{}) error {
+	if f.Credentials == nil {
+		return nil
+	}
+	for _, k := range f.SensitiveValues {
+		if v, ok := values[k]; ok && v != "" {
+			continue
+		}
+		secret, err := f.Credentials.Credential(k)
+		if err != nil {
+			return fmt.Errorf("couldn't resolve sensitive value '%s': %s", k, err.Error())
+		}
+		values[k] = secret
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package httpsim
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteResolvesCredentials(t *testing.T) {
+	f := Flow{
+		RequiredValues:  []string{"username", "password"},
+		SensitiveValues: []string{"password"},
+		Credentials: CredentialFunc(func(key string) (string, error) {
+			if key == "password" {
+				return "hunter2", nil
+			}
+			return "", errors.New("unknown key")
+		}),
+	}
+	err := f.Execute(map[string]interface{}{"username": "bob"})
+	assert.Nil(t, err)
+	assert.Equal(t, "hunter2", f.Values["password"])
+
+	// given values take precedence
+	err = f.Execute(map[string]interface{}{"username": "bob", "password": "given"})
+	assert.Nil(t, err)
+	assert.Equal(t, "given", f.Values["password"])
+
+	f.Credentials = CredentialFunc(func(key string) (string, error) {
+		return "", errors.New("locked")
+	})
+	err = f.Execute(map[string]interface{}{"username": "bob"})
+	assert.EqualError(t, err, "couldn't resolve sensitive value 'password': locked")
+}
+
+func TestCredentialHelper_Credential(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	dir, err := ioutil.TempDir("", "httpsim")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	helper := filepath.Join(dir, "docker-credential-test")
+	script := `#!/bin/sh
+read url
+if [ "$url" = "httpsim://bank/password" ]; then
+	echo '{"ServerURL":"httpsim://bank/password","Username":"bob","Secret":"hunter2"}'
+else
+	echo "credentials not found in native keychain"
+	exit 1
+fi
+`
+	assert.Nil(t, ioutil.WriteFile(helper, []byte(script), 0700))
+
+	h := &CredentialHelper{Program: helper, Prefix: "httpsim://bank/"}
+	secret, err := h.Credential("password")
+	assert.Nil(t, err)
+	assert.Equal(t, "hunter2", secret)
+
+	_, err = h.Credential("pin")
+	assert.EqualError(t, err, helper+": credentials not found in native keychain")
+}
@@ -0,0 +1,100 @@
+package httpsim
+
+import (
+	"net/url"
+)
+
+// CSRFPolicy declares once how the CSRF token is obtained and sent: it's
+// extracted from every response that has it, so it's refreshed when the server
+// rotates it, and added to the requests that need it. The steps don't repeat
+// the extraction and injection.
+type CSRFPolicy struct {
+	// Extracter extracts the token from the response bodies (e.g. a meta tag),
+	// it's stored in the Values like an output. The responses it fails on keep
+	// the previous token.
+	Extracter Extracter
+	// Cookie, if set, is the cookie holding the token instead (double-submit,
+	// e.g. "XSRF-TOKEN"), read from the CookieJar for each request
+	Cookie string
+	// Header, if set, is the header the token is sent in (e.g. "X-CSRF-Token")
+	Header string
+	// Field, if set, is the field of the form bodies (url.Values) the token is
+	// sent in
+	Field string
+	// Methods are the methods of the requests the token is added to, the unsafe
+	// ones (POST, PUT, PATCH, DELETE...) when empty
+	Methods []string
+}
+
+// needsToken returns whether the token is added to the request
+func (c *CSRFPolicy) needsToken(r *Request) bool {
+	if len(c.Methods) == 0 {
+		return !safeMethod(r.Method)
+	}
+	return methodIn(r.Method, c.Methods, false)
+}
+
+// csrfToken returns the current token for the request, "" if there's none yet
+func (f *Flow) csrfToken(r *Request) string {
+	c := f.CSRF
+	if c.Cookie != "" {
+		u, err := url.Parse(r.URL)
+		if err != nil || f.CookieJar == nil {
+			return ""
+		}
+		for _, cookie := range f.CookieJar.Cookies(u) {
+			if cookie.Name == c.Cookie {
+				// cookie values are often escaped, e.g. by Angular's
+				if v, err := url.QueryUnescape(cookie.Value); err == nil {
+					return v
+				}
+				return cookie.Value
+			}
+		}
+		return ""
+	}
+	if c.Extracter == nil {
+		return ""
+	}
+	name := extracterName(c.Extracter)
+	if v, ok := f.Values[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// injectCSRF adds the CSRF token to the step's templated request, unless it
+// sets it itself
+func (f *Flow) injectCSRF(step *Step) {
+	c := f.CSRF
+	if c == nil || !c.needsToken(&step.Request) {
+		return
+	}
+	token := f.csrfToken(&step.Request)
+	if token == "" {
+		return
+	}
+	if c.Header != "" && step.Request.Header.Get(c.Header) == "" {
+		step.Request.Header.Set(c.Header, token)
+	}
+	if form, ok := step.Request.Body.(url.Values); ok && c.Field != "" {
+		if _, set := form[c.Field]; !set {
+			// the form is the step definition's
+			form = newBody(form).(url.Values)
+			form.Set(c.Field, token)
+			step.Request.Body = form
+		}
+	}
+}
+
+// refreshCSRF stores the token of the response body, when it has one
+func (f *Flow) refreshCSRF(body []byte) {
+	c := f.CSRF
+	if c == nil || c.Extracter == nil || len(body) == 0 {
+		return
+	}
+	name, token, err := withDelims(c.Extracter, f.Delims).Extract(string(body), f.Values)
+	if err == nil && name != "" && token != "" {
+		f.Values[name] = token
+	}
+}
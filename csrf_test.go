@@ -0,0 +1,80 @@
+package httpsim
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_CSRF(t *testing.T) {
+	token := 0
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			b, _ := io.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(b))
+			received = append(received, r.Header.Get("X-CSRF-Token")+"/"+form.Get("csrf"))
+		}
+		// the token rotates on every page but the API's
+		if r.URL.Path != "/api" {
+			token++
+			fmt.Fprintf(w, `<meta name="csrf" content="t%d">`, token)
+		}
+	}))
+	defer srv.Close()
+
+	form := url.Values{"item": {"1"}}
+	f := Flow{
+		CSRF: &CSRFPolicy{
+			Extracter: Extractable{Name: "csrf", AfterThis: `name="csrf" content="`, BeforeThis: `"`, MaxLength: -1, MinLength: -1},
+			Header:    "X-CSRF-Token",
+			Field:     "csrf",
+		},
+		Steps: []Step{
+			{Name: "home", Request: Request{URL: srv.URL + "/", Method: "GET"}},
+			{Name: "add", Request: Request{URL: srv.URL + "/cart", Method: "POST", Body: form}},
+			{Name: "api", Request: Request{URL: srv.URL + "/api", Method: "POST"}},
+			{Name: "pay", Request: Request{URL: srv.URL + "/pay", Method: "POST", Header: http.Header{"X-Csrf-Token": {"mine"}},
+				Body: url.Values{"csrf": {"mine"}}}},
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{"t1/t1", "t2/", "mine/mine"}, received)
+	assert.Equal(t, "t3", f.Values["csrf"])
+	// the step definitions are kept
+	assert.Equal(t, url.Values{"item": {"1"}}, form)
+
+	// the templated forms get the field too
+	received = nil
+	f.Steps[1].Request.Body = url.Values{"item": {"{{.item}}"}}
+	f.Steps[1].KeysInput = []string{"item"}
+	assert.Nil(t, f.Execute(map[string]interface{}{"item": "2"}))
+	assert.Equal(t, []string{"t4/t4", "t5/", "mine/mine"}, received)
+}
+
+func TestFlow_CSRFCookie(t *testing.T) {
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			http.SetCookie(w, &http.Cookie{Name: "XSRF-TOKEN", Value: "a%3Db", Path: "/"})
+			return
+		}
+		received = r.Header.Get("X-XSRF-TOKEN")
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		CSRF: &CSRFPolicy{Cookie: "XSRF-TOKEN", Header: "X-XSRF-TOKEN", Methods: []string{"PUT"}},
+		Steps: []Step{
+			{Name: "app", Request: Request{URL: srv.URL + "/", Method: "GET"}},
+			{Name: "save", Request: Request{URL: srv.URL + "/doc", Method: "PUT", Body: "{}"}},
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "a=b", received)
+}
@@ -0,0 +1,112 @@
+package httpsim
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CSVExtractable extracts a cell out of a CSV response. The column is picked by
+// index or by its Header, the row by index or by the value of another column
+// (MatchHeader/MatchValue), e.g. the amount of the transaction with a given reference.
+type CSVExtractable struct {
+	// Name is the name of the value extracted
+	Name string
+	// Header is the header (first row) of the column to extract, Column is used if empty
+	Header string
+	// Column is the index of the column to extract
+	Column int
+	// Row is the index of the row to extract, not counting the header row when
+	// headers are used. -1 is the last row.
+	Row int
+	// MatchHeader and MatchValue select the first row whose MatchHeader column equals
+	// MatchValue instead of using Row
+	MatchHeader string
+	MatchValue  string
+	// Templated renders the MatchValue as a template with the values, with the
+	// step's Delims
+	Templated bool
+	// Comma is the field delimiter, ',' when left 0
+	Comma rune
+
+	delims Delims
+}
+
+func (e CSVExtractable) withDelims(d Delims) Extracter {
+	e.delims = d
+	return e
+}
+
+// ExtractBinary extracts the cell, for CSVs served with a non text content type
+func (e CSVExtractable) ExtractBinary(body []byte, contentType string, v map[string]interface{}) (string, string, error) {
+	return e.Extract(string(body), v)
+}
+
+// Extract extracts the cell out of the CSV body
+func (e CSVExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	r := csv.NewReader(strings.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	if e.Comma != 0 {
+		r.Comma = e.Comma
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		return e.Name, "", err
+	}
+
+	col := e.Column
+	if e.Header != "" || e.MatchHeader != "" {
+		if len(rows) == 0 {
+			return e.Name, "", errors.New("no header row")
+		}
+		header := rows[0]
+		rows = rows[1:]
+		if e.Header != "" {
+			if col = csvColumn(header, e.Header); col == -1 {
+				return e.Name, "", fmt.Errorf("no column '%s'", e.Header)
+			}
+		}
+		if e.MatchHeader != "" {
+			match := csvColumn(header, e.MatchHeader)
+			if match == -1 {
+				return e.Name, "", fmt.Errorf("no column '%s'", e.MatchHeader)
+			}
+			value := e.MatchValue
+			if e.Templated && strings.Contains(value, e.delims.left()) {
+				if value, err = e.delims.replace(v, value); err != nil {
+					return e.Name, "", err
+				}
+			}
+			for _, row := range rows {
+				if match < len(row) && row[match] == value && col < len(row) {
+					return e.Name, row[col], nil
+				}
+			}
+			return e.Name, "", fmt.Errorf("no row with %s '%s'", e.MatchHeader, value)
+		}
+	}
+
+	row := e.Row
+	if row < 0 {
+		row += len(rows)
+	}
+	if row < 0 || row >= len(rows) {
+		return e.Name, "", fmt.Errorf("no row %d", e.Row)
+	}
+	if col < 0 || col >= len(rows[row]) {
+		return e.Name, "", fmt.Errorf("no column %d in row %d", col, e.Row)
+	}
+	return e.Name, rows[row][col], nil
+}
+
+// csvColumn returns the index of the header (trimmed, case insensitive) or -1
+func csvColumn(header []string, name string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), name) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,37 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVExtractable_Extract(t *testing.T) {
+	body := "Date;Reference;Amount\n2017-01-02;AB12;-10,00\n2017-01-03;CD34;250,00\n"
+
+	ex := CSVExtractable{Name: "amount", Comma: ';', Row: 1, Column: 2}
+	_, value, err := ex.Extract(body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "-10,00", value)
+
+	ex = CSVExtractable{Name: "amount", Comma: ';', Header: "amount", Row: -1}
+	_, value, err = ex.Extract(body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "250,00", value)
+
+	ex = CSVExtractable{Name: "date", Comma: ';', Header: "Date", MatchHeader: "Reference", MatchValue: "{{.ref}}", Templated: true}
+	_, value, err = ex.Extract(body, map[string]interface{}{"ref": "AB12"})
+	assert.Nil(t, err)
+	assert.Equal(t, "2017-01-02", value)
+
+	_, _, err = ex.Extract(body, map[string]interface{}{"ref": "XX"})
+	assert.EqualError(t, err, "no row with Reference 'XX'")
+
+	ex = CSVExtractable{Name: "x", Comma: ';', Header: "Balance"}
+	_, _, err = ex.Extract(body, nil)
+	assert.EqualError(t, err, "no column 'Balance'")
+
+	ex = CSVExtractable{Name: "x", Row: 5}
+	_, _, err = ex.Extract("a,b\n", nil)
+	assert.EqualError(t, err, "no row 5")
+}
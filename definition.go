@@ -0,0 +1,392 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// flowDefinition is a flow as written in a YAML or JSON document, see LoadFlow
+type flowDefinition struct {
+	RequiredValues  []scalar         `json:"required_values,omitempty"`
+	SensitiveValues []scalar         `json:"sensitive_values,omitempty"`
+	Steps           []stepDefinition `json:"steps"`
+}
+
+// stepDefinition is a step of a flowDefinition. Body, BodyBase64 (binary
+// bodies) and Form are exclusive.
+type stepDefinition struct {
+	Name            scalar              `json:"name"`
+	Method          scalar              `json:"method,omitempty"`
+	URL             scalar              `json:"url"`
+	Headers         map[string]scalars  `json:"headers,omitempty"`
+	Body            scalar              `json:"body,omitempty"`
+	BodyBase64      []byte              `json:"body_base64,omitempty"`
+	Form            map[string]scalars  `json:"form,omitempty"`
+	IgnoreRedirects bool                `json:"ignore_redirects,omitempty"`
+	HiddenFields    bool                `json:"hidden_fields,omitempty"`
+	Inputs          []scalar            `json:"inputs,omitempty"`
+	Extract         []extractDefinition `json:"extract,omitempty"`
+	AnyStatus       bool                `json:"any_status,omitempty"`
+	ExpectStatus    []scalar            `json:"expect_status,omitempty"`
+	Forbid          []scalar            `json:"forbid,omitempty"`
+	ForbidRegexp    []scalar            `json:"forbid_regexp,omitempty"`
+}
+
+// extractDefinition is an Extractable of a stepDefinition, or a JSONExtractable
+// when JSON is set, or an HTMLExtractable when Selector is. The lengths are
+// unlimited when omitted.
+type extractDefinition struct {
+	Name            scalar             `json:"name,omitempty"`
+	After           scalar             `json:"after,omitempty"`
+	Before          scalar             `json:"before,omitempty"`
+	JSON            scalar             `json:"json,omitempty"`
+	Selector        scalar             `json:"selector,omitempty"`
+	Attr            scalar             `json:"attr,omitempty"`
+	Iterate         bool               `json:"iterate,omitempty"`
+	Templated       bool               `json:"templated,omitempty"`
+	MaxLength       *int               `json:"max_length,omitempty"`
+	MinLength       *int               `json:"min_length,omitempty"`
+	Regexp          scalar             `json:"regexp,omitempty"`
+	IgnoreNotFound  bool               `json:"ignore_not_found,omitempty"`
+	Occurrence      int                `json:"occurrence,omitempty"`
+	SearchBackwards bool               `json:"search_backwards,omitempty"`
+	WindowStart     scalar             `json:"window_start,omitempty"`
+	WindowEnd       scalar             `json:"window_end,omitempty"`
+	AnchorValue     scalar             `json:"anchor_value,omitempty"`
+	Anchor          scalar             `json:"anchor,omitempty"`
+	Again           *extractDefinition `json:"again,omitempty"`
+}
+
+// scalar is a string that can be written as a number or boolean too, e.g. the
+// status 200
+type scalar string
+
+func (s *scalar) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case map[string]interface{}, []interface{}:
+		return fmt.Errorf("expected a string, got %s", b)
+	case nil:
+		*s = ""
+	default:
+		*s = scalar(fmt.Sprint(t))
+	}
+	return nil
+}
+
+// scalars are the values of a header or form field, one can be written without
+// a list
+type scalars []scalar
+
+func (s *scalars) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		return json.Unmarshal(b, (*[]scalar)(s))
+	}
+	var v scalar
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = scalars{v}
+	return nil
+}
+
+func (s scalars) MarshalJSON() ([]byte, error) {
+	if len(s) == 1 {
+		return json.Marshal(s[0])
+	}
+	return json.Marshal([]scalar(s))
+}
+
+// LoadFlow builds a flow from its YAML or JSON definition, so flows can be
+// written without Go e.g.
+//
+//	required_values: [user, password]
+//	sensitive_values: [password]
+//	steps:
+//	  - name: login
+//	    method: POST
+//	    url: https://example.com/login
+//	    form:
+//	      user: "{{.user}}"
+//	      password: "{{.password}}"
+//	    inputs: [user, password]
+//	    extract:
+//	      - name: token
+//	        after: 'name="token" value="'
+//	        before: '"'
+//
+// Values are extracted between after and before, or at a json path, or from
+// the element of a CSS selector (its attr, or text). See contrib/flows for
+// more examples.
+//
+// YAML definitions are limited to block mappings and sequences, scalars, block
+// scalars (| and >) and sequences of scalars ([a, b]). The flow is compiled.
+func LoadFlow(data []byte) (*Flow, error) {
+	doc := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(doc, []byte("{")) {
+		tree, err := parseYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flow definition: %s", err.Error())
+		}
+		if _, ok := tree.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("invalid flow definition: expected a mapping")
+		}
+		if doc, err = json.Marshal(tree); err != nil {
+			return nil, fmt.Errorf("invalid flow definition: %s", err.Error())
+		}
+	}
+	var def flowDefinition
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("invalid flow definition: %s", err.Error())
+	}
+	f, err := def.flow()
+	if err != nil {
+		return nil, fmt.Errorf("invalid flow definition: %s", err.Error())
+	}
+	if err := f.Compile(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// flow builds the flow of the definition
+func (d *flowDefinition) flow() (*Flow, error) {
+	f := &Flow{RequiredValues: strs(d.RequiredValues), SensitiveValues: strs(d.SensitiveValues)}
+	for i, s := range d.Steps {
+		step := Step{
+			Name: string(s.Name),
+			Request: Request{
+				URL:             string(s.URL),
+				Method:          strings.ToUpper(string(s.Method)),
+				IgnoreRedirects: s.IgnoreRedirects,
+			},
+			KeysInput:    strs(s.Inputs),
+			HiddenFields: s.HiddenFields,
+			AnyStatus:    s.AnyStatus,
+			ExpectStatus: strs(s.ExpectStatus),
+			Forbid:       strs(s.Forbid),
+			ForbidRegexp: strs(s.ForbidRegexp),
+		}
+		if step.Request.URL == "" {
+			return nil, fmt.Errorf("step %d.'%s' has no url", i, step.Name)
+		}
+		if step.Request.Method == "" {
+			step.Request.Method = http.MethodGet
+		}
+		if len(s.Headers) != 0 {
+			step.Request.Header = http.Header{}
+			for k, vals := range s.Headers {
+				for _, v := range vals {
+					step.Request.Header.Add(k, string(v))
+				}
+			}
+		}
+		bodies := 0
+		if s.Body != "" {
+			step.Request.Body, bodies = string(s.Body), bodies+1
+		}
+		if s.BodyBase64 != nil {
+			step.Request.Body, bodies = s.BodyBase64, bodies+1
+		}
+		if s.Form != nil {
+			form := url.Values{}
+			for k, vals := range s.Form {
+				form[k] = strs(vals)
+			}
+			step.Request.Body, bodies = form, bodies+1
+		}
+		if bodies > 1 {
+			return nil, fmt.Errorf("step %d.'%s' has more than one of body, body_base64 and form", i, step.Name)
+		}
+		for _, e := range s.Extract {
+			if e.Name == "" {
+				return nil, fmt.Errorf("step %d.'%s' extracts a value without name", i, step.Name)
+			}
+			out, err := e.extracter()
+			if err != nil {
+				return nil, fmt.Errorf("step %d.'%s' %s", i, step.Name, err.Error())
+			}
+			step.KeysOutput = append(step.KeysOutput, out)
+		}
+		f.Steps = append(f.Steps, step)
+	}
+	return f, nil
+}
+
+// extracter returns the Extractable, JSONExtractable or HTMLExtractable of the
+// definition
+func (d *extractDefinition) extracter() (Extracter, error) {
+	kinds := 0
+	for _, set := range []bool{d.After != "" || d.Before != "" || d.Regexp != "", d.JSON != "", d.Selector != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return nil, fmt.Errorf("extracts '%s' with more than one of after/before/regexp, json and selector", d.Name)
+	}
+	switch {
+	case d.JSON != "":
+		return JSONExtractable{Name: string(d.Name), Path: string(d.JSON), Templated: d.Templated,
+			IgnoreNotFound: d.IgnoreNotFound}, nil
+	case d.Selector != "":
+		return HTMLExtractable{Name: string(d.Name), Selector: string(d.Selector), Attr: string(d.Attr),
+			Templated: d.Templated, IgnoreNotFound: d.IgnoreNotFound}, nil
+	}
+	return *d.extractable(), nil
+}
+
+// extractable returns the Extractable of the definition
+func (d *extractDefinition) extractable() *Extractable {
+	if d == nil {
+		return nil
+	}
+	e := &Extractable{
+		Name: string(d.Name), AfterThis: string(d.After), BeforeThis: string(d.Before), Iterate: d.Iterate,
+		Templated: d.Templated, MaxLength: -1, MinLength: -1, MatchRegexp: string(d.Regexp), IgnoreNotFound: d.IgnoreNotFound,
+		Occurrence: d.Occurrence, SearchBackwards: d.SearchBackwards,
+		WindowStart: string(d.WindowStart), WindowEnd: string(d.WindowEnd),
+		AnchorValue: string(d.AnchorValue), Anchor: string(d.Anchor), Again: d.Again.extractable(),
+	}
+	if d.MaxLength != nil {
+		e.MaxLength = *d.MaxLength
+	}
+	if d.MinLength != nil {
+		e.MinLength = *d.MinLength
+	}
+	return e
+}
+
+// MarshalFlow returns the JSON definition of the flow, see LoadFlow. Only what
+// definitions describe is marshalled: e.g. the hooks and checks other than
+// ExpectStatus and Forbid are left out. The steps' outputs must be Extractables,
+// JSONExtractables or HTMLExtractables.
+func MarshalFlow(f *Flow) ([]byte, error) {
+	def := flowDefinition{RequiredValues: scalarsOf(f.RequiredValues), SensitiveValues: scalarsOf(f.SensitiveValues),
+		Steps: []stepDefinition{}}
+	for i, step := range f.Steps {
+		s := stepDefinition{
+			Name:            scalar(step.Name),
+			Method:          scalar(step.Request.Method),
+			URL:             scalar(step.Request.URL),
+			IgnoreRedirects: step.Request.IgnoreRedirects,
+			HiddenFields:    step.HiddenFields,
+			Inputs:          scalarsOf(step.KeysInput),
+			AnyStatus:       step.AnyStatus,
+			ExpectStatus:    scalarsOf(step.ExpectStatus),
+			Forbid:          scalarsOf(step.Forbid),
+			ForbidRegexp:    scalarsOf(step.ForbidRegexp),
+		}
+		if len(step.Request.Header) != 0 {
+			s.Headers = map[string]scalars{}
+			for k, vals := range step.Request.Header {
+				s.Headers[k] = scalarsOf(vals)
+			}
+		}
+		switch body := step.Request.Body.(type) {
+		case nil:
+		case string:
+			s.Body = scalar(body)
+		case []byte:
+			s.BodyBase64 = body
+		case url.Values:
+			s.Form = map[string]scalars{}
+			for k, vals := range body {
+				s.Form[k] = scalarsOf(vals)
+			}
+		default:
+			return nil, fmt.Errorf("Step %d.'%s' can't be marshalled because its body is a %T", i, step.Name, body)
+		}
+		for n, out := range step.KeysOutput {
+			d := extracterDefinitionOf(out)
+			if d == nil {
+				return nil, fmt.Errorf("Step %d.'%s' can't be marshalled because its output %d isn't an Extractable, "+
+					"JSONExtractable or HTMLExtractable", i, step.Name, n)
+			}
+			s.Extract = append(s.Extract, *d)
+		}
+		def.Steps = append(def.Steps, s)
+	}
+	return json.MarshalIndent(def, "", "  ")
+}
+
+// MarshalFlowYAML returns the YAML definition of the flow, see MarshalFlow
+func MarshalFlowYAML(f *Flow) ([]byte, error) {
+	b, err := MarshalFlow(f)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(b)
+}
+
+// extracterDefinitionOf returns the definition of the extracter, nil if it can't
+// be defined
+func extracterDefinitionOf(e Extracter) *extractDefinition {
+	switch t := e.(type) {
+	case Extractable:
+		return extractDefinitionOf(&t)
+	case *Extractable:
+		return extractDefinitionOf(t)
+	case JSONExtractable:
+		return &extractDefinition{Name: scalar(t.Name), JSON: scalar(t.Path), Templated: t.Templated,
+			IgnoreNotFound: t.IgnoreNotFound}
+	case HTMLExtractable:
+		return &extractDefinition{Name: scalar(t.Name), Selector: scalar(t.Selector), Attr: scalar(t.Attr),
+			Templated: t.Templated, IgnoreNotFound: t.IgnoreNotFound}
+	}
+	return nil
+}
+
+// extractDefinitionOf returns the definition of the Extractable
+func extractDefinitionOf(e *Extractable) *extractDefinition {
+	if e == nil {
+		return nil
+	}
+	d := &extractDefinition{
+		Name: scalar(e.Name), After: scalar(e.AfterThis), Before: scalar(e.BeforeThis), Iterate: e.Iterate,
+		Templated: e.Templated, Regexp: scalar(e.MatchRegexp), IgnoreNotFound: e.IgnoreNotFound,
+		Occurrence: e.Occurrence, SearchBackwards: e.SearchBackwards,
+		WindowStart: scalar(e.WindowStart), WindowEnd: scalar(e.WindowEnd),
+		AnchorValue: scalar(e.AnchorValue), Anchor: scalar(e.Anchor), Again: extractDefinitionOf(e.Again),
+	}
+	if e.MaxLength != -1 {
+		d.MaxLength = &e.MaxLength
+	}
+	if e.MinLength != -1 {
+		d.MinLength = &e.MinLength
+	}
+	return d
+}
+
+func strs(s []scalar) []string {
+	if s == nil {
+		return nil
+	}
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[i] = string(v)
+	}
+	return out
+}
+
+func scalarsOf(s []string) []scalar {
+	if s == nil {
+		return nil
+	}
+	out := make([]scalar, len(s))
+	for i, v := range s {
+		out[i] = scalar(v)
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const loginDefinition = `# the login flow
+required_values: [user, password]
+sensitive_values:
+- password
+steps:
+  - name: login
+    method: post
+    url: "{{.site}}/login"
+    headers:
+      Accept: text/html
+      X-Tags: [a, b]
+    form:
+      user: "{{.user}}"
+      password: "{{.password}}"
+    inputs: [site, user, password]
+    expect_status: [200, 3xx]
+    forbid: [Invalid password]
+    extract:
+      - name: token
+        after: 'name="token" value="'
+        before: '"'
+        max_length: 32
+  - name: profile
+    url: '{{.site}}/profile?token={{.token}}'
+    inputs: [site, token]
+  - name: note
+    method: PUT
+    url: "{{.site}}/note"
+    body: |
+      {
+        "text": "hello {{.user}} # not a comment"
+      }
+    inputs: [site, user]
+`
+
+func TestLoadFlow(t *testing.T) {
+	f, err := LoadFlow([]byte(loginDefinition))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"user", "password"}, f.RequiredValues)
+	assert.Equal(t, []string{"password"}, f.SensitiveValues)
+	if !assert.Len(t, f.Steps, 3) {
+		return
+	}
+	login := f.Steps[0]
+	assert.Equal(t, "POST", login.Request.Method)
+	assert.Equal(t, "{{.site}}/login", login.Request.URL)
+	assert.Equal(t, http.Header{"Accept": {"text/html"}, "X-Tags": {"a", "b"}}, login.Request.Header)
+	assert.Equal(t, url.Values{"user": {"{{.user}}"}, "password": {"{{.password}}"}}, login.Request.Body)
+	assert.Equal(t, []string{"200", "3xx"}, login.ExpectStatus)
+	assert.Equal(t, []string{"Invalid password"}, login.Forbid)
+	assert.Equal(t, []Extracter{Extractable{Name: "token", AfterThis: `name="token" value="`, BeforeThis: `"`,
+		MaxLength: 32, MinLength: -1}}, login.KeysOutput)
+	assert.Equal(t, "GET", f.Steps[1].Request.Method)
+	assert.Equal(t, "{\n  \"text\": \"hello {{.user}} # not a comment\"\n}\n", f.Steps[2].Request.Body)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.Write([]byte(`<input name="token" value="t0k">`))
+		}
+	}))
+	defer srv.Close()
+	assert.Nil(t, f.Execute(map[string]interface{}{"site": srv.URL, "user": "bob", "password": "pw"}))
+	assert.Equal(t, "t0k", f.Values["token"])
+}
+
+func TestLoadFlow_JSON(t *testing.T) {
+	f, err := LoadFlow([]byte(`{"steps": [{"name": "home", "url": "https://example.com", "body_base64": "AAE="}]}`))
+	if assert.Nil(t, err) {
+		assert.Equal(t, []byte{0, 1}, f.Steps[0].Request.Body)
+	}
+}
+
+func TestLoadFlow_Invalid(t *testing.T) {
+	for doc, msg := range map[string]string{
+		"steps:\n  - name: a\n    urll: x\n":              `invalid flow definition: json: unknown field "urll"`,
+		"steps:\n  - name: a\n":                           "invalid flow definition: step 0.'a' has no url",
+		"steps:\n  - url: x\n    body: a\n    form: {}\n": "invalid flow definition: step 0.'' has more than one of body, body_base64 and form",
+		"steps:\n - url: x\n   extract:\n   - after: a\n": "invalid flow definition: step 0.'' extracts a value without name",
+		"steps:\n - url: x\n   extract:\n   - name: a\n     json: a\n     after: b\n": "invalid flow definition: " +
+			"step 0.'' extracts 'a' with more than one of after/before/regexp, json and selector",
+		"steps:\n  - url: '{{.a'\n":                               "Step 0.'' invalid URL template: template: replacement:1: unclosed action",
+		"steps:\n  - name: a\n    expected_codes: [200, , 302]\n": "invalid flow definition: line 3: empty item in flow sequence",
+		"- a\n":                            "invalid flow definition: expected a mapping",
+		"steps:\n  - url: x\n   name: a\n": "invalid flow definition: line 3: expected an item of the sequence",
+		`{"steps": 1}`:                     "invalid flow definition: json: cannot unmarshal number into Go struct field flowDefinition.steps of type []httpsim.stepDefinition",
+		"steps:\n  - url: x\n    headers:\n      A: {b: c}\n": "invalid flow definition: line 4: flow mappings, anchors, aliases and tags aren't supported",
+	} {
+		_, err := LoadFlow([]byte(doc))
+		assert.EqualError(t, err, msg, doc)
+	}
+}
+
+func TestMarshalFlow(t *testing.T) {
+	f, err := LoadFlow([]byte(loginDefinition))
+	if !assert.Nil(t, err) {
+		return
+	}
+	upload := &Extractable{Name: "id", AfterThis: "id={{.prefix}}", Templated: true, MaxLength: -1, MinLength: 2,
+		Again: &Extractable{AfterThis: "<", BeforeThis: ">", MaxLength: -1, MinLength: -1}}
+	f.Steps = append(f.Steps, Step{Name: "upload", Request: Request{URL: "/upload", Method: "POST", Body: []byte{0, 1}},
+		KeysOutput: []Extracter{upload, JSONExtractable{Name: "size", Path: "files[{{.n}}].size", Templated: true, IgnoreNotFound: true},
+			HTMLExtractable{Name: "link", Selector: "a.download", Attr: "href"}},
+		HiddenFields: true, AnyStatus: true})
+
+	for _, marshal := range []func(*Flow) ([]byte, error){MarshalFlow, MarshalFlowYAML} {
+		b, err := marshal(f)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		loaded, err := LoadFlow(b)
+		if !assert.Nil(t, err, string(b)) || !assert.Len(t, loaded.Steps, 4) {
+			continue
+		}
+		assert.Equal(t, f.Steps[:3], loaded.Steps[:3], string(b))
+		assert.Equal(t, []Extracter{*upload, f.Steps[3].KeysOutput[1], f.Steps[3].KeysOutput[2]}, loaded.Steps[3].KeysOutput)
+		assert.True(t, loaded.Steps[3].HiddenFields)
+		assert.True(t, loaded.Steps[3].AnyStatus)
+		assert.Equal(t, []byte{0, 1}, loaded.Steps[3].Request.Body)
+		assert.Equal(t, f.RequiredValues, loaded.RequiredValues)
+		assert.Equal(t, f.SensitiveValues, loaded.SensitiveValues)
+	}
+
+	f.Steps[0].KeysOutput = append(f.Steps[0].KeysOutput, ExtracterFunc(nil))
+	_, err = MarshalFlow(f)
+	assert.EqualError(t, err, "Step 0.'login' can't be marshalled because its output 1 isn't an Extractable, "+
+		"JSONExtractable or HTMLExtractable")
+}
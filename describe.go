@@ -0,0 +1,168 @@
+package httpsim
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ValueDoc documents a value of the flow, for Describe
+type ValueDoc struct {
+	// Type is the type of the value e.g. "string", "date", "number", "string" when empty
+	Type        string
+	Description string
+}
+
+// FlowDescription is a machine-readable description of a flow, e.g. for UIs
+// presenting flows as runnable templates
+type FlowDescription struct {
+	Required []ValueDescription `json:"required"`
+	Steps    []StepDescription  `json:"steps"`
+	Teardown []StepDescription  `json:"teardown,omitempty"`
+}
+
+// ValueDescription describes a value given to or produced by a flow
+type ValueDescription struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+}
+
+// StepDescription describes a step
+type StepDescription struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	// URL is the URL template
+	URL    string   `json:"url"`
+	Inputs []string `json:"inputs,omitempty"`
+	// Outputs are the values extracted, the ones of extracters without a Name
+	// field (e.g. ExtracterFunc) can't be described
+	Outputs []ValueDescription `json:"outputs,omitempty"`
+	// Assertions are the checks made on the response
+	Assertions []string `json:"assertions,omitempty"`
+}
+
+// Describe returns the description of the flow: its required values, and the
+// values used and produced, URLs and assertions of its steps
+func (f *Flow) Describe() FlowDescription {
+	d := FlowDescription{Required: []ValueDescription{}, Steps: []StepDescription{}}
+	for _, k := range f.RequiredValues {
+		d.Required = append(d.Required, f.describeValue(k))
+	}
+	for _, step := range f.Steps {
+		d.Steps = append(d.Steps, f.describeStep(step))
+	}
+	for _, step := range f.Teardown {
+		d.Teardown = append(d.Teardown, f.describeStep(step))
+	}
+	return d
+}
+
+func (f *Flow) describeValue(name string) ValueDescription {
+	doc := f.ValueDocs[name]
+	v := ValueDescription{Name: name, Type: doc.Type, Description: doc.Description}
+	if v.Type == "" {
+		v.Type = "string"
+	}
+	for _, s := range f.SensitiveValues {
+		v.Sensitive = v.Sensitive || s == name
+	}
+	return v
+}
+
+func (f *Flow) describeStep(step Step) StepDescription {
+	d := StepDescription{
+		Name:   step.Name,
+		Method: step.Request.Method,
+		URL:    step.Request.URL,
+		Inputs: step.KeysInput,
+	}
+	for _, e := range step.KeysOutput {
+		if name := extracterName(e); name != "" {
+			v := f.describeValue(name)
+			switch step.Normalize[name] {
+			case NormalizeNumber:
+				v.Type = "number"
+			case NormalizeDate:
+				v.Type = "date"
+			}
+			if _, ok := e.(ValueExtracter); ok && f.ValueDocs[name].Type == "" {
+				v.Type = "records"
+			}
+			d.Outputs = append(d.Outputs, v)
+		}
+	}
+	if step.CaptureHost != "" {
+		d.Outputs = append(d.Outputs, f.describeValue(step.CaptureHost))
+	}
+
+	assert := func(format string, a ...interface{}) {
+		d.Assertions = append(d.Assertions, fmt.Sprintf(format, a...))
+	}
+	if !step.AnyStatus {
+		assert("status is %s", strings.Join(step.expectedStatus(), " or "))
+	}
+	for _, s := range step.Forbid {
+		assert("body doesn't contain %q", s)
+	}
+	for _, s := range step.ForbidRegexp {
+		assert("body doesn't match %q", s)
+	}
+	if step.ExpectBodySHA256 != "" {
+		assert("body SHA-256 is %s", step.ExpectBodySHA256)
+	}
+	if tls := step.TLS; tls != nil || f.TLS != nil {
+		if tls == nil {
+			tls = f.TLS
+		}
+		if len(tls.Pins) != 0 {
+			assert("certificate matches one of %d pins", len(tls.Pins))
+		}
+		if tls.Issuer != "" {
+			assert("certificate issuer contains %q", tls.Issuer)
+		}
+		if tls.MinValidity != 0 {
+			assert("certificate is valid for %s", tls.MinValidity)
+		}
+	}
+	if dns := step.DNS; dns != nil || f.DNS != nil {
+		if dns == nil {
+			dns = f.DNS
+		}
+		if len(dns.CIDRs) != 0 {
+			assert("host resolves within %s", strings.Join(dns.CIDRs, ", "))
+		}
+		if dns.History != nil {
+			assert("host resolution doesn't change")
+		}
+	}
+	if step.CORS != nil {
+		assert("CORS allows origin %s", step.CORS.Origin)
+	}
+	for _, inv := range step.Invariants {
+		assert("invariant %s", inv.Name)
+	}
+	if f.OpenAPI != nil {
+		assert("request and response follow the OpenAPI spec")
+	}
+	return d
+}
+
+// extracterName returns the Name field of the extracter, empty if it has none
+func extracterName(e Extracter) string {
+	v := reflect.ValueOf(e)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if name := v.FieldByName("Name"); name.IsValid() && name.Kind() == reflect.String {
+		return name.String()
+	}
+	return ""
+}
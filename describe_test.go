@@ -0,0 +1,69 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Describe(t *testing.T) {
+	f := Flow{
+		RequiredValues:  []string{"user", "password", "since"},
+		SensitiveValues: []string{"password"},
+		ValueDocs: map[string]ValueDoc{
+			"user":  {Description: "login e-mail"},
+			"since": {Type: "date", Description: "first day of the export"},
+		},
+		TLS: &TLSCheck{MinValidity: 24 * time.Hour},
+		Steps: []Step{
+			{
+				Name:      "login",
+				Request:   Request{URL: "https://bank.example.com/login", Method: "POST", Body: "u={{.user}}&p={{.password}}"},
+				KeysInput: []string{"user", "password"},
+				KeysOutput: []Extracter{
+					Extractable{Name: "csrf"},
+					&CSVExtractable{Name: "balance"},
+					TableExtractable{Name: "accounts"},
+					ExtracterFunc(func(string, map[string]interface{}) (string, string, error) { return "", "", nil }),
+				},
+				Normalize:        map[string]Normalization{"balance": NormalizeNumber},
+				Forbid:           []string{"Invalid password"},
+				ExpectStatus:     []string{"302"},
+				ExpectBodySHA256: "abc",
+				CORS:             &CORS{Origin: "https://app.example.com"},
+			},
+			{Name: "export", Request: Request{URL: "https://bank.example.com/export?since={{.since}}", Method: "GET"},
+				KeysInput: []string{"since"}, AnyStatus: true},
+		},
+	}
+	d := f.Describe()
+	assert.Equal(t, []ValueDescription{
+		{Name: "user", Type: "string", Description: "login e-mail"},
+		{Name: "password", Type: "string", Sensitive: true},
+		{Name: "since", Type: "date", Description: "first day of the export"},
+	}, d.Required)
+	assert.Len(t, d.Steps, 2)
+
+	login := d.Steps[0]
+	assert.Equal(t, "POST", login.Method)
+	assert.Equal(t, []string{"user", "password"}, login.Inputs)
+	assert.Equal(t, []ValueDescription{
+		{Name: "csrf", Type: "string"},
+		{Name: "balance", Type: "number"},
+		{Name: "accounts", Type: "records"},
+	}, login.Outputs)
+	assert.Equal(t, []string{
+		"status is 302",
+		`body doesn't contain "Invalid password"`,
+		"body SHA-256 is abc",
+		"certificate is valid for 24h0m0s",
+		"CORS allows origin https://app.example.com",
+	}, login.Assertions)
+	assert.Equal(t, []string{"certificate is valid for 24h0m0s"}, d.Steps[1].Assertions)
+
+	b, err := json.Marshal(d)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `{"name":"password","type":"string","sensitive":true}`)
+}
@@ -0,0 +1,108 @@
+package httpsim
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxDiffLines is the number of changed lines shown in a diff summary
+	maxDiffLines = 20
+	// maxDiffLineLength truncates the lines shown in a diff summary
+	maxDiffLineLength = 120
+	// maxDiffCells bounds the LCS table, bigger changes are shown as a block
+	maxDiffCells = 4000000
+)
+
+// diffSummary returns a short line diff ("- old", "+ new") between two bodies,
+// ignoring indentation and blank lines. Empty when they're the same.
+func diffSummary(old, new []byte) string {
+	a, b := diffLines(old), diffLines(new)
+
+	// common prefix and suffix are the usual case and cheap
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	a, b = a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	var changes []string
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			changes = append(changes, "- "+l)
+		}
+		for _, l := range b {
+			changes = append(changes, "+ "+l)
+		}
+	} else {
+		changes = lcsDiff(a, b)
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	shown := changes
+	if len(shown) > maxDiffLines {
+		shown = shown[:maxDiffLines]
+	}
+	for i, l := range shown {
+		if len(l) > maxDiffLineLength {
+			shown[i] = l[:maxDiffLineLength] + "..."
+		}
+	}
+	summary := strings.Join(shown, "\n")
+	if len(changes) > len(shown) {
+		summary += fmt.Sprintf("\n... %d more changed lines", len(changes)-len(shown))
+	}
+	return summary
+}
+
+func diffLines(body []byte) []string {
+	var lines []string
+	for _, l := range strings.Split(string(body), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// lcsDiff returns the removed and added lines between a and b, in order
+func lcsDiff(a, b []string) []string {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var changes []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			changes = append(changes, "- "+a[i])
+			i++
+		default:
+			changes = append(changes, "+ "+b[j])
+			j++
+		}
+	}
+	return changes
+}
@@ -0,0 +1,155 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DNSInfo is what a request's host resolved to
+type DNSInfo struct {
+	Host string
+	// IPs are the sorted addresses the host resolved to. They're the ones resolved
+	// earlier in the run when the connection was reused, nil if never resolved
+	// (reused connection of a previous run, IP hosts).
+	IPs []string
+	// RemoteAddr is the address connected to
+	RemoteAddr string
+}
+
+// DNSCheck are assertions on what the hosts resolve to, e.g. to check DNS failovers
+type DNSCheck struct {
+	// CIDRs must contain the resolved and connected addresses, any when empty
+	CIDRs []string
+	// History, if set, keeps what the hosts resolved to: the step fails when it
+	// changed since the previous run
+	History *DNSHistory
+}
+
+// DNSHistory keeps what the hosts resolved to, it's safe for concurrent use
+type DNSHistory struct {
+	mu sync.Mutex
+	m  map[string][]string
+}
+
+// NewDNSHistory creates an empty history
+func NewDNSHistory() *DNSHistory {
+	return &DNSHistory{m: map[string][]string{}}
+}
+
+// swap stores the IPs of the host and returns the previous ones
+func (h *DNSHistory) swap(host string, ips []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev := h.m[host]
+	h.m[host] = ips
+	return prev
+}
+
+// DNSError is the error returned when a host's resolution fails a DNSCheck
+type DNSError struct {
+	Step     int
+	StepName string
+	Host     string
+	Reason   string
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because DNS check of %s failed: %s",
+		e.Step, e.StepName, e.Host, e.Reason)
+}
+
+// dnsTrace traces the resolution and connection of a request. Dials may still
+// complete once the request is done, reads are locked too.
+type dnsTrace struct {
+	mu   sync.Mutex
+	info DNSInfo
+}
+
+// context returns a context tracing the request
+func (t *dnsTrace) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone: func(di httptrace.DNSDoneInfo) {
+			var ips []string
+			for _, a := range di.Addrs {
+				ips = append(ips, a.IP.String())
+			}
+			sort.Strings(ips)
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.info.IPs = ips
+		},
+		GotConn: func(ci httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.info.RemoteAddr = ci.Conn.RemoteAddr().String()
+		},
+	})
+}
+
+// result returns the traced info
+func (t *dnsTrace) result() *DNSInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := t.info
+	return &info
+}
+
+// resolved completes the info with the run's previous resolution of the host
+func (run *runState) resolved(info *DNSInfo) {
+	if info.IPs == nil {
+		info.IPs = run.dns[info.Host]
+		return
+	}
+	if run.dns == nil {
+		run.dns = map[string][]string{}
+	}
+	run.dns[info.Host] = info.IPs
+}
+
+// CheckDNS checks the host's resolution against the step's DNS check, or the
+// flow's one given when the step has none
+func (s *Step) CheckDNS(info *DNSInfo, flowCheck *DNSCheck, stepNb int) error {
+	check := s.DNS
+	if check == nil {
+		check = flowCheck
+	}
+	if check == nil || info == nil {
+		return nil
+	}
+	fail := func(format string, a ...interface{}) error {
+		return &DNSError{Step: stepNb, StepName: s.Name, Host: info.Host, Reason: fmt.Sprintf(format, a...)}
+	}
+
+	if len(check.CIDRs) != 0 {
+		addrs := append([]string(nil), info.IPs...)
+		if host, _, err := net.SplitHostPort(info.RemoteAddr); err == nil {
+			addrs = append(addrs, host)
+		}
+		for _, addr := range addrs {
+			if !inCIDRs(net.ParseIP(addr), check.CIDRs) {
+				return fail("%s isn't within %s", addr, strings.Join(check.CIDRs, ", "))
+			}
+		}
+	}
+	if check.History != nil && info.IPs != nil {
+		prev := check.History.swap(info.Host, info.IPs)
+		if prev != nil && strings.Join(prev, ",") != strings.Join(info.IPs, ",") {
+			return fail("resolved to %s, was %s", strings.Join(info.IPs, ", "), strings.Join(prev, ", "))
+		}
+	}
+	return nil
+}
+
+func inCIDRs(ip net.IP, cidrs []string) bool {
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteDNS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	local := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	f := Flow{
+		Transport: &http.Transport{DisableKeepAlives: true},
+		Steps: []Step{
+			{Name: "home", Request: Request{URL: local, Method: "GET"}},
+			{Name: "ip", Request: Request{URL: srv.URL, Method: "GET"}},
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	dns := f.Steps[0].Response.DNS
+	assert.Equal(t, "localhost", dns.Host)
+	assert.Contains(t, dns.IPs, "127.0.0.1")
+	assert.Equal(t, srv.Listener.Addr().String(), dns.RemoteAddr)
+	// IP hosts aren't resolved
+	assert.Nil(t, f.Steps[1].Response.DNS.IPs)
+	assert.Equal(t, srv.Listener.Addr().String(), f.Steps[1].Response.DNS.RemoteAddr)
+
+	f.DNS = &DNSCheck{CIDRs: []string{"127.0.0.0/8", "::1/128"}, History: NewDNSHistory()}
+	assert.Nil(t, f.Execute(nil))
+	assert.Nil(t, f.Execute(nil))
+
+	f.DNS.History.swap("localhost", []string{"10.0.0.1"})
+	err := f.Execute(nil)
+	assert.IsType(t, &DNSError{}, err)
+	assert.Contains(t, err.Error(), "Step 0.'home' failed because DNS check of localhost failed: resolved to ")
+	assert.Contains(t, err.Error(), ", was 10.0.0.1")
+
+	f.Steps[1].DNS = &DNSCheck{CIDRs: []string{"10.0.0.0/8"}}
+	f.DNS = nil
+	err = f.Execute(nil)
+	assert.EqualError(t, err, "Step 1.'ip' failed because DNS check of 127.0.0.1 failed: 127.0.0.1 isn't within 10.0.0.0/8")
+}
@@ -0,0 +1,98 @@
+package httpsim
+
+import "fmt"
+
+// DuplicatePolicy is what to do when a step writes a value that was given or
+// written by another step
+type DuplicatePolicy int
+
+const (
+	// DuplicateAllow overwrites the value silently
+	DuplicateAllow DuplicatePolicy = iota
+	// DuplicateWarn overwrites the value with a warning
+	DuplicateWarn
+	// DuplicateFail fails the step with a *DuplicateWriteError
+	DuplicateFail
+)
+
+// DuplicateWriteError is the error returned when a step overwrites a value
+// unintentionally, see Flow.DuplicateWrites
+type DuplicateWriteError struct {
+	Step     int
+	StepName string
+	Value    string
+	// Previous tells who wrote the value first
+	Previous ValueProvenance
+}
+
+func (e *DuplicateWriteError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because it overwrites '%s' %s", e.Step, e.StepName, e.Value,
+		previousWriter(e.Previous))
+}
+
+// previousWriter describes who wrote the value
+func previousWriter(p ValueProvenance) string {
+	if p.Given {
+		return "given to Execute"
+	}
+	return fmt.Sprintf("output by step %d.'%s'", p.Step, p.StepName)
+}
+
+// checkWrite applies the DuplicateWrites policy to the step writing the value
+func (f *Flow) checkWrite(i int, step *Step, name string) error {
+	prev, ok := f.Provenance[name]
+	if f.DuplicateWrites == DuplicateAllow || !ok || !prev.Given && prev.Step == i {
+		return nil
+	}
+	for _, k := range step.Overwrite {
+		if k == name {
+			return nil
+		}
+	}
+	if f.DuplicateWrites == DuplicateFail {
+		return &DuplicateWriteError{Step: i, StepName: step.Name, Value: name, Previous: prev}
+	}
+	f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name,
+		Message: fmt.Sprintf("overwrites '%s' %s", name, previousWriter(prev))})
+	return nil
+}
+
+// lintDuplicates warns about the outputs written by several steps, or
+// overwriting required values
+func (f *Flow) lintDuplicates() []LintWarning {
+	var warnings []LintWarning
+	required := map[string]bool{}
+	for _, k := range f.RequiredValues {
+		required[k] = true
+	}
+	output := map[string]int{}
+	for i, step := range f.Steps {
+		for _, k := range step.KeysInput {
+			if j, ok := output[k]; ok && required[k] {
+				warnings = append(warnings, LintWarning{Step: i, StepName: step.Name, Message: fmt.Sprintf(
+					"input '%s' shadows the required value: it's output by step %d.'%s'", k, j, f.Steps[j].Name)})
+			}
+		}
+		intended := map[string]bool{}
+		for _, k := range step.Overwrite {
+			intended[k] = true
+		}
+		for _, e := range step.KeysOutput {
+			k := extracterName(e)
+			if k == "" || intended[k] {
+				continue
+			}
+			if j, ok := output[k]; ok && j != i {
+				warnings = append(warnings, LintWarning{Step: i, StepName: step.Name, Message: fmt.Sprintf(
+					"outputs '%s' already output by step %d.'%s'", k, j, f.Steps[j].Name)})
+			} else if required[k] {
+				warnings = append(warnings, LintWarning{Step: i, StepName: step.Name,
+					Message: fmt.Sprintf("outputs '%s' which overwrites the required value", k)})
+			}
+			if _, ok := output[k]; !ok {
+				output[k] = i
+			}
+		}
+	}
+	return warnings
+}
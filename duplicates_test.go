@@ -0,0 +1,57 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_LintDuplicates(t *testing.T) {
+	f := Flow{
+		RequiredValues: []string{"user", "token"},
+		Steps: []Step{
+			{Name: "a", KeysOutput: []Extracter{Extractable{Name: "id"}, Extractable{Name: "token"}}},
+			{Name: "b", KeysInput: []string{"token"}, KeysOutput: []Extracter{Extractable{Name: "id"}}},
+			{Name: "c", KeysOutput: []Extracter{Extractable{Name: "id"}}, Overwrite: []string{"id"}},
+		},
+	}
+	var messages []string
+	for _, w := range f.lintDuplicates() {
+		messages = append(messages, w.String())
+	}
+	assert.Equal(t, []string{
+		"Step 0.'a' outputs 'token' which overwrites the required value",
+		"Step 1.'b' input 'token' shadows the required value: it's output by step 0.'a'",
+		"Step 1.'b' outputs 'id' already output by step 0.'a'",
+	}, messages)
+}
+
+func TestFlow_DuplicateWrites(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>" + r.URL.Path + "</b>"))
+	}))
+	defer srv.Close()
+	id := Extractable{Name: "id", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}
+	f := Flow{Steps: []Step{
+		{Name: "first", Request: Request{URL: srv.URL + "/1", Method: "GET"}, KeysOutput: []Extracter{id}},
+		{Name: "second", Request: Request{URL: srv.URL + "/2", Method: "GET"}, KeysOutput: []Extracter{id}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "/2", f.Values["id"])
+	assert.Empty(t, f.Warnings)
+
+	f.DuplicateWrites = DuplicateWarn
+	assert.Nil(t, f.Execute(map[string]interface{}{}))
+	assert.Len(t, f.Warnings, 1)
+	assert.Equal(t, "Step 1.'second' overwrites 'id' output by step 0.'first'", f.Warnings[0].String())
+
+	f.DuplicateWrites = DuplicateFail
+	err := f.Execute(map[string]interface{}{"id": "given"})
+	assert.EqualError(t, err, "Step 0.'first' failed because it overwrites 'id' given to Execute")
+	assert.IsType(t, &DuplicateWriteError{}, err)
+
+	f.Steps[1].Overwrite = []string{"id"}
+	assert.Nil(t, f.Execute(nil))
+}
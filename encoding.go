@@ -0,0 +1,109 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// EncodingResult is the response to a step's request re-sent with an Accept-Encoding
+type EncodingResult struct {
+	AcceptEncoding  string
+	ContentEncoding string
+	// Size is the size of the body as sent by the server
+	Size int
+	// SHA256 is the hex SHA-256 of the decoded body, empty when the encoding
+	// isn't supported (e.g. br)
+	SHA256 string
+}
+
+// decodeBody decodes the body of the content encoding, ok is false when the
+// encoding isn't supported
+func decodeBody(encoding string, body []byte) (decoded []byte, ok bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, true, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, true, err
+		}
+		decoded, err = ioutil.ReadAll(r)
+		return decoded, true, err
+	case "deflate":
+		r, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, true, err
+		}
+		decoded, err = ioutil.ReadAll(r)
+		return decoded, true, err
+	}
+	return nil, false, nil
+}
+
+// compareEncodings re-sends the step's request with each of its CompareEncodings
+// and warns when the responses differ from body, the step's decoded body
+func (f *Flow) compareEncodings(run *runState, i int, step *Step, body []byte) ([]EncodingResult, error) {
+	if len(step.CompareEncodings) == 0 || step.StreamBody || step.noResponseBody() {
+		return nil, nil
+	}
+	warn := func(format string, a ...interface{}) {
+		f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name,
+			Message: "encoding: " + fmt.Sprintf(format, a...)})
+	}
+	want := BodySHA256(body, false)
+
+	var results []EncodingResult
+	for _, accept := range step.CompareEncodings {
+		req := step.Request
+		req.Header = cloneHeader(req.Header)
+		req.Header.Set("Accept-Encoding", accept)
+		resp, err := req.DoContext(run.requestCtx, run.clientFor(step))
+		if err != nil {
+			return results, fmt.Errorf("Step %d.'%s' failed with Accept-Encoding %s: %s",
+				i, step.Name, accept, err.Error())
+		}
+		raw, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return results, fmt.Errorf("Step %d.'%s' failed with Accept-Encoding %s: %s",
+				i, step.Name, accept, err.Error())
+		}
+
+		res := EncodingResult{AcceptEncoding: accept, ContentEncoding: resp.Header.Get("Content-Encoding"),
+			Size: len(raw)}
+		decoded, ok, err := decodeBody(res.ContentEncoding, raw)
+		switch {
+		case err != nil:
+			warn("Accept-Encoding %s got an invalid %s body: %s", accept, res.ContentEncoding, err.Error())
+		case ok:
+			res.SHA256 = BodySHA256(decoded, false)
+			if res.SHA256 != want {
+				warn("Accept-Encoding %s got a different body", accept)
+			}
+			if res.ContentEncoding != "" && len(raw) >= len(decoded) {
+				warn("Accept-Encoding %s got a %s body larger than the decoded one (%d >= %d bytes)",
+					accept, res.ContentEncoding, len(raw), len(decoded))
+			}
+		}
+		if res.ContentEncoding != "" && !acceptsEncoding(accept, res.ContentEncoding) {
+			warn("Accept-Encoding %s got a %s body", accept, res.ContentEncoding)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// acceptsEncoding returns whether the Accept-Encoding accepts the content encoding
+func acceptsEncoding(accept, encoding string) bool {
+	for _, a := range strings.Split(accept, ",") {
+		a = strings.TrimSpace(strings.SplitN(a, ";", 2)[0])
+		if a == "*" || strings.EqualFold(a, encoding) {
+			return true
+		}
+	}
+	return false
+}
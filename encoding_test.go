@@ -0,0 +1,89 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteCompareEncodings(t *testing.T) {
+	page := strings.Repeat("<p>hello</p>", 100)
+	broken := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch ae := r.Header.Get("Accept-Encoding"); {
+		case strings.Contains(ae, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			if broken {
+				gz.Write([]byte("stale"))
+			} else {
+				gz.Write([]byte(page))
+			}
+			gz.Close()
+		case ae == "deflate":
+			// ignores the client, sends gzip anyway
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(page))
+			gz.Close()
+		case ae == "br":
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte("opaque"))
+		default:
+			w.Write([]byte(page))
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:             "home",
+		Request:          Request{URL: srv.URL, Method: "GET", Header: http.Header{"Accept-Encoding": {"identity"}}},
+		CompareEncodings: []string{"gzip", "identity", "br"},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Len(t, f.Warnings, 0)
+	encodings := f.Steps[0].Response.Encodings
+	assert.Len(t, encodings, 3)
+	assert.Equal(t, "gzip", encodings[0].ContentEncoding)
+	assert.True(t, encodings[0].Size < len(page))
+	assert.Equal(t, BodySHA256([]byte(page), false), encodings[0].SHA256)
+	assert.Equal(t, len(page), encodings[1].Size)
+	assert.Equal(t, "", encodings[2].SHA256)
+
+	broken = true
+	f.Steps[0].CompareEncodings = []string{"gzip", "deflate"}
+	assert.Nil(t, f.Execute(nil))
+	var got []string
+	for _, w := range f.Warnings {
+		got = append(got, w.String())
+	}
+	assert.Equal(t, []string{
+		"Step 0.'home' encoding: Accept-Encoding gzip got a different body",
+		"Step 0.'home' encoding: Accept-Encoding gzip got a gzip body larger than the decoded one (30 >= 5 bytes)",
+		"Step 0.'home' encoding: Accept-Encoding deflate got a gzip body",
+	}, got)
+}
+
+func TestDecodeBody(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte("deflated"))
+	zw.Close()
+	b, ok, err := decodeBody("deflate", buf.Bytes())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "deflated", string(b))
+
+	_, ok, err = decodeBody("gzip", []byte("nope"))
+	assert.True(t, ok)
+	assert.NotNil(t, err)
+
+	_, ok, _ = decodeBody("zstd", nil)
+	assert.False(t, ok)
+}
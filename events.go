@@ -0,0 +1,104 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The types of the events of an EventLog
+const (
+	// EventRequest is a request sent, Status is 0 when it failed
+	EventRequest = "request"
+	// EventExtraction is a value extracted, or that couldn't be
+	EventExtraction = "extraction"
+	// EventAssertion is a check of a response (status, Forbid, invariants...)
+	EventAssertion = "assertion"
+)
+
+// Event is what happened during an execution, as written in the event log
+type Event struct {
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"run_id"`
+	Type     string    `json:"type"`
+	Step     int       `json:"step"`
+	StepName string    `json:"step_name"`
+	// Method, URL, Status and Duration (to the response's headers) are the
+	// request's
+	Method   string  `json:"method,omitempty"`
+	URL      string  `json:"url,omitempty"`
+	Status   int     `json:"status,omitempty"`
+	Duration float64 `json:"duration_ms,omitempty"`
+	// Value is the name of the value extracted
+	Value string `json:"value,omitempty"`
+	// Assertion is the check made: "status", "forbid", "body_sha256",
+	// "invariants" or "post_hook"
+	Assertion string `json:"assertion,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// EventLog streams the events of executions as NDJSON (JSON lines) while they
+// run, e.g. to a file tailed by a log shipper. The SensitiveValues of the flows
+// are redacted. It's safe for concurrent use.
+type EventLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewEventLog creates an event log writing to w
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{w: w}
+}
+
+// Write appends the event to the log
+func (l *EventLog) Write(event Event) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err = l.w.Write(append(b, '\n')); err != nil && l.err == nil {
+		l.err = err
+	}
+	return err
+}
+
+// Err returns the first error writing the log. Unlike the Audit log's, they
+// don't fail the executions.
+func (l *EventLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// event writes the step's event to the flow's event log, if any
+func (f *Flow) event(i int, step *Step, event Event, err error) {
+	if f.Events == nil {
+		return
+	}
+	event.Time, event.RunID, event.Step, event.StepName = time.Now().UTC(), f.RunID, i, step.Name
+	if err != nil {
+		event.Error = f.redact(err.Error())
+	}
+	f.Events.Write(event)
+}
+
+// requestEvent logs the step's request, started at started
+func (f *Flow) requestEvent(i int, step *Step, resp *http.Response, started time.Time, err error) {
+	event := Event{Type: EventRequest, Method: step.Request.Method, URL: f.redact(step.Request.URL),
+		Duration: ms(time.Since(started))}
+	if resp != nil {
+		event.Status = resp.StatusCode
+	}
+	f.event(i, step, event, err)
+}
+
+// assertion logs the step's assertion, returning its error
+func (f *Flow) assertion(i int, step *Step, name string, err error) error {
+	f.event(i, step, Event{Type: EventAssertion, Assertion: name}, err)
+	return err
+}
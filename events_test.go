@@ -0,0 +1,95 @@
+package httpsim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<b id="token">abc</b>`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	f := Flow{
+		RequiredValues:  []string{"password"},
+		SensitiveValues: []string{"password"},
+		Events:          NewEventLog(&buf),
+		Steps: []Step{{
+			Name:      "login",
+			Request:   Request{URL: srv.URL + "/login?p={{.password}}", Method: "GET"},
+			KeysInput: []string{"password"},
+			Forbid:    []string{"error"},
+			KeysOutput: []Extracter{
+				Extractable{Name: "token", AfterThis: `id="token">`, BeforeThis: "<", MaxLength: -1, MinLength: -1},
+			},
+			PostHook: func(int, http.Header, []byte) error { return errors.New("rejected s3cr&t") },
+		}},
+	}
+	assert.NotNil(t, f.Execute(map[string]interface{}{"password": "s3cr&t"}))
+	assert.NotContains(t, buf.String(), "s3cr")
+
+	var events []Event
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event Event
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, f.RunID, event.RunID)
+		assert.Equal(t, "login", event.StepName)
+		assert.False(t, event.Time.IsZero())
+		events = append(events, event)
+	}
+	if !assert.Len(t, events, 5) {
+		return
+	}
+	assert.Equal(t, EventRequest, events[0].Type)
+	assert.Equal(t, "GET", events[0].Method)
+	assert.Equal(t, srv.URL+"/login?p="+redacted, events[0].URL)
+	assert.Equal(t, 200, events[0].Status)
+	assert.Equal(t, Event{Type: EventAssertion, Assertion: "status"}, stripped(events[1]))
+	assert.Equal(t, Event{Type: EventAssertion, Assertion: "forbid"}, stripped(events[2]))
+	assert.Equal(t, Event{Type: EventExtraction, Value: "token"}, stripped(events[3]))
+	assert.Equal(t, Event{Type: EventAssertion, Assertion: "post_hook",
+		Error: "Step 0.'login' rejected " + redacted}, stripped(events[4]))
+	assert.Nil(t, f.Events.Err())
+}
+
+func TestFlow_ExecuteEventsRequestError(t *testing.T) {
+	var buf bytes.Buffer
+	f := Flow{
+		Events: NewEventLog(&buf),
+		Steps:  []Step{{Name: "down", Request: Request{URL: "http://127.0.0.1:1/", Method: "GET"}}},
+	}
+	assert.NotNil(t, f.Execute(nil))
+
+	var event Event
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &event))
+	assert.Equal(t, EventRequest, event.Type)
+	assert.Equal(t, 0, event.Status)
+	assert.NotEmpty(t, event.Error)
+}
+
+func TestEventLog_Err(t *testing.T) {
+	l := NewEventLog(failingWriter{})
+	assert.NotNil(t, l.Write(Event{Type: EventRequest}))
+	assert.EqualError(t, l.Err(), "disk full")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// stripped returns the event without its common fields
+func stripped(e Event) Event {
+	return Event{Type: e.Type, Value: e.Value, Assertion: e.Assertion, Error: e.Error}
+}
@@ -0,0 +1,111 @@
+package httpsim
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Experiment is an overlay of values, headers and cookies applied to a share of
+// a Runner's runs e.g. 10% of the users sending a feature flag cookie
+type Experiment struct {
+	Name string
+	// Percent is the share of the runs (0-100) the experiment is applied to
+	Percent float64
+	// Values override the run's values
+	Values map[string]interface{}
+	// Header is set on every request, overriding the steps' header
+	Header http.Header
+	// Cookies are sent with every request, see Request.Cookies
+	Cookies []CookieTemplate
+}
+
+// ExperimentResult are the results of the runs of an experiment, "control" for
+// the runs without any
+type ExperimentResult struct {
+	Experiment string
+	Runs       int
+	Failures   int
+	// Duration is the total duration of the runs
+	Duration time.Duration
+}
+
+// MeanDuration is the mean duration of the runs
+func (r ExperimentResult) MeanDuration() time.Duration {
+	if r.Runs == 0 {
+		return 0
+	}
+	return r.Duration / time.Duration(r.Runs)
+}
+
+// pickExperiment returns the experiment of a run, nil for the control group
+func (r *Runner) pickExperiment() *Experiment {
+	if len(r.Experiments) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	if r.Rand == nil {
+		r.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	n := r.Rand.Float64() * 100
+	r.mu.Unlock()
+	for i := range r.Experiments {
+		if n -= r.Experiments[i].Percent; n < 0 {
+			return &r.Experiments[i]
+		}
+	}
+	return nil
+}
+
+// apply applies the experiment to the run's flow and values
+func (e *Experiment) apply(f *Flow, values map[string]interface{}) {
+	for k, v := range e.Values {
+		values[k] = v
+	}
+	for _, steps := range [][]Step{f.Steps, f.Teardown} {
+		for i := range steps {
+			req := &steps[i].Request
+			for k, v := range e.Header {
+				if req.Header == nil {
+					req.Header = http.Header{}
+				}
+				req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+			if len(e.Cookies) != 0 {
+				req.Cookies = append(append([]CookieTemplate(nil), req.Cookies...), e.Cookies...)
+			}
+		}
+	}
+}
+
+// record adds the run to its experiment's results
+func (r *Runner) record(experiment string, err error, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.results == nil {
+		r.results = map[string]*ExperimentResult{}
+	}
+	res, ok := r.results[experiment]
+	if !ok {
+		res = &ExperimentResult{Experiment: experiment}
+		r.results[experiment] = res
+	}
+	res.Runs++
+	res.Duration += d
+	if err != nil {
+		res.Failures++
+	}
+}
+
+// Results returns the results of the runs by experiment, sorted by name
+func (r *Runner) Results() []ExperimentResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := make([]ExperimentResult, 0, len(r.results))
+	for _, res := range r.results {
+		results = append(results, *res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Experiment < results[j].Experiment })
+	return results
+}
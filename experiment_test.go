@@ -0,0 +1,67 @@
+package httpsim
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_Experiments(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		flag, _ := r.Cookie("flag")
+		key := r.URL.Query().Get("plan") + "/" + r.Header.Get("X-Variant")
+		if flag != nil {
+			key += "/" + flag.Value
+		}
+		seen[key]++
+		if flag != nil && flag.Value == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{Name: "home", Request: Request{URL: srv.URL + "/?plan={{.plan}}", Method: "GET",
+		Header: http.Header{"X-Variant": {"a"}}}, KeysInput: []string{"plan"}}}}
+	compiled, err := f.CompileFlow()
+	assert.Nil(t, err)
+	r := NewRunner(compiled)
+	r.Rand = rand.New(rand.NewSource(1))
+	r.Experiments = []Experiment{
+		{Name: "new-plan", Percent: 30, Values: map[string]interface{}{"plan": "pro"}, Header: http.Header{"x-variant": {"b"}}},
+		{Name: "flag", Percent: 20, Cookies: []CookieTemplate{{Name: "flag", Value: "broken"}}},
+	}
+	for i := 0; i < 200; i++ {
+		r.Run(map[string]interface{}{"plan": "free"})
+	}
+
+	results := r.Results()
+	assert.Len(t, results, 3)
+	byName := map[string]ExperimentResult{}
+	total := 0
+	for _, res := range results {
+		byName[res.Experiment] = res
+		total += res.Runs
+	}
+	assert.Equal(t, 200, total)
+	assert.Equal(t, []string{"control", "flag", "new-plan"},
+		[]string{results[0].Experiment, results[1].Experiment, results[2].Experiment})
+	assert.InDelta(t, 100, byName["control"].Runs, 25)
+	assert.InDelta(t, 60, byName["new-plan"].Runs, 20)
+	assert.Equal(t, byName["flag"].Runs, byName["flag"].Failures)
+	assert.Equal(t, 0, byName["control"].Failures)
+	assert.True(t, byName["control"].MeanDuration() > 0)
+
+	assert.Equal(t, byName["control"].Runs, seen["free/a"])
+	assert.Equal(t, byName["new-plan"].Runs, seen["pro/b"])
+	assert.Equal(t, byName["flag"].Runs, seen["free/a/broken"])
+	// the definition isn't changed
+	assert.Equal(t, "a", f.Steps[0].Request.Header.Get("X-Variant"))
+}
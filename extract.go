@@ -0,0 +1,171 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ExtractionErrors are the errors of a step extracting its values in parallel
+type ExtractionErrors []*ExtractionError
+
+func (e ExtractionErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// delimitedExtracter is an extracter rendering its Templated fields with the
+// delimiters of its step's templates
+type delimitedExtracter interface {
+	withDelims(d Delims) Extracter
+}
+
+// withDelims returns the extracter rendering its templates with the delimiters
+func withDelims(e Extracter, d Delims) Extracter {
+	if de, ok := e.(delimitedExtracter); ok {
+		return de.withDelims(d)
+	}
+	return e
+}
+
+// extraction is the result of one of the KeysOutput
+type extraction struct {
+	name      string
+	value     interface{}
+	err       error
+	recovered *RecoveredValue
+}
+
+// extractOutputs runs the step's KeysOutput on the body and stores them in the values.
+// Binary bodies (images, PDFs...) are only given to BinaryExtracters.
+func (f *Flow) extractOutputs(i int, step *Step, header http.Header, body []byte) error {
+	contentType := sniffContentType(header, body)
+	binary := isBinary(contentType)
+	for _, extract := range step.KeysOutput {
+		if _, ok := extract.(BinaryExtracter); !ok && binary {
+			return fmt.Errorf("Step %d.'%s' failed because response is binary (%s) and %T isn't a BinaryExtracter",
+				i, step.Name, contentType, extract)
+		}
+	}
+	text := string(body)
+	idx := newBodyIndex(text)
+
+	if !step.ParallelExtract {
+		for _, extract := range step.KeysOutput {
+			ex := f.extractOne(step, extract, body, idx, contentType, binary)
+			if err := f.storeExtraction(i, step, ex); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Extracters only read the values, which are written once they're all done
+	results := make([]extraction, len(step.KeysOutput))
+	var wg sync.WaitGroup
+	for j, extract := range step.KeysOutput {
+		wg.Add(1)
+		go func(j int, extract Extracter) {
+			defer wg.Done()
+			defer func() {
+				if p := recovered(recover()); p != nil {
+					results[j].err = p
+				}
+			}()
+			results[j] = f.extractOne(step, extract, body, idx, contentType, binary)
+		}(j, extract)
+	}
+	wg.Wait()
+	for _, ex := range results {
+		if p, ok := ex.err.(*panicError); ok {
+			return p
+		}
+	}
+
+	var errs ExtractionErrors
+	for _, ex := range results {
+		if err := f.storeExtraction(i, step, ex); err != nil {
+			ee, ok := err.(*ExtractionError)
+			if !ok {
+				return err
+			}
+			errs = append(errs, ee)
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	} else if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// extractOne runs the extracter, then its Recovery if it failed
+func (f *Flow) extractOne(step *Step, e Extracter, body []byte, idx *bodyIndex, contentType string,
+	binary bool) extraction {
+	var ex extraction
+	e = withDelims(e, f.delims(step))
+	if ve, ok := e.(ValueExtracter); ok && !binary {
+		ex.name, ex.value, ex.err = ve.ExtractValue(idx.body, f.Values)
+	} else {
+		ex.name, ex.value, ex.err = runExtracter(e, body, idx, contentType, binary, f.Values,
+			f.decompressionLimit(step, body))
+	}
+	n, err := ex.name, ex.err
+	if err != nil && step.Recovery[n] != nil {
+		// one markup change shouldn't take the flow down, try the fallback
+		if _, rs, rerr := runExtracter(withDelims(step.Recovery[n], f.delims(step)), body, idx, contentType, binary, f.Values,
+			f.decompressionLimit(step, body)); rerr == nil {
+			ex.value, ex.err = rs, nil
+			ex.recovered = &RecoveredValue{Name: n, Err: err}
+		}
+	}
+	return ex
+}
+
+// storeExtraction stores the extracted value in the values
+func (f *Flow) storeExtraction(i int, step *Step, ex extraction) (err error) {
+	defer func() { f.event(i, step, Event{Type: EventExtraction, Value: ex.name}, err) }()
+	if ex.err != nil {
+		return &ExtractionError{Step: i, StepName: step.Name, Value: ex.name, Err: ex.err}
+	}
+	if ex.name == "" {
+		return fmt.Errorf("Step %d.'%s' failed because extracted value has no index %v",
+			i, step.Name, ex.value)
+	}
+	if s, ok := ex.value.(string); ok {
+		var err error
+		if ex.value, err = f.normalize(step, ex.name, s); err != nil {
+			return &ExtractionError{Step: i, StepName: step.Name, Value: ex.name, Err: err}
+		}
+	}
+	if resp := step.Response; resp != nil && ex.recovered != nil {
+		resp.Recovered = append(resp.Recovered, *ex.recovered)
+	}
+	if err := f.checkWrite(i, step, ex.name); err != nil {
+		return err
+	}
+	f.Values[ex.name] = ex.value
+	f.provenance(i, step, ex.name, ex.recovered != nil)
+	return nil
+}
+
+// runExtracter runs the extracter, using ExtractBinary for binary bodies and the
+// body's shared index for Extractables. PDFs are inflated up to the limit.
+func runExtracter(e Extracter, body []byte, idx *bodyIndex, contentType string, binary bool,
+	values map[string]interface{}, limit *decompressionLimit) (string, string, error) {
+	if pe, ok := e.(PDFTextExtractable); ok && binary {
+		return pe.extractPDF(body, values, limit)
+	}
+	if be, ok := e.(BinaryExtracter); ok && binary {
+		return be.ExtractBinary(body, contentType, values)
+	}
+	if ex, ok := e.(Extractable); ok {
+		return ex.extractIndexed(idx, values)
+	}
+	return e.Extract(idx.body, values)
+}
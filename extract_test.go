@@ -0,0 +1,50 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteParallelExtract(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(w, "<td id=\"c%d\">%d</td>\n", i, i*i)
+		}
+	}))
+	defer srv.Close()
+
+	var outputs []Extracter
+	for i := 0; i < 50; i++ {
+		outputs = append(outputs, Extractable{
+			Name: fmt.Sprintf("c%d", i), AfterThis: fmt.Sprintf(`id="c%d">`, i), BeforeThis: "<",
+			MaxLength: -1, MinLength: -1,
+		})
+	}
+	// same key written twice: the last declared wins, as when sequential
+	outputs = append(outputs, Extractable{Name: "c0", AfterThis: `id="c1">`, BeforeThis: "<", MaxLength: -1, MinLength: -1})
+
+	f := Flow{Steps: []Step{{
+		Name:            "table",
+		Request:         Request{URL: srv.URL, Method: "GET"},
+		ParallelExtract: true,
+		KeysOutput:      outputs,
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "1", f.Values["c0"])
+	assert.Equal(t, "2401", f.Values["c49"])
+
+	f.Steps[0].KeysOutput = []Extracter{
+		Extractable{Name: "a", AfterThis: "nope", BeforeThis: "<"},
+		outputs[3],
+		Extractable{Name: "b", AfterThis: "nope either", BeforeThis: "<"},
+	}
+	err := f.Execute(nil)
+	assert.IsType(t, ExtractionErrors{}, err)
+	assert.EqualError(t, err, "Step 0.'table' failed because couldn't extract 'a': not found\n"+
+		"Step 0.'table' failed because couldn't extract 'b': not found")
+	assert.Equal(t, "9", f.Values["c3"])
+}
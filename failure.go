@@ -0,0 +1,261 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// The phases of a step, where it may fail
+const (
+	// PhaseInputs checks the step's values and renders its request
+	PhaseInputs = "inputs"
+	// PhaseRequest sends the request and reads the response
+	PhaseRequest = "request"
+	// PhaseChecks checks the response (DNS, TLS, status, Forbid...)
+	PhaseChecks = "checks"
+	// PhaseExtraction extracts the outputs and checks the invariants
+	PhaseExtraction = "extraction"
+	// PhasePostHook runs the PostHook and saves the snapshot
+	PhasePostHook = "post hook"
+)
+
+// excerptLength is the number of bytes of the body kept when no marker is found
+const excerptLength = 200
+
+// FailureReport is everything about a failed step, to tell what went wrong
+// without rerunning the flow
+type FailureReport struct {
+	// RunID is the failed execution's, see Flow.Trace
+	RunID string
+	// Seed is the seed of the execution's generated values, see
+	// Flow.GeneratorSeed
+	Seed     int64
+	Step     int
+	StepName string
+	// Phase is the phase the step failed in, see PhaseInputs...
+	Phase string
+	// Err is the step's error, its message redacted (see Flow.SensitiveValues
+	// and Flow.Scrubber) as the URL and Excerpt are
+	Err error
+
+	// Method and URL are the step's request, rendered when the failure is past
+	// the PhaseInputs
+	Method string
+	URL    string
+	// StatusCode is 0 when there was no response
+	StatusCode int
+	// Excerpt is the response body around the first marker of the step's
+	// extracters found in it, or its start when none is
+	Excerpt string
+	// MissingMarkers are the markers of the step's extracters the body doesn't contain
+	MissingMarkers []string
+
+	// Cookies are the jar's cookies for the request's URL
+	Cookies []CookieSummary
+	// ValuesPresent are the names of the flow's values, ValuesNeeded the step's
+	// KeysInput and ValuesMissing the ones empty or not present
+	ValuesPresent []string
+	ValuesNeeded  []string
+	ValuesMissing []string
+
+	// Wire are the dumps of the requests of the failing step, and its
+	// predecessor's first, see Flow.WireDumps
+	Wire []WireDump
+
+	// Checkpoint replays the step, see Flow.ReplayStep
+	Checkpoint *Checkpoint
+}
+
+// CookieSummary is a cookie of the session, its value isn't kept
+type CookieSummary struct {
+	Name   string
+	Length int
+}
+
+// stepFailure is the first step failing during an execution
+type stepFailure struct {
+	step  int
+	phase string
+	// sent is the step as executed
+	sent Step
+	err  error
+	wire []WireDump
+	cp   *Checkpoint
+}
+
+// failed records the first failure of the run
+func (f *Flow) failed(run *runState, i int, def *Step, err error) {
+	if f.failure != nil {
+		return
+	}
+	failure := &stepFailure{step: i, phase: run.phase, sent: *def, err: err, wire: f.failureWire(run)}
+	if run.current != nil {
+		failure.sent = *run.current
+	}
+	failure.cp = f.checkpoint(i, &failure.sent)
+	f.failure = failure
+}
+
+// Failure returns the report of the step that failed the last execution, nil
+// when it succeeded or failed before executing a step
+func (f *Flow) Failure() *FailureReport {
+	failure := f.failure
+	if failure == nil {
+		return nil
+	}
+	step := &failure.sent
+	r := &FailureReport{
+		RunID:        f.RunID,
+		Seed:         f.GeneratedSeed,
+		Step:         failure.step,
+		StepName:     step.Name,
+		Phase:        failure.phase,
+		Err:          f.redactError(failure.err),
+		Method:       step.Request.Method,
+		URL:          f.redact(step.Request.URL),
+		ValuesNeeded: step.KeysInput,
+		Wire:         failure.wire,
+		Checkpoint:   failure.cp,
+	}
+
+	if resp := step.Response; resp != nil {
+		if resp.Raw != nil {
+			r.StatusCode = resp.Raw.StatusCode
+		}
+		r.Excerpt, r.MissingMarkers = markerExcerpt(resp.Body, extractMarkers(step))
+		r.Excerpt = f.redact(r.Excerpt)
+	}
+
+	if u, err := url.Parse(step.Request.URL); err == nil && f.CookieJar != nil && u.Host != "" {
+		for _, c := range f.CookieJar.Cookies(u) {
+			r.Cookies = append(r.Cookies, CookieSummary{Name: c.Name, Length: len(c.Value)})
+		}
+	}
+
+	for k := range f.Values {
+		r.ValuesPresent = append(r.ValuesPresent, k)
+	}
+	sort.Strings(r.ValuesPresent)
+	for _, k := range step.KeysInput {
+		if v, ok := f.Values[k]; !ok || v == "" {
+			r.ValuesMissing = append(r.ValuesMissing, k)
+		}
+	}
+	return r
+}
+
+// redactedError is an error whose message is redacted, see Flow.redactError
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the underlying error
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns the error with its message redacted, err itself when
+// there's nothing to redact
+func (f *Flow) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := f.redact(err.Error()); msg != err.Error() {
+		return &redactedError{msg: msg, err: err}
+	}
+	return err
+}
+
+// extractMarkers returns the markers the step's Extractables look for, the
+// templated ones are skipped
+func extractMarkers(step *Step) []string {
+	var markers []string
+	for _, e := range step.KeysOutput {
+		ex, ok := e.(Extractable)
+		if !ok || ex.Templated {
+			continue
+		}
+		for _, m := range []string{ex.WindowStart, ex.Anchor, ex.AfterThis} {
+			if m != "" {
+				markers = append(markers, m)
+			}
+		}
+	}
+	return markers
+}
+
+// markerExcerpt returns the body around the first marker found, and the markers
+// not found
+func markerExcerpt(body []byte, markers []string) (string, []string) {
+	ex := ""
+	var missing []string
+	for _, m := range markers {
+		i := strings.Index(string(body), m)
+		if i == -1 {
+			missing = append(missing, m)
+			continue
+		}
+		if ex == "" {
+			ex = excerpt(body, i, i+len(m))
+		}
+	}
+	if ex == "" && len(body) != 0 {
+		end := len(body)
+		if end > excerptLength {
+			end = excerptLength
+		}
+		ex = excerpt(body[:end], 0, end)
+	}
+	return ex, missing
+}
+
+// String renders the report for humans
+func (r *FailureReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Step %d.'%s' failed in %s: %v\n", r.Step, r.StepName, r.Phase, r.Err)
+	fmt.Fprintf(&b, "Run: %s\n", r.RunID)
+	if r.Seed != 0 {
+		fmt.Fprintf(&b, "Seed: %d\n", r.Seed)
+	}
+	fmt.Fprintf(&b, "Request: %s %s\n", r.Method, r.URL)
+	if r.StatusCode != 0 {
+		fmt.Fprintf(&b, "Status: %d\n", r.StatusCode)
+	}
+	if r.Excerpt != "" {
+		fmt.Fprintf(&b, "Body: %s\n", r.Excerpt)
+	}
+	if len(r.MissingMarkers) != 0 {
+		fmt.Fprintf(&b, "Markers not found: '%s'\n", strings.Join(r.MissingMarkers, "', '"))
+	}
+	var cookies []string
+	for _, c := range r.Cookies {
+		cookies = append(cookies, fmt.Sprintf("%s (%d bytes)", c.Name, c.Length))
+	}
+	if len(cookies) == 0 {
+		cookies = []string{"none"}
+	}
+	fmt.Fprintf(&b, "Cookies: %s\n", strings.Join(cookies, ", "))
+	fmt.Fprintf(&b, "Values: %s\n", strings.Join(r.ValuesPresent, ", "))
+	if len(r.ValuesNeeded) != 0 {
+		fmt.Fprintf(&b, "Needed: %s\n", strings.Join(r.ValuesNeeded, ", "))
+	}
+	if len(r.ValuesMissing) != 0 {
+		fmt.Fprintf(&b, "Missing: %s\n", strings.Join(r.ValuesMissing, ", "))
+	}
+	for _, w := range r.Wire {
+		fmt.Fprintf(&b, "\n--- Step %d.'%s' request\n%s\n", w.Step, w.StepName, w.Request)
+		if w.Err != "" {
+			fmt.Fprintf(&b, "--- failed: %s\n", w.Err)
+		} else {
+			fmt.Fprintf(&b, "--- response\n%s\n", w.Response)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package httpsim
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Failure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abcdef", Path: "/"})
+		if r.URL.Path == "/login" {
+			w.Write([]byte(`<form><input name="nonce" value="n1"></form>`))
+			return
+		}
+		w.Write([]byte(`<div class="error">Your session expired</div>`))
+	}))
+	defer srv.Close()
+	f := Flow{
+		RequiredValues: []string{"user"},
+		Steps: []Step{
+			{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "GET"},
+				KeysOutput: []Extracter{Extractable{Name: "nonce", AfterThis: `name="nonce" value="`, BeforeThis: `"`, MaxLength: -1, MinLength: -1}}},
+			{Name: "account", Request: Request{URL: srv.URL + "/account/{{.user}}", Method: "GET"}, KeysInput: []string{"user"},
+				KeysOutput: []Extracter{Extractable{Name: "balance", AfterThis: `<span id="balance">`, BeforeThis: "<", MaxLength: -1, MinLength: -1}}},
+		},
+	}
+	assert.Nil(t, f.Failure())
+	err := f.Execute(map[string]interface{}{"user": "bob"})
+	assert.NotNil(t, err)
+
+	r := f.Failure()
+	assert.NotNil(t, r)
+	assert.Equal(t, 1, r.Step)
+	assert.Equal(t, "account", r.StepName)
+	assert.Equal(t, PhaseExtraction, r.Phase)
+	assert.Equal(t, err, r.Err)
+	assert.Equal(t, srv.URL+"/account/bob", r.URL)
+	assert.Equal(t, 200, r.StatusCode)
+	assert.Equal(t, `<div class="error">Your session expired</div>`, r.Excerpt)
+	assert.Equal(t, []string{`<span id="balance">`}, r.MissingMarkers)
+	assert.Equal(t, []CookieSummary{{Name: "session", Length: 6}}, r.Cookies)
+	assert.Equal(t, []string{"nonce", "user"}, r.ValuesPresent)
+	assert.Equal(t, []string{"user"}, r.ValuesNeeded)
+	assert.Empty(t, r.ValuesMissing)
+	assert.Contains(t, r.String(), "Step 1.'account' failed in extraction: ")
+	assert.Contains(t, r.String(), "Markers not found: '<span id=\"balance\">'\n")
+	assert.Contains(t, r.String(), "Cookies: session (6 bytes)\n")
+
+	// the report is of the last execution
+	f.Steps = f.Steps[:1]
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "bob"}))
+	assert.Nil(t, f.Failure())
+}
+
+func TestFlow_FailurePhases(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	f := Flow{Steps: []Step{{Name: "a", Request: Request{URL: srv.URL + "/{{.id}}", Method: "GET"}, KeysInput: []string{"id"}}}}
+	assert.NotNil(t, f.Execute(nil))
+	assert.Equal(t, PhaseInputs, f.Failure().Phase)
+	assert.Equal(t, []string{"id"}, f.Failure().ValuesMissing)
+	assert.Equal(t, 0, f.Failure().StatusCode)
+
+	assert.NotNil(t, f.Execute(map[string]interface{}{"id": "1"}))
+	assert.Equal(t, PhaseChecks, f.Failure().Phase)
+	assert.Equal(t, 403, f.Failure().StatusCode)
+	assert.Equal(t, srv.URL+"/1", f.Failure().URL)
+
+	f.Steps[0].Request.URL = "http://127.0.0.1:1/{{.id}}"
+	assert.NotNil(t, f.Execute(map[string]interface{}{"id": "1"}))
+	assert.Equal(t, PhaseRequest, f.Failure().Phase)
+}
+
+func TestFlow_FailureRedacted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<p>Invalid token s3cr&t</p>`))
+	}))
+	defer srv.Close()
+	f := Flow{
+		SensitiveValues: []string{"token"},
+		Steps: []Step{{Name: "a", Request: Request{URL: srv.URL + "/me?token={{.token}}", Method: "GET"},
+			KeysInput: []string{"token"}, PostHook: func(int, http.Header, []byte) error { return nil }}},
+	}
+	err := f.Execute(map[string]interface{}{"token": "s3cr&t"})
+	assert.NotNil(t, err)
+	var status *StatusError
+	assert.True(t, errors.As(f.Failure().Err, &status))
+
+	f.Steps[0].ExpectStatus = []string{"403"}
+	f.Steps[0].PostHook = func(int, http.Header, []byte) error { return errors.New("rejected s3cr&t") }
+	err = f.Execute(map[string]interface{}{"token": "s3cr&t"})
+	assert.NotNil(t, err)
+	r := f.Failure()
+	assert.Equal(t, srv.URL+"/me?token="+redacted, r.URL)
+	assert.Equal(t, "<p>Invalid token "+redacted+"</p>", r.Excerpt)
+	assert.Equal(t, "Step 0.'a' rejected "+redacted, r.Err.Error())
+	assert.True(t, errors.Is(r.Err, err))
+	assert.NotContains(t, r.String(), "s3cr")
+}
@@ -0,0 +1,147 @@
+package httpsim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Faults are the failures injected in the responses of a ReplayTransport or
+// MockServer, to test flows against them. The rates are the shares (0 to 1) of
+// the requests affected, the same Seed injects the same faults in the same
+// sequence of requests.
+type Faults struct {
+	Seed int64
+	// ErrorRate of the requests are answered with ErrorStatus, 500 when 0
+	ErrorRate   float64
+	ErrorStatus int
+	// DelayRate of the responses are delayed by Delay
+	DelayRate float64
+	Delay     time.Duration
+	// ResetRate of the connections are reset before the response
+	ResetRate float64
+	// TruncateRate of the responses have their body cut in half, the connection
+	// is closed
+	TruncateRate float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// fault is the faults injected in a response
+type fault struct {
+	err, delay, reset, truncate bool
+}
+
+// next returns the faults of the next response
+func (f *Faults) next() fault {
+	if f == nil {
+		return fault{}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(f.Seed))
+	}
+	// always draw them all, so the faults of a request don't depend on the rates
+	err, delay, reset, truncate := f.rand.Float64(), f.rand.Float64(), f.rand.Float64(), f.rand.Float64()
+	return fault{err: err < f.ErrorRate, delay: delay < f.DelayRate, reset: reset < f.ResetRate, truncate: truncate < f.TruncateRate}
+}
+
+// apply replaces the response by the error status when injected
+func (f *Faults) apply(ft fault, resp MockResponse) MockResponse {
+	if ft.err {
+		status := f.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		resp = MockResponse{Status: status, Body: []byte(http.StatusText(status))}
+	}
+	return resp
+}
+
+// errConnectionReset is the error of the requests whose connection is reset
+var errConnectionReset = fmt.Errorf("injected fault: %w", syscall.ECONNRESET)
+
+// truncatedBody reads the truncated body, then fails with io.ErrUnexpectedEOF
+type truncatedBody struct {
+	r io.Reader
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return nil
+}
+
+// sleep waits for the delay, or for the request to be canceled
+func sleep(req *http.Request, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// faultyResponse returns the transport's response with the faults injected
+func (f *Faults) faultyResponse(req *http.Request, ft fault, resp *http.Response, body []byte) (*http.Response, error) {
+	if ft.delay {
+		if err := sleep(req, f.Delay); err != nil {
+			return nil, err
+		}
+	}
+	if ft.reset {
+		return nil, errConnectionReset
+	}
+	if ft.truncate {
+		resp.Body = &truncatedBody{r: bytes.NewReader(body[:len(body)/2])}
+	}
+	return resp, nil
+}
+
+// serveFaulty writes the server's response with the faults injected, it
+// returns false when the response is left to the caller
+func (f *Faults) serveFaulty(w http.ResponseWriter, req *http.Request, ft fault, resp MockResponse) bool {
+	if ft.delay {
+		if sleep(req, f.Delay) != nil {
+			return true
+		}
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if ft.reset && ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+		}
+		return true
+	}
+	if !ft.truncate || !ok {
+		return false
+	}
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(resp.Body)))
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body[:len(resp.Body)/2])
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	if conn, _, err := hijacker.Hijack(); err == nil {
+		conn.Close()
+	}
+	return true
+}
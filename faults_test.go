@@ -0,0 +1,92 @@
+package httpsim
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func faultyInteractions() []Interaction {
+	return []Interaction{{Request: RecordedRequest{Method: "GET", URL: "https://site/page"}, Status: 200, Body: []byte("0123456789")}}
+}
+
+func TestFaultsDeterministic(t *testing.T) {
+	run := func() []fault {
+		f := &Faults{Seed: 7, ErrorRate: 0.5, DelayRate: 0.5, ResetRate: 0.5, TruncateRate: 0.5}
+		var faults []fault
+		for i := 0; i < 20; i++ {
+			faults = append(faults, f.next())
+		}
+		return faults
+	}
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Contains(t, first, fault{})
+	assert.NotEqual(t, first[0], first[1])
+
+	var none *Faults
+	assert.Equal(t, fault{}, none.next())
+}
+
+func TestReplayTransportFaults(t *testing.T) {
+	get := func(faults *Faults) (*http.Response, error) {
+		tr := NewReplayTransport(faultyInteractions(), nil)
+		tr.Faults = faults
+		req, _ := http.NewRequest("GET", "https://site/page", nil)
+		return tr.RoundTrip(req)
+	}
+
+	resp, err := get(&Faults{ErrorRate: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+	resp, err = get(&Faults{ErrorRate: 1, ErrorStatus: 503})
+	assert.Nil(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+
+	_, err = get(&Faults{ResetRate: 1})
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+
+	resp, err = get(&Faults{TruncateRate: 1})
+	assert.Nil(t, err)
+	body, err := io.ReadAll(resp.Body)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, "01234", string(body))
+
+	started := time.Now()
+	resp, err = get(&Faults{DelayRate: 1, Delay: 50 * time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+}
+
+func TestMockServerFaults(t *testing.T) {
+	execute := func(faults *Faults) (*Flow, error) {
+		mock := NewMockServer(faultyInteractions(), nil)
+		mock.Faults = faults
+		server := httptest.NewServer(mock)
+		defer server.Close()
+		f := &Flow{Steps: []Step{{Name: "page", Request: Request{URL: server.URL + "/page", Method: "GET"}}}}
+		return f, f.Execute(nil)
+	}
+
+	f, err := execute(&Faults{ErrorRate: 1})
+	assert.NotNil(t, err)
+	assert.Equal(t, 500, f.Steps[0].Response.Raw.StatusCode)
+
+	_, err = execute(&Faults{ResetRate: 1})
+	assert.NotNil(t, err)
+
+	_, err = execute(&Faults{TruncateRate: 1})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unexpected EOF")
+
+	f, err = execute(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(f.Steps[0].Response.Body))
+}
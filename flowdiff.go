@@ -0,0 +1,220 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DiffFlows returns a human-readable summary of the changes of the definition
+// from a to b, e.g. to review them: the required values, the steps added and
+// removed (matched by name) and, for the others, their request templates,
+// extracters and assertions. Empty when the definitions are the same.
+func DiffFlows(a, b *Flow) string {
+	var lines []string
+	if changes := setChanges(a.RequiredValues, b.RequiredValues); changes != "" {
+		lines = append(lines, "required values: "+changes)
+	}
+	lines = append(lines, diffSteps("", a, b, a.Steps, b.Steps)...)
+	lines = append(lines, diffSteps("teardown ", a, b, a.Teardown, b.Teardown)...)
+	return strings.Join(lines, "\n")
+}
+
+// diffSteps returns the changes of the steps, matched by name
+func diffSteps(kind string, fa, fb *Flow, a, b []Step) []string {
+	index := func(steps []Step) map[string]int {
+		m := map[string]int{}
+		for i, s := range steps {
+			m[s.Name] = i
+		}
+		return m
+	}
+	ia, ib := index(a), index(b)
+	// the steps moved are the ones whose order among the common steps changed
+	rank := func(steps []Step, other map[string]int) map[string]int {
+		m := map[string]int{}
+		for _, s := range steps {
+			if _, ok := other[s.Name]; ok {
+				m[s.Name] = len(m)
+			}
+		}
+		return m
+	}
+	ra, rb := rank(a, ib), rank(b, ia)
+
+	var lines []string
+	for i, s := range a {
+		if _, ok := ib[s.Name]; !ok {
+			lines = append(lines, fmt.Sprintf("- %sstep %d.'%s' %s %s", kind, i, s.Name, s.Request.Method, s.Request.URL))
+		}
+	}
+	for j, s := range b {
+		i, ok := ia[s.Name]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("+ %sstep %d.'%s' %s %s", kind, j, s.Name, s.Request.Method, s.Request.URL))
+			continue
+		}
+		changes := diffStep(fa, fb, &a[i], &s)
+		if ra[s.Name] != rb[s.Name] && i != j {
+			changes = append([]string{fmt.Sprintf("moved from %d to %d", i, j)}, changes...)
+		}
+		if len(changes) != 0 {
+			lines = append(lines, fmt.Sprintf("~ %sstep %d.'%s':", kind, j, s.Name))
+			for _, c := range changes {
+				lines = append(lines, "    "+strings.Replace(c, "\n", "\n    ", -1))
+			}
+		}
+	}
+	return lines
+}
+
+// diffStep returns the changes of a step's definition
+func diffStep(fa, fb *Flow, a, b *Step) []string {
+	var changes []string
+	changed := func(what, old, new string) {
+		if old != new {
+			changes = append(changes, fmt.Sprintf("%s: '%s' -> '%s'", what, old, new))
+		}
+	}
+	changed("method", a.Request.Method, b.Request.Method)
+	changed("URL", a.Request.URL, b.Request.URL)
+
+	keys := map[string]bool{}
+	for k := range a.Request.Header {
+		keys[k] = true
+	}
+	for k := range b.Request.Header {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		changed("header "+k, strings.Join(a.Request.Header[k], ", "), strings.Join(b.Request.Header[k], ", "))
+	}
+	if diff := diffSummary(bodyText(a.Request.Body), bodyText(b.Request.Body)); diff != "" {
+		changes = append(changes, "body:\n"+diff)
+	}
+	if c := setChanges(a.KeysInput, b.KeysInput); c != "" {
+		changes = append(changes, "inputs: "+c)
+	}
+	changes = append(changes, diffExtracters(a.KeysOutput, b.KeysOutput)...)
+	if c := setChanges(fa.describeStep(*a).Assertions, fb.describeStep(*b).Assertions); c != "" {
+		changes = append(changes, "assertions: "+c)
+	}
+	return changes
+}
+
+// bodyText returns the request body template as lines, the form values one per line
+func bodyText(v interface{}) []byte {
+	if form, ok := v.(url.Values); ok {
+		var lines []string
+		for _, k := range sortedKeys(form) {
+			lines = append(lines, k+"="+strings.Join(form[k], ", "))
+		}
+		return []byte(strings.Join(lines, "\n"))
+	}
+	return requestBody(v)
+}
+
+// diffExtracters returns the changes of the extracters, matched by output name
+func diffExtracters(a, b []Extracter) []string {
+	byName := func(extracters []Extracter) (map[string]Extracter, []string) {
+		m := map[string]Extracter{}
+		var names []string
+		for _, e := range extracters {
+			name := extracterName(e)
+			if name == "" {
+				continue
+			}
+			m[name] = e
+			names = append(names, name)
+		}
+		return m, names
+	}
+	ma, na := byName(a)
+	mb, nb := byName(b)
+
+	var changes []string
+	if c := setChanges(na, nb); c != "" {
+		changes = append(changes, "outputs: "+c)
+	}
+	for _, name := range nb {
+		old, ok := ma[name]
+		if !ok {
+			continue
+		}
+		if fields := fieldChanges(old, mb[name]); len(fields) != 0 {
+			changes = append(changes, fmt.Sprintf("output '%s': %s", name, strings.Join(fields, ", ")))
+		}
+	}
+	return changes
+}
+
+// fieldChanges returns the changed fields of two extracters of the same struct
+// type, the extracters that aren't structs (ExtracterFunc) can't be compared
+func fieldChanges(a, b Extracter) []string {
+	va, vb := reflect.Indirect(reflect.ValueOf(a)), reflect.Indirect(reflect.ValueOf(b))
+	if va.Type() != vb.Type() {
+		return []string{fmt.Sprintf("extracter %s -> %s", va.Type(), vb.Type())}
+	}
+	if va.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		field := va.Type().Field(i)
+		if field.PkgPath != "" || field.Type.Kind() == reflect.Func {
+			continue
+		}
+		if old, new := fieldString(va.Field(i)), fieldString(vb.Field(i)); old != new {
+			fields = append(fields, fmt.Sprintf("%s '%s' -> '%s'", field.Name, old, new))
+		}
+	}
+	return fields
+}
+
+// fieldString formats the field, with the value it points to
+func fieldString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		return fmt.Sprintf("%+v", v.Elem().Interface())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// setChanges returns the items removed ("- x") and added ("+ y") from a to b
+func setChanges(a, b []string) string {
+	in := func(s []string, x string) bool {
+		for _, y := range s {
+			if x == y {
+				return true
+			}
+		}
+		return false
+	}
+	var changes []string
+	for _, x := range a {
+		if !in(b, x) {
+			changes = append(changes, "- "+x)
+		}
+	}
+	for _, x := range b {
+		if !in(a, x) {
+			changes = append(changes, "+ "+x)
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// sortedKeys returns the keys of the map, sorted
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffFlows(t *testing.T) {
+	token := Extractable{Name: "token", AfterThis: "<i>", BeforeThis: "</i>", MaxLength: -1, MinLength: -1}
+	a := Flow{
+		RequiredValues: []string{"user", "password"},
+		Steps: []Step{
+			{Name: "home", Request: Request{URL: "https://site/", Method: "GET"}},
+			{Name: "login", Request: Request{URL: "https://site/login", Method: "POST",
+				Header: http.Header{"X-A": {"1"}},
+				Body:   url.Values{"user": {"{{.user}}"}, "password": {"{{.password}}"}}},
+				KeysInput: []string{"user", "password"}, KeysOutput: []Extracter{token}},
+			{Name: "logout", Request: Request{URL: "https://site/logout", Method: "GET"}},
+		},
+	}
+	b := a.CompleteCopy()
+	assert.Equal(t, "", DiffFlows(&a, &b))
+
+	b.RequiredValues = append(b.RequiredValues, "otp")
+	b.Steps = append(b.Steps[:2], Step{Name: "mfa", Request: Request{URL: "https://site/mfa", Method: "POST"}})
+	login := &b.Steps[1]
+	login.Request.Header = http.Header{"X-A": {"2"}, "X-B": {"b"}}
+	login.Request.Body = url.Values{"username": {"{{.user}}"}, "password": {"{{.password}}"}}
+	token.AfterThis = "<b>"
+	token.Iterate = true
+	login.KeysOutput = []Extracter{token, Extractable{Name: "csrf"}}
+	login.Forbid = []string{"error"}
+
+	assert.Equal(t, `required values: + otp
+- step 2.'logout' GET https://site/logout
+~ step 1.'login':
+    header X-A: '1' -> '2'
+    header X-B: '' -> 'b'
+    body:
+    - user={{.user}}
+    + username={{.user}}
+    outputs: + csrf
+    output 'token': AfterThis '<i>' -> '<b>', Iterate 'false' -> 'true'
+    assertions: + body doesn't contain "error"
++ step 2.'mfa' POST https://site/mfa`, DiffFlows(&a, &b))
+
+	c := a.CompleteCopy()
+	c.Steps[0], c.Steps[1] = c.Steps[1], c.Steps[0]
+	c.Steps = append([]Step{{Name: "new", Request: Request{URL: "https://site/new", Method: "GET"}}}, c.Steps...)
+	assert.Equal(t, `+ step 0.'new' GET https://site/new
+~ step 2.'home':
+    moved from 0 to 2`, DiffFlows(&a, &c))
+}
@@ -1 +1,64 @@
 package httpsim
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTransport counts the requests it makes
+type countingTransport struct {
+	n int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.n++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFlow_Client(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+		case "/account":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "s1" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	transport := &countingTransport{}
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Transport: transport, Jar: jar, Timeout: 50 * time.Millisecond}
+	f := Flow{Client: client, Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "GET"}},
+		{Name: "account", Request: Request{URL: srv.URL + "/account", Method: "GET"}},
+	}}
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, 2, transport.n)
+	// the flow's jar is attached to a copy of the client
+	assert.NotEqual(t, jar, f.CookieJar)
+	assert.Equal(t, jar, client.Jar)
+	assert.Empty(t, jar.Cookies(f.Steps[0].Response.Raw.Request.URL))
+
+	// the Transport overrides the client's
+	override := &countingTransport{}
+	f.Transport = override
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, 2, override.n)
+	assert.Equal(t, 2, transport.n)
+
+	f.Steps = append(f.Steps, Step{Name: "slow", Request: Request{URL: srv.URL + "/slow", Method: "GET"}})
+	err := f.ExecuteContext(context.Background(), nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Client.Timeout exceeded")
+}
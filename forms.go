@@ -0,0 +1,79 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// formPage is the last page received, whose forms the next step may submit
+type formPage struct {
+	url  *url.URL
+	body []byte
+}
+
+// hiddenFields returns the hidden fields of the page's first form submitted
+// with the method to the URL, false when there's none
+func (p *formPage) hiddenFields(method, target string) (url.Values, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, false
+	}
+	for _, form := range parseHTML(string(p.body)).findTag("form") {
+		formMethod := form.Attrs["method"]
+		if formMethod == "" {
+			formMethod = http.MethodGet
+		}
+		action, err := p.url.Parse(form.Attrs["action"])
+		if err != nil || !strings.EqualFold(formMethod, method) || !sameEndpoint(action, u) {
+			continue
+		}
+		fields := url.Values{}
+		for _, input := range form.findTag("input") {
+			if strings.EqualFold(input.Attrs["type"], "hidden") && input.Attrs["name"] != "" {
+				fields.Add(input.Attrs["name"], input.Attrs["value"])
+			}
+		}
+		return fields, true
+	}
+	return nil, false
+}
+
+// sameEndpoint returns whether the URLs have the same scheme, host and path
+func sameEndpoint(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host) &&
+		strings.TrimSuffix(a.Path, "/") == strings.TrimSuffix(b.Path, "/")
+}
+
+// carryHiddenFields adds to the step's form body the hidden fields of the
+// previous page's form it submits, unless it sets them
+func (f *Flow) carryHiddenFields(run *runState, i int, step *Step) {
+	if !step.HiddenFields || run.page == nil || safeMethod(step.Request.Method) {
+		return
+	}
+	fields, ok := run.page.hiddenFields(step.Request.Method, step.Request.URL)
+	if !ok {
+		f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name,
+			Message: "forms: the previous page has no form submitted to the step's URL"})
+		return
+	}
+	var form url.Values
+	switch t := step.Request.Body.(type) {
+	case nil:
+		form = url.Values{}
+	case url.Values:
+		// the form is the step definition's
+		form = newBody(t).(url.Values)
+	default:
+		f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name,
+			Message: fmt.Sprintf("forms: can't carry the hidden fields into a %T body", t)})
+		return
+	}
+	for k, v := range fields {
+		if _, set := form[k]; !set {
+			form[k] = v
+		}
+	}
+	step.Request.Body = form
+}
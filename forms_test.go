@@ -0,0 +1,55 @@
+package httpsim
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_HiddenFields(t *testing.T) {
+	var received url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			b, _ := io.ReadAll(r.Body)
+			received, _ = url.ParseQuery(string(b))
+			return
+		}
+		w.Write([]byte(`<form action="/search"><input type="hidden" name="x" value="1"></form>
+<form method="POST" action="login">
+  <input type="hidden" name="__VIEWSTATE" value="abc&amp;d">
+  <INPUT TYPE="HIDDEN" name="nonce" value="n1">
+  <input type="text" name="user" value="">
+  <input type="hidden" name="lang" value="en">
+</form>`))
+	}))
+	defer srv.Close()
+
+	form := url.Values{"user": {"bob"}, "lang": {"fr"}}
+	f := Flow{Steps: []Step{
+		{Name: "page", Request: Request{URL: srv.URL + "/account/", Method: "GET"}},
+		{Name: "login", Request: Request{URL: srv.URL + "/account/login", Method: "POST", Body: form}, HiddenFields: true},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, url.Values{"user": {"bob"}, "lang": {"fr"}, "__VIEWSTATE": {"abc&d"}, "nonce": {"n1"}}, received)
+	assert.Equal(t, url.Values{"user": {"bob"}, "lang": {"fr"}}, form)
+	assert.Empty(t, f.Warnings)
+
+	// the templated forms are carried the hidden fields too
+	f.Steps[1].Request.Body = url.Values{"user": {"{{.user}}"}}
+	f.Steps[1].KeysInput = []string{"user"}
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "alice"}))
+	assert.Equal(t, url.Values{"user": {"alice"}, "lang": {"en"}, "__VIEWSTATE": {"abc&d"}, "nonce": {"n1"}}, received)
+	assert.Empty(t, f.Warnings)
+
+	f.Steps[1].Request.Body = form
+	f.Steps[1].KeysInput = nil
+	f.Steps[1].Request.URL = srv.URL + "/elsewhere"
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, url.Values{"user": {"bob"}, "lang": {"fr"}}, received)
+	assert.Equal(t, []LintWarning{{Step: 1, StepName: "login",
+		Message: "forms: the previous page has no form submitted to the step's URL"}}, f.Warnings)
+}
@@ -0,0 +1,109 @@
+package httpsim
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+)
+
+// SeedBodies are tricky response bodies (empty, truncated, malformed markup,
+// invalid UTF-8...) to seed the fuzzing of extracters with, along with captured
+// bodies
+var SeedBodies = [][]byte{
+	{},
+	[]byte("<"),
+	[]byte("<html><body><div id=\"a\">value</div></body></html>"),
+	[]byte("<div><span>unclosed<div>value"),
+	[]byte("<input name=\"token\" value=\"\">"),
+	[]byte("<input name=\"token\" value=\"a\"><input name=\"token\" value=\"b\">"),
+	[]byte("<!-- <b>commented</b> --><b>value</b>"),
+	[]byte("<script>var s = \"</b>\";</script><b>value</b>"),
+	[]byte("{\"token\": \"value\", \"nested\": {\"token\": null}}"),
+	[]byte("{\"token\": "),
+	[]byte("\xff\xfe<b>\xc3\x28</b>"),
+	[]byte("<b>été \U0001F600</b>"),
+	[]byte("<b></b><b>value</b>"),
+	[]byte("<b><b><b>value</b></b></b>"),
+	bytes.Repeat([]byte("<b>"), 64),
+}
+
+// CheckExtracter runs the extracter on the body like a flow would, e.g. in a
+// fuzz test. The extraction failing is fine, an error is returned when the
+// extracter panics, extracts a value without a name, or, for Extractables,
+// when its indexed and plain extractions disagree.
+func CheckExtracter(e Extracter, body []byte, values map[string]interface{}) (err error) {
+	defer func() {
+		if p := recovered(recover()); p != nil {
+			err = fmt.Errorf("extracter panicked: %v\n%s", p.value, p.stack)
+		}
+	}()
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	idx := newBodyIndex(string(body))
+	name, value, xerr := runExtracter(e, body, idx, sniffContentType(nil, body), false, values, &decompressionLimit{left: -1})
+	if xerr != nil {
+		return nil
+	}
+	if name == "" {
+		return fmt.Errorf("extracted value %q has no name", value)
+	}
+	if ex, ok := e.(Extractable); ok {
+		_, plain, perr := ex.Extract(string(body), values)
+		if perr != nil || plain != value {
+			return fmt.Errorf("indexed extraction %q differs from plain extraction %q (%v)", value, plain, perr)
+		}
+	}
+	return nil
+}
+
+// FuzzBody returns n mutations of the recorded body (truncations, deletions,
+// duplications, markup and whitespace changes...), the same seed giving the same
+// mutations, to test the robustness of extractions against site changes
+func FuzzBody(body []byte, seed int64, n int) [][]byte {
+	r := rand.New(rand.NewSource(seed))
+	inserts := [][]byte{[]byte("<"), []byte(">"), []byte("\""), []byte("</div>"), []byte("<!--"), []byte(" "),
+		[]byte("\n"), []byte("&amp;"), []byte("é"), []byte("\xff")}
+	mutations := make([][]byte, n)
+	for i := range mutations {
+		b := append([]byte(nil), body...)
+		for m := 1 + r.Intn(3); m > 0; m-- {
+			b = mutateBody(r, b, inserts)
+		}
+		mutations[i] = b
+	}
+	return mutations
+}
+
+// mutateBody applies one random mutation to the body
+func mutateBody(r *rand.Rand, b []byte, inserts [][]byte) []byte {
+	if len(b) == 0 {
+		return append(b, inserts[r.Intn(len(inserts))]...)
+	}
+	at := r.Intn(len(b))
+	end := at + r.Intn(len(b)-at) + 1
+	switch r.Intn(6) {
+	case 0:
+		// truncate
+		return b[:at]
+	case 1:
+		// delete a span
+		return append(b[:at:at], b[end:]...)
+	case 2:
+		// duplicate a span
+		return append(append(append([]byte(nil), b[:end]...), b[at:end]...), b[end:]...)
+	case 3:
+		// insert markup or characters
+		return append(append(append([]byte(nil), b[:at]...), inserts[r.Intn(len(inserts))]...), b[at:]...)
+	case 4:
+		// change the case of a span
+		span := bytes.ToLower(b[at:end])
+		if r.Intn(2) == 0 {
+			span = bytes.ToUpper(b[at:end])
+		}
+		return append(append(append([]byte(nil), b[:at]...), span...), b[end:]...)
+	default:
+		// collapse the whitespace of a span
+		return append(append(append([]byte(nil), b[:at]...), bytes.Join(bytes.Fields(b[at:end]), []byte(" "))...), b[end:]...)
+	}
+}
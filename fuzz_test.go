@@ -0,0 +1,61 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzExtractable(f *testing.F) {
+	for _, body := range SeedBodies {
+		f.Add(body)
+	}
+	extracters := []Extracter{
+		Extractable{Name: "b", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1},
+		Extractable{Name: "b", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: 5, MinLength: 1, Iterate: true},
+		Extractable{Name: "last", AfterThis: "value=\"", BeforeThis: "\"", MaxLength: -1, MinLength: -1, Occurrence: -1},
+		Extractable{Name: "json", AfterThis: "\"token\": \"", BeforeThis: "\"", MaxLength: -1, MinLength: -1, MatchRegexp: "^[a-z]+$"},
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		for _, e := range extracters {
+			if err := CheckExtracter(e, body, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCheckExtracter(t *testing.T) {
+	panics := ExtracterFunc(func(body string, values map[string]interface{}) (string, string, error) {
+		return "x", string(body[10]), nil
+	})
+	err := CheckExtracter(panics, []byte("short"), nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "extracter panicked: runtime error: index out of range")
+	assert.Nil(t, CheckExtracter(panics, []byte("long enough body"), nil))
+
+	unnamed := ExtracterFunc(func(string, map[string]interface{}) (string, string, error) { return "", "v", nil })
+	assert.EqualError(t, CheckExtracter(unnamed, nil, nil), `extracted value "v" has no name`)
+}
+
+func TestFuzzBody(t *testing.T) {
+	body := []byte("<form><input name=\"token\" value=\"abc\"></form>")
+	mutations := FuzzBody(body, 1, 50)
+	assert.Len(t, mutations, 50)
+	assert.Equal(t, mutations, FuzzBody(body, 1, 50))
+	assert.NotEqual(t, mutations, FuzzBody(body, 2, 50))
+	changed := 0
+	for _, m := range mutations {
+		if string(m) != string(body) {
+			changed++
+		}
+	}
+	assert.Greater(t, changed, 40)
+	// the recorded body isn't modified
+	assert.Equal(t, "<form><input name=\"token\" value=\"abc\"></form>", string(body))
+
+	e := Extractable{Name: "token", AfterThis: "value=\"", BeforeThis: "\"", MaxLength: -1, MinLength: -1}
+	for _, m := range mutations {
+		assert.Nil(t, CheckExtracter(e, m, nil))
+	}
+}
@@ -0,0 +1,180 @@
+package httpsim
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Generator generates a random but valid value for each execution of a flow,
+// e.g. the email of a signup, see Flow.Generators
+type Generator interface {
+	// Generate returns a value drawn from r, the values given and generated
+	// before may be used
+	Generate(r *rand.Rand, values map[string]interface{}) interface{}
+}
+
+// GeneratorFunc is an adapter to use an ordinary function as a Generator
+type GeneratorFunc func(r *rand.Rand, values map[string]interface{}) interface{}
+
+// Generate calls f(r, values)
+func (f GeneratorFunc) Generate(r *rand.Rand, values map[string]interface{}) interface{} {
+	return f(r, values)
+}
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "David", "Emma", "Farid", "Grace", "Hugo", "Ines", "Jun",
+		"Karim", "Laura", "Mei", "Nathan", "Olga", "Pablo", "Quentin", "Rosa", "Sven", "Tara"}
+	lastNames = []string{"Martin", "Smith", "Garcia", "Müller", "Rossi", "Nguyen", "Kowalski", "Silva",
+		"Dubois", "Tanaka", "Johnson", "Haddad", "O'Brien", "Jensen", "Novak", "Moreau"}
+)
+
+// RandomInt generates ints between min and max, included
+func RandomInt(min, max int) Generator {
+	return GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		if max <= min {
+			return min
+		}
+		return min + r.Intn(max-min+1)
+	})
+}
+
+// RandomAmount generates amounts between min and max, formatted with 2 decimals
+// e.g. "12.34"
+func RandomAmount(min, max float64) Generator {
+	return GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		cents := int64(min*100 + 0.5)
+		if span := int64(max*100+0.5) - cents; span > 0 {
+			cents += r.Int63n(span + 1)
+		}
+		return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+	})
+}
+
+// RandomChoice generates one of the choices
+func RandomChoice(choices ...interface{}) Generator {
+	return GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		return choices[r.Intn(len(choices))]
+	})
+}
+
+// RandomString generates strings of n characters of the charset, alphanumeric
+// when empty
+func RandomString(charset string, n int) Generator {
+	if charset == "" {
+		charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	}
+	chars := []rune(charset)
+	return GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		s := make([]rune, n)
+		for i := range s {
+			s[i] = chars[r.Intn(len(chars))]
+		}
+		return string(s)
+	})
+}
+
+// RandomName generates full names e.g. "Grace Nguyen"
+func RandomName() Generator {
+	return GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		return randomName(r)
+	})
+}
+
+func randomName(r *rand.Rand) string {
+	return firstNames[r.Intn(len(firstNames))] + " " + lastNames[r.Intn(len(lastNames))]
+}
+
+// RandomEmail generates unlikely to be taken addresses at the domain, e.g.
+// "grace.nguyen.4821@example.com". When nameValue is set, the address is made
+// out of that value (e.g. a name generated by RandomName) instead of a random
+// name.
+func RandomEmail(domain, nameValue string) Generator {
+	if domain == "" {
+		domain = "example.com"
+	}
+	gen := GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		var name string
+		if v, ok := values[nameValue]; ok && nameValue != "" {
+			name = fmt.Sprint(v)
+		} else {
+			name = randomName(r)
+		}
+		var local []string
+		for _, part := range strings.Fields(strings.ToLower(name)) {
+			part = strings.Map(func(c rune) rune {
+				if i := strings.IndexRune(accented, c); i >= 0 {
+					c = rune(unaccented[len([]rune(accented[:i]))])
+				}
+				if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+					return c
+				}
+				return -1
+			}, part)
+			if part != "" {
+				local = append(local, part)
+			}
+		}
+		local = append(local, fmt.Sprintf("%04d", r.Intn(10000)))
+		return strings.Join(local, ".") + "@" + domain
+	})
+	if nameValue == "" {
+		return gen
+	}
+	return dependentGenerator{gen, []string{nameValue}}
+}
+
+// accented are the lowercase accented letters folded to the unaccented letter at
+// the same position, for email addresses
+const (
+	accented   = "àáâãäåāăąçćĉčďđèéêëēĕėęěĝğġģĥìíîïĩīĭįıĵķĺļľłñńņňòóôõöøōŏőŕŗřśŝşšţťùúûüũūŭůűųŵýÿŷźżž"
+	unaccented = "aaaaaaaaaccccddeeeeeeeeegggghiiiiiiiiijkllllnnnnooooooooorrrssssttuuuuuuuuuuwyyyzzz"
+)
+
+// dependentGenerator is a generator using other generated values, they're
+// generated first
+type dependentGenerator struct {
+	Generator
+	needs []string
+}
+
+// generateValues generates the values of the Generators not given, in name
+// order (the values a generator needs first), with a rand seeded with the
+// GeneratorSeed or a new seed, kept in GeneratedSeed. The rand is returned, nil
+// without Generators.
+func (f *Flow) generateValues(values map[string]interface{}) *rand.Rand {
+	f.GeneratedSeed = 0
+	if len(f.Generators) == 0 {
+		return nil
+	}
+	seed := f.GeneratorSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	f.GeneratedSeed = seed
+	r := rand.New(rand.NewSource(seed))
+	visited := map[string]bool{}
+	var generate func(name string)
+	generate = func(name string) {
+		gen, ok := f.Generators[name]
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		if d, ok := gen.(dependentGenerator); ok {
+			for _, need := range d.needs {
+				generate(need)
+			}
+		}
+		// drawn even for the values given, so the others don't depend on them
+		v := gen.Generate(r, values)
+		if given, ok := values[name]; !ok || given == "" {
+			values[name] = v
+		}
+	}
+	for _, name := range sortedKeys(f.Generators) {
+		generate(name)
+	}
+	return r
+}
@@ -0,0 +1,99 @@
+package httpsim
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerators(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		n := RandomInt(3, 5).Generate(r, nil).(int)
+		assert.True(t, n >= 3 && n <= 5)
+		amount, err := strconv.ParseFloat(RandomAmount(9.99, 20).Generate(r, nil).(string), 64)
+		assert.Nil(t, err)
+		assert.True(t, amount >= 9.99 && amount <= 20)
+		assert.Contains(t, []interface{}{"eur", "usd"}, RandomChoice("eur", "usd").Generate(r, nil))
+		assert.Regexp(t, "^[ab]{8}$", RandomString("ab", 8).Generate(r, nil))
+		assert.Regexp(t, `^[a-z]+\.[a-z]+\.[0-9]{4}@shop\.test$`, RandomEmail("shop.test", "").Generate(r, nil))
+	}
+	assert.Equal(t, 7, RandomInt(7, 7).Generate(r, nil))
+	assert.Equal(t, "1.50", RandomAmount(1.5, 1.5).Generate(r, nil))
+	assert.Regexp(t, `^jose\.obrien\.[0-9]{4}@example\.com$`,
+		RandomEmail("", "name").Generate(r, map[string]interface{}{"name": "José O'Brien"}))
+	assert.Regexp(t, `^zoe\.muller\.[0-9]{4}@example\.com$`,
+		RandomEmail("", "name").Generate(r, map[string]interface{}{"name": "Zoë Müller"}))
+}
+
+func TestFlow_GenerateValues(t *testing.T) {
+	f := Flow{Generators: map[string]Generator{
+		"email":  RandomEmail("", "name"),
+		"name":   RandomChoice("Ada Lovelace", "Alan Turing"),
+		"amount": RandomAmount(1, 100),
+	}, GeneratorSeed: 42}
+	values := map[string]interface{}{}
+	f.generateValues(values)
+	assert.Equal(t, int64(42), f.GeneratedSeed)
+	assert.Len(t, values, 3)
+	// the email is made out of the generated name, generated first
+	if values["name"] == "Ada Lovelace" {
+		assert.Regexp(t, `^ada\.lovelace\.[0-9]{4}@`, values["email"])
+	} else {
+		assert.Regexp(t, `^alan\.turing\.[0-9]{4}@`, values["email"])
+	}
+
+	// the same seed generates the same values, the given ones are kept
+	again := map[string]interface{}{"amount": "5.00"}
+	f.generateValues(again)
+	assert.Equal(t, "5.00", again["amount"])
+	assert.Equal(t, values["email"], again["email"])
+	assert.Equal(t, values["name"], again["name"])
+
+	f.GeneratorSeed = 0
+	other := map[string]interface{}{}
+	f.generateValues(other)
+	assert.NotEqual(t, int64(0), f.GeneratedSeed)
+	assert.Len(t, other, 3)
+
+	f.Generators = nil
+	f.generateValues(other)
+	assert.Equal(t, int64(0), f.GeneratedSeed)
+}
+
+func TestFlow_ExecuteGenerators(t *testing.T) {
+	var emails []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		emails = append(emails, r.URL.Query().Get("email"))
+		if len(emails) == 2 {
+			w.WriteHeader(http.StatusConflict)
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		RequiredValues: []string{"email"},
+		Generators:     map[string]Generator{"email": RandomEmail("shop.test", "")},
+		Steps: []Step{{Name: "signup", Request: Request{URL: srv.URL + "/signup?email={{.email}}", Method: "GET"},
+			KeysInput: []string{"email"}, ExpectStatus: []string{"2xx"}}},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, emails[0], f.Values["email"])
+	assert.True(t, f.Provenance["email"].Given)
+
+	err := f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, emails[0], emails[1])
+	report := f.Failure()
+	assert.Equal(t, f.GeneratedSeed, report.Seed)
+	assert.Contains(t, report.String(), "Seed: "+strconv.FormatInt(f.GeneratedSeed, 10)+"\n")
+
+	// replaying the seed sends the same email
+	f.GeneratorSeed = report.Seed
+	f.Execute(nil)
+	assert.Equal(t, emails[1], emails[2])
+}
@@ -0,0 +1,103 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// harEntry is a request of a HAR (HTTP Archive) recording and its response
+type harEntry struct {
+	Request struct {
+		Method  string `json:"method"`
+		URL     string `json:"url"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// harSkippedHeaders are the recorded headers the client sets itself
+var harSkippedHeaders = map[string]bool{"cookie": true, "content-length": true, "host": true,
+	"connection": true, "accept-encoding": true}
+
+// parseHAR returns the entries of the HAR recording to the hosts allowed
+func parseHAR(data []byte, hosts *HostFilter) ([]harEntry, error) {
+	var har struct {
+		Log struct {
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %s", err.Error())
+	}
+	var entries []harEntry
+	for _, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HAR: %s", err.Error())
+		}
+		if hosts.Allowed(u.Hostname()) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// ImportHAR imports the requests of a HAR recording (e.g. exported from a
+// browser) as steps, expecting the recorded statuses. The requests to the hosts
+// filtered (e.g. Block NoiseHosts) are dropped. Cookies are left to the jar.
+// The values of the responses reused by later requests are detected, extracted
+// and templated, see Collection.Correlations.
+func ImportHAR(data []byte, hosts *HostFilter) (*Collection, error) {
+	entries, err := parseHAR(data, hosts)
+	if err != nil {
+		return nil, err
+	}
+	b := newCollectionBuilder()
+	for _, e := range entries {
+		u, _ := url.Parse(e.Request.URL)
+		step := Step{
+			Name:         e.Request.Method + " " + u.Path,
+			Request:      Request{Method: e.Request.Method, URL: e.Request.URL, Header: http.Header{}},
+			ExpectStatus: []string{strconv.Itoa(e.Response.Status)},
+		}
+		if e.Response.Status/100 == 3 {
+			step.Request.IgnoreRedirects = true
+		}
+		for _, h := range e.Request.Headers {
+			if name := strings.ToLower(h.Name); !harSkippedHeaders[name] && !strings.HasPrefix(name, ":") {
+				step.Request.Header.Add(h.Name, h.Value)
+			}
+		}
+		if e.Request.PostData != nil && e.Request.PostData.Text != "" {
+			step.Request.Body = e.Request.PostData.Text
+			if step.Request.Header.Get("Content-Type") == "" && e.Request.PostData.MimeType != "" {
+				step.Request.Header.Set("Content-Type", e.Request.PostData.MimeType)
+			}
+		}
+		b.add(step)
+	}
+	b.c.Correlations = correlate(entries, b.c.Flow.Steps)
+	return b.collection(), nil
+}
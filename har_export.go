@@ -0,0 +1,136 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// harNameValue is a header or query parameter of an exported HAR
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harExportEntry is an exported request, the underscored fields are the
+// simulation's
+type harExportEntry struct {
+	StartedDateTime string                 `json:"startedDateTime"`
+	Time            float64                `json:"time"`
+	Request         map[string]interface{} `json:"request"`
+	Response        map[string]interface{} `json:"response"`
+	Cache           struct{}               `json:"cache"`
+	Timings         map[string]float64     `json:"timings"`
+	RunID           string                 `json:"_runId"`
+	Step            int                    `json:"_step"`
+	StepName        string                 `json:"_stepName"`
+	Tags            map[string]string      `json:"_tags,omitempty"`
+}
+
+// harHeaders returns the headers, sorted, with the SensitiveValues and session
+// headers redacted
+func (f *Flow) harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for _, k := range sortedKeys(h) {
+		for _, v := range h[k] {
+			v = f.redact(v)
+			for _, session := range wireHeaders {
+				if strings.EqualFold(k, session) {
+					v = redacted
+				}
+			}
+			headers = append(headers, harNameValue{Name: k, Value: v})
+		}
+	}
+	return headers
+}
+
+// ms returns the duration in milliseconds
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ExportHAR exports the requests of the last execution's steps and their
+// responses as a HAR (HTTP Archive), with the SensitiveValues redacted. The
+// entries are tagged with the run's ID, the step and the Trace's tags.
+func (f *Flow) ExportHAR() ([]byte, error) {
+	entries := []harExportEntry{}
+	var tags map[string]string
+	if f.Trace != nil {
+		tags = f.Trace.Tags
+	}
+	add := func(i int, step *Step) {
+		// the steps not executed keep the previous runs' responses
+		if step.Response == nil || step.Response.runID != f.RunID || step.Response.Raw == nil || step.Response.Raw.Request == nil {
+			return
+		}
+		resp := step.Response.Raw
+		req := resp.Request
+		query := []harNameValue{}
+		for _, k := range sortedKeys(req.URL.Query()) {
+			for _, v := range req.URL.Query()[k] {
+				query = append(query, harNameValue{Name: k, Value: f.redact(v)})
+			}
+		}
+		request := map[string]interface{}{
+			"method":      req.Method,
+			"url":         f.redact(req.URL.String()),
+			"httpVersion": resp.Proto, // the request's Proto isn't the one negotiated
+			"headers":     f.harHeaders(req.Header),
+			"queryString": query,
+			"cookies":     []interface{}{},
+			"headersSize": -1,
+			"bodySize":    -1,
+		}
+		if req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				b, _ := io.ReadAll(body)
+				if len(b) != 0 {
+					request["postData"] = map[string]string{"mimeType": req.Header.Get("Content-Type"), "text": f.redact(string(b))}
+					request["bodySize"] = len(b)
+				}
+			}
+		}
+		entries = append(entries, harExportEntry{
+			StartedDateTime: step.Response.started.UTC().Format(time.RFC3339Nano),
+			Time:            ms(step.Response.duration),
+			Request:         request,
+			Response: map[string]interface{}{
+				"status":      resp.StatusCode,
+				"statusText":  http.StatusText(resp.StatusCode),
+				"httpVersion": resp.Proto,
+				"headers":     f.harHeaders(resp.Header),
+				"cookies":     []interface{}{},
+				"content": map[string]interface{}{
+					"size":     len(step.Response.Body),
+					"mimeType": resp.Header.Get("Content-Type"),
+					"text":     f.redact(string(step.Response.Body)),
+				},
+				"redirectURL": resp.Header.Get("Location"),
+				"headersSize": -1,
+				"bodySize":    -1,
+			},
+			Timings:  map[string]float64{"send": 0, "wait": ms(step.Response.duration), "receive": 0},
+			RunID:    f.RunID,
+			Step:     i,
+			StepName: step.Name,
+			Tags:     tags,
+		})
+	}
+	for i := range f.Steps {
+		add(i, &f.Steps[i])
+	}
+	for j := range f.Teardown {
+		add(len(f.Steps)+j, &f.Teardown[j])
+	}
+
+	har := map[string]interface{}{"log": map[string]interface{}{
+		"version": "1.2",
+		"creator": map[string]string{"name": "httpsim", "version": "1"},
+		"pages":   []interface{}{},
+		"entries": entries,
+	}}
+	return json.MarshalIndent(har, "", "  ")
+}
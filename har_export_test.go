@@ -0,0 +1,97 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExportHAR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello " + r.FormValue("password")))
+	}))
+	defer srv.Close()
+	f := Flow{
+		RequiredValues:  []string{"password"},
+		SensitiveValues: []string{"password"},
+		Trace:           &TraceHeaders{Tags: map[string]string{"scenario": "login"}},
+		Steps: []Step{
+			{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "POST", Body: "password={{.password}}",
+				Header: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}}, KeysInput: []string{"password"}},
+			{Name: "home", Request: Request{URL: srv.URL + "/?q=1", Method: "GET"}},
+		},
+	}
+	assert.Nil(t, f.Execute(map[string]interface{}{"password": "hunter2"}))
+	b, err := f.ExportHAR()
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				RunID    string            `json:"_runId"`
+				Step     int               `json:"_step"`
+				StepName string            `json:"_stepName"`
+				Tags     map[string]string `json:"_tags"`
+				Request  struct {
+					Method  string         `json:"method"`
+					Version string         `json:"httpVersion"`
+					URL     string         `json:"url"`
+					Headers []harNameValue `json:"headers"`
+					Query   []harNameValue `json:"queryString"`
+					Post    struct {
+						Text string `json:"text"`
+					} `json:"postData"`
+				} `json:"request"`
+				Response struct {
+					Status  int            `json:"status"`
+					Version string         `json:"httpVersion"`
+					Headers []harNameValue `json:"headers"`
+					Content struct {
+						Text string `json:"text"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	assert.Nil(t, json.Unmarshal(b, &har))
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Len(t, har.Log.Entries, 2)
+	login, home := har.Log.Entries[0], har.Log.Entries[1]
+	assert.Equal(t, f.RunID, login.RunID)
+	assert.Equal(t, "login", login.StepName)
+	assert.Equal(t, map[string]string{"scenario": "login"}, login.Tags)
+	assert.Equal(t, "POST", login.Request.Method)
+	assert.Equal(t, "HTTP/1.1", login.Request.Version)
+	assert.Equal(t, "password=REDACTED", login.Request.Post.Text)
+	assert.Contains(t, login.Request.Headers, harNameValue{Name: "X-Flow-Step", Value: "login"})
+	assert.Contains(t, login.Request.Headers, harNameValue{Name: "X-Flow-Run-Id", Value: f.RunID})
+	assert.Equal(t, "hello REDACTED", login.Response.Content.Text)
+	assert.Contains(t, login.Response.Headers, harNameValue{Name: "Set-Cookie", Value: "REDACTED"})
+	assert.Equal(t, 1, home.Step)
+	assert.Equal(t, []harNameValue{{Name: "q", Value: "1"}}, home.Request.Query)
+	assert.Contains(t, home.Request.Headers, harNameValue{Name: "Cookie", Value: "REDACTED"})
+
+	// the steps not executed by the last run aren't exported
+	f.Steps[1].Request.URL = "http://127.0.0.1:1/"
+	assert.NotNil(t, f.Execute(map[string]interface{}{"password": "hunter2"}))
+	b, _ = f.ExportHAR()
+	assert.Nil(t, json.Unmarshal(b, &har))
+	assert.Len(t, har.Log.Entries, 1)
+
+	f.Transport = &fakeHTTP3{}
+	f.Steps = f.Steps[:1]
+	assert.Nil(t, f.Execute(map[string]interface{}{"password": "hunter2"}))
+	b, _ = f.ExportHAR()
+	assert.Nil(t, json.Unmarshal(b, &har))
+	if assert.Len(t, har.Log.Entries, 1) {
+		assert.Equal(t, "HTTP/3.0", har.Log.Entries[0].Request.Version)
+		assert.Equal(t, "HTTP/3.0", har.Log.Entries[0].Response.Version)
+	}
+}
@@ -0,0 +1,47 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testHAR = `{"log": {"entries": [
+  {"request": {"method": "GET", "url": "https://shop.example.com/login",
+    "headers": [{"name": ":authority", "value": "shop.example.com"}, {"name": "Accept", "value": "text/html"}, {"name": "Cookie", "value": "a=b"}]},
+   "response": {"status": 200, "content": {"mimeType": "text/html", "text": "<input name=\"csrf\" value=\"c5rfT0ken42\">"}}},
+  {"request": {"method": "GET", "url": "https://www.google-analytics.com/collect?v=1"},
+   "response": {"status": 204, "content": {}}},
+  {"request": {"method": "POST", "url": "https://shop.example.com/login",
+    "headers": [{"name": "Content-Length", "value": "31"}],
+    "postData": {"mimeType": "application/x-www-form-urlencoded", "text": "user=bob&csrf=c5rfT0ken42"}},
+   "response": {"status": 302, "content": {"mimeType": "application/json", "text": "{\"session\": \"s3ss10nId99\", \"id\": 7}"}}},
+  {"request": {"method": "GET", "url": "https://shop.example.com/account?session=s3ss10nId99"},
+   "response": {"status": 200, "content": {}}}
+]}}`
+
+func TestImportHAR(t *testing.T) {
+	c, err := ImportHAR([]byte(testHAR), &HostFilter{Block: NoiseHosts})
+	assert.Nil(t, err)
+	assert.Len(t, c.Flow.Steps, 3)
+
+	get := c.Flow.Steps[0]
+	assert.Equal(t, "GET /login", get.Name)
+	assert.Equal(t, "text/html", get.Request.Header.Get("Accept"))
+	assert.Len(t, get.Request.Header, 1)
+	assert.Equal(t, []string{"200"}, get.ExpectStatus)
+
+	post := c.Flow.Steps[1]
+	assert.Equal(t, "POST", post.Request.Method)
+	assert.Equal(t, "user=bob&csrf={{.csrf}}", post.Request.Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", post.Request.Header.Get("Content-Type"))
+	assert.True(t, post.Request.IgnoreRedirects)
+	assert.Equal(t, []string{"302"}, post.ExpectStatus)
+
+	c, err = ImportHAR([]byte(testHAR), nil)
+	assert.Nil(t, err)
+	assert.Len(t, c.Flow.Steps, 4)
+
+	_, err = ImportHAR([]byte(`{`), nil)
+	assert.NotNil(t, err)
+}
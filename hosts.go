@@ -0,0 +1,72 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HostFilter keeps the flow's requests to the target application: the requests
+// to other hosts (e.g. analytics, CDNs) are skipped, or stubbed. Hosts match
+// themselves and their subdomains.
+type HostFilter struct {
+	// Allow, if not empty, are the only hosts requests are sent to
+	Allow []string
+	// Block are the hosts requests aren't sent to
+	Block []string
+	// Stub answers the filtered requests with an empty 200 response instead of
+	// skipping them, for the steps whose response is expected
+	Stub bool
+}
+
+// NoiseHosts are common analytics, tracking, and CDN hosts, e.g. to Block when
+// importing recordings
+var NoiseHosts = []string{
+	"google-analytics.com", "googletagmanager.com", "doubleclick.net", "googlesyndication.com",
+	"facebook.net", "connect.facebook.com", "hotjar.com", "segment.io", "segment.com",
+	"mixpanel.com", "amplitude.com", "clarity.ms", "sentry.io", "nr-data.net", "newrelic.com",
+	"fonts.googleapis.com", "fonts.gstatic.com", "cdnjs.cloudflare.com", "cdn.jsdelivr.net", "unpkg.com",
+}
+
+// hostMatches returns whether the host is one of the hosts or their subdomains
+func hostMatches(host string, hosts []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns whether requests are sent to the host
+func (h *HostFilter) Allowed(host string) bool {
+	if h == nil {
+		return true
+	}
+	if len(h.Allow) != 0 && !hostMatches(host, h.Allow) {
+		return false
+	}
+	return !hostMatches(host, h.Block)
+}
+
+// filterHost returns whether the step's request is filtered by the flow's Hosts,
+// storing the stub response in def then
+func (f *Flow) filterHost(i int, def, step *Step) bool {
+	u, err := url.Parse(step.Request.URL)
+	if err != nil || f.Hosts.Allowed(u.Hostname()) {
+		return false
+	}
+	what := "skipped"
+	def.Response = nil
+	if f.Hosts.Stub {
+		what = "stubbed"
+		def.Response = &Response{Header: http.Header{}, Body: []byte{}, json: &lazyJSON{},
+			Raw: &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}}}
+	}
+	f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name,
+		Message: fmt.Sprintf("hosts: %s the request to %s", what, u.Hostname())})
+	return true
+}
@@ -0,0 +1,53 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostFilter_Allowed(t *testing.T) {
+	var h *HostFilter
+	assert.True(t, h.Allowed("example.com"))
+
+	h = &HostFilter{Allow: []string{"example.com"}, Block: []string{"cdn.example.com"}}
+	assert.True(t, h.Allowed("example.com"))
+	assert.True(t, h.Allowed("API.example.com."))
+	assert.False(t, h.Allowed("cdn.example.com"))
+	assert.False(t, h.Allowed("img.cdn.example.com"))
+	assert.False(t, h.Allowed("notexample.com"))
+
+	h = &HostFilter{Block: NoiseHosts}
+	assert.False(t, h.Allowed("www.google-analytics.com"))
+	assert.True(t, h.Allowed("shop.example.com"))
+}
+
+func TestFlow_Hosts(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Hosts: &HostFilter{Block: []string{"tracker.invalid"}},
+		Steps: []Step{
+			{Name: "page", Request: Request{URL: srv.URL + "/page", Method: "GET"}},
+			{Name: "track", Request: Request{URL: "http://tracker.invalid/t", Method: "GET"}},
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{"/page"}, paths)
+	assert.Nil(t, f.Steps[1].Response)
+	assert.Len(t, f.Warnings, 1)
+	assert.Equal(t, "hosts: skipped the request to tracker.invalid", f.Warnings[0].Message)
+
+	f.Hosts = &HostFilter{Allow: []string{"127.0.0.1"}, Stub: true}
+	f.Warnings = nil
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, 200, f.Steps[1].Response.Raw.StatusCode)
+	assert.True(t, strings.HasPrefix(f.Warnings[0].Message, "hosts: stubbed"))
+}
@@ -0,0 +1,262 @@
+package httpsim
+
+import (
+	"html"
+	"strings"
+)
+
+// htmlNode is a node of the simplified DOM built by parseHTML
+type htmlNode struct {
+	// Tag is the lowercase tag name, empty for text nodes
+	Tag   string
+	Attrs map[string]string
+	// Text is the unescaped text of text nodes
+	Text     string
+	Parent   *htmlNode
+	Children []*htmlNode
+}
+
+var (
+	htmlVoid = map[string]bool{
+		"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+		"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	}
+	htmlRawText = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+	// htmlAutoClose lists the open elements implicitly closed by an opening tag
+	htmlAutoClose = map[string][]string{
+		"tr":     {"tr", "td", "th"},
+		"td":     {"td", "th"},
+		"th":     {"td", "th"},
+		"thead":  {"thead", "tbody", "tfoot", "tr", "td", "th"},
+		"tbody":  {"thead", "tbody", "tfoot", "tr", "td", "th"},
+		"tfoot":  {"thead", "tbody", "tfoot", "tr", "td", "th"},
+		"li":     {"li"},
+		"option": {"option"},
+		"p":      {"p"},
+		"dt":     {"dt", "dd"},
+		"dd":     {"dt", "dd"},
+	}
+	// htmlClosesP are the block elements implicitly closing an open paragraph
+	htmlClosesP = []string{"address", "article", "aside", "blockquote", "div", "dl", "fieldset", "footer",
+		"form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "main", "nav", "ol", "pre", "section",
+		"table", "ul"}
+	// htmlScope are the elements implicit closes don't cross (e.g. nested tables)
+	htmlScope = map[string]bool{"table": true, "ul": true, "ol": true, "select": true, "dl": true}
+)
+
+func init() {
+	for _, tag := range htmlClosesP {
+		htmlAutoClose[tag] = append(htmlAutoClose[tag], "p")
+	}
+}
+
+// parseHTML builds a tolerant simplified DOM out of the document. It doesn't
+// implement the whole HTML5 algorithm but copes with the usual unclosed tags.
+func parseHTML(doc string) *htmlNode {
+	root := &htmlNode{Tag: "#document"}
+	cur := root
+	appendText := func(text string) {
+		if text != "" {
+			cur.Children = append(cur.Children, &htmlNode{Text: html.UnescapeString(text), Parent: cur})
+		}
+	}
+
+	for i := 0; i < len(doc); {
+		lt := strings.IndexByte(doc[i:], '<')
+		if lt == -1 {
+			appendText(doc[i:])
+			break
+		}
+		appendText(doc[i : i+lt])
+		i += lt
+
+		switch {
+		case strings.HasPrefix(doc[i:], "<!--"):
+			end := strings.Index(doc[i+4:], "-->")
+			if end == -1 {
+				return root
+			}
+			i += 4 + end + 3
+		case strings.HasPrefix(doc[i:], "<!"), strings.HasPrefix(doc[i:], "<?"):
+			end := strings.IndexByte(doc[i:], '>')
+			if end == -1 {
+				return root
+			}
+			i += end + 1
+		case strings.HasPrefix(doc[i:], "</"):
+			end := strings.IndexByte(doc[i:], '>')
+			if end == -1 {
+				return root
+			}
+			tag := strings.ToLower(strings.TrimSpace(doc[i+2 : i+end]))
+			i += end + 1
+			// close up to the matching open element, ignore stray end tags
+			for n := cur; n != root; n = n.Parent {
+				if n.Tag == tag {
+					cur = n.Parent
+					break
+				}
+			}
+		default:
+			tag, attrs, selfClosing, n := parseHTMLTag(doc[i:])
+			if n == 0 {
+				appendText("<")
+				i++
+				continue
+			}
+			i += n
+			for _, closed := range htmlAutoClose[tag] {
+				for n := cur; n != root && !htmlScope[n.Tag]; n = n.Parent {
+					if n.Tag == closed {
+						cur = n.Parent
+						break
+					}
+				}
+			}
+			node := &htmlNode{Tag: tag, Attrs: attrs, Parent: cur}
+			cur.Children = append(cur.Children, node)
+			if htmlRawText[tag] {
+				end := strings.Index(strings.ToLower(doc[i:]), "</"+tag)
+				if end == -1 {
+					end = len(doc) - i
+				}
+				if text := doc[i : i+end]; text != "" {
+					node.Children = []*htmlNode{{Text: text, Parent: node}}
+					if tag != "script" && tag != "style" {
+						node.Children[0].Text = html.UnescapeString(text)
+					}
+				}
+				i += end
+				if gt := strings.IndexByte(doc[i:], '>'); gt != -1 {
+					i += gt + 1
+				}
+			} else if !selfClosing && !htmlVoid[tag] {
+				cur = node
+			}
+		}
+	}
+	return root
+}
+
+// parseHTMLTag parses the opening tag at the start of s, n is 0 if it isn't one
+func parseHTMLTag(s string) (tag string, attrs map[string]string, selfClosing bool, n int) {
+	i := 1
+	for i < len(s) && (isHTMLNameChar(s[i])) {
+		i++
+	}
+	if i == 1 {
+		return "", nil, false, 0
+	}
+	tag = strings.ToLower(s[1:i])
+	attrs = map[string]string{}
+	for i < len(s) {
+		for i < len(s) && strings.IndexByte(" \t\r\n\f", s[i]) != -1 {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return tag, attrs, selfClosing, i + 1
+		}
+		if s[i] == '/' {
+			selfClosing = true
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && strings.IndexByte(" \t\r\n\f/>=", s[i]) == -1 {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		value := ""
+		for i < len(s) && strings.IndexByte(" \t\r\n\f", s[i]) != -1 {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && strings.IndexByte(" \t\r\n\f", s[i]) != -1 {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				q := s[i]
+				end := strings.IndexByte(s[i+1:], q)
+				if end == -1 {
+					return "", nil, false, 0
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && strings.IndexByte(" \t\r\n\f>", s[i]) == -1 {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		selfClosing = false
+		if _, ok := attrs[name]; !ok && name != "" {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return "", nil, false, 0
+}
+
+func isHTMLNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == ':'
+}
+
+// text returns the text content of the node, whitespace collapsed
+func (n *htmlNode) text() string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		if n.Tag == "" {
+			b.WriteString(n.Text)
+			b.WriteByte(' ')
+			return
+		}
+		if n.Tag == "script" || n.Tag == "style" {
+			return
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// hasClass returns whether the class attribute contains the class
+func (n *htmlNode) hasClass(class string) bool {
+	for _, c := range strings.Fields(n.Attrs["class"]) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the element descendants matching, in document order
+func (n *htmlNode) find(match func(*htmlNode) bool) []*htmlNode {
+	var found []*htmlNode
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		for _, c := range n.Children {
+			if c.Tag == "" {
+				continue
+			}
+			if match(c) {
+				found = append(found, c)
+			}
+			walk(c)
+		}
+	}
+	walk(n)
+	return found
+}
+
+// findTag returns the element descendants with the tag
+func (n *htmlNode) findTag(tag string) []*htmlNode {
+	return n.find(func(c *htmlNode) bool { return c.Tag == tag })
+}
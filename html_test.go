@@ -0,0 +1,32 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHTML(t *testing.T) {
+	doc := parseHTML(`<!DOCTYPE html>
+<html><head><title>A &amp; B</title><script>if (a < b) { x = "</p>" }</script></head>
+<body>
+	<!-- <table> -->
+	<p class="intro lead">Hello <b>world</b>
+	<p>Second &euro; paragraph<br>
+	<ul><li>one<li>two</ul>
+	<input type=hidden name='csrf' value="a&quot;b" disabled>
+</body></html>`)
+
+	assert.Equal(t, "A & B", doc.findTag("title")[0].text())
+	ps := doc.findTag("p")
+	assert.Len(t, ps, 2)
+	assert.Equal(t, "Hello world", ps[0].text())
+	assert.True(t, ps[0].hasClass("lead"))
+	assert.Equal(t, "Second € paragraph", ps[1].text())
+	lis := doc.findTag("li")
+	assert.Len(t, lis, 2)
+	assert.Equal(t, "two", lis[1].text())
+	input := doc.findTag("input")[0]
+	assert.Equal(t, map[string]string{"type": "hidden", "name": "csrf", "value": `a"b`, "disabled": ""}, input.Attrs)
+	assert.Len(t, doc.findTag("table"), 0)
+}
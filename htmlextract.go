@@ -0,0 +1,67 @@
+package httpsim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HTMLExtractable extracts the text, or an attribute, of the first element
+// matching a CSS selector (e.g. `input[name=csrf]` and its "value" attribute),
+// sturdier than delimiters on pages whose markup or whitespace changes. See
+// cssSelector for the selectors supported.
+type HTMLExtractable struct {
+	// Name is the name of the value extracted
+	Name string
+	// Selector is the CSS selector of the element
+	Selector string
+	// Attr is the attribute to extract, the element's text (whitespace collapsed)
+	// is extracted when empty
+	Attr string
+	// Templated renders the Selector as a template with the values, with the
+	// step's Delims
+	Templated bool
+	// IgnoreNotFound set to true if you want to ignore errors when not found
+	IgnoreNotFound bool
+
+	delims Delims
+}
+
+func (e HTMLExtractable) withDelims(d Delims) Extracter {
+	e.delims = d
+	return e
+}
+
+// Extract extracts the text or attribute of the element out of the HTML body
+func (e HTMLExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	selector := e.Selector
+	if e.Templated && strings.Contains(selector, e.delims.left()) {
+		var err error
+		if selector, err = e.delims.replace(v, selector); err != nil {
+			return e.Name, "", fmt.Errorf("couldn't render selector: %s", err.Error())
+		}
+	}
+	s, err := compileSelector(selector)
+	if err != nil {
+		return e.Name, "", err
+	}
+	found := parseHTML(body).find(s.match)
+	if len(found) == 0 {
+		return e.notFound(fmt.Errorf("no element matches '%s'", selector))
+	}
+	if e.Attr == "" {
+		return e.Name, found[0].text(), nil
+	}
+	value, ok := found[0].Attrs[strings.ToLower(e.Attr)]
+	if !ok {
+		return e.notFound(fmt.Errorf("the element matching '%s' has no attribute '%s'", selector, e.Attr))
+	}
+	return e.Name, value, nil
+}
+
+// notFound returns the error, nil if ignored
+func (e HTMLExtractable) notFound(err error) (string, string, error) {
+	if e.IgnoreNotFound {
+		return e.Name, "", nil
+	}
+	return e.Name, "", err
+}
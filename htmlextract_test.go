@@ -0,0 +1,36 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLExtractable_Extract(t *testing.T) {
+	body := `<form id="login">
+		<input type="hidden"
+			name="csrf"   value="a&amp;b">
+		<label>  Welcome
+			back,  <b>bob</b> </label>
+	</form>`
+
+	name, value, err := HTMLExtractable{Name: "csrf", Selector: "input[name=csrf]", Attr: "value"}.Extract(body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "csrf", name)
+	assert.Equal(t, "a&b", value)
+
+	_, value, err = HTMLExtractable{Name: "welcome", Selector: "#{{.form}} label", Templated: true}.Extract(body,
+		map[string]interface{}{"form": "login"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Welcome back, bob", value)
+
+	_, _, err = HTMLExtractable{Name: "x", Selector: "input[name=token]", Attr: "value"}.Extract(body, nil)
+	assert.EqualError(t, err, "no element matches 'input[name=token]'")
+	_, _, err = HTMLExtractable{Name: "x", Selector: "label", Attr: "for"}.Extract(body, nil)
+	assert.EqualError(t, err, "the element matching 'label' has no attribute 'for'")
+	_, value, err = HTMLExtractable{Name: "x", Selector: "label", Attr: "for", IgnoreNotFound: true}.Extract(body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+	_, _, err = HTMLExtractable{Name: "x", Selector: "a:hover"}.Extract(body, nil)
+	assert.EqualError(t, err, "invalid selector 'a:hover': unsupported pseudo-class ':hover'")
+}
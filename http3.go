@@ -0,0 +1,43 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// advertisesHTTP3 returns whether the Alt-Svc header offers HTTP/3 ("h3" or a
+// draft like "h3-29")
+func advertisesHTTP3(altSvc string) bool {
+	for _, svc := range strings.Split(altSvc, ",") {
+		proto := strings.TrimSpace(strings.SplitN(svc, "=", 2)[0])
+		if proto == "h3" || strings.HasPrefix(proto, "h3-") {
+			return true
+		}
+	}
+	return false
+}
+
+// altSvc records the hosts advertising HTTP/3, their next requests use it like
+// browsers do
+func (run *runState) altSvc(resp *http.Response) {
+	if run.http3 == nil || resp.Request == nil || !advertisesHTTP3(resp.Header.Get("Alt-Svc")) {
+		return
+	}
+	if run.h3Hosts == nil {
+		run.h3Hosts = map[string]bool{}
+	}
+	run.h3Hosts[resp.Request.URL.Host] = true
+}
+
+// usesHTTP3 returns whether the step's request is sent with the flow's HTTP3 transport
+func (run *runState) usesHTTP3(step *Step) bool {
+	if run.http3 == nil {
+		return false
+	}
+	if step.HTTP3 {
+		return true
+	}
+	u, err := url.Parse(step.Request.URL)
+	return err == nil && run.h3Hosts[u.Host]
+}
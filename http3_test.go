@@ -0,0 +1,60 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHTTP3 stands for an HTTP/3 transport, its responses are marked as HTTP/3
+type fakeHTTP3 struct {
+	requests int
+}
+
+func (t *fakeHTTP3) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		resp.Proto, resp.ProtoMajor, resp.ProtoMinor = "HTTP/3.0", 3, 0
+	}
+	return resp, err
+}
+
+func TestFlow_HTTP3(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/advertise" {
+			w.Header().Set("Alt-Svc", `h3=":443"; ma=86400, h3-29=":443"`)
+		}
+	}))
+	defer srv.Close()
+	h3 := &fakeHTTP3{}
+	f := Flow{Steps: []Step{
+		{Name: "forced", Request: Request{URL: srv.URL + "/quic", Method: "GET"}, HTTP3: true},
+		{Name: "tcp", Request: Request{URL: srv.URL + "/advertise", Method: "GET"}},
+		{Name: "upgraded", Request: Request{URL: srv.URL + "/next", Method: "GET"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "HTTP/1.1", f.Steps[0].Response.Protocol)
+	assert.Equal(t, "HTTP/1.1", f.Steps[2].Response.Protocol)
+
+	f.HTTP3 = h3
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "HTTP/3.0", f.Steps[0].Response.Protocol)
+	assert.Equal(t, "HTTP/1.1", f.Steps[1].Response.Protocol)
+	assert.Equal(t, "HTTP/3.0", f.Steps[2].Response.Protocol)
+	assert.Equal(t, 2, h3.requests)
+
+	// the hosts advertising h3 aren't remembered across runs
+	f.Steps = f.Steps[2:]
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "HTTP/1.1", f.Steps[0].Response.Protocol)
+}
+
+func TestAdvertisesHTTP3(t *testing.T) {
+	assert.True(t, advertisesHTTP3(`h3=":443"; ma=86400`))
+	assert.True(t, advertisesHTTP3(`h2=":443", h3-29=":443"`))
+	assert.False(t, advertisesHTTP3(`h2=":443"`))
+	assert.False(t, advertisesHTTP3(`clear`))
+}
@@ -0,0 +1,49 @@
+package httpsim
+
+import (
+	"errors"
+	"strings"
+)
+
+// ImageExtractable extracts a value rendered as an image (simple captchas, balance
+// images...) with a user supplied Recognize func e.g. calling an OCR library or a
+// vision API, so httpsim doesn't depend on one itself.
+type ImageExtractable struct {
+	// Name is the name of the value extracted
+	Name string
+	// Recognize returns the text in the image
+	Recognize func(image []byte) (string, error)
+	// Then, if set, extracts the value out of the recognized text
+	Then *Extractable
+}
+
+func (e ImageExtractable) withDelims(d Delims) Extracter {
+	if e.Then != nil {
+		then := *e.Then
+		then.delims = d
+		e.Then = &then
+	}
+	return e
+}
+
+// Extract recognizes the image given as a string
+func (e ImageExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	return e.ExtractBinary([]byte(body), "", v)
+}
+
+// ExtractBinary recognizes the image
+func (e ImageExtractable) ExtractBinary(body []byte, contentType string, v map[string]interface{}) (string, string, error) {
+	if e.Recognize == nil {
+		return e.Name, "", errors.New("no Recognize func")
+	}
+	text, err := e.Recognize(body)
+	if err != nil {
+		return e.Name, "", err
+	}
+	text = strings.TrimSpace(text)
+	if e.Then != nil {
+		_, value, err := e.Then.Extract(text, v)
+		return e.Name, value, err
+	}
+	return e.Name, text, nil
+}
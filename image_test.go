@@ -0,0 +1,38 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteImageExtractable(t *testing.T) {
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0Abalance")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(png)
+	}))
+	defer srv.Close()
+
+	var got []byte
+	f := Flow{Steps: []Step{{
+		Name:    "balance image",
+		Request: Request{URL: srv.URL, Method: "GET"},
+		KeysOutput: []Extracter{ImageExtractable{
+			Name: "balance",
+			Recognize: func(image []byte) (string, error) {
+				got = image
+				return " Balance: 12.34 EUR\n", nil
+			},
+			Then: &Extractable{Name: "amount", AfterThis: ": ", BeforeThis: " EUR", MaxLength: -1, MinLength: -1},
+		}},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, png, got)
+	assert.Equal(t, "12.34", f.Values["balance"])
+	assert.NotContains(t, f.Values, "amount")
+
+	_, _, err := ImageExtractable{Name: "x"}.ExtractBinary(png, "image/png", nil)
+	assert.EqualError(t, err, "no Recognize func")
+}
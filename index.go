@@ -0,0 +1,90 @@
+package httpsim
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// bodyIndex lazily indexes the positions of the markers searched in a body, in one
+// pass per marker, so that looking for the Nth occurrence (or many extracters
+// sharing the same huge body) doesn't re-walk the body from the start every time.
+// It's safe for concurrent use.
+type bodyIndex struct {
+	body string
+
+	mu        sync.Mutex
+	positions map[string][]int
+}
+
+func newBodyIndex(body string) *bodyIndex {
+	return &bodyIndex{body: body, positions: map[string][]int{}}
+}
+
+// find returns the positions of the non-overlapping occurrences of marker
+func (x *bodyIndex) find(marker string) []int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if pos, ok := x.positions[marker]; ok {
+		return pos
+	}
+	var pos []int
+	if marker == "" {
+		pos = []int{0}
+	} else {
+		for from := 0; ; {
+			i := strings.Index(x.body[from:], marker)
+			if i == -1 {
+				break
+			}
+			pos = append(pos, from+i)
+			from += i + len(marker)
+		}
+	}
+	x.positions[marker] = pos
+	return pos
+}
+
+// after returns the position of the first occurrence of marker at or after from
+func (x *bodyIndex) after(marker string, from int) int {
+	if marker == "" {
+		return from
+	}
+	// occurrences overlapping an indexed one (e.g. "aa" in "aaa") aren't indexed
+	if selfOverlapping(marker) {
+		if i := strings.Index(x.body[from:], marker); i != -1 {
+			return from + i
+		}
+		return -1
+	}
+	pos := x.find(marker)
+	if i := sort.SearchInts(pos, from); i < len(pos) {
+		return pos[i]
+	}
+	return -1
+}
+
+// selfOverlapping tells whether two occurrences of the marker may overlap, i.e.
+// whether it starts with one of its suffixes
+func selfOverlapping(marker string) bool {
+	for n := 1; n < len(marker); n++ {
+		if strings.HasPrefix(marker, marker[len(marker)-n:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// between returns the string between the occ-th occurrence of bef and the first aft after it
+func (x *bodyIndex) between(bef, aft string, occ int) (bool, string) {
+	pos := x.find(bef)
+	if occ >= len(pos) {
+		return false, ""
+	}
+	start := pos[occ] + len(bef)
+	end := x.after(aft, start)
+	if end == -1 {
+		return false, ""
+	}
+	return true, x.body[start:end]
+}
@@ -0,0 +1,56 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyIndex_Between(t *testing.T) {
+	idx := newBodyIndex("[a][b][c]")
+	for i, exp := range []string{"a", "b", "c"} {
+		found, str := idx.between("[", "]", i)
+		assert.True(t, found)
+		assert.Equal(t, exp, str)
+	}
+	found, _ := idx.between("[", "]", 3)
+	assert.False(t, found)
+
+	// adjacent occurrences were skipped before
+	idx = newBodyIndex("-[1]-[2]")
+	_, str := idx.between("-[", "]", 1)
+	assert.Equal(t, "2", str)
+
+	// the closing marker overlapping an opening one
+	idx = newBodyIndex("xaax")
+	found, str = idx.between("xa", "ax", 0)
+	assert.True(t, found)
+	assert.Equal(t, "", str)
+	_, _ = idx.between("ax", "x", 0)
+	found, str = idx.between("x", "ax", 0)
+	assert.True(t, found)
+	assert.Equal(t, "a", str)
+}
+
+func TestBodyIndex_AfterSelfOverlapping(t *testing.T) {
+	idx := newBodyIndex("xaaaa")
+	assert.Equal(t, 1, idx.after("aa", 0))
+	// between the indexed occurrences 1 and 3
+	assert.Equal(t, 2, idx.after("aa", 2))
+	assert.Equal(t, 3, idx.after("aa", 3))
+	assert.Equal(t, -1, idx.after("aa", 4))
+
+	idx = newBodyIndex("abababab")
+	assert.Equal(t, 2, idx.after("abab", 1))
+	assert.Equal(t, 4, idx.after("abab", 3))
+	assert.Equal(t, -1, idx.after("abab", 5))
+
+	found, str := newBodyIndex("[x]]]").between("[", "]]", 0)
+	assert.True(t, found)
+	assert.Equal(t, "x", str)
+
+	assert.True(t, selfOverlapping("aa"))
+	assert.True(t, selfOverlapping("abcab"))
+	assert.False(t, selfOverlapping("ab"))
+	assert.False(t, selfOverlapping("a"))
+}
@@ -0,0 +1,67 @@
+package httpsim
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Invariant is an assertion on the values (e.g. balance_after == balance_before
+// - amount), checked once a step's values are extracted. It verifies business
+// rules spanning several steps.
+type Invariant struct {
+	// Name describes the invariant e.g. "balance_after == balance_before - amount"
+	Name string
+	// Check returns an error when the values break the invariant
+	Check func(values map[string]interface{}) error
+}
+
+// InvariantError is the error returned when the values break an invariant
+type InvariantError struct {
+	Step      int
+	StepName  string
+	Invariant string
+	Err       error
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because invariant '%s' doesn't hold: %s",
+		e.Step, e.StepName, e.Invariant, e.Err.Error())
+}
+
+func (e *InvariantError) Unwrap() error {
+	return e.Err
+}
+
+// NumberValue returns the value as a number, e.g. for Invariants. Strings are
+// parsed, see Normalize to store locale-formatted numbers in the canonical form.
+func NumberValue(values map[string]interface{}, name string) (float64, error) {
+	switch v := values[name].(type) {
+	case nil:
+		return 0, fmt.Errorf("value '%s' is missing", name)
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("value '%s' isn't a number: %s", name, v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("value '%s' isn't a number: %v", name, v)
+	}
+}
+
+// CheckInvariants returns an *InvariantError for the first of the step's
+// Invariants the values break
+func (s *Step) CheckInvariants(values map[string]interface{}, stepNb int) error {
+	for _, inv := range s.Invariants {
+		if err := inv.Check(values); err != nil {
+			return &InvariantError{Step: stepNb, StepName: s.Name, Invariant: inv.Name, Err: err}
+		}
+	}
+	return nil
+}
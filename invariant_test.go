@@ -0,0 +1,80 @@
+package httpsim
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberValue(t *testing.T) {
+	values := map[string]interface{}{"a": "1234.5", "b": 2, "c": "1,234", "d": true}
+	n, err := NumberValue(values, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, 1234.5, n)
+	n, err = NumberValue(values, "b")
+	assert.Nil(t, err)
+	assert.Equal(t, 2.0, n)
+	_, err = NumberValue(values, "c")
+	assert.EqualError(t, err, "value 'c' isn't a number: 1,234")
+	_, err = NumberValue(values, "d")
+	assert.NotNil(t, err)
+	_, err = NumberValue(values, "e")
+	assert.EqualError(t, err, "value 'e' is missing")
+}
+
+func TestStep_Invariants(t *testing.T) {
+	balance := "1,000.00"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<b>%s</b>", balance)
+		if r.Method == "POST" {
+			balance = "950.00"
+		}
+	}))
+	defer srv.Close()
+
+	balanceOf := func(name string) Extractable {
+		return Extractable{Name: name, AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}
+	}
+	f := Flow{Steps: []Step{
+		{Name: "before", Request: Request{URL: srv.URL, Method: "GET"}, KeysOutput: []Extracter{balanceOf("before")},
+			Normalize: map[string]Normalization{"before": NormalizeNumber}},
+		{Name: "transfer", Request: Request{URL: srv.URL, Method: "POST"}},
+		{Name: "after", Request: Request{URL: srv.URL, Method: "GET"}, KeysOutput: []Extracter{balanceOf("after")},
+			Normalize: map[string]Normalization{"after": NormalizeNumber},
+			Invariants: []Invariant{{
+				Name: "after == before - amount",
+				Check: func(v map[string]interface{}) error {
+					before, err := NumberValue(v, "before")
+					if err != nil {
+						return err
+					}
+					after, err := NumberValue(v, "after")
+					if err != nil {
+						return err
+					}
+					amount, err := NumberValue(v, "amount")
+					if err != nil {
+						return err
+					}
+					if after != before-amount {
+						return fmt.Errorf("%v != %v - %v", after, before, amount)
+					}
+					return nil
+				},
+			}}},
+	}}
+	assert.Nil(t, f.Execute(map[string]interface{}{"amount": "50"}))
+
+	balance = "1,000.00"
+	err := f.Execute(map[string]interface{}{"amount": "100"})
+	assert.EqualError(t, err, "Step 2.'after' failed because invariant 'after == before - amount' doesn't hold: 950 != 1000 - 100")
+	var ie *InvariantError
+	assert.True(t, errors.As(err, &ie))
+	assert.Equal(t, 2, ie.Step)
+
+	assert.Contains(t, f.Describe().Steps[2].Assertions, "invariant after == before - amount")
+}
@@ -0,0 +1,163 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONExtractable extracts a value out of a JSON response with a dot/bracket path
+// e.g. `data.token` or `items[0].id`, sturdier than delimiters on nested JSON.
+// Strings are extracted as is, numbers as written, objects and arrays as compact
+// JSON.
+type JSONExtractable struct {
+	// Name is the name of the value extracted
+	Name string
+	// Path is the path of the value, "" for the whole body. Indexes may be
+	// negative, -1 being the last element.
+	Path string
+	// Templated renders the Path as a template with the values, with the
+	// step's Delims
+	Templated bool
+	// IgnoreNotFound set to true if you want to ignore errors when not found
+	IgnoreNotFound bool
+
+	delims Delims
+}
+
+func (e JSONExtractable) withDelims(d Delims) Extracter {
+	e.delims = d
+	return e
+}
+
+// Extract extracts the value at the path out of the JSON body
+func (e JSONExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	path := e.Path
+	if e.Templated && strings.Contains(path, e.delims.left()) {
+		var err error
+		if path, err = e.delims.replace(v, path); err != nil {
+			return e.Name, "", fmt.Errorf("couldn't render path: %s", err.Error())
+		}
+	}
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return e.Name, "", err
+	}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return e.Name, "", fmt.Errorf("invalid JSON: %s", err.Error())
+	}
+
+	cur := doc
+	for i, seg := range segments {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			child, ok := node[seg.key]
+			if seg.index != nil || !ok {
+				return e.notFound(segments[:i+1])
+			}
+			cur = child
+		case []interface{}:
+			if seg.index == nil {
+				return e.notFound(segments[:i+1])
+			}
+			idx := *seg.index
+			if idx < 0 {
+				idx += len(node)
+			}
+			if idx < 0 || idx >= len(node) {
+				return e.notFound(segments[:i+1])
+			}
+			cur = node[idx]
+		default:
+			return e.notFound(segments[:i+1])
+		}
+	}
+
+	switch val := cur.(type) {
+	case string:
+		return e.Name, val, nil
+	case json.Number:
+		return e.Name, val.String(), nil
+	case nil:
+		return e.Name, "", nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(cur); err != nil {
+		return e.Name, "", err
+	}
+	return e.Name, strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// notFound returns the error of the path not found, nil if ignored
+func (e JSONExtractable) notFound(path []jsonSegment) (string, string, error) {
+	if e.IgnoreNotFound {
+		return e.Name, "", nil
+	}
+	return e.Name, "", fmt.Errorf("path '%s' not found", formatJSONPath(path))
+}
+
+// jsonSegment is a segment of a JSON path, an object key or an array index
+type jsonSegment struct {
+	key   string
+	index *int
+}
+
+// parseJSONPath parses a dot/bracket path e.g. `items[0].id`
+func parseJSONPath(path string) ([]jsonSegment, error) {
+	var segments []jsonSegment
+	rest := path
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path '%s': unclosed [", path)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path '%s': invalid index '%s'", path, rest[1:end])
+			}
+			segments = append(segments, jsonSegment{index: &idx})
+			rest = rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path '%s': empty key", path)
+			}
+			segments = append(segments, jsonSegment{key: rest[:end]})
+			rest = rest[end:]
+		}
+		if strings.HasPrefix(rest, ".") {
+			if rest = rest[1:]; rest == "" || rest[0] == '[' {
+				return nil, fmt.Errorf("invalid path '%s': empty key", path)
+			}
+		}
+	}
+	return segments, nil
+}
+
+// formatJSONPath formats the segments back into a path
+func formatJSONPath(segments []jsonSegment) string {
+	var b strings.Builder
+	for _, seg := range segments {
+		if seg.index != nil {
+			fmt.Fprintf(&b, "[%d]", *seg.index)
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(seg.key)
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONExtractable_Extract(t *testing.T) {
+	body := `{"data": {"token": "abc", "user.name": "bob"}, "items": [{"id": 12345678901234567890, "tags": ["a", "b"]}, {"id": 2, "ok": true, "note": null}]}`
+
+	cases := []struct {
+		path  string
+		value string
+	}{
+		{"data.token", "abc"},
+		{"items[0].id", "12345678901234567890"},
+		{"items[-1].id", "2"},
+		{"items[1].ok", "true"},
+		{"items[1].note", ""},
+		{"items[0].tags", `["a","b"]`},
+		{"items[0].tags[1]", "b"},
+		{"data", `{"token":"abc","user.name":"bob"}`},
+	}
+	for _, c := range cases {
+		name, value, err := JSONExtractable{Name: "v", Path: c.path}.Extract(body, nil)
+		assert.Nil(t, err, c.path)
+		assert.Equal(t, "v", name)
+		assert.Equal(t, c.value, value, c.path)
+	}
+
+	_, value, err := JSONExtractable{Name: "v", Path: "items[{{.i}}].id", Templated: true}.Extract(body, map[string]interface{}{"i": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "2", value)
+	_, value, err = JSONExtractable{Name: "v"}.Extract(`"whole"`, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "whole", value)
+
+	_, _, err = JSONExtractable{Name: "v", Path: "items[2].id"}.Extract(body, nil)
+	assert.EqualError(t, err, "path 'items[2]' not found")
+	_, _, err = JSONExtractable{Name: "v", Path: "data.token.x"}.Extract(body, nil)
+	assert.EqualError(t, err, "path 'data.token.x' not found")
+	_, _, err = JSONExtractable{Name: "v", Path: "data[0]"}.Extract(body, nil)
+	assert.EqualError(t, err, "path 'data[0]' not found")
+	_, value, err = JSONExtractable{Name: "v", Path: "data.missing", IgnoreNotFound: true}.Extract(body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+
+	_, _, err = JSONExtractable{Name: "v", Path: "data.token"}.Extract("<html>", nil)
+	assert.NotNil(t, err)
+	for _, path := range []string{"items[0", "items[x]", "data..token", "data.", ".data"} {
+		_, _, err = JSONExtractable{Name: "v", Path: path}.Extract(body, nil)
+		assert.Contains(t, err.Error(), "invalid path '"+path+"'")
+	}
+}
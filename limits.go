@@ -0,0 +1,139 @@
+package httpsim
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// BodyLimitError is the error returned when a response body exceeds the size or
+// decompression ratio limits
+type BodyLimitError struct {
+	Step     int
+	StepName string
+	Limit    int64
+	// Ratio is true when the decompression ratio limit was exceeded
+	Ratio bool
+}
+
+func (e *BodyLimitError) Error() string {
+	if e.Ratio {
+		return fmt.Sprintf("Step %d.'%s' failed because body decompression ratio exceeds %d",
+			e.Step, e.StepName, e.Limit)
+	}
+	return fmt.Sprintf("Step %d.'%s' failed because body exceeds %d bytes", e.Step, e.StepName, e.Limit)
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedBody fails reading a body past its size or decompression ratio limits
+type limitedBody struct {
+	io.ReadCloser
+	read       int64
+	compressed *countingReader
+	maxSize    int64
+	maxRatio   int64
+	step       int
+	stepName   string
+	err        error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	switch {
+	case b.maxSize > 0 && b.read > b.maxSize:
+		b.err = &BodyLimitError{Step: b.step, StepName: b.stepName, Limit: b.maxSize}
+	case b.maxRatio > 0 && b.compressed != nil && b.read > b.maxRatio*b.compressed.n:
+		b.err = &BodyLimitError{Step: b.step, StepName: b.stepName, Limit: b.maxRatio, Ratio: true}
+	default:
+		return n, err
+	}
+	return 0, b.err
+}
+
+// limitBody limits the step's body to the flow's and step's limits. compressed
+// counts the bytes read from the wire when the body is decompressed.
+func (f *Flow) limitBody(i int, step *Step, body io.ReadCloser, compressed *countingReader) io.ReadCloser {
+	maxSize := f.MaxBodySize
+	if step.MaxBodySize != 0 {
+		maxSize = step.MaxBodySize
+	}
+	if maxSize <= 0 && (f.MaxDecompressionRatio <= 0 || compressed == nil) {
+		return body
+	}
+	return &limitedBody{
+		ReadCloser: body,
+		compressed: compressed,
+		maxSize:    maxSize,
+		maxRatio:   f.MaxDecompressionRatio,
+		step:       i,
+		stepName:   step.Name,
+	}
+}
+
+// decompressionLimit caps the size of what's decompressed out of a body (archive
+// members, PDF streams...), see Flow.MaxBodySize and Flow.MaxDecompressionRatio
+type decompressionLimit struct {
+	// left is the number of bytes left to decompress, no limit when negative
+	left  int64
+	limit int64
+	ratio int64
+}
+
+// decompressionLimitError is returned past a decompressionLimit
+type decompressionLimitError struct {
+	limit int64
+	ratio int64
+}
+
+func (e *decompressionLimitError) Error() string {
+	if e.ratio > 0 {
+		return fmt.Sprintf("decompression ratio exceeds %d", e.ratio)
+	}
+	return fmt.Sprintf("decompressed size exceeds %d bytes", e.limit)
+}
+
+// read reads r, failing past the limit. What was read is returned with the
+// read errors.
+func (l *decompressionLimit) read(r io.Reader) ([]byte, error) {
+	if l.left < 0 {
+		return ioutil.ReadAll(r)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, l.left+1))
+	if int64(len(b)) > l.left {
+		return nil, &decompressionLimitError{limit: l.limit, ratio: l.ratio}
+	}
+	l.left -= int64(len(b))
+	return b, err
+}
+
+// decompressionLimit returns the limit of what's decompressed out of the step's
+// body: its max body size, and the max decompression ratio of the body
+func (f *Flow) decompressionLimit(step *Step, body []byte) *decompressionLimit {
+	l := &decompressionLimit{left: -1}
+	maxSize := f.MaxBodySize
+	if step.MaxBodySize != 0 {
+		maxSize = step.MaxBodySize
+	}
+	if maxSize > 0 {
+		l.left, l.limit = maxSize, maxSize
+	}
+	if max := f.MaxDecompressionRatio * int64(len(body)); f.MaxDecompressionRatio > 0 && (l.left < 0 || max < l.left) {
+		l.left, l.limit, l.ratio = max, max, f.MaxDecompressionRatio
+	}
+	return l
+}
@@ -0,0 +1,80 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteMaxBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>" + strings.Repeat("x", 5000) + "</b>"))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		MaxBodySize: 1024,
+		Steps:       []Step{{Name: "big", Request: Request{URL: srv.URL, Method: "GET"}}},
+	}
+	err := f.Execute(nil)
+	assert.EqualError(t, err, "Step 0.'big' failed because body exceeds 1024 bytes")
+	assert.IsType(t, &BodyLimitError{}, err)
+
+	// streamed
+	f.Steps[0].StreamBody = true
+	f.Steps[0].KeysOutput = []Extracter{StreamExtractable{Name: "x", AfterThis: "</b>", BeforeThis: "<"}}
+	err = f.Execute(nil)
+	assert.IsType(t, &BodyLimitError{}, err)
+
+	// the step allows it
+	f.Steps[0] = Step{Name: "big", Request: Request{URL: srv.URL, Method: "GET"}, MaxBodySize: -1}
+	assert.Nil(t, f.Execute(nil))
+	f.Steps[0].MaxBodySize = 10000
+	assert.Nil(t, f.Execute(nil))
+}
+
+func TestFlow_ExecuteDecompressionBomb(t *testing.T) {
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(b)
+		gz.Close()
+		return buf.Bytes()
+	}
+	bomb := gzipped(make([]byte, 10<<20))
+	page := gzipped([]byte(`<html><b id="v">ok</b></html>`))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/bomb" {
+			w.Write(bomb)
+		} else {
+			w.Write(page)
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		MaxDecompressionRatio: 100,
+		Steps: []Step{{
+			Name:       "page",
+			Request:    Request{URL: srv.URL + "/page", Method: "GET", Header: http.Header{"Accept-Encoding": {"gzip"}}},
+			KeysOutput: []Extracter{Extractable{Name: "v", AfterThis: `id="v">`, BeforeThis: "<", MaxLength: -1, MinLength: -1}},
+		}},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "ok", f.Values["v"])
+
+	f.Steps[0].Request.URL = srv.URL + "/bomb"
+	err := f.Execute(nil)
+	assert.EqualError(t, err, "Step 0.'page' failed because body decompression ratio exceeds 100")
+	lerr, ok := err.(*BodyLimitError)
+	assert.True(t, ok)
+	if ok {
+		assert.True(t, lerr.Ratio)
+	}
+}
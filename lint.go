@@ -0,0 +1,111 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// LintWarning is a probable mistake found in a flow definition, or during its run
+type LintWarning struct {
+	// Step is the index of the step, -1 when about the whole flow
+	Step     int
+	StepName string
+	Message  string
+}
+
+func (w LintWarning) String() string {
+	if w.Step == -1 {
+		return "Flow " + w.Message
+	}
+	return fmt.Sprintf("Step %d.'%s' %s", w.Step, w.StepName, w.Message)
+}
+
+// Lint checks the flow definition for probable mistakes. It doesn't execute anything.
+func (f *Flow) Lint() []LintWarning {
+	var warnings []LintWarning
+
+	// browser identity: templated headers can only be checked at runtime
+	var first *identity
+	for i, step := range f.Steps {
+		id := identity{step: i, name: step.Name, header: http.Header{}}
+		for k, v := range step.Request.Header {
+			if len(v) != 0 && !strings.Contains(v[0], f.delims(&step).left()) {
+				id.header.Set(k, v[0])
+			}
+		}
+		warnings = append(warnings, id.check(first)...)
+		if first == nil && id.header.Get("User-Agent") != "" {
+			first = &id
+		}
+	}
+
+	warnings = append(warnings, f.lintMethods()...)
+	warnings = append(warnings, f.lintChaos()...)
+	warnings = append(warnings, f.lintRetries()...)
+	return append(warnings, f.lintDuplicates()...)
+}
+
+// identity is the browser identity presented by a step's request
+type identity struct {
+	step   int
+	name   string
+	header http.Header
+}
+
+var (
+	uaChromium = regexp.MustCompile(`(?:Chrome|Chromium|Edg|OPR)/(\d+)`)
+	uaOther    = regexp.MustCompile(`Firefox/\d+|Version/[\d.]+ (?:Mobile/\w+ )?Safari/`)
+	uaPlatform = map[string]string{
+		`"Windows"`:   "Windows",
+		`"macOS"`:     "Mac OS X",
+		`"Linux"`:     "Linux",
+		`"Android"`:   "Android",
+		`"Chrome OS"`: "CrOS",
+		`"iOS"`:       "iPhone",
+	}
+)
+
+// check returns the inconsistencies of the identity, and between it and the
+// first identity of the flow if any
+func (id identity) check(first *identity) []LintWarning {
+	var warnings []LintWarning
+	warn := func(format string, a ...interface{}) {
+		warnings = append(warnings, LintWarning{Step: id.step, StepName: id.name,
+			Message: "fingerprint: " + fmt.Sprintf(format, a...)})
+	}
+
+	ua := id.header.Get("User-Agent")
+	chua := id.header.Get("Sec-Ch-Ua")
+	if ua != "" && chua != "" {
+		if m := uaChromium.FindStringSubmatch(ua); m != nil {
+			if !strings.Contains(chua, `v="`+m[1]+`"`) {
+				warn("Sec-Ch-Ua %s doesn't match the User-Agent version %s", chua, m[1])
+			}
+		} else if uaOther.MatchString(ua) {
+			warn("Sec-Ch-Ua is sent but the User-Agent isn't a Chromium browser")
+		}
+	}
+	if platform := id.header.Get("Sec-Ch-Ua-Platform"); ua != "" && platform != "" {
+		if token, ok := uaPlatform[platform]; ok && !strings.Contains(ua, token) {
+			warn("Sec-Ch-Ua-Platform %s doesn't match the User-Agent", platform)
+		}
+	}
+	if mobile := id.header.Get("Sec-Ch-Ua-Mobile"); ua != "" && mobile != "" {
+		if (mobile == "?1") != strings.Contains(ua, "Mobile") {
+			warn("Sec-Ch-Ua-Mobile %s doesn't match the User-Agent", mobile)
+		}
+	}
+
+	if first != nil {
+		if firstUA := first.header.Get("User-Agent"); ua != "" && ua != firstUA {
+			warn("User-Agent differs from step %d.'%s'", first.step, first.name)
+		}
+		lang, firstLang := id.header.Get("Accept-Language"), first.header.Get("Accept-Language")
+		if lang != "" && firstLang != "" && lang != firstLang {
+			warn("Accept-Language %s differs from step %d.'%s' (%s)", lang, first.step, first.name, firstLang)
+		}
+	}
+	return warnings
+}
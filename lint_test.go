@@ -0,0 +1,63 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_LintFingerprint(t *testing.T) {
+	chrome := testUserAgents[0]
+	f := Flow{Steps: []Step{
+		{Name: "home", Request: Request{Header: http.Header{
+			"User-Agent":         {chrome.UserAgent},
+			"Sec-Ch-Ua":          {chrome.SecCHUA},
+			"Sec-Ch-Ua-Mobile":   {"?0"},
+			"Sec-Ch-Ua-Platform": {`"Windows"`},
+			"Accept-Language":    {"en-US,en;q=0.9"},
+		}}},
+		{Name: "login", Request: Request{Header: http.Header{
+			"User-Agent":         {chrome.UserAgent},
+			"Sec-Ch-Ua":          {`"Chromium";v="119"`},
+			"Sec-Ch-Ua-Platform": {`"macOS"`},
+			"Accept-Language":    {"fr-FR"},
+		}}},
+		{Name: "api", Request: Request{Header: http.Header{
+			"User-Agent": {testUserAgents[1].UserAgent},
+			"Sec-Ch-Ua":  {chrome.SecCHUA},
+		}}},
+		{Name: "templated", Request: Request{Header: http.Header{"User-Agent": {"{{.ua}}"}}}},
+	}}
+
+	var msgs []string
+	for _, w := range f.Lint() {
+		msgs = append(msgs, w.String())
+	}
+	assert.Equal(t, []string{
+		`Step 1.'login' fingerprint: Sec-Ch-Ua "Chromium";v="119" doesn't match the User-Agent version 120`,
+		`Step 1.'login' fingerprint: Sec-Ch-Ua-Platform "macOS" doesn't match the User-Agent`,
+		`Step 1.'login' fingerprint: Accept-Language fr-FR differs from step 0.'home' (en-US,en;q=0.9)`,
+		`Step 2.'api' fingerprint: Sec-Ch-Ua is sent but the User-Agent isn't a Chromium browser`,
+		`Step 2.'api' fingerprint: User-Agent differs from step 0.'home'`,
+	}, msgs)
+}
+
+func TestFlow_ExecuteCheckFingerprint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	f := Flow{
+		CheckFingerprint: true,
+		UserAgents:       &UserAgentPolicy{Pool: testUserAgents},
+		Steps: []Step{
+			{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}},
+			{Name: "api", Request: Request{URL: srv.URL, Method: "GET", Header: http.Header{"User-Agent": {"{{.ua}}"}}},
+				KeysInput: []string{"ua"}},
+		},
+	}
+	assert.Nil(t, f.Execute(map[string]interface{}{"ua": "curl/7.50"}))
+	assert.Len(t, f.Warnings, 1)
+	assert.Equal(t, "Step 1.'api' fingerprint: User-Agent differs from step 0.'home'", f.Warnings[0].String())
+}
@@ -0,0 +1,199 @@
+package httpsim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Locale describes how numbers and dates are formatted by a site, to normalize
+// the extracted values into canonical forms
+type Locale struct {
+	// Decimal is the decimal separator e.g. ","
+	Decimal rune
+	// Group are the accepted digit group separators e.g. ".", " "
+	Group []rune
+	// DateLayouts are the time.Parse layouts of the dates, tried in order. Month
+	// names are written in English, see Months.
+	DateLayouts []string
+	// Months are the lowercase month names (full or abbreviated) mapped to their
+	// month, translated to English before parsing dates
+	Months map[string]time.Month
+}
+
+// The common locales
+var (
+	LocaleUS = Locale{
+		Decimal:     '.',
+		Group:       []rune{','},
+		DateLayouts: []string{"01/02/2006", "1/2/2006", "January 2, 2006", "Jan 2, 2006", "2006-01-02"},
+	}
+	LocaleUK = Locale{
+		Decimal:     '.',
+		Group:       []rune{','},
+		DateLayouts: []string{"02/01/2006", "2/1/2006", "2 January 2006", "2 Jan 2006", "2006-01-02"},
+	}
+	LocaleFR = Locale{
+		Decimal:     ',',
+		Group:       []rune{' ', '\u00a0', '\u202f', '.'},
+		DateLayouts: []string{"02/01/2006", "2/1/2006", "2 January 2006", "2006-01-02"},
+		Months: map[string]time.Month{
+			"janvier": time.January, "février": time.February, "fevrier": time.February, "mars": time.March,
+			"avril": time.April, "mai": time.May, "juin": time.June, "juillet": time.July, "août": time.August,
+			"aout": time.August, "septembre": time.September, "octobre": time.October,
+			"novembre": time.November, "décembre": time.December, "decembre": time.December,
+		},
+	}
+	LocaleDE = Locale{
+		Decimal:     ',',
+		Group:       []rune{'.', ' ', '\u00a0', '\''},
+		DateLayouts: []string{"02.01.2006", "2.1.2006", "2. January 2006", "2006-01-02"},
+		Months: map[string]time.Month{
+			"januar": time.January, "jänner": time.January, "februar": time.February, "märz": time.March,
+			"april": time.April, "mai": time.May, "juni": time.June, "juli": time.July, "august": time.August,
+			"september": time.September, "oktober": time.October, "november": time.November,
+			"dezember": time.December,
+		},
+	}
+)
+
+// ParseNumber parses a number formatted in the locale, with an optional sign or
+// parentheses for negatives and surrounding currency symbols or units
+// (e.g. "1.234,56 €"), into its canonical form e.g. "1234.56"
+func (l Locale) ParseNumber(s string) (string, error) {
+	runes := []rune(strings.TrimSpace(s))
+	first, last := -1, -1
+	for i, r := range runes {
+		if unicode.IsDigit(r) {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first == -1 {
+		return "", fmt.Errorf("no number in '%s'", s)
+	}
+	if first > 0 && runes[first-1] == l.Decimal {
+		first--
+	}
+
+	// the sign or parentheses are next to the number, only currency symbols or
+	// spaces between
+	filler := func(r rune) bool { return unicode.IsSpace(r) || unicode.Is(unicode.Sc, r) }
+	minus := func(r rune) bool { return r == '-' || r == '−' }
+	before, after := first-1, last+1
+	for before >= 0 && filler(runes[before]) {
+		before--
+	}
+	for after < len(runes) && filler(runes[after]) {
+		after++
+	}
+	negative := false
+	switch {
+	case before >= 0 && minus(runes[before]):
+		// not a separator e.g. "Paid - 45"
+		negative = before+1 == first || !unicode.IsSpace(runes[before+1]) ||
+			strings.TrimFunc(string(runes[:before]), filler) == ""
+	case before >= 0 && runes[before] == '(':
+		negative = after < len(runes) && runes[after] == ')'
+	case after < len(runes) && minus(runes[after]):
+		// the trailing sign ends the text, not e.g. "45 - paid"
+		negative = strings.TrimFunc(string(runes[after+1:]), filler) == ""
+	}
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	decimal := false
+	for _, r := range runes[first : last+1] {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == l.Decimal:
+			if decimal {
+				return "", fmt.Errorf("invalid number '%s'", s)
+			}
+			decimal = true
+			if b.Len() == 0 || b.String() == "-" {
+				b.WriteByte('0')
+			}
+			b.WriteByte('.')
+		case l.isGroup(r) && !decimal:
+		default:
+			return "", fmt.Errorf("invalid number '%s'", s)
+		}
+	}
+	return b.String(), nil
+}
+
+func (l Locale) isGroup(r rune) bool {
+	for _, g := range l.Group {
+		if r == g {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDate parses a date formatted in the locale
+func (l Locale) ParseDate(s string) (time.Time, error) {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(l.Months) != 0 {
+		words := strings.Fields(s)
+		for i, w := range words {
+			if m, ok := l.Months[strings.ToLower(strings.TrimSuffix(w, "."))]; ok {
+				words[i] = m.String()
+			}
+		}
+		s = strings.Join(words, " ")
+	}
+	for _, layout := range l.DateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date '%s' matches none of the locale's layouts", s)
+}
+
+// Normalization is the canonical form an extracted value is normalized into
+type Normalization int
+
+const (
+	// NormalizeNumber normalizes numbers e.g. "1.234,56 €" into "1234.56"
+	NormalizeNumber Normalization = iota + 1
+	// NormalizeDate normalizes dates into "2006-01-02"
+	NormalizeDate
+)
+
+// Normalize returns the canonical form of the value
+func (l Locale) Normalize(n Normalization, value string) (string, error) {
+	switch n {
+	case NormalizeNumber:
+		return l.ParseNumber(value)
+	case NormalizeDate:
+		t, err := l.ParseDate(value)
+		if err != nil {
+			return "", err
+		}
+		return t.Format("2006-01-02"), nil
+	}
+	return "", errors.New("unknown normalization")
+}
+
+// normalize normalizes the value according to the step's Normalize with the
+// flow's locale. Empty values (not found but ignored) are kept.
+func (f *Flow) normalize(step *Step, name, value string) (string, error) {
+	n, ok := step.Normalize[name]
+	if !ok || value == "" {
+		return value, nil
+	}
+	locale := LocaleUS
+	if f.Locale != nil {
+		locale = *f.Locale
+	}
+	return locale.Normalize(n, value)
+}
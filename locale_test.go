@@ -0,0 +1,113 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocale_ParseNumber(t *testing.T) {
+	tests := []struct {
+		locale Locale
+		in     string
+		want   string
+	}{
+		{LocaleFR, "1.234,56 €", "1234.56"},
+		{LocaleFR, "1 234 567,8 EUR", "1234567.8"},
+		{LocaleFR, "-12,50 €", "-12.50"},
+		{LocaleDE, "1.234.567", "1234567"},
+		{LocaleDE, "(3,5)", "-3.5"},
+		{LocaleUS, "$1,234.56", "1234.56"},
+		{LocaleUS, "USD 42", "42"},
+		{LocaleUS, ".5", "0.5"},
+		{LocaleUS, "1,000.00-", "-1000.00"},
+		{LocaleFR, "Total (EUR) 45,00", "45.00"},
+		{LocaleFR, "45,00 - paid", "45.00"},
+		{LocaleFR, "Paid - 45,00", "45.00"},
+		{LocaleFR, "Total: -45,00", "-45.00"},
+		{LocaleFR, "- 45,00 €", "-45.00"},
+		{LocaleFR, "€ -45,00", "-45.00"},
+		{LocaleFR, "(45,00 €)", "-45.00"},
+		{LocaleFR, "45,00 € -", "-45.00"},
+	}
+	for _, tt := range tests {
+		got, err := tt.locale.ParseNumber(tt.in)
+		assert.Nil(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{"", "n/a", "1,2,3", "12 34 abc 5"} {
+		_, err := LocaleFR.ParseNumber(in)
+		assert.NotNil(t, err, in)
+	}
+	// group separators after the decimal one
+	_, err := LocaleUS.ParseNumber("1.234,5")
+	assert.NotNil(t, err)
+}
+
+func TestLocale_ParseDate(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		locale Locale
+		in     string
+	}{
+		{LocaleFR, "05/03/2024"},
+		{LocaleFR, "5 mars 2024"},
+		{LocaleFR, "5  Mars\n2024"},
+		{LocaleDE, "05.03.2024"},
+		{LocaleDE, "5. März 2024"},
+		{LocaleUS, "03/05/2024"},
+		{LocaleUS, "March 5, 2024"},
+		{LocaleUK, "5 Mar 2024"},
+		{LocaleUK, "2024-03-05"},
+	}
+	for _, tt := range tests {
+		got, err := tt.locale.ParseDate(tt.in)
+		assert.Nil(t, err, tt.in)
+		assert.Equal(t, day, got, tt.in)
+	}
+
+	_, err := LocaleFR.ParseDate("32/01/2024")
+	assert.NotNil(t, err)
+	_, err = LocaleUS.ParseDate("5 mars 2024")
+	assert.NotNil(t, err)
+}
+
+func TestFlow_ExecuteNormalize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<b id="balance">1.234,56 €</b><i id="date">5 mars 2024</i><u id="raw">1.234,56</u>`))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Locale: &LocaleFR,
+		Steps: []Step{{
+			Name:    "account",
+			Request: Request{URL: srv.URL, Method: "GET"},
+			KeysOutput: []Extracter{
+				Extractable{Name: "balance", AfterThis: `id="balance">`, BeforeThis: "<", MaxLength: -1, MinLength: -1},
+				Extractable{Name: "date", AfterThis: `id="date">`, BeforeThis: "<", MaxLength: -1, MinLength: -1},
+				Extractable{Name: "raw", AfterThis: `id="raw">`, BeforeThis: "<", MaxLength: -1, MinLength: -1},
+				Extractable{Name: "missing", AfterThis: `id="missing">`, BeforeThis: "<", MaxLength: -1,
+					MinLength: -1, IgnoreNotFound: true},
+			},
+			Normalize: map[string]Normalization{
+				"balance": NormalizeNumber, "date": NormalizeDate, "missing": NormalizeNumber,
+			},
+		}},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "1234.56", f.Values["balance"])
+	assert.Equal(t, "2024-03-05", f.Values["date"])
+	assert.Equal(t, "1.234,56", f.Values["raw"])
+	assert.Equal(t, "", f.Values["missing"])
+
+	// the default locale can't read it
+	f.Locale = nil
+	err := f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.IsType(t, &ExtractionError{}, err)
+}
@@ -0,0 +1,87 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// defaultLoopMax is the number of executions of a Loop without Max
+const defaultLoopMax = 10
+
+// Loop re-executes a step until its response passes Until, e.g. polling a
+// job's status endpoint until it's "done"
+type Loop struct {
+	// Until is checked like the PostHook once an execution succeeded, the step
+	// is executed again while it returns an error
+	Until func(statusCode int, header http.Header, body []byte) error
+	// Delay is the time between the executions
+	Delay time.Duration
+	// Max is the maximum number of executions, 10 when 0
+	Max int
+}
+
+// LoopError is returned when a step's Loop.Until didn't pass in Loop.Max
+// executions, or the execution was canceled while waiting
+type LoopError struct {
+	Step     int
+	StepName string
+	// Executions is the number of times the step was executed
+	Executions int
+	// Err is the last error of Until, or the context's error
+	Err error
+}
+
+func (e *LoopError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because its loop didn't pass after %d executions: %s",
+		e.Step, e.StepName, e.Executions, e.Err.Error())
+}
+
+// Unwrap returns the last error of Until
+func (e *LoopError) Unwrap() error {
+	return e.Err
+}
+
+// loopStep executes the step, again while its Loop.Until fails
+func (f *Flow) loopStep(run *runState, i int, def *Step) error {
+	loop := def.Loop
+	if loop == nil || loop.Until == nil {
+		return f.retryStep(run, i, def)
+	}
+	max := loop.Max
+	if max <= 0 {
+		max = defaultLoopMax
+	}
+	for n := 1; ; n++ {
+		// skipped or cached steps have no response to check
+		if err := f.retryStep(run, i, def); err != nil || def.Response == nil {
+			return err
+		}
+		resp := def.Response
+		err := loop.Until(resp.Raw.StatusCode, resp.Header, resp.Body)
+		if err == nil {
+			return nil
+		}
+		if n >= max {
+			return &LoopError{Step: i, StepName: def.Name, Executions: n, Err: err}
+		}
+		if err := wait(loop.Delay, run.ctx, run.requestCtx); err != nil {
+			return &LoopError{Step: i, StepName: def.Name, Executions: n, Err: err}
+		}
+	}
+}
+
+// wait waits for d, or until a context is done
+func wait(d time.Duration, ctx, other context.Context) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-other.Done():
+		return other.Err()
+	}
+}
@@ -0,0 +1,56 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_LoopStep(t *testing.T) {
+	var polls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		status := "running"
+		if polls >= 3 {
+			status = "done"
+		}
+		fmt.Fprintf(w, `{"status": "%s"}`, status)
+	}))
+	defer srv.Close()
+
+	until := func(statusCode int, header http.Header, body []byte) error {
+		if string(body) != `{"status": "done"}` {
+			return errors.New("the job isn't done")
+		}
+		return nil
+	}
+	f := Flow{Steps: []Step{{Name: "job", Request: Request{URL: srv.URL, Method: "GET"},
+		KeysOutput: []Extracter{JSONExtractable{Name: "status", Path: "status"}},
+		Loop:       &Loop{Until: until, Delay: time.Millisecond}}}}
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, 3, polls)
+	assert.Equal(t, "done", f.Values["status"])
+
+	polls = -10
+	f.Steps[0].Loop.Max = 4
+	err := f.ExecuteContext(context.Background(), nil)
+	var loopErr *LoopError
+	assert.True(t, errors.As(err, &loopErr))
+	assert.Equal(t, 4, loopErr.Executions)
+	assert.EqualError(t, err, "Step 0.'job' failed because its loop didn't pass after 4 executions: the job isn't done")
+
+	// canceled while waiting
+	polls = -10
+	f.Steps[0].Loop.Delay = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = f.ExecuteContext(ctx, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, -9, polls)
+}
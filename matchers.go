@@ -0,0 +1,131 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"regexp"
+)
+
+// RecordedRequest is a request of a recorded interaction, or the request
+// matched against it
+type RecordedRequest struct {
+	Method string
+	// URL is absolute for the ReplayTransport, the path and query for the MockServer
+	URL  string
+	Body []byte
+}
+
+// RequestMatcher tells whether a request matches a recorded one, see ReplayTransport
+type RequestMatcher interface {
+	Match(recorded, actual *RecordedRequest) bool
+}
+
+// MatcherFunc is an adapter to use an ordinary function as a RequestMatcher
+type MatcherFunc func(recorded, actual *RecordedRequest) bool
+
+// Match calls f(recorded, actual)
+func (f MatcherFunc) Match(recorded, actual *RecordedRequest) bool {
+	return f(recorded, actual)
+}
+
+// sameTarget returns whether the requests have the same method and URL
+func sameTarget(recorded, actual *RecordedRequest) bool {
+	return recorded.Method == actual.Method && recorded.URL == actual.URL
+}
+
+// ExactMatcher matches the requests with the same method, URL and body
+type ExactMatcher struct{}
+
+// Match implements RequestMatcher
+func (ExactMatcher) Match(recorded, actual *RecordedRequest) bool {
+	return sameTarget(recorded, actual) && bytes.Equal(recorded.Body, actual.Body)
+}
+
+// JSONMatcher matches the requests with the same method and URL, and the same
+// JSON bodies but for the Ignore fields (e.g. "timestamp", "nonce"), at any
+// depth. The bodies that aren't JSON must be the same.
+type JSONMatcher struct {
+	Ignore []string
+}
+
+// Match implements RequestMatcher
+func (m JSONMatcher) Match(recorded, actual *RecordedRequest) bool {
+	if !sameTarget(recorded, actual) {
+		return false
+	}
+	var a, b interface{}
+	if json.Unmarshal(recorded.Body, &a) != nil || json.Unmarshal(actual.Body, &b) != nil {
+		return bytes.Equal(recorded.Body, actual.Body)
+	}
+	ignore := map[string]bool{}
+	for _, k := range m.Ignore {
+		ignore[k] = true
+	}
+	return reflect.DeepEqual(withoutFields(a, ignore), withoutFields(b, ignore))
+}
+
+// withoutFields removes the fields from the JSON value's objects
+func withoutFields(v interface{}, fields map[string]bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if fields[k] {
+				delete(t, k)
+				continue
+			}
+			t[k] = withoutFields(child, fields)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = withoutFields(child, fields)
+		}
+	}
+	return v
+}
+
+// FormMatcher matches the requests with the same method and URL whose
+// form-encoded body has the recorded body's Fields, all of them when empty.
+// The other fields of the request are ignored.
+type FormMatcher struct {
+	Fields []string
+}
+
+// Match implements RequestMatcher
+func (m FormMatcher) Match(recorded, actual *RecordedRequest) bool {
+	if !sameTarget(recorded, actual) {
+		return false
+	}
+	a, err := url.ParseQuery(string(recorded.Body))
+	if err != nil {
+		return false
+	}
+	b, err := url.ParseQuery(string(actual.Body))
+	if err != nil {
+		return false
+	}
+	fields := m.Fields
+	if len(fields) == 0 {
+		for k := range a {
+			fields = append(fields, k)
+		}
+	}
+	for _, k := range fields {
+		if !reflect.DeepEqual(a[k], b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// URLRegexpMatcher matches the requests with the recorded method whose URL
+// matches URL, e.g. to ignore a cache buster. The bodies are ignored.
+type URLRegexpMatcher struct {
+	URL *regexp.Regexp
+}
+
+// Match implements RequestMatcher
+func (m URLRegexpMatcher) Match(recorded, actual *RecordedRequest) bool {
+	return recorded.Method == actual.Method && m.URL.MatchString(actual.URL)
+}
@@ -0,0 +1,36 @@
+package httpsim
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchers(t *testing.T) {
+	req := func(method, url, body string) *RecordedRequest {
+		return &RecordedRequest{Method: method, URL: url, Body: []byte(body)}
+	}
+	recorded := req("POST", "https://site/api", `{"user": "bob", "ts": 1, "meta": {"nonce": "a", "v": 1}}`)
+
+	assert.True(t, ExactMatcher{}.Match(recorded, req("POST", "https://site/api", string(recorded.Body))))
+	assert.False(t, ExactMatcher{}.Match(recorded, req("POST", "https://site/api", `{}`)))
+
+	m := JSONMatcher{Ignore: []string{"ts", "nonce"}}
+	assert.True(t, m.Match(recorded, req("POST", "https://site/api", `{"meta": {"v": 1, "nonce": "b"}, "ts": 2, "user": "bob"}`)))
+	assert.False(t, m.Match(recorded, req("POST", "https://site/api", `{"meta": {"v": 2}, "user": "bob"}`)))
+	assert.False(t, m.Match(recorded, req("PUT", "https://site/api", string(recorded.Body))))
+	assert.True(t, m.Match(req("POST", "u", "a=b"), req("POST", "u", "a=b")))
+	assert.False(t, m.Match(req("POST", "u", "a=b"), req("POST", "u", "a=c")))
+
+	form := req("POST", "https://site/login", "user=bob&csrf=x1&ts=1")
+	assert.True(t, FormMatcher{Fields: []string{"user"}}.Match(form, req("POST", "https://site/login", "ts=2&user=bob&csrf=x2")))
+	assert.False(t, FormMatcher{Fields: []string{"user"}}.Match(form, req("POST", "https://site/login", "user=alice")))
+	assert.True(t, FormMatcher{}.Match(form, req("POST", "https://site/login", "user=bob&csrf=x1&ts=1&extra=1")))
+	assert.False(t, FormMatcher{}.Match(form, req("POST", "https://site/login", "user=bob&csrf=x1")))
+
+	re := URLRegexpMatcher{URL: regexp.MustCompile(`^https://site/app\.js\?v=\d+$`)}
+	assert.True(t, re.Match(req("GET", "https://site/app.js?v=1", ""), req("GET", "https://site/app.js?v=2", "")))
+	assert.False(t, re.Match(req("GET", "https://site/app.js?v=1", ""), req("POST", "https://site/app.js?v=2", "")))
+	assert.False(t, re.Match(req("GET", "https://site/app.js?v=1", ""), req("GET", "https://site/app.css?v=2", "")))
+}
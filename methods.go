@@ -0,0 +1,91 @@
+package httpsim
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// noResponseBody returns whether the step's responses have no body (HEAD): the
+// body isn't read, nor extracted from, hashed or snapshotted
+func (s *Step) noResponseBody() bool {
+	return strings.EqualFold(s.Request.Method, http.MethodHead)
+}
+
+// bodyless returns whether the response can't have a body, even when its
+// headers describe one: HEAD requests, 1xx, 204 and 304 responses, or an empty
+// Content-Length
+func bodyless(step *Step, resp *http.Response) bool {
+	return step.noResponseBody() || resp.StatusCode < 200 || resp.StatusCode == http.StatusNoContent ||
+		resp.StatusCode == http.StatusNotModified || resp.ContentLength == 0
+}
+
+// safeMethod returns whether the method doesn't modify the server's state
+// (GET, HEAD, OPTIONS, TRACE), see Flow.ReadOnly
+func safeMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// defaultContentType returns the Content-Type of the body when the request
+// doesn't set it, for the methods whose bodies servers often require to be typed
+// (DELETE, PATCH). It's empty when there's no body or it can't be guessed.
+func (r *Request) defaultContentType(body []byte) string {
+	if len(body) == 0 || r.Header.Get("Content-Type") != "" {
+		return ""
+	}
+	switch strings.ToUpper(r.Method) {
+	case http.MethodDelete, http.MethodPatch:
+	default:
+		return ""
+	}
+	if _, form := r.Body.(url.Values); form {
+		return "application/x-www-form-urlencoded"
+	}
+	if b := bytes.TrimSpace(body); len(b) != 0 && (b[0] == '{' || b[0] == '[') {
+		return "application/json"
+	}
+	return ""
+}
+
+// requestBody returns the bytes of the request's body
+func requestBody(v interface{}) []byte {
+	switch t := v.(type) {
+	case []byte:
+		return t
+	case string:
+		return []byte(t)
+	case url.Values:
+		return []byte(t.Encode())
+	}
+	return nil
+}
+
+// lintMethods warns about the requests misusing their method
+func (f *Flow) lintMethods() []LintWarning {
+	var warnings []LintWarning
+	for i, step := range f.Steps {
+		warn := func(format string, a ...interface{}) {
+			warnings = append(warnings, LintWarning{Step: i, StepName: step.Name, Message: fmt.Sprintf(format, a...)})
+		}
+		method := strings.ToUpper(step.Request.Method)
+		if (method == http.MethodGet || method == http.MethodHead) && len(requestBody(step.Request.Body)) != 0 {
+			warn("%s request has a body, servers may ignore or reject it", method)
+		}
+		if method != http.MethodHead {
+			continue
+		}
+		if len(step.KeysOutput) != 0 {
+			warn("HEAD response has no body to extract %d outputs from", len(step.KeysOutput))
+		}
+		if len(step.Forbid) != 0 || len(step.ForbidRegexp) != 0 || step.ExpectBodySHA256 != "" {
+			warn("HEAD response has no body to check")
+		}
+	}
+	return warnings
+}
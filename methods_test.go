@@ -0,0 +1,96 @@
+package httpsim
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Head(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Id", "42")
+		w.Write([]byte("<b>body</b>"))
+	}))
+	defer srv.Close()
+	f := Flow{Steps: []Step{{
+		Name:             "head",
+		Request:          Request{URL: srv.URL, Method: "HEAD"},
+		KeysOutput:       []Extracter{Extractable{Name: "b", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}},
+		ExpectBodySHA256: "00",
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	_, ok := f.Values["b"]
+	assert.False(t, ok)
+}
+
+func TestFlow_GzipWithoutBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		switch r.URL.Path {
+		case "/cached":
+			w.WriteHeader(http.StatusNotModified)
+		case "/broken":
+			w.Write([]byte("this isn't gzipped"))
+		}
+	}))
+	defer srv.Close()
+
+	header := http.Header{"Accept-Encoding": {"gzip"}}
+	f := Flow{Steps: []Step{
+		{Name: "head", Request: Request{URL: srv.URL, Method: "HEAD", Header: header}},
+		{Name: "cached", Request: Request{URL: srv.URL + "/cached", Method: "GET", Header: header}, ExpectStatus: []string{"304"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+
+	f.Steps = []Step{{Name: "broken", Request: Request{URL: srv.URL + "/broken", Method: "GET", Header: header}}}
+	assert.EqualError(t, f.Execute(nil), "Step 0.'broken' failed because couldn't decompress body: gzip: invalid header")
+}
+
+func TestRequest_DefaultContentType(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		got = append(got, r.Method+" "+r.Header.Get("Content-Type"))
+	}))
+	defer srv.Close()
+	header := http.Header{"Content-Type": {"text/plain"}}
+	f := Flow{Steps: []Step{
+		{Name: "delete", Request: Request{URL: srv.URL, Method: "DELETE", Body: `{"id": 1}`}},
+		{Name: "patch", Request: Request{URL: srv.URL, Method: "PATCH", Body: url.Values{"a": {"b"}}}},
+		{Name: "typed", Request: Request{URL: srv.URL, Method: "PATCH", Body: "{}", Header: header}},
+		{Name: "empty", Request: Request{URL: srv.URL, Method: "DELETE"}},
+		{Name: "post", Request: Request{URL: srv.URL, Method: "POST", Body: "{}"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{
+		"DELETE application/json",
+		"PATCH application/x-www-form-urlencoded",
+		"PATCH text/plain",
+		"DELETE ",
+		"POST ",
+	}, got)
+	assert.Equal(t, "text/plain", header.Get("Content-Type"))
+	assert.Empty(t, f.Steps[0].Request.Header.Get("Content-Type"))
+}
+
+func TestFlow_LintMethods(t *testing.T) {
+	f := Flow{Steps: []Step{
+		{Name: "get", Request: Request{Method: "GET", Body: "a=b"}},
+		{Name: "empty", Request: Request{Method: "GET", Body: ""}},
+		{Name: "head", Request: Request{Method: "head"}, KeysOutput: []Extracter{Extractable{Name: "id"}}, Forbid: []string{"error"}},
+		{Name: "delete", Request: Request{Method: "DELETE", Body: "{}"}},
+	}}
+	var messages []string
+	for _, w := range f.lintMethods() {
+		messages = append(messages, w.String())
+	}
+	assert.Equal(t, []string{
+		"Step 0.'get' GET request has a body, servers may ignore or reject it",
+		"Step 2.'head' HEAD response has no body to extract 1 outputs from",
+		"Step 2.'head' HEAD response has no body to check",
+	}, messages)
+}
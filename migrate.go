@@ -0,0 +1,56 @@
+package httpsim
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// migratedHost returns the host the response migrates the client to: the
+// Location's when it isn't followed (the followed redirects are the request's),
+// else the first alternative host of the Alt-Svc, else the request's
+func migratedHost(resp *http.Response) string {
+	if resp.Request == nil {
+		return ""
+	}
+	u := resp.Request.URL
+	if resp.StatusCode/100 == 3 {
+		if loc, err := resp.Location(); err == nil && loc.Host != "" {
+			return loc.Host
+		}
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if host := altSvcHost(resp.Header.Get("Alt-Svc"), port); host != "" {
+		return host
+	}
+	return u.Host
+}
+
+// altSvcHost returns the first alternative of the Alt-Svc header on another
+// host, e.g. "alt.example.com:8443" for h2="alt.example.com:8443". The port is
+// omitted when it's the request's.
+func altSvcHost(altSvc, port string) string {
+	for _, svc := range strings.Split(altSvc, ",") {
+		kv := strings.SplitN(strings.SplitN(svc, ";", 2)[0], "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		authority, err := strconv.Unquote(strings.TrimSpace(kv[1]))
+		if err != nil {
+			continue
+		}
+		i := strings.LastIndex(authority, ":")
+		if i <= 0 {
+			// same host, another port
+			continue
+		}
+		if authority[i+1:] == port {
+			return authority[:i]
+		}
+		return authority
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package httpsim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAltSvcHost(t *testing.T) {
+	for altSvc, host := range map[string]string{
+		`h2="alt.example.com:443"; ma=3600`:           "alt.example.com",
+		`h3=":443", h2="alt.example.com:8443"`:        "alt.example.com:8443",
+		`h3-29=":443"; ma=86400, h3=":443"; ma=86400`: "",
+		`clear`: "",
+		``:      "",
+	} {
+		assert.Equal(t, host, altSvcHost(altSvc, "443"), altSvc)
+	}
+}
+
+func TestFlow_CaptureHost(t *testing.T) {
+	var paths []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer api.Close()
+	apiHost := strings.TrimPrefix(api.URL, "http://")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session":
+			// migrates the client to the API's host
+			http.Redirect(w, r, api.URL+"/welcome", http.StatusFound)
+		case "/region":
+			w.Header().Set("Alt-Svc", `h2="`+apiHost+`"; ma=60`)
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{
+		{Name: "session", Request: Request{URL: srv.URL + "/session", Method: "GET", IgnoreRedirects: true},
+			ExpectStatus: []string{"302"}, CaptureHost: "api"},
+		{Name: "orders", Request: Request{URL: "http://{{.api}}/orders", Method: "GET"}, KeysInput: []string{"api"},
+			CaptureHost: "same"},
+		{Name: "region", Request: Request{URL: srv.URL + "/region", Method: "GET"}, CaptureHost: "regional"},
+		{Name: "profile", Request: Request{URL: "http://{{.regional}}/profile", Method: "GET"}, KeysInput: []string{"regional"}},
+	}}
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, apiHost, f.Values["api"])
+	assert.Equal(t, apiHost, f.Values["same"])
+	assert.Equal(t, apiHost, f.Values["regional"])
+	assert.Equal(t, []string{"/orders", "/profile"}, paths)
+
+	// followed redirects are the request's host
+	f.Steps[0].Request.IgnoreRedirects = false
+	f.Steps[0].ExpectStatus = nil
+	paths = nil
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, apiHost, f.Values["api"])
+	assert.Equal(t, []string{"/welcome", "/orders", "/profile"}, paths)
+}
+
+func TestFlow_DescribeCaptureHost(t *testing.T) {
+	f := Flow{Steps: []Step{{Name: "session", Request: Request{URL: "https://example.com/session", Method: "GET"},
+		CaptureHost: "api"}}}
+	assert.Equal(t, []ValueDescription{{Name: "api", Type: "string"}}, f.Describe().Steps[0].Outputs)
+}
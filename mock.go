@@ -0,0 +1,316 @@
+package httpsim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// Interaction is a recorded request and its response, replayed by the
+// ReplayTransport and MockServer
+type Interaction struct {
+	Request RecordedRequest
+	Status  int
+	Header  http.Header
+	Body    []byte
+	// Matcher, if set, overrides the replayer's matcher for this interaction
+	Matcher RequestMatcher
+
+	// The interactions with a Sequence, When or Do are scripted: they answer
+	// all the requests they match, before the recorded ones.
+
+	// Sequence are the responses to the successive requests matched, the last
+	// one repeating. Status, Header and Body are ignored then.
+	Sequence []MockResponse
+	// When, if set, must be true for the interaction to match, e.g. a counter
+	// below a limit or a token not redeemed yet
+	When func(state *MockState, req *RecordedRequest) bool
+	// Do, if set, updates the state once the interaction matched, e.g. counts
+	// the logins or stores a token
+	Do func(state *MockState, req *RecordedRequest)
+}
+
+// MockResponse is a response of a scripted interaction
+type MockResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// scripted returns whether the interaction is scripted
+func (in *Interaction) scripted() bool {
+	return len(in.Sequence) != 0 || in.When != nil || in.Do != nil
+}
+
+// MockState is the state of the scripted interactions: counters and values.
+// It's only used from When and Do, which are called with it locked.
+type MockState struct {
+	counters map[string]int
+	values   map[string]string
+}
+
+func newMockState() *MockState {
+	return &MockState{counters: map[string]int{}, values: map[string]string{}}
+}
+
+// Incr increments the counter and returns its new value
+func (s *MockState) Incr(name string) int {
+	s.counters[name]++
+	return s.counters[name]
+}
+
+// Count returns the value of the counter, 0 if never incremented
+func (s *MockState) Count(name string) int {
+	return s.counters[name]
+}
+
+// Set stores the value
+func (s *MockState) Set(name, value string) {
+	s.values[name] = value
+}
+
+// Get returns the value, and whether it's set
+func (s *MockState) Get(name string) (string, bool) {
+	v, ok := s.values[name]
+	return v, ok
+}
+
+// Delete removes the value
+func (s *MockState) Delete(name string) {
+	delete(s.values, name)
+}
+
+// InteractionsFromHAR returns the interactions of a HAR recording to the hosts allowed
+func InteractionsFromHAR(data []byte, hosts *HostFilter) ([]Interaction, error) {
+	entries, err := parseHAR(data, hosts)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	for _, e := range entries {
+		in := Interaction{
+			Request: RecordedRequest{Method: e.Request.Method, URL: e.Request.URL},
+			Status:  e.Response.Status,
+			Header:  http.Header{},
+			Body:    []byte(harBody(e)),
+		}
+		if e.Request.PostData != nil {
+			in.Request.Body = []byte(e.Request.PostData.Text)
+		}
+		for _, h := range e.Response.Headers {
+			in.Header.Add(h.Name, h.Value)
+		}
+		// the body is decoded, and its length may differ from the recorded one
+		in.Header.Del("Content-Encoding")
+		in.Header.Del("Content-Length")
+		interactions = append(interactions, in)
+	}
+	return interactions, nil
+}
+
+// interactionSet finds the interactions matching requests. The scripted ones
+// are tried first, the recorded ones are then replayed in order: the first
+// unused one matching is picked, the last used one when all were (e.g.
+// polling). It's safe for concurrent use.
+type interactionSet struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	calls        []int
+	state        *MockState
+	matcher      RequestMatcher
+}
+
+func newInteractionSet(interactions []Interaction, matcher RequestMatcher) *interactionSet {
+	if matcher == nil {
+		matcher = ExactMatcher{}
+	}
+	return &interactionSet{interactions: interactions, used: make([]bool, len(interactions)),
+		calls: make([]int, len(interactions)), state: newMockState(), matcher: matcher}
+}
+
+// reset forgets the interactions used and the state
+func (s *interactionSet) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used = make([]bool, len(s.interactions))
+	s.calls = make([]int, len(s.interactions))
+	s.state = newMockState()
+}
+
+// matches returns whether the interaction matches the request
+func (s *interactionSet) matches(in *Interaction, actual *RecordedRequest) bool {
+	matcher := in.Matcher
+	if matcher == nil {
+		matcher = s.matcher
+	}
+	return matcher.Match(&in.Request, actual) && (in.When == nil || in.When(s.state, actual))
+}
+
+// respond returns the response to the request, false if no interaction matches
+func (s *interactionSet) respond(actual *RecordedRequest) (MockResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	picked, last := -1, -1
+	for i := range s.interactions {
+		if in := &s.interactions[i]; in.scripted() && s.matches(in, actual) {
+			picked = i
+			break
+		}
+	}
+	for i := 0; picked == -1 && i < len(s.interactions); i++ {
+		in := &s.interactions[i]
+		if in.scripted() || !s.matches(in, actual) {
+			continue
+		}
+		if !s.used[i] {
+			picked = i
+		}
+		last = i
+	}
+	if picked == -1 {
+		picked = last
+	}
+	if picked == -1 {
+		return MockResponse{}, false
+	}
+
+	in := &s.interactions[picked]
+	s.used[picked] = true
+	s.calls[picked]++
+	if in.Do != nil {
+		in.Do(s.state, actual)
+	}
+	if n := len(in.Sequence); n != 0 {
+		if s.calls[picked] > n {
+			return in.Sequence[n-1], true
+		}
+		return in.Sequence[s.calls[picked]-1], true
+	}
+	return MockResponse{Status: in.Status, Header: in.Header, Body: in.Body}, true
+}
+
+// readRequest returns the request as matched against the recorded ones, its
+// URL is the path and query when pathOnly
+func readRequest(req *http.Request, pathOnly bool) (*RecordedRequest, error) {
+	actual := &RecordedRequest{Method: req.Method, URL: req.URL.String()}
+	if pathOnly {
+		actual.URL = req.URL.RequestURI()
+	}
+	if req.Body != nil {
+		defer req.Body.Close()
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		actual.Body = b
+	}
+	return actual, nil
+}
+
+// ReplayTransport is an http.RoundTripper answering with the recorded
+// interactions, e.g. to run flows offline (see Flow.Transport)
+type ReplayTransport struct {
+	// Faults, if set, are injected in the responses
+	Faults *Faults
+
+	set *interactionSet
+}
+
+// NewReplayTransport replays the interactions matched with the matcher,
+// ExactMatcher when nil
+func NewReplayTransport(interactions []Interaction, matcher RequestMatcher) *ReplayTransport {
+	return &ReplayTransport{set: newInteractionSet(interactions, matcher)}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	actual, err := readRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+	in, ok := t.set.respond(actual)
+	if !ok {
+		return nil, fmt.Errorf("no recorded interaction matches %s %s", req.Method, req.URL)
+	}
+	ft := t.Faults.next()
+	in = t.Faults.apply(ft, in)
+	header := in.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	resp := &http.Response{
+		Status:        strconv.Itoa(in.Status) + " " + http.StatusText(in.Status),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(in.Body)),
+		ContentLength: int64(len(in.Body)),
+		Request:       req,
+	}
+	return t.Faults.faultyResponse(req, ft, resp, in.Body)
+}
+
+// Reset forgets the interactions replayed and the state of the scripted ones
+func (t *ReplayTransport) Reset() {
+	t.set.reset()
+}
+
+// MockServer is an http.Handler answering with the recorded interactions, e.g.
+// to stand in for a site in tests. It matches the path and query of the
+// requests, the recorded hosts are ignored.
+type MockServer struct {
+	// Faults, if set, are injected in the responses
+	Faults *Faults
+
+	set *interactionSet
+}
+
+// NewMockServer serves the interactions matched with the matcher, ExactMatcher
+// when nil
+func NewMockServer(interactions []Interaction, matcher RequestMatcher) *MockServer {
+	local := make([]Interaction, len(interactions))
+	for i, in := range interactions {
+		local[i] = in
+		if u, err := url.Parse(in.Request.URL); err == nil {
+			local[i].Request.URL = u.RequestURI()
+		}
+	}
+	return &MockServer{set: newInteractionSet(local, matcher)}
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	actual, err := readRequest(req, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in, ok := s.set.respond(actual)
+	if !ok {
+		http.Error(w, fmt.Sprintf("no recorded interaction matches %s %s", req.Method, actual.URL), http.StatusNotImplemented)
+		return
+	}
+	ft := s.Faults.next()
+	in = s.Faults.apply(ft, in)
+	if s.Faults.serveFaulty(w, req, ft, in) {
+		return
+	}
+	for k, vs := range in.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(in.Status)
+	w.Write(in.Body)
+}
+
+// Reset forgets the interactions served and the state of the scripted ones
+func (s *MockServer) Reset() {
+	s.set.reset()
+}
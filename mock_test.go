@@ -0,0 +1,125 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayTransport(t *testing.T) {
+	nonce := Extractable{Name: "nonce", AfterThis: "<i>", BeforeThis: "</i>", MaxLength: -1, MinLength: -1}
+	interactions := []Interaction{
+		{Request: RecordedRequest{Method: "GET", URL: "https://site/login?cb=1"}, Status: 200, Body: []byte("<i>n1</i>"),
+			Matcher: URLRegexpMatcher{URL: regexp.MustCompile(`^https://site/login\?cb=\d+$`)}},
+		{Request: RecordedRequest{Method: "POST", URL: "https://site/login", Body: []byte(`{"nonce": "n0", "user": "bob"}`)},
+			Status: 302, Header: http.Header{"Location": {"/home"}}},
+		{Request: RecordedRequest{Method: "GET", URL: "https://site/home"}, Status: 200, Body: []byte("first")},
+		{Request: RecordedRequest{Method: "GET", URL: "https://site/home"}, Status: 200, Body: []byte("second")},
+	}
+	f := Flow{
+		Transport: NewReplayTransport(interactions, JSONMatcher{Ignore: []string{"nonce"}}),
+		Steps: []Step{
+			{Name: "page", Request: Request{URL: "https://site/login?cb=42", Method: "GET"}, KeysOutput: []Extracter{nonce}},
+			{Name: "login", Request: Request{URL: "https://site/login", Method: "POST", Body: `{"nonce": "{{.nonce}}", "user": "bob"}`, IgnoreRedirects: true},
+				KeysInput: []string{"nonce"}},
+			{Name: "home", Request: Request{URL: "https://site/home", Method: "GET"}},
+			{Name: "poll", Request: Request{URL: "https://site/home", Method: "GET"}},
+			{Name: "poll again", Request: Request{URL: "https://site/home", Method: "GET"}},
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "first", string(f.Steps[2].Response.Body))
+	assert.Equal(t, "second", string(f.Steps[3].Response.Body))
+	assert.Equal(t, "second", string(f.Steps[4].Response.Body))
+
+	f.Steps = append(f.Steps, Step{Name: "new", Request: Request{URL: "https://site/new", Method: "GET"}})
+	f.Transport = NewReplayTransport(interactions, JSONMatcher{Ignore: []string{"nonce"}})
+	err := f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no recorded interaction matches GET https://site/new")
+}
+
+func TestMockServer(t *testing.T) {
+	recording := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>" + r.URL.Path + "</p>"))
+	}))
+	f := Flow{Steps: []Step{
+		{Name: "a", Request: Request{URL: recording.URL + "/a?x=1", Method: "GET"}},
+		{Name: "b", Request: Request{URL: recording.URL + "/b", Method: "POST", Body: "user=bob&ts=1"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	recording.Close()
+	har, err := f.ExportHAR()
+	assert.Nil(t, err)
+	interactions, err := InteractionsFromHAR(har, nil)
+	assert.Nil(t, err)
+	assert.Len(t, interactions, 2)
+
+	mock := httptest.NewServer(NewMockServer(interactions, FormMatcher{Fields: []string{"user"}}))
+	defer mock.Close()
+	f.Steps[0].Request.URL = mock.URL + "/a?x=1"
+	f.Steps[1].Request.URL = mock.URL + "/b"
+	f.Steps[1].Request.Body = "user=bob&ts=2"
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "<p>/b</p>", string(f.Steps[1].Response.Body))
+	assert.Equal(t, "text/html", f.Steps[1].Response.Header.Get("Content-Type"))
+
+	f.Steps[1].Request.Body = "user=alice"
+	err = f.Execute(nil)
+	assert.IsType(t, &StatusError{}, err)
+	assert.Equal(t, 501, f.Steps[1].Response.Raw.StatusCode)
+}
+
+func TestMockServer_Scripted(t *testing.T) {
+	get := func(url string) RecordedRequest { return RecordedRequest{Method: "GET", URL: url} }
+	interactions := []Interaction{
+		// the first 2 logins succeed, the next are rate limited
+		{Request: RecordedRequest{Method: "POST", URL: "/login"}, Matcher: URLRegexpMatcher{URL: regexp.MustCompile(`^/login$`)},
+			When:     func(s *MockState, req *RecordedRequest) bool { return s.Count("login") < 2 },
+			Do:       func(s *MockState, req *RecordedRequest) { s.Incr("login") },
+			Sequence: []MockResponse{{Status: 200, Body: []byte("<i>t1</i>")}, {Status: 200, Body: []byte("<i>t2</i>")}}},
+		{Request: RecordedRequest{Method: "POST", URL: "/login"}, Matcher: URLRegexpMatcher{URL: regexp.MustCompile(`^/login$`)},
+			Sequence: []MockResponse{{Status: 429}}},
+		// the tokens are redeemed once
+		{Request: get("/redeem"), Matcher: URLRegexpMatcher{URL: regexp.MustCompile(`^/redeem\?token=`)},
+			When: func(s *MockState, req *RecordedRequest) bool {
+				_, redeemed := s.Get(req.URL)
+				return !redeemed
+			},
+			Do:       func(s *MockState, req *RecordedRequest) { s.Set(req.URL, "redeemed") },
+			Sequence: []MockResponse{{Status: 200, Body: []byte("ok")}}},
+		{Request: get("/redeem"), Matcher: URLRegexpMatcher{URL: regexp.MustCompile(`^/redeem\?token=`)},
+			Sequence: []MockResponse{{Status: 409, Body: []byte("already redeemed")}}},
+		// recorded
+		{Request: get("/home"), Status: 200, Body: []byte("home")},
+	}
+	mock := NewMockServer(interactions, nil)
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	token := Extractable{Name: "token", AfterThis: "<i>", BeforeThis: "</i>", MaxLength: -1, MinLength: -1}
+	f := Flow{Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "POST", Body: "user=bob"}, KeysOutput: []Extracter{token}},
+		{Name: "redeem", Request: Request{URL: srv.URL + "/redeem?token={{.token}}", Method: "GET"}, KeysInput: []string{"token"}},
+		{Name: "home", Request: Request{URL: srv.URL + "/home", Method: "GET"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "t1", f.Values["token"])
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "t2", f.Values["token"])
+	err := f.Execute(nil)
+	assert.IsType(t, &StatusError{}, err)
+	assert.Equal(t, 429, f.Steps[0].Response.Raw.StatusCode)
+
+	mock.Reset()
+	f.Steps[1].Request.URL = srv.URL + "/redeem?token=fixed"
+	f.Steps[1].KeysInput = nil
+	assert.Nil(t, f.Execute(nil))
+	err = f.Execute(nil)
+	assert.IsType(t, &StatusError{}, err)
+	assert.Equal(t, "already redeemed", string(f.Steps[1].Response.Body))
+}
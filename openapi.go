@@ -0,0 +1,476 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPISpec is an OpenAPI 3 document the steps' requests and responses are
+// validated against: paths, methods, parameters, statuses and JSON bodies. The
+// schema keywords supported are type, nullable, enum, properties, required,
+// additionalProperties, items, allOf/anyOf/oneOf, the bounds and pattern;
+// formats aren't checked.
+type OpenAPISpec struct {
+	root     map[string]interface{}
+	prefixes []string
+}
+
+// ParseOpenAPI parses a JSON OpenAPI document, YAML ones must be converted first
+func ParseOpenAPI(data []byte) (*OpenAPISpec, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %s", err.Error())
+	}
+	if _, ok := root["paths"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document: no paths")
+	}
+	s := &OpenAPISpec{root: root}
+	// the paths are relative to the servers' URLs
+	servers, _ := root["servers"].([]interface{})
+	for _, server := range servers {
+		raw, _ := obj(server)["url"].(string)
+		if u, err := url.Parse(raw); err == nil {
+			s.prefixes = append(s.prefixes, strings.TrimSuffix(u.Path, "/"))
+		}
+	}
+	if len(s.prefixes) == 0 {
+		s.prefixes = []string{""}
+	}
+	return s, nil
+}
+
+// ContractError is the error returned when a step's request or response
+// violates the flow's OpenAPISpec
+type ContractError struct {
+	Step       int
+	StepName   string
+	Violations []string
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because it violates the OpenAPI spec: %s",
+		e.Step, e.StepName, strings.Join(e.Violations, "; "))
+}
+
+// obj returns v as a JSON object, nil if it isn't one
+func obj(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// resolve follows the $ref of the node, local ones only ("#/components/...")
+func (s *OpenAPISpec) resolve(node map[string]interface{}) map[string]interface{} {
+	for i := 0; node != nil && i < 32; i++ {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var cur interface{} = s.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.Replace(strings.Replace(part, "~1", "/", -1), "~0", "~", -1)
+			cur = obj(cur)[part]
+		}
+		node = obj(cur)
+	}
+	return node
+}
+
+// operation returns the operation and path parameters of the request, nil if the
+// spec has no such path. The path item is returned for its parameters.
+func (s *OpenAPISpec) operation(method, path string) (op, item map[string]interface{}, params map[string]string, found bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	best := -1
+	for tpl, v := range obj(s.root["paths"]) {
+		for _, prefix := range s.prefixes {
+			tplSegments := strings.Split(strings.Trim(prefix+tpl, "/"), "/")
+			if len(tplSegments) != len(segments) {
+				continue
+			}
+			literals, matched := 0, map[string]string{}
+			for i, t := range tplSegments {
+				switch {
+				case strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") && segments[i] != "":
+					matched[t[1:len(t)-1]], _ = url.PathUnescape(segments[i])
+				case t == segments[i]:
+					literals++
+				default:
+					literals = -1
+				}
+				if literals < 0 {
+					break
+				}
+			}
+			// literal segments win over templated ones, e.g. /users/me over /users/{id}
+			if literals > best {
+				best, item, params = literals, s.resolve(obj(v)), matched
+			}
+		}
+	}
+	if best < 0 {
+		return nil, nil, nil, false
+	}
+	return s.resolve(obj(item[strings.ToLower(method)])), item, params, true
+}
+
+// validateRequest returns the violations of the request
+func (s *OpenAPISpec) validateRequest(r Request) []string {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return []string{"invalid URL: " + err.Error()}
+	}
+	op, item, pathParams, found := s.operation(r.Method, u.Path)
+	if !found {
+		return []string{fmt.Sprintf("path %s isn't in the spec", u.Path)}
+	}
+	if op == nil {
+		return []string{fmt.Sprintf("method %s isn't allowed on %s", r.Method, u.Path)}
+	}
+
+	var violations []string
+	// the operation's parameters override the path item's
+	params := map[string]map[string]interface{}{}
+	for _, list := range []interface{}{item["parameters"], op["parameters"]} {
+		l, _ := list.([]interface{})
+		for _, p := range l {
+			p := s.resolve(obj(p))
+			name, _ := p["name"].(string)
+			in, _ := p["in"].(string)
+			params[in+" "+name] = p
+		}
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	query := u.Query()
+	for _, k := range keys {
+		p := params[k]
+		name, _ := p["name"].(string)
+		in, _ := p["in"].(string)
+		var values []string
+		switch in {
+		case "path":
+			if v, ok := pathParams[name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = query[name]
+		case "header":
+			values = r.Header.Values(name)
+		case "cookie":
+			if c, err := (&http.Request{Header: r.Header}).Cookie(name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+		if len(values) == 0 {
+			if required, _ := p["required"].(bool); required || in == "path" {
+				violations = append(violations, fmt.Sprintf("%s parameter %s is required", in, name))
+			}
+			continue
+		}
+		schema := s.resolve(obj(p["schema"]))
+		for _, v := range values {
+			violations = append(violations, s.validate(schema, s.coerce(schema, v),
+				fmt.Sprintf("%s parameter %s", in, name))...)
+		}
+	}
+
+	body := s.resolve(obj(op["requestBody"]))
+	if body == nil {
+		return violations
+	}
+	var raw []byte
+	switch b := r.Body.(type) {
+	case string:
+		raw = []byte(b)
+	case []byte:
+		raw = b
+	case url.Values:
+		raw = []byte(b.Encode())
+	}
+	if len(raw) == 0 {
+		if required, _ := body["required"].(bool); required {
+			violations = append(violations, "request body is required")
+		}
+		return violations
+	}
+	return append(violations, s.validateContent(obj(body["content"]), r.Header.Get("Content-Type"), raw,
+		"request body")...)
+}
+
+// validateResponse returns the violations of the response to the method and path
+func (s *OpenAPISpec) validateResponse(method, path string, resp *http.Response, body []byte, streamed bool) []string {
+	op, _, _, _ := s.operation(method, path)
+	if op == nil {
+		// already reported for the request
+		return nil
+	}
+	responses := obj(op["responses"])
+	code := strconv.Itoa(resp.StatusCode)
+	documented := s.resolve(obj(responses[code]))
+	if documented == nil {
+		documented = s.resolve(obj(responses[code[:1]+"XX"]))
+	}
+	if documented == nil {
+		documented = s.resolve(obj(responses["default"]))
+	}
+	if documented == nil {
+		return []string{fmt.Sprintf("status %d isn't documented", resp.StatusCode)}
+	}
+	if streamed {
+		return nil
+	}
+	content := obj(documented["content"])
+	if len(content) == 0 {
+		return nil
+	}
+	return s.validateContent(content, resp.Header.Get("Content-Type"), body, "response body")
+}
+
+// validateContent validates the body against the schema of its media type
+func (s *OpenAPISpec) validateContent(content map[string]interface{}, contentType string, body []byte,
+	what string) []string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var media map[string]interface{}
+	for _, mt := range []string{mediaType, strings.SplitN(mediaType, "/", 2)[0] + "/*", "*/*"} {
+		if m, ok := content[mt]; ok {
+			media = s.resolve(obj(m))
+			break
+		}
+	}
+	if media == nil {
+		if mediaType == "" && len(content) == 1 {
+			// undeclared Content-Type, use the only one documented
+			for mt, m := range content {
+				mediaType, media = mt, s.resolve(obj(m))
+			}
+		} else {
+			return []string{fmt.Sprintf("%s has undocumented Content-Type %s", what, contentType)}
+		}
+	}
+	schema := s.resolve(obj(media["schema"]))
+	if schema == nil || mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []string{fmt.Sprintf("%s isn't valid JSON: %s", what, err.Error())}
+	}
+	return s.validate(schema, v, what)
+}
+
+// schemaTypes returns the types of the schema, "null" included when nullable
+func schemaTypes(schema map[string]interface{}) []string {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	if nullable, _ := schema["nullable"].(bool); nullable && len(types) != 0 {
+		types = append(types, "null")
+	}
+	return types
+}
+
+// coerce converts the parameter's value to its schema's type
+func (s *OpenAPISpec) coerce(schema map[string]interface{}, v string) interface{} {
+	for _, t := range schemaTypes(schema) {
+		switch t {
+		case "integer", "number":
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return n
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		case "array":
+			var items []interface{}
+			for _, item := range strings.Split(v, ",") {
+				items = append(items, s.coerce(s.resolve(obj(schema["items"])), item))
+			}
+			return items
+		}
+	}
+	return v
+}
+
+// jsonType returns the JSON schema type of the decoded value
+func jsonType(v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// validate returns the violations of the decoded JSON value, at path
+func (s *OpenAPISpec) validate(schema map[string]interface{}, v interface{}, path string) []string {
+	if schema == nil {
+		return nil
+	}
+	var violations []string
+	violate := func(format string, a ...interface{}) {
+		violations = append(violations, path+": "+fmt.Sprintf(format, a...))
+	}
+
+	got := jsonType(v)
+	if types := schemaTypes(schema); len(types) != 0 {
+		ok := false
+		for _, t := range types {
+			ok = ok || t == got || t == "number" && got == "integer"
+		}
+		if !ok {
+			violate("expected %s, got %s", strings.Join(types, " or "), got)
+			return violations
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || fmt.Sprint(e) == fmt.Sprint(v) && jsonType(e) == got
+		}
+		if !found {
+			violate("%v isn't one of %v", v, enum)
+		}
+	}
+
+	for _, sub := range []string{"allOf", "anyOf", "oneOf"} {
+		list, ok := schema[sub].([]interface{})
+		if !ok {
+			continue
+		}
+		matching := 0
+		for _, alt := range list {
+			alt := s.resolve(obj(alt))
+			errs := s.validate(alt, v, path)
+			if sub == "allOf" {
+				violations = append(violations, errs...)
+			} else if len(errs) == 0 {
+				matching++
+			}
+		}
+		switch {
+		case sub == "anyOf" && matching == 0:
+			violate("matches none of anyOf")
+		case sub == "oneOf" && matching != 1:
+			violate("matches %d of oneOf instead of 1", matching)
+		}
+	}
+
+	number := func(k string) (float64, bool) {
+		n, ok := schema[k].(float64)
+		return n, ok
+	}
+	switch val := v.(type) {
+	case float64:
+		if min, ok := number("minimum"); ok && val < min {
+			violate("%v is less than %v", val, min)
+		}
+		if max, ok := number("maximum"); ok && val > max {
+			violate("%v is more than %v", val, max)
+		}
+	case string:
+		if min, ok := number("minLength"); ok && float64(len([]rune(val))) < min {
+			violate("shorter than %v", min)
+		}
+		if max, ok := number("maxLength"); ok && float64(len([]rune(val))) > max {
+			violate("longer than %v", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := compileRegexp(pattern); err == nil && !re.MatchString(val) {
+				violate("%q doesn't match %s", val, pattern)
+			}
+		}
+	case []interface{}:
+		if min, ok := number("minItems"); ok && float64(len(val)) < min {
+			violate("has fewer than %v items", min)
+		}
+		if max, ok := number("maxItems"); ok && float64(len(val)) > max {
+			violate("has more than %v items", max)
+		}
+		items := s.resolve(obj(schema["items"]))
+		for i, item := range val {
+			violations = append(violations, s.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case map[string]interface{}:
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if k, _ := r.(string); k != "" {
+				if _, ok := val[k]; !ok {
+					violate("missing required property %s", k)
+				}
+			}
+		}
+		props := obj(schema["properties"])
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := props[k]; ok {
+				violations = append(violations, s.validate(s.resolve(obj(prop)), val[k], path+"."+k)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					violate("unexpected property %s", k)
+				}
+			case map[string]interface{}:
+				violations = append(violations, s.validate(s.resolve(additional), val[k], path+"."+k)...)
+			}
+		}
+	}
+	return violations
+}
+
+// CheckOpenAPI returns a *ContractError if the step's request or its response
+// violates the spec
+func (s *Step) CheckOpenAPI(spec *OpenAPISpec, resp *http.Response, body []byte, stepNb int) error {
+	if spec == nil {
+		return nil
+	}
+	violations := spec.validateRequest(s.Request)
+	if len(violations) == 0 {
+		// the response is the final one's, after redirects
+		method, path := s.Request.Method, ""
+		if u, err := url.Parse(s.Request.URL); err == nil {
+			path = u.Path
+		}
+		if resp.Request != nil && resp.Request.URL != nil {
+			method, path = resp.Request.Method, resp.Request.URL.Path
+		}
+		violations = spec.validateResponse(method, path, resp, body, s.StreamBody)
+	}
+	if len(violations) != 0 {
+		return &ContractError{Step: stepNb, StepName: s.Name, Violations: violations}
+	}
+	return nil
+}
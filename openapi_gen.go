@@ -0,0 +1,192 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GenerateSteps builds skeleton Steps from the spec's operations, given by
+// operationId or as "METHOD /path": their URL is the baseURL (the spec's first
+// server when empty) and path, its parameters and the required query and header
+// ones being templated values, their body the documented example (or one built
+// from the schema), and their expected statuses the documented 2xx and 3xx.
+func (s *OpenAPISpec) GenerateSteps(baseURL string, operations ...string) ([]Step, error) {
+	if baseURL == "" {
+		servers, _ := s.root["servers"].([]interface{})
+		if len(servers) != 0 {
+			baseURL, _ = obj(servers[0])["url"].(string)
+		}
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var steps []Step
+	for _, name := range operations {
+		path, method, op := s.findOperation(name)
+		if op == nil {
+			return nil, fmt.Errorf("no operation '%s' in the OpenAPI spec", name)
+		}
+		step := Step{Name: name, Request: Request{Method: method, Header: http.Header{}}}
+		if id, ok := op["operationId"].(string); ok {
+			step.Name = id
+		}
+
+		item := s.resolve(obj(obj(s.root["paths"])[path]))
+		var query []string
+		seen := map[string]bool{}
+		for _, list := range []interface{}{op["parameters"], item["parameters"]} {
+			l, _ := list.([]interface{})
+			for _, p := range l {
+				p := s.resolve(obj(p))
+				pname, _ := p["name"].(string)
+				in, _ := p["in"].(string)
+				required, _ := p["required"].(bool)
+				// the operation's parameters override the path item's
+				if seen[in+" "+pname] || !required && in != "path" {
+					continue
+				}
+				seen[in+" "+pname] = true
+				placeholder := templatePlaceholder(pname)
+				switch in {
+				case "path":
+					path = strings.Replace(path, "{"+pname+"}", placeholder, -1)
+				case "query":
+					query = append(query, pname+"="+placeholder)
+				case "header":
+					step.Request.Header.Set(pname, placeholder)
+				default:
+					continue
+				}
+				step.KeysInput = append(step.KeysInput, pname)
+			}
+		}
+		sort.Strings(query)
+		step.Request.URL = baseURL + path
+		if len(query) != 0 {
+			step.Request.URL += "?" + strings.Join(query, "&")
+		}
+
+		if body := s.resolve(obj(op["requestBody"])); body != nil {
+			content := obj(body["content"])
+			if media := s.resolve(obj(content["application/json"])); media != nil {
+				example, ok := media["example"]
+				if !ok {
+					example = s.example(s.resolve(obj(media["schema"])), 0)
+				}
+				b, err := json.MarshalIndent(example, "", "  ")
+				if err != nil {
+					return nil, err
+				}
+				step.Request.Body = string(b)
+				step.Request.Header.Set("Content-Type", "application/json")
+			}
+		}
+
+		var statuses []string
+		for code := range obj(op["responses"]) {
+			if len(code) == 3 && (code[0] == '2' || code[0] == '3') {
+				statuses = append(statuses, strings.ToLower(code))
+			}
+		}
+		sort.Strings(statuses)
+		step.ExpectStatus = statuses
+		if len(step.Request.Header) == 0 {
+			step.Request.Header = nil
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// templatePlaceholder returns the template of the value named name
+func templatePlaceholder(name string) string {
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || i > 0 && unicode.IsDigit(r)) {
+			return fmt.Sprintf("{{index . %q}}", name)
+		}
+	}
+	return "{{." + name + "}}"
+}
+
+// findOperation returns the operation by operationId or "METHOD /path"
+func (s *OpenAPISpec) findOperation(name string) (path, method string, op map[string]interface{}) {
+	if parts := strings.SplitN(name, " ", 2); len(parts) == 2 {
+		item := s.resolve(obj(obj(s.root["paths"])[parts[1]]))
+		if op := s.resolve(obj(item[strings.ToLower(parts[0])])); op != nil {
+			return parts[1], strings.ToUpper(parts[0]), op
+		}
+	}
+	for path, item := range obj(s.root["paths"]) {
+		item := s.resolve(obj(item))
+		for method, op := range item {
+			op := s.resolve(obj(op))
+			if id, _ := op["operationId"].(string); id != "" && id == name {
+				return path, strings.ToUpper(method), op
+			}
+		}
+	}
+	return "", "", nil
+}
+
+// example returns an example of the schema: its example or default, or one built
+// from its type with the required properties only
+func (s *OpenAPISpec) example(schema map[string]interface{}, depth int) interface{} {
+	if schema == nil || depth > 8 {
+		return nil
+	}
+	for _, k := range []string{"example", "default"} {
+		if v, ok := schema[k]; ok {
+			return v
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) != 0 {
+		return enum[0]
+	}
+	for _, k := range []string{"allOf", "oneOf", "anyOf"} {
+		if list, ok := schema[k].([]interface{}); ok && len(list) != 0 {
+			if k != "allOf" {
+				return s.example(s.resolve(obj(list[0])), depth+1)
+			}
+			merged := map[string]interface{}{}
+			for _, sub := range list {
+				if m, ok := s.example(s.resolve(obj(sub)), depth+1).(map[string]interface{}); ok {
+					for k, v := range m {
+						merged[k] = v
+					}
+				}
+			}
+			return merged
+		}
+	}
+	types := schemaTypes(schema)
+	if len(types) == 0 && schema["properties"] != nil {
+		types = []string{"object"}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+	switch types[0] {
+	case "string":
+		return ""
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "array":
+		return []interface{}{}
+	case "object":
+		v := map[string]interface{}{}
+		props := obj(schema["properties"])
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if k, _ := r.(string); k != "" {
+				v[k] = s.example(s.resolve(obj(props[k])), depth+1)
+			}
+		}
+		return v
+	}
+	return nil
+}
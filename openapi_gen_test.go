@@ -0,0 +1,77 @@
+package httpsim
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpec_GenerateSteps(t *testing.T) {
+	spec, err := ParseOpenAPI([]byte(`{
+  "servers": [{"url": "https://api.example.com/v1"}],
+  "paths": {
+    "/users/{id}/items": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "get": {
+        "operationId": "listItems",
+        "parameters": [
+          {"name": "page", "in": "query", "schema": {"type": "integer"}},
+          {"name": "sort", "in": "query", "required": true, "schema": {"type": "string"}},
+          {"name": "X-Tenant", "in": "header", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {"200": {"description": "ok"}, "304": {"description": "not modified"}, "404": {"description": "no"}}
+      },
+      "post": {
+        "requestBody": {"content": {"application/json": {"schema": {
+          "type": "object",
+          "required": ["name", "qty", "meta"],
+          "properties": {
+            "name": {"type": "string", "example": "pen"},
+            "qty": {"type": "integer"},
+            "meta": {"type": "object", "required": ["tag"], "properties": {"tag": {"enum": ["a", "b"]}}},
+            "note": {"type": "string"}
+          }
+        }}}},
+        "responses": {"2XX": {"description": "created"}}
+      }
+    }
+  }
+}`))
+	assert.Nil(t, err)
+
+	steps, err := spec.GenerateSteps("", "listItems", "post /users/{id}/items")
+	assert.Nil(t, err)
+	assert.Len(t, steps, 2)
+
+	assert.Equal(t, Step{
+		Name: "listItems",
+		Request: Request{
+			Method: "GET",
+			URL:    "https://api.example.com/v1/users/{{.id}}/items?sort={{.sort}}",
+			Header: http.Header{"X-Tenant": {`{{index . "X-Tenant"}}`}},
+		},
+		KeysInput:    []string{"sort", "X-Tenant", "id"},
+		ExpectStatus: []string{"200", "304"},
+	}, steps[0])
+
+	assert.Equal(t, "post /users/{id}/items", steps[1].Name)
+	assert.Equal(t, "POST", steps[1].Request.Method)
+	assert.Equal(t, "https://api.example.com/v1/users/{{.id}}/items", steps[1].Request.URL)
+	assert.Equal(t, "application/json", steps[1].Request.Header.Get("Content-Type"))
+	assert.Equal(t, `{
+  "meta": {
+    "tag": "a"
+  },
+  "name": "pen",
+  "qty": 0
+}`, steps[1].Request.Body)
+	assert.Equal(t, []string{"2xx"}, steps[1].ExpectStatus)
+
+	steps, err = spec.GenerateSteps("http://localhost:8080/", "listItems")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:8080/users/{{.id}}/items?sort={{.sort}}", steps[0].Request.URL)
+
+	_, err = spec.GenerateSteps("", "nope")
+	assert.EqualError(t, err, "no operation 'nope' in the OpenAPI spec")
+}
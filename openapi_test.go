@@ -0,0 +1,117 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "servers": [{"url": "https://api.example.com/v1"}],
+  "paths": {
+    "/users/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+      "get": {
+        "parameters": [{"name": "fields", "in": "query", "schema": {"type": "string", "enum": ["all", "short"]}}],
+        "responses": {
+          "200": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+          "404": {"description": "not found"}
+        }
+      }
+    },
+    "/users/me": {
+      "get": {"responses": {"2XX": {"description": "ok"}}}
+    },
+    "/users": {
+      "post": {
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}},
+        "responses": {"default": {"description": "any"}}
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "User": {
+        "type": "object",
+        "required": ["name"],
+        "additionalProperties": false,
+        "properties": {
+          "id": {"type": "integer", "minimum": 1},
+          "name": {"type": "string", "minLength": 1},
+          "email": {"type": "string", "nullable": true, "pattern": "@"},
+          "tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+          "role": {"oneOf": [{"type": "string", "enum": ["admin"]}, {"type": "integer"}]}
+        }
+      }
+    }
+  }
+}`
+
+func TestOpenAPISpec_ValidateRequest(t *testing.T) {
+	spec, err := ParseOpenAPI([]byte(testSpec))
+	assert.Nil(t, err)
+	_, err = ParseOpenAPI([]byte(`{}`))
+	assert.NotNil(t, err)
+
+	base := "https://api.example.com/v1"
+	assert.Nil(t, spec.validateRequest(Request{Method: "GET", URL: base + "/users/12?fields=all"}))
+	assert.Nil(t, spec.validateRequest(Request{Method: "GET", URL: base + "/users/me"}))
+	assert.Equal(t, []string{"path /v1/nope isn't in the spec"},
+		spec.validateRequest(Request{Method: "GET", URL: base + "/nope"}))
+	assert.Equal(t, []string{"method DELETE isn't allowed on /v1/users/12"},
+		spec.validateRequest(Request{Method: "DELETE", URL: base + "/users/12"}))
+	assert.Equal(t, []string{
+		"path parameter id: expected integer, got string",
+		"query parameter fields: x isn't one of [all short]",
+	}, spec.validateRequest(Request{Method: "GET", URL: base + "/users/bob?fields=x"}))
+
+	assert.Equal(t, []string{"request body is required"},
+		spec.validateRequest(Request{Method: "POST", URL: base + "/users"}))
+	assert.Nil(t, spec.validateRequest(Request{Method: "POST", URL: base + "/users",
+		Header: http.Header{"Content-Type": {"application/json"}}, Body: `{"name": "bob", "email": null}`}))
+	assert.Equal(t, []string{
+		"request body: missing required property name",
+		"request body.email: \"bob\" doesn't match @",
+		"request body.id: 0 is less than 1",
+		"request body.role: matches 0 of oneOf instead of 1",
+		"request body.tags: has more than 2 items",
+		"request body.tags[1]: expected string, got integer",
+		"request body: unexpected property x",
+	}, spec.validateRequest(Request{Method: "POST", URL: base + "/users",
+		Body: `{"id": 0, "email": "bob", "tags": ["a", 1, "c"], "role": "user", "x": 1}`}))
+	assert.Equal(t, []string{"request body has undocumented Content-Type text/plain"},
+		spec.validateRequest(Request{Method: "POST", URL: base + "/users",
+			Header: http.Header{"Content-Type": {"text/plain"}}, Body: "bob"}))
+}
+
+func TestFlow_OpenAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/users/1":
+			w.Write([]byte(`{"id": 1, "name": "bob"}`))
+		case "/v1/users/2":
+			w.Write([]byte(`{"id": "2"}`))
+		default:
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+	defer srv.Close()
+	spec, err := ParseOpenAPI([]byte(testSpec))
+	assert.Nil(t, err)
+
+	run := func(path string) error {
+		f := Flow{OpenAPI: spec, Steps: []Step{{Name: "user", AnyStatus: true,
+			Request: Request{Method: "GET", URL: srv.URL + path}}}}
+		return f.Execute(nil)
+	}
+	assert.Nil(t, run("/v1/users/1"))
+	assert.EqualError(t, run("/v1/users/2"), "Step 0.'user' failed because it violates the OpenAPI spec: "+
+		"response body: missing required property name; response body.id: expected integer, got string")
+	err = run("/v1/users/3")
+	assert.IsType(t, &ContractError{}, err)
+	assert.Equal(t, []string{"status 418 isn't documented"}, err.(*ContractError).Violations)
+}
@@ -0,0 +1,79 @@
+package httpsim
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// StepError is the error of a step that panicked (in a PostHook, an Extracter...)
+type StepError struct {
+	Step     int
+	StepName string
+	Err      error
+	// Stack is the stack trace of the goroutine that panicked
+	Stack []byte
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because %s", e.Step, e.StepName, e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// panicError is a recovered panic, with the stack of the goroutine that panicked
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
+
+// recovered returns the recovered panic value as a panicError, nil if there's none
+func recovered(value interface{}) *panicError {
+	if value == nil {
+		return nil
+	}
+	return &panicError{value: value, stack: debug.Stack()}
+}
+
+// runStep executes the step, turning panics, including the ones of the step's
+// goroutines, into a *StepError, and reports its progress to OnStep
+func (f *Flow) runStep(run *runState, i int, def *Step) (err error) {
+	start := time.Now()
+	if f.OnStep != nil {
+		f.OnStep(StepEvent{Step: i, StepName: def.Name})
+	}
+	var before map[string]interface{}
+	if f.RecordValues {
+		before = f.redactedValues()
+	}
+	defer func() {
+		if p := recovered(recover()); p != nil {
+			err = p
+		}
+		var p *panicError
+		if errors.As(err, &p) {
+			err = &StepError{Step: i, StepName: def.Name, Err: p, Stack: p.stack}
+		}
+		err = budgetError(err)
+		if err != nil {
+			f.failed(run, i, def, err)
+		}
+		if f.RecordValues {
+			f.StepResults = append(f.StepResults, StepResult{Step: i, StepName: def.Name,
+				ValuesBefore: before, ValuesAfter: f.redactedValues(), Err: err})
+		}
+		f.record(run, i, def, start, err)
+		if f.OnStep != nil {
+			f.OnStep(StepEvent{Step: i, StepName: def.Name, Done: true, Err: err, Duration: time.Since(start)})
+		}
+	}()
+	return f.timeoutStep(run, i, def)
+}
@@ -0,0 +1,64 @@
+package httpsim
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecutePanics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>ok</b>"))
+	}))
+	defer srv.Close()
+
+	boom := ExtracterFunc(func(string, map[string]interface{}) (string, string, error) {
+		var m map[string]string
+		m["x"] = "y"
+		return "x", "y", nil
+	})
+	ok := Extractable{Name: "ok", AfterThis: "<b>", BeforeThis: "<", MaxLength: -1, MinLength: -1}
+	tests := []struct {
+		name string
+		step Step
+		msg  string
+	}{
+		{"posthook", Step{PostHook: func(int, http.Header, []byte) error { panic("hook failed") }}, "panic: hook failed"},
+		{"extracter", Step{KeysOutput: []Extracter{ok, boom}}, "panic: assignment to entry in nil map"},
+		{"parallel", Step{KeysOutput: []Extracter{ok, boom, ok}, ParallelExtract: true}, "panic: assignment to entry in nil map"},
+		{"stream", Step{StreamBody: true, KeysOutput: []Extracter{
+			StreamExtractable{Name: "ok", AfterThis: "<b>", BeforeThis: "<"}, panicStream{},
+		}}, "panic: stream failed"},
+	}
+	for _, tt := range tests {
+		tt.step.Name = tt.name
+		tt.step.Request = Request{URL: srv.URL, Method: "GET"}
+		f := Flow{Steps: []Step{tt.step}}
+		var err error
+		assert.NotPanics(t, func() { err = f.Execute(nil) }, tt.name)
+
+		var se *StepError
+		assert.True(t, errors.As(err, &se), tt.name)
+		if se == nil {
+			continue
+		}
+		assert.Equal(t, "Step 0.'"+tt.name+"' failed because "+tt.msg, err.Error())
+		assert.Equal(t, tt.name, se.StepName)
+		assert.True(t, strings.Contains(string(se.Stack), "panic"), tt.name)
+	}
+}
+
+type panicStream struct{}
+
+func (panicStream) Extract(string, map[string]interface{}) (string, string, error) {
+	panic("stream failed")
+}
+
+func (panicStream) ExtractStream(io.Reader, map[string]interface{}) (string, string, error) {
+	panic("stream failed")
+}
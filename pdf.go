@@ -0,0 +1,268 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PDFTextExtractable extracts a value out of the text of a PDF response. The embedded
+// Extractable is run on the text, unless Regexp is set in which case its first
+// submatch (or the whole match) is the value. The inflated streams count towards
+// the step's MaxBodySize and the flow's MaxDecompressionRatio.
+type PDFTextExtractable struct {
+	Extractable
+	// Regexp searches the text instead of AfterThis/BeforeThis
+	Regexp string
+}
+
+// Extract extracts the value out of the PDF given as a string
+func (e PDFTextExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	return e.ExtractBinary([]byte(body), "application/pdf", v)
+}
+
+func (e PDFTextExtractable) withDelims(d Delims) Extracter {
+	e.Extractable.delims = d
+	return e
+}
+
+// ExtractBinary extracts the value out of the PDF
+func (e PDFTextExtractable) ExtractBinary(body []byte, contentType string, v map[string]interface{}) (string, string, error) {
+	return e.extractPDF(body, v, &decompressionLimit{left: -1})
+}
+
+// extractPDF extracts the value out of the PDF, inflating its streams up to
+// the limit
+func (e PDFTextExtractable) extractPDF(body []byte, v map[string]interface{}, limit *decompressionLimit) (string, string, error) {
+	text, err := pdfText(body, limit)
+	if err != nil {
+		return e.Name, "", err
+	}
+	if e.Regexp == "" {
+		return e.Extractable.Extract(text, v)
+	}
+	re, err := compileRegexp(e.Regexp)
+	if err != nil {
+		return e.Name, "", err
+	}
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		if e.IgnoreNotFound {
+			return e.Name, "", nil
+		}
+		return e.Name, "", errors.New("not found")
+	}
+	if len(match) > 1 {
+		return e.Name, match[1], nil
+	}
+	return e.Name, match[0], nil
+}
+
+var (
+	pdfStream     = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfOtherCodec = regexp.MustCompile(`/(DCTDecode|JPXDecode|CCITTFaxDecode|JBIG2Decode|LZWDecode|ASCII85Decode|ASCIIHexDecode|RunLengthDecode)`)
+)
+
+// PDFText returns the text shown by the content streams of a PDF, one text line per
+// line. It's best-effort: uncompressed and FlateDecode streams are supported but
+// fonts with custom encodings (e.g. Identity-H) won't give readable text.
+func PDFText(data []byte) (string, error) {
+	return pdfText(data, &decompressionLimit{left: -1})
+}
+
+// pdfText is PDFText, inflating the streams up to the limit
+func pdfText(data []byte, limit *decompressionLimit) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\r\n\t "), []byte("%PDF")) {
+		return "", errors.New("not a PDF")
+	}
+	var out strings.Builder
+	for _, loc := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end == -1 {
+			break
+		}
+		content := data[start : start+end]
+		if pdfOtherCodec.Match(dict) || bytes.Contains(dict, []byte("/Subtype/Image")) ||
+			bytes.Contains(dict, []byte("/Subtype /Image")) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// truncated streams still give what could be inflated
+			if content, err = limit.read(r); err != nil {
+				if _, ok := err.(*decompressionLimitError); ok {
+					return "", err
+				}
+			}
+		}
+		pdfContentText(content, &out)
+	}
+	var lines []string
+	for _, l := range strings.Split(out.String(), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// pdfContentText writes the text shown by the Tj, TJ, ' and " operators of the content stream
+func pdfContentText(c []byte, out *strings.Builder) {
+	var (
+		operands []string
+		inArray  bool
+	)
+	for i := 0; i < len(c); {
+		ch := c[i]
+		switch {
+		case ch == '(':
+			s, n := pdfLiteral(c[i:])
+			operands = append(operands, s)
+			i += n
+		case ch == '<' && i+1 < len(c) && c[i+1] == '<', ch == '>' && i+1 < len(c) && c[i+1] == '>':
+			i += 2
+		case ch == '<':
+			end := bytes.IndexByte(c[i:], '>')
+			if end == -1 {
+				return
+			}
+			operands = append(operands, pdfHex(c[i+1:i+end]))
+			i += end + 1
+		case ch == '[':
+			inArray = true
+			i++
+		case ch == ']':
+			inArray = false
+			i++
+		case ch == '%':
+			for i < len(c) && c[i] != '\n' && c[i] != '\r' {
+				i++
+			}
+		case ch == '/':
+			i++
+			for i < len(c) && !pdfDelimiter(c[i]) {
+				i++
+			}
+		case pdfDelimiter(ch):
+			i++
+		default:
+			start := i
+			for i < len(c) && !pdfDelimiter(c[i]) {
+				i++
+			}
+			word := string(c[start:i])
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				// big negative kerning in TJ arrays separates words
+				if inArray && n <= -200 {
+					operands = append(operands, " ")
+				}
+				continue
+			}
+			switch word {
+			case "Tj", "TJ":
+				out.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				out.WriteString("\n" + strings.Join(operands, ""))
+			case "T*", "Td", "TD", "ET":
+				out.WriteString("\n")
+			}
+			operands = nil
+		}
+	}
+}
+
+func pdfDelimiter(ch byte) bool {
+	switch ch {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%', ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+// pdfLiteral decodes the literal string starting at c[0] == '(' and returns
+// it with the number of bytes consumed
+func pdfLiteral(c []byte) (string, int) {
+	var (
+		b     strings.Builder
+		depth int
+	)
+	for i := 0; i < len(c); i++ {
+		switch ch := c[i]; ch {
+		case '(':
+			if depth > 0 {
+				b.WriteByte(ch)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(ch)
+		case '\\':
+			i++
+			if i >= len(c) {
+				break
+			}
+			switch e := c[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case '\r', '\n':
+				// line continuation
+				if e == '\r' && i+1 < len(c) && c[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					oct := 0
+					for n := 0; n < 3 && i < len(c) && c[i] >= '0' && c[i] <= '7'; n++ {
+						oct = oct*8 + int(c[i]-'0')
+						i++
+					}
+					i--
+					b.WriteByte(byte(oct))
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String(), len(c)
+}
+
+// pdfHex decodes the content of a hex string
+func pdfHex(c []byte) string {
+	var digits []byte
+	for _, ch := range c {
+		if (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F') {
+			digits = append(digits, ch)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return string(out)
+}
@@ -0,0 +1,103 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPDF(compress bool, content string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	stream := []byte(content)
+	filter := ""
+	if compress {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write(stream)
+		w.Close()
+		stream = z.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+	fmt.Fprintf(&buf, "4 0 obj\n<< /Length %d%s >>\nstream\n", len(stream), filter)
+	buf.Write(stream)
+	buf.WriteString("\nendstream\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestPDFText(t *testing.T) {
+	content := `BT /F1 12 Tf 72 712 Td (Statement of account) Tj ET
+BT 72 690 Td [(Bal)-20(ance:)-300(1,234.56 EUR)] TJ ET
+BT 72 670 Td <4942414E3A2044453839> Tj ET
+BT (Escaped \(parens\) and \101) Tj ET`
+	for _, compress := range []bool{false, true} {
+		text, err := PDFText(testPDF(compress, content))
+		assert.Nil(t, err)
+		assert.Equal(t, "Statement of account\nBalance: 1,234.56 EUR\nIBAN: DE89\nEscaped (parens) and A", text)
+	}
+
+	_, err := PDFText([]byte("<html>"))
+	assert.EqualError(t, err, "not a PDF")
+}
+
+func TestPDFTextExtractable_ExtractBinary(t *testing.T) {
+	pdf := testPDF(true, `BT 72 690 Td [(Balance:)-300(1,234.56 EUR)] TJ ET`)
+
+	ex := PDFTextExtractable{Extractable: Extractable{
+		AfterThis: "Balance: ", BeforeThis: " EUR", Name: "balance", MaxLength: -1, MinLength: -1,
+	}}
+	name, value, err := ex.ExtractBinary(pdf, "application/pdf", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "balance", name)
+	assert.Equal(t, "1,234.56", value)
+
+	ex = PDFTextExtractable{Extractable: Extractable{Name: "balance"}, Regexp: `([0-9,.]+) EUR`}
+	_, value, err = ex.ExtractBinary(pdf, "application/pdf", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,234.56", value)
+
+	// the first submatch, or the whole match
+	ex.Regexp = `([0-9,]+)\.([0-9]+) (EUR)`
+	_, value, err = ex.ExtractBinary(pdf, "application/pdf", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,234", value)
+	ex.Regexp = `[0-9,.]+ EUR`
+	_, value, err = ex.ExtractBinary(pdf, "application/pdf", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,234.56 EUR", value)
+}
+
+func TestFlow_ExecutePDFLimits(t *testing.T) {
+	pdf := testPDF(true, `BT [(Balance: 10.00 EUR)] TJ ET `+strings.Repeat("% padding\n", 1<<16))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(pdf)
+	}))
+	defer srv.Close()
+
+	f := Flow{MaxDecompressionRatio: 10, Steps: []Step{{
+		Name:    "statement",
+		Request: Request{URL: srv.URL, Method: "GET"},
+		KeysOutput: []Extracter{PDFTextExtractable{Extractable: Extractable{Name: "balance"},
+			Regexp: `Balance: ([0-9.]+) EUR`}},
+	}}}
+	err := f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "decompression ratio exceeds 10")
+
+	f.MaxDecompressionRatio = 0
+	f.MaxBodySize = 1 << 16
+	err = f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "decompressed size exceeds 65536 bytes")
+
+	f.MaxBodySize = 0
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "10.00", f.Values["balance"])
+}
@@ -0,0 +1,174 @@
+package httpsim
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Plan returns what executing the flow with the values would do, for review
+// before running it e.g. against production: its steps in order, their rendered
+// URLs, the values they need and produce, and the assertions made on their
+// responses. Placeholders that can't be rendered yet are shown between brackets:
+// [name: known after step N] or [name: missing]; sensitive values are [name: sensitive].
+func (f *Flow) Plan(values map[string]interface{}) (string, error) {
+	d := f.Describe()
+	sensitive := map[string]bool{}
+	for _, k := range f.SensitiveValues {
+		sensitive[k] = true
+	}
+	// producedBy is the step producing each value not given
+	producedBy := map[string]int{}
+	missing := map[string]bool{}
+
+	var b strings.Builder
+	plural := func(n int, what string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, what)
+		}
+		return fmt.Sprintf("%d %ss", n, what)
+	}
+	b.WriteString("Plan: " + plural(len(f.Steps), "step"))
+	if len(f.Teardown) != 0 {
+		b.WriteString(", " + plural(len(f.Teardown), "teardown step"))
+	}
+	b.WriteString("\n")
+
+	plan := func(i int, step Step, sd StepDescription) error {
+		delims := f.delims(&step)
+		fields, err := templateFields(delims, step.Request.URL)
+		if err != nil {
+			return fmt.Errorf("Step %d.'%s' failed because the URL isn't a valid template: %s", i, step.Name, err.Error())
+		}
+		vals := map[string]interface{}{}
+		var needs []string
+		for _, k := range append(append([]string(nil), step.KeysInput...), fields...) {
+			if _, ok := vals[k]; ok {
+				continue
+			}
+			v, given := values[k]
+			step, produced := producedBy[k]
+			switch {
+			case given && sensitive[k]:
+				vals[k] = "[" + k + ": sensitive]"
+			case given:
+				vals[k] = v
+			case produced:
+				vals[k] = fmt.Sprintf("[%s: known after step %d]", k, step)
+			default:
+				vals[k] = "[" + k + ": missing]"
+				missing[k] = true
+			}
+			needs = append(needs, k)
+		}
+		var url bytes.Buffer
+		tpl, _ := delims.parse(step.Request.URL)
+		if err := tpl.Execute(&url, vals); err != nil {
+			return fmt.Errorf("Step %d.'%s' failed because the URL couldn't be rendered: %s", i, step.Name, err.Error())
+		}
+
+		fmt.Fprintf(&b, "\n  %d. %s\n", i, step.Name)
+		fmt.Fprintf(&b, "     %s %s\n", sd.Method, url.String())
+		if len(needs) != 0 {
+			fmt.Fprintf(&b, "     needs:    %s\n", strings.Join(needs, ", "))
+		}
+		if len(sd.Outputs) != 0 {
+			var outputs []string
+			for _, o := range sd.Outputs {
+				outputs = append(outputs, o.Name)
+				if _, ok := producedBy[o.Name]; !ok {
+					producedBy[o.Name] = i
+				}
+			}
+			fmt.Fprintf(&b, "     produces: %s\n", strings.Join(outputs, ", "))
+		}
+		for _, a := range sd.Assertions {
+			fmt.Fprintf(&b, "     asserts:  %s\n", a)
+		}
+		return nil
+	}
+	for i, step := range f.Steps {
+		if err := plan(i, step, d.Steps[i]); err != nil {
+			return "", err
+		}
+	}
+	if len(f.Teardown) != 0 {
+		b.WriteString("\nTeardown:\n")
+		for i, step := range f.Teardown {
+			if err := plan(len(f.Steps)+i, step, d.Teardown[i]); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	for _, k := range f.RequiredValues {
+		if _, ok := values[k]; !ok {
+			missing[k] = true
+		}
+	}
+	if len(missing) != 0 {
+		var names []string
+		for k := range missing {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "\nMissing values: %s\n", strings.Join(names, ", "))
+	}
+	return b.String(), nil
+}
+
+// templateFields returns the names of the values the template uses, in order
+func templateFields(delims Delims, text string) ([]string, error) {
+	tpl, err := delims.parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	seen := map[string]bool{}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, c := range n.Nodes {
+					walk(c)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n != nil {
+				for _, c := range n.Cmds {
+					walk(c)
+				}
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.FieldNode:
+			if k := n.Ident[0]; !seen[k] {
+				seen[k] = true
+				fields = append(fields, k)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	if tpl.Tree != nil {
+		walk(tpl.Tree.Root)
+	}
+	return fields, nil
+}
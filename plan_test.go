@@ -0,0 +1,66 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Plan(t *testing.T) {
+	f := Flow{
+		RequiredValues:  []string{"user", "token", "since"},
+		SensitiveValues: []string{"token"},
+		Steps: []Step{
+			{
+				Name:      "login",
+				Request:   Request{URL: "https://bank.example.com/login?u={{.user}}&t={{.token}}", Method: "POST"},
+				KeysInput: []string{"user", "token"},
+				KeysOutput: []Extracter{
+					Extractable{Name: "session"},
+				},
+			},
+			{
+				Name:      "export",
+				Request:   Request{URL: "https://bank.example.com/{{.session}}/export{{if .since}}?since={{.since}}{{end}}", Method: "GET"},
+				KeysInput: []string{"session"},
+				AnyStatus: true,
+			},
+		},
+		Teardown: []Step{
+			{Name: "logout", Request: Request{URL: "https://bank.example.com/logout", Method: "POST"}},
+		},
+	}
+	plan, err := f.Plan(map[string]interface{}{"user": "bob", "token": "secret"})
+	assert.Nil(t, err)
+	assert.Equal(t, `Plan: 2 steps, 1 teardown step
+
+  0. login
+     POST https://bank.example.com/login?u=bob&t=[token: sensitive]
+     needs:    user, token
+     produces: session
+     asserts:  status is 2xx
+
+  1. export
+     GET https://bank.example.com/[session: known after step 0]/export?since=[since: missing]
+     needs:    session, since
+
+Teardown:
+
+  2. logout
+     POST https://bank.example.com/logout
+     asserts:  status is 2xx
+
+Missing values: since
+`, plan)
+	assert.NotContains(t, plan, "secret")
+
+	f.Steps[0].Request.URL = "{{.user"
+	_, err = f.Plan(nil)
+	assert.NotNil(t, err)
+}
+
+func TestTemplateFields(t *testing.T) {
+	fields, err := templateFields(Delims{}, "{{.a}}/{{range .b}}{{.c.d}}{{end}}/{{with .e}}x{{else}}{{.a}}{{end}}")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "e"}, fields)
+}
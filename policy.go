@@ -0,0 +1,136 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Policy are guardrails against template bugs sending destructive requests to
+// the wrong place: the steps' requests are checked once templated, before
+// they're sent, and a *PolicyViolationError is returned when one breaks a rule.
+type Policy struct {
+	// AllowHosts, if not empty, are the only hosts (and their subdomains)
+	// requests are sent to
+	AllowHosts []string
+	// AllowURLs, if not empty, are regexps one of which the URLs must match
+	AllowURLs []string
+	// DenyURLs are regexps the URLs must not match
+	DenyURLs []string
+	// DenyMethods are the methods never sent, e.g. "DELETE"
+	DenyMethods []string
+	// Rules deny methods to some hosts, e.g. no POST to production
+	Rules []PolicyRule
+}
+
+// PolicyRule denies the Methods, all of them when empty, to the Hosts and their
+// subdomains
+type PolicyRule struct {
+	Hosts   []string
+	Methods []string
+}
+
+// PolicyViolationError is the error returned when a step's request breaks the
+// flow's Policy. It isn't sent.
+type PolicyViolationError struct {
+	Step     int
+	StepName string
+	Method   string
+	URL      string
+	// Rule is the rule broken
+	Rule string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because %s %s violates the policy: %s",
+		e.Step, e.StepName, e.Method, e.URL, e.Rule)
+}
+
+// methodIn returns whether the method is one of the methods, all of them when
+// empty and all is set
+func methodIn(method string, methods []string, all bool) bool {
+	if len(methods) == 0 {
+		return all
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile checks and precompiles the policy's regexps
+func (p *Policy) compile() error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string(nil), p.AllowURLs...), p.DenyURLs...) {
+		if _, err := compileRegexp(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// violation returns the rule the request breaks, "" when none
+func (p *Policy) violation(method, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	host := u.Hostname()
+	if len(p.AllowHosts) != 0 && !hostMatches(host, p.AllowHosts) {
+		return fmt.Sprintf("host %s not allowed", host), nil
+	}
+	if methodIn(method, p.DenyMethods, false) {
+		return fmt.Sprintf("method %s denied", method), nil
+	}
+	for _, rule := range p.Rules {
+		if hostMatches(host, rule.Hosts) && methodIn(method, rule.Methods, true) {
+			return fmt.Sprintf("method %s denied to %s", method, host), nil
+		}
+	}
+	allowed := len(p.AllowURLs) == 0
+	for _, pattern := range p.AllowURLs {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return "", err
+		}
+		allowed = allowed || re.MatchString(rawURL)
+	}
+	if !allowed {
+		return "URL not allowed", nil
+	}
+	for _, pattern := range p.DenyURLs {
+		re, err := compileRegexp(pattern)
+		if err != nil {
+			return "", err
+		}
+		if re.MatchString(rawURL) {
+			return fmt.Sprintf("URL denied by %s", pattern), nil
+		}
+	}
+	return "", nil
+}
+
+// checkPolicy checks the step's templated request against the flow's ReadOnly
+// and Policy
+func (f *Flow) checkPolicy(i int, step *Step) error {
+	if f.ReadOnly && !step.AllowWrite && !safeMethod(step.Request.Method) {
+		return &PolicyViolationError{Step: i, StepName: step.Name, Method: step.Request.Method,
+			URL: f.redact(step.Request.URL), Rule: "the flow is read-only"}
+	}
+	if f.Policy == nil {
+		return nil
+	}
+	rule, err := f.Policy.violation(step.Request.Method, step.Request.URL)
+	if err != nil {
+		return fmt.Errorf("Step %d.'%s' failed because the policy couldn't be checked: %s", i, step.Name, err.Error())
+	}
+	if rule != "" {
+		return &PolicyViolationError{Step: i, StepName: step.Name, Method: step.Request.Method,
+			URL: f.redact(step.Request.URL), Rule: rule}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Policy(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		RequiredValues: []string{"id"},
+		Steps: []Step{
+			{Name: "list", Request: Request{URL: srv.URL + "/items", Method: "GET"}},
+			{Name: "delete", Request: Request{URL: srv.URL + "/items/{{.id}}", Method: "DELETE"}, KeysInput: []string{"id"}},
+		},
+		Policy: &Policy{AllowHosts: []string{"127.0.0.1"}, DenyURLs: []string{`/items/$`}},
+	}
+	assert.Nil(t, f.Compile())
+	assert.Nil(t, f.Execute(map[string]interface{}{"id": "1"}))
+
+	// e.g. an empty id
+	f.Steps[1].Request.URL = srv.URL + "/items/"
+	f.Steps[1].KeysInput = nil
+	err := f.Execute(map[string]interface{}{"id": "1"})
+	assert.Equal(t, &PolicyViolationError{Step: 1, StepName: "delete", Method: "DELETE", URL: srv.URL + "/items/",
+		Rule: "URL denied by /items/$"}, err)
+	assert.EqualError(t, err, "Step 1.'delete' failed because DELETE "+srv.URL+"/items/ violates the policy: URL denied by /items/$")
+
+	f.Policy = &Policy{DenyMethods: []string{"delete"}}
+	assert.Contains(t, f.Execute(map[string]interface{}{"id": "1"}).Error(), "method DELETE denied")
+	f.Policy = &Policy{Rules: []PolicyRule{{Hosts: []string{"127.0.0.1"}, Methods: []string{"POST", "DELETE"}}}}
+	assert.Contains(t, f.Execute(map[string]interface{}{"id": "1"}).Error(), "method DELETE denied to 127.0.0.1")
+	f.Policy = &Policy{AllowHosts: []string{"staging.example.com"}}
+	assert.Contains(t, f.Execute(map[string]interface{}{"id": "1"}).Error(), "Step 0.'list' failed because GET")
+	f.Policy = &Policy{AllowURLs: []string{`/items$`}}
+	assert.Contains(t, f.Execute(map[string]interface{}{"id": "1"}).Error(), "URL not allowed")
+
+	// no violating request was sent
+	assert.Equal(t, []string{"GET /items", "DELETE /items/1", "GET /items", "GET /items", "GET /items", "GET /items"}, sent)
+
+	f.Policy = &Policy{DenyURLs: []string{"("}}
+	assert.NotNil(t, f.Compile())
+}
+
+func TestFlow_ReadOnly(t *testing.T) {
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		ReadOnly: true,
+		Steps: []Step{
+			{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "POST", Body: "user=bob"}, AllowWrite: true},
+			{Name: "home", Request: Request{URL: srv.URL + "/home", Method: "GET"}},
+			{Name: "order", Request: Request{URL: srv.URL + "/orders", Method: "put"}},
+		},
+	}
+	err := f.Execute(nil)
+	assert.Equal(t, &PolicyViolationError{Step: 2, StepName: "order", Method: "put", URL: srv.URL + "/orders",
+		Rule: "the flow is read-only"}, err)
+	assert.Equal(t, []string{"POST /login", "GET /home"}, sent)
+
+	f.ReadOnly = false
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "put /orders", sent[len(sent)-1])
+}
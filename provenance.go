@@ -0,0 +1,74 @@
+package httpsim
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValueProvenance tells where a value of the flow comes from
+type ValueProvenance struct {
+	// Given is whether the value was given to Execute, Step is -1 then
+	Given    bool
+	Step     int
+	StepName string
+	// Extracter is the type of the extracter that produced the value, or
+	// "recovery" when it's the step's Recovery's
+	Extracter string
+	// Time is when the value was last written, Writes how many times it was
+	Time   time.Time
+	Writes int
+}
+
+// provenance records that the step wrote the value
+func (f *Flow) provenance(i int, step *Step, name string, recovered bool) {
+	if f.Provenance == nil {
+		f.Provenance = map[string]ValueProvenance{}
+	}
+	p := ValueProvenance{Step: i, StepName: step.Name, Time: time.Now(), Writes: f.Provenance[name].Writes + 1}
+	for _, e := range step.KeysOutput {
+		if extracterName(e) == name {
+			p.Extracter = fmt.Sprintf("%T", e)
+		}
+	}
+	if recovered {
+		p.Extracter = "recovery"
+	}
+	f.Provenance[name] = p
+}
+
+// givenProvenance records the values given to Execute
+func (f *Flow) givenProvenance(values map[string]interface{}) {
+	f.Provenance = make(map[string]ValueProvenance, len(values))
+	now := time.Now()
+	for k := range values {
+		f.Provenance[k] = ValueProvenance{Given: true, Step: -1, Time: now, Writes: 1}
+	}
+}
+
+// missingValue returns the *MissingValueError of the value the step i needs,
+// telling which step was expected to produce it
+func (f *Flow) missingValue(i int, step *Step, name string) error {
+	err := NewMVE(fmt.Sprintf("Step %d.'%s' failed:", i, step.Name), name)
+	from, what := -1, "expected from"
+	if p, ok := f.Provenance[name]; ok && !p.Given {
+		from, what = p.Step, "extracted empty by"
+	}
+	for j := i - 1; j >= 0 && from == -1; j-- {
+		for _, e := range f.Steps[j].KeysOutput {
+			if extracterName(e) == name {
+				from = j
+			}
+		}
+	}
+	if from < 0 || from >= len(f.Steps) {
+		return err
+	}
+	err.Hint = fmt.Sprintf("%s step %d.'%s'", what, from, f.Steps[from].Name)
+	switch resp := f.Steps[from].Response; {
+	case resp == nil:
+		err.Hint += " which wasn't executed"
+	case resp.Raw != nil:
+		err.Hint += fmt.Sprintf(" which returned %d", resp.Raw.StatusCode)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Provenance(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				w.Write([]byte(`<input name="csrf" value="abc">`))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	csrf := Extractable{Name: "csrf", AfterThis: `value="`, BeforeThis: `"`, MaxLength: -1, MinLength: -1, IgnoreNotFound: true}
+	f := Flow{Steps: []Step{
+		{Name: "get login page", Request: Request{URL: srv.URL + "/login", Method: "GET"}, AnyStatus: true,
+			KeysOutput: []Extracter{csrf}},
+		{Name: "login", Request: Request{URL: srv.URL + "/post?csrf={{.csrf}}", Method: "GET"}, KeysInput: []string{"csrf"}},
+	}}
+	assert.Nil(t, f.Execute(map[string]interface{}{"user": "bob"}))
+	assert.True(t, f.Provenance["user"].Given)
+	p := f.Provenance["csrf"]
+	assert.Equal(t, 0, p.Step)
+	assert.Equal(t, "get login page", p.StepName)
+	assert.Equal(t, "httpsim.Extractable", p.Extracter)
+	assert.Equal(t, 1, p.Writes)
+	assert.False(t, p.Time.IsZero())
+
+	status = http.StatusForbidden
+	err := f.Execute(nil)
+	assert.EqualError(t, err, "Step 1.'login' failed: missing or empty key value: csrf "+
+		"(extracted empty by step 0.'get login page' which returned 403)")
+
+	// the value wasn't extracted at all
+	f.Hosts = &HostFilter{Block: []string{"127.0.0.1"}}
+	err = f.Execute(nil)
+	assert.EqualError(t, err, "Step 1.'login' failed: missing or empty key value: csrf "+
+		"(expected from step 0.'get login page' which wasn't executed)")
+}
@@ -0,0 +1,129 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ProxyProvider returns the proxy of each request, e.g. to rotate the
+// requests through a pool of proxies. nil sends the request directly.
+type ProxyProvider interface {
+	Proxy(req *http.Request) (*url.URL, error)
+}
+
+// ProxyFunc is a function used as a ProxyProvider
+type ProxyFunc func(req *http.Request) (*url.URL, error)
+
+// Proxy returns f(req)
+func (f ProxyFunc) Proxy(req *http.Request) (*url.URL, error) {
+	return f(req)
+}
+
+// RotateProxies returns the provider sending the requests through the proxies
+// in turn
+func RotateProxies(proxies ...string) (ProxyProvider, error) {
+	if len(proxies) == 0 {
+		return nil, errors.New("no proxies to rotate")
+	}
+	urls := make([]*url.URL, len(proxies))
+	for i, p := range proxies {
+		u, err := url.Parse(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL '%s': %s", p, err.Error())
+		}
+		urls[i] = u
+	}
+	var (
+		mu   sync.Mutex
+		next int
+	)
+	return ProxyFunc(func(req *http.Request) (*url.URL, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		u := urls[next]
+		next = (next + 1) % len(urls)
+		return u, nil
+	}), nil
+}
+
+// errProxyTransport is returned when the flow's requests are proxied by a
+// transport that isn't an *http.Transport
+var errProxyTransport = errors.New("proxies need the flow's Transport to be an *http.Transport")
+
+// stepProxyKey is the context key of the proxy of the step's requests
+type stepProxyKey struct{}
+
+// proxied returns whether the flow sends requests through proxies
+func (f *Flow) proxied() bool {
+	if f.Proxy != "" || f.ProxyProvider != nil {
+		return true
+	}
+	for _, steps := range [][]Step{f.Steps, f.Teardown} {
+		for _, step := range steps {
+			if step.Proxy != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newProxyTransport returns a copy of the flow's transport sending the
+// requests through the step's Proxy, else the ProxyProvider's, else the
+// flow's Proxy. nil when the flow has no proxies.
+func (f *Flow) newProxyTransport() (*http.Transport, error) {
+	if !f.proxied() {
+		return nil, nil
+	}
+	transport := f.transport()
+	base, ok := transport.(*http.Transport)
+	if transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return nil, errProxyTransport
+	}
+	var proxy *url.URL
+	if f.Proxy != "" {
+		var err error
+		if proxy, err = url.Parse(f.Proxy); err != nil {
+			return nil, fmt.Errorf("Flow invalid proxy URL: %s", err.Error())
+		}
+	}
+	provider := f.ProxyProvider
+	t := base.Clone()
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		if u, ok := req.Context().Value(stepProxyKey{}).(*url.URL); ok {
+			return u, nil
+		}
+		if provider != nil {
+			return provider.Proxy(req)
+		}
+		if proxy != nil {
+			return proxy, nil
+		}
+		if base.Proxy != nil {
+			return base.Proxy(req)
+		}
+		return nil, nil
+	}
+	return t, nil
+}
+
+// stepProxy sends the requests of a step with a Proxy through it
+type stepProxy struct {
+	rt    http.RoundTripper
+	proxy string
+}
+
+func (t *stepProxy) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(t.proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %s", err.Error())
+	}
+	return t.rt.RoundTrip(req.WithContext(context.WithValue(req.Context(), stepProxyKey{}, u)))
+}
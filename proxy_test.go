@@ -0,0 +1,48 @@
+package httpsim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Proxies(t *testing.T) {
+	var proxied []string
+	newProxy := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the target's absolute URL is requested from the proxy
+			proxied = append(proxied, name+" "+r.URL.String())
+		}))
+	}
+	a, b, c := newProxy("a"), newProxy("b"), newProxy("c")
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	f := Flow{Proxy: a.URL, Steps: []Step{
+		{Name: "home", Request: Request{URL: "http://shop.test/", Method: "GET"}},
+		{Name: "search", Request: Request{URL: "http://shop.test/search", Method: "GET"}},
+		{Name: "geo", Request: Request{URL: "http://shop.test/geo", Method: "GET"}, Proxy: c.URL},
+	}}
+	assert.Nil(t, f.Compile())
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, []string{"a http://shop.test/", "a http://shop.test/search", "c http://shop.test/geo"}, proxied)
+
+	proxied = nil
+	rotate, err := RotateProxies(a.URL, b.URL)
+	assert.Nil(t, err)
+	f.ProxyProvider = rotate
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, []string{"a http://shop.test/", "b http://shop.test/search", "c http://shop.test/geo"}, proxied)
+
+	_, err = RotateProxies()
+	assert.NotNil(t, err)
+
+	f.Steps[2].Proxy = "http://%zz"
+	assert.EqualError(t, f.Compile(), `Step 2.'geo' invalid proxy URL: parse "http://%zz": invalid URL escape "%zz"`)
+	f.Transport = http.NewFileTransport(http.Dir("."))
+	assert.Equal(t, errProxyTransport, f.ExecuteContext(context.Background(), nil))
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Dir persists the outcomes as JSON files in the directory, one per job. The
+// numbers of their Values are read back as float64.
+type Dir string
+
+// Save writes the outcome, atomically replacing the job's previous one
+func (d Dir) Save(outcome Outcome) error {
+	b, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(string(d), ".outcome-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(outcome.JobID))
+}
+
+// Outcome reads the outcome of the job
+func (d Dir) Outcome(jobID string) (Outcome, error) {
+	var o Outcome
+	b, err := os.ReadFile(d.path(jobID))
+	if os.IsNotExist(err) {
+		return o, ErrNoOutcome
+	} else if err != nil {
+		return o, err
+	}
+	return o, json.Unmarshal(b, &o)
+}
+
+// path is the file of the job's outcome, its ID being escaped
+func (d Dir) path(jobID string) string {
+	return filepath.Join(string(d), url.PathEscape(jobID)+".json")
+}
@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gee-m/httpsim"
+)
+
+// Executor executes the queued jobs with a bounded number of workers
+type Executor struct {
+	backend     Backend
+	concurrency int
+	// RetryDelay is the delay before a failed job is queued again
+	RetryDelay time.Duration
+
+	mu      sync.RWMutex
+	flows   map[string]*httpsim.CompiledFlow
+	lastID  int64
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	retries sync.WaitGroup
+}
+
+// NewExecutor creates an executor of the backend's jobs running at most
+// concurrency of them at once
+func NewExecutor(backend Backend, concurrency int) *Executor {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Executor{backend: backend, concurrency: concurrency, flows: map[string]*httpsim.CompiledFlow{}}
+}
+
+// Register registers the flow the jobs refer to by name
+func (e *Executor) Register(name string, flow *httpsim.CompiledFlow) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flows[name] = flow
+}
+
+// Enqueue queues the job, giving it an ID if it has none, and returns its ID
+func (e *Executor) Enqueue(job Job) (string, error) {
+	e.mu.RLock()
+	_, ok := e.flows[job.Flow]
+	e.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("queue: no flow '%s'", job.Flow)
+	}
+	if job.ID == "" {
+		job.ID = strconv.FormatInt(atomic.AddInt64(&e.lastID, 1), 10)
+	}
+	return job.ID, e.backend.Push(job)
+}
+
+// Start starts the workers, until Stop is called or ctx is done. Running flows
+// are canceled then (see httpsim.Flow.ExecuteContext).
+func (e *Executor) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	e.cancel = cancel
+	e.mu.Unlock()
+	for i := 0; i < e.concurrency; i++ {
+		e.workers.Add(1)
+		go e.work(ctx)
+	}
+}
+
+// Stop stops the workers and waits for them
+func (e *Executor) Stop() {
+	e.mu.RLock()
+	cancel := e.cancel
+	e.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+	e.workers.Wait()
+	e.retries.Wait()
+}
+
+func (e *Executor) work(ctx context.Context) {
+	defer e.workers.Done()
+	for {
+		job, err := e.backend.Pop(ctx)
+		if err != nil {
+			return
+		}
+		e.execute(ctx, job)
+	}
+}
+
+// execute runs the job, and queues it again or saves its outcome
+func (e *Executor) execute(ctx context.Context, job Job) {
+	e.mu.RLock()
+	compiled := e.flows[job.Flow]
+	e.mu.RUnlock()
+
+	job.Attempt++
+	outcome := Outcome{JobID: job.ID, Flow: job.Flow, Attempts: job.Attempt, Started: time.Now().UTC()}
+	var err error
+	if compiled == nil {
+		err = fmt.Errorf("queue: no flow '%s'", job.Flow)
+	} else {
+		var f *httpsim.Flow
+		f, err = compiled.RunContext(ctx, job.Values)
+		outcome.Values = make(map[string]interface{}, len(f.Values))
+		for k, v := range f.Values {
+			outcome.Values[k] = v
+		}
+		for _, k := range f.SensitiveValues {
+			delete(outcome.Values, k)
+		}
+		for _, w := range f.Warnings {
+			outcome.Warnings = append(outcome.Warnings, w.String())
+		}
+	}
+	outcome.Ended = time.Now().UTC()
+
+	if err != nil && job.Attempt <= job.MaxRetries && ctx.Err() == nil {
+		if _, missing := err.(*httpsim.MissingValueError); !missing && compiled != nil {
+			e.retry(ctx, job)
+			return
+		}
+	}
+	if err != nil {
+		outcome.Err = err.Error()
+	}
+	e.backend.Save(outcome)
+}
+
+// retry queues the job again after the RetryDelay
+func (e *Executor) retry(ctx context.Context, job Job) {
+	e.retries.Add(1)
+	go func() {
+		defer e.retries.Done()
+		select {
+		case <-time.After(e.RetryDelay):
+			e.backend.Push(job)
+		case <-ctx.Done():
+			e.backend.Save(Outcome{JobID: job.ID, Flow: job.Flow, Attempts: job.Attempt, Err: ctx.Err().Error()})
+		}
+	}()
+}
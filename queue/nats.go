@@ -0,0 +1,201 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS is a Backend sharing the jobs between the processes through a NATS
+// server: Push publishes them on the subject, and each is delivered to one of the
+// NATS backends subscribed to it, in the queue group named after it. Core NATS
+// doesn't keep the messages: the jobs published while no backend is subscribed,
+// and the ones delivered but not popped when a backend is closed, are lost, and
+// the Priority only orders the jobs delivered to a backend. The outcomes are
+// stored in the Outcomes given, e.g. a Dir the workers share. The numbers of the
+// jobs' Values are read back as float64.
+type NATS struct {
+	Outcomes
+	subject string
+	conn    net.Conn
+	jobs    *Memory
+
+	mu     sync.Mutex // guards the writes and err
+	err    error
+	closed chan struct{}
+}
+
+// natsTimeout is the time the connection handshake is waited for
+const natsTimeout = 10 * time.Second
+
+// DialNATS connects to the NATS server at addr (host:port, or a nats://
+// URL with the user and password or token) and subscribes to the subject. The
+// outcomes are kept in memory when outcomes is nil.
+func DialNATS(addr, subject string, outcomes Outcomes) (*NATS, error) {
+	if outcomes == nil {
+		outcomes = NewMemory()
+	}
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "name": "httpsim-queue"}
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = u.Host
+		if u.User != nil {
+			if pass, ok := u.User.Password(); ok {
+				connect["user"], connect["pass"] = u.User.Username(), pass
+			} else {
+				connect["auth_token"] = u.User.Username()
+			}
+		}
+	}
+	nc, err := net.DialTimeout("tcp", addr, natsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	n := &NATS{Outcomes: outcomes, subject: subject, conn: nc, jobs: NewMemory(), closed: make(chan struct{})}
+	r := bufio.NewReader(nc)
+	nc.SetDeadline(time.Now().Add(natsTimeout))
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "INFO ") {
+		nc.Close()
+		return nil, fmt.Errorf("queue: nats: unexpected greeting %q: %v", line, err)
+	}
+	b, _ := json.Marshal(connect)
+	fmt.Fprintf(nc, "CONNECT %s\r\nSUB %s %s 1\r\nPING\r\n", b, subject, subject)
+	// the server replies PONG once the CONNECT and SUB are processed
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		op, args := splitOp(strings.TrimSuffix(line, "\r\n"))
+		if op == "PONG" {
+			break
+		} else if op == "-ERR" {
+			nc.Close()
+			return nil, fmt.Errorf("queue: nats: %s", args)
+		}
+	}
+	nc.SetDeadline(time.Time{})
+	go n.read(r)
+	return n, nil
+}
+
+// Push publishes the job
+func (n *NATS) Push(job Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return n.err
+	}
+	_, err = fmt.Fprintf(n.conn, "PUB %s %d\r\n%s\r\n", n.subject, len(b), b)
+	return err
+}
+
+// Pop dequeues the job with the highest priority of the ones delivered
+func (n *NATS) Pop(ctx context.Context) (Job, error) {
+	popCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-n.closed:
+			cancel()
+		case <-popCtx.Done():
+		}
+	}()
+	job, err := n.jobs.Pop(popCtx)
+	if err != nil && ctx.Err() == nil {
+		return job, n.Err()
+	}
+	return job, err
+}
+
+// Err returns the error which closed the connection, if any
+func (n *NATS) Err() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+// Close closes the connection
+func (n *NATS) Close() error {
+	n.fail(errors.New("queue: nats: closed"))
+	return nil
+}
+
+// fail closes the connection because of err
+func (n *NATS) fail(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return
+	}
+	n.err = err
+	n.conn.Close()
+	close(n.closed)
+}
+
+// read reads the messages from the server, queuing the jobs
+func (n *NATS) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			n.fail(err)
+			return
+		}
+		line = strings.TrimSuffix(line, "\r\n")
+		switch op, args := splitOp(line); op {
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <size>
+			fields := strings.Fields(args)
+			if len(fields) < 3 {
+				n.fail(fmt.Errorf("queue: nats: invalid %q", line))
+				return
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				n.fail(fmt.Errorf("queue: nats: invalid %q", line))
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				n.fail(err)
+				return
+			}
+			var job Job
+			if json.Unmarshal(payload[:size], &job) == nil {
+				n.jobs.Push(job)
+			}
+		case "PING":
+			n.mu.Lock()
+			io.WriteString(n.conn, "PONG\r\n")
+			n.mu.Unlock()
+		case "-ERR":
+			n.fail(fmt.Errorf("queue: nats: %s", args))
+			return
+		}
+	}
+}
+
+// splitOp splits the protocol line into its operation and arguments
+func splitOp(line string) (string, string) {
+	if i := strings.IndexByte(line, ' '); i != -1 {
+		return strings.ToUpper(line[:i]), line[i+1:]
+	}
+	return strings.ToUpper(line), ""
+}
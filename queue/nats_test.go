@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNATS delivers the messages published to the subscriptions of their
+// subject, in turn within a queue group
+type fakeNATS struct {
+	net.Listener
+	token string
+
+	mu   sync.Mutex
+	subs map[string][]*natsSub // by queue group
+	next map[string]int
+}
+
+type natsSub struct {
+	c   net.Conn
+	mu  *sync.Mutex
+	sid string
+}
+
+func newFakeNATS(t *testing.T, token string) *fakeNATS {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{Listener: l, token: token, subs: map[string][]*natsSub{}, next: map[string]int{}}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATS) serve(c net.Conn) {
+	defer c.Close()
+	var mu sync.Mutex
+	write := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(c, line)
+	}
+	write(`INFO {"server_id":"fake","max_payload":1048576}` + "\r\n")
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		op, args := splitOp(strings.TrimSuffix(line, "\r\n"))
+		switch op {
+		case "CONNECT":
+			var opts struct {
+				Token string `json:"auth_token"`
+			}
+			json.Unmarshal([]byte(args), &opts)
+			if opts.Token != s.token {
+				write("-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "SUB":
+			// SUB <subject> <queue group> <sid>
+			f := strings.Fields(args)
+			s.mu.Lock()
+			s.subs[f[1]] = append(s.subs[f[1]], &natsSub{c: c, mu: &mu, sid: f[2]})
+			s.mu.Unlock()
+		case "PUB":
+			f := strings.Fields(args)
+			size, _ := strconv.Atoi(f[1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			subs := s.subs[f[0]]
+			if len(subs) != 0 {
+				sub := subs[s.next[f[0]]%len(subs)]
+				s.next[f[0]]++
+				sub.mu.Lock()
+				fmt.Fprintf(sub.c, "MSG %s %s %d\r\n%s", f[0], sub.sid, size, payload)
+				sub.mu.Unlock()
+			}
+			s.mu.Unlock()
+		case "PING":
+			write("PONG\r\n")
+		}
+	}
+}
+
+func TestNATS(t *testing.T) {
+	srv := newFakeNATS(t, "t0k3n")
+	defer srv.Close()
+
+	_, err := DialNATS(srv.Addr().String(), "jobs", nil)
+	assert.EqualError(t, err, "queue: nats: 'Authorization Violation'")
+
+	addr := "nats://t0k3n@" + srv.Addr().String()
+	outcomes := NewMemory()
+	a, err := DialNATS(addr, "jobs", outcomes)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer a.Close()
+	b, err := DialNATS(addr, "jobs", outcomes)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	// each job is delivered to one of the backends
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, a.Push(Job{ID: strconv.Itoa(i), Priority: i, Values: map[string]interface{}{"n": i}}))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	popped := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		for _, n := range []*NATS{a, b} {
+			job, err := n.Pop(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, float64(job.Priority), job.Values["n"])
+			popped[job.ID] = true
+		}
+	}
+	assert.Len(t, popped, 4)
+
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+	_, err = a.Pop(short)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// the backends share the outcomes given
+	assert.Nil(t, a.Save(Outcome{JobID: "1"}))
+	_, err = b.Outcome("1")
+	assert.Nil(t, err)
+
+	b.Close()
+	_, err = b.Pop(context.Background())
+	assert.EqualError(t, err, "queue: nats: closed")
+	assert.NotNil(t, b.Push(Job{ID: "x"}))
+}
+
+func TestExecutor_NATS(t *testing.T) {
+	srv := newFakeNATS(t, "")
+	defer srv.Close()
+	n, err := DialNATS(srv.Addr().String(), "jobs", nil)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer n.Close()
+	testExecutor(t, n)
+}
@@ -0,0 +1,170 @@
+// Package queue runs flows asynchronously: jobs are queued in a Backend and
+// executed by a bounded pool of workers, with priorities and retries, their
+// outcomes persisted in the Backend.
+//
+// Memory keeps the jobs and outcomes in memory. Redis and NATS share the jobs
+// between the processes, speaking the servers' protocols themselves so that,
+// like httpsim, the package only depends on the standard library. Dir persists
+// the outcomes in files, for the backends not keeping them, see WithOutcomes.
+// Other backends must hand each job to a single worker, and replace the outcome
+// of a job saved again.
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Job is a run of a registered flow
+type Job struct {
+	ID     string
+	Flow   string
+	Values map[string]interface{}
+	// Priority orders the jobs, higher first, then in queuing order
+	Priority int
+	// MaxRetries is the number of times the job is retried when it fails
+	MaxRetries int
+	// Attempt is the number of times the job was executed
+	Attempt int
+}
+
+// Outcome is the result of a job
+type Outcome struct {
+	JobID    string
+	Flow     string
+	Attempts int
+	// Err is the error of the last attempt, empty on success
+	Err string
+	// Values are the flow's values, without its SensitiveValues
+	Values   map[string]interface{}
+	Warnings []string
+	Started  time.Time
+	Ended    time.Time
+}
+
+// ErrNoOutcome is returned by Backend.Outcome when the job has none yet
+var ErrNoOutcome = errors.New("queue: no outcome")
+
+// Outcomes stores the outcomes of the jobs. It must be safe for concurrent use.
+type Outcomes interface {
+	// Save stores the outcome of a job
+	Save(outcome Outcome) error
+	// Outcome returns the outcome of the job, ErrNoOutcome if it has none
+	Outcome(jobID string) (Outcome, error)
+}
+
+// Backend stores the jobs and their outcomes. It must be safe for concurrent use.
+type Backend interface {
+	// Push queues the job
+	Push(job Job) error
+	// Pop dequeues the job to execute next, waiting for one until ctx is done
+	Pop(ctx context.Context) (Job, error)
+	Outcomes
+}
+
+// WithOutcomes returns the backend storing the outcomes in outcomes, e.g. a Dir
+// for a Memory backend whose outcomes must survive the process
+func WithOutcomes(backend Backend, outcomes Outcomes) Backend {
+	return withOutcomes{Backend: backend, outcomes: outcomes}
+}
+
+type withOutcomes struct {
+	Backend
+	outcomes Outcomes
+}
+
+func (b withOutcomes) Save(outcome Outcome) error { return b.outcomes.Save(outcome) }
+func (b withOutcomes) Outcome(jobID string) (Outcome, error) {
+	return b.outcomes.Outcome(jobID)
+}
+
+// Memory is a Backend in memory
+type Memory struct {
+	mu       sync.Mutex
+	jobs     jobHeap
+	seq      int
+	pushed   chan struct{}
+	outcomes map[string]Outcome
+}
+
+// NewMemory creates an empty in-memory Backend
+func NewMemory() *Memory {
+	return &Memory{pushed: make(chan struct{}), outcomes: map[string]Outcome{}}
+}
+
+// Push queues the job
+func (m *Memory) Push(job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	heap.Push(&m.jobs, queued{job: job, seq: m.seq})
+	close(m.pushed)
+	m.pushed = make(chan struct{})
+	return nil
+}
+
+// Pop dequeues the job with the highest priority
+func (m *Memory) Pop(ctx context.Context) (Job, error) {
+	for {
+		m.mu.Lock()
+		if len(m.jobs) != 0 {
+			q := heap.Pop(&m.jobs).(queued)
+			m.mu.Unlock()
+			return q.job, nil
+		}
+		pushed := m.pushed
+		m.mu.Unlock()
+
+		select {
+		case <-pushed:
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		}
+	}
+}
+
+// Save stores the outcome
+func (m *Memory) Save(outcome Outcome) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcomes[outcome.JobID] = outcome
+	return nil
+}
+
+// Outcome returns the outcome of the job
+func (m *Memory) Outcome(jobID string) (Outcome, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.outcomes[jobID]
+	if !ok {
+		return Outcome{}, ErrNoOutcome
+	}
+	return o, nil
+}
+
+// queued is a job in the heap
+type queued struct {
+	job Job
+	seq int
+}
+
+type jobHeap []queued
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].job.Priority != h[j].job.Priority {
+		return h[i].job.Priority > h[j].job.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h jobHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x interface{}) { *h = append(*h, x.(queued)) }
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	q := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return q
+}
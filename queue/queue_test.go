@@ -0,0 +1,155 @@
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gee-m/httpsim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Priority(t *testing.T) {
+	m := NewMemory()
+	m.Push(Job{ID: "low"})
+	m.Push(Job{ID: "high", Priority: 2})
+	m.Push(Job{ID: "low2"})
+	m.Push(Job{ID: "mid", Priority: 1})
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		job, err := m.Pop(context.Background())
+		assert.Nil(t, err)
+		ids = append(ids, job.ID)
+	}
+	assert.Equal(t, []string{"high", "mid", "low", "low2"}, ids)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := m.Pop(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	_, err = m.Outcome("low")
+	assert.Equal(t, ErrNoOutcome, err)
+}
+
+func TestMemory_PopWaits(t *testing.T) {
+	m := NewMemory()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Push(Job{ID: "1"})
+	}()
+	job, err := m.Pop(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "1", job.ID)
+}
+
+func TestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outcomes")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	d := Dir(dir)
+
+	_, err = d.Outcome("a/b")
+	assert.Equal(t, ErrNoOutcome, err)
+	o := Outcome{JobID: "a/b", Flow: "f", Attempts: 1, Values: map[string]interface{}{"n": 1}}
+	assert.Nil(t, d.Save(o))
+	o.Attempts, o.Err = 2, "boom"
+	assert.Nil(t, d.Save(o))
+	got, err := d.Outcome("a/b")
+	assert.Nil(t, err)
+	assert.Equal(t, "boom", got.Err)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Equal(t, float64(1), got.Values["n"])
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal(t, []string{filepath.Join(dir, "a%2Fb.json")}, names)
+
+	// the outcomes of a Memory backend saved in the Dir
+	m := NewMemory()
+	b := WithOutcomes(m, d)
+	assert.Nil(t, b.Save(Outcome{JobID: "c"}))
+	_, err = m.Outcome("c")
+	assert.Equal(t, ErrNoOutcome, err)
+	_, err = Dir(dir).Outcome("c")
+	assert.Nil(t, err)
+}
+
+func waitOutcome(t *testing.T, b Backend, id string) Outcome {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if o, err := b.Outcome(id); err == nil {
+			return o
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no outcome for job %s", id)
+	return Outcome{}
+}
+
+func TestExecutor(t *testing.T) {
+	testExecutor(t, NewMemory())
+}
+
+// testExecutor executes jobs queued in the backend
+func testExecutor(t *testing.T, b Backend) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first attempt of each job fails
+		if atomic.AddInt32(&calls, 1)%2 == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("<b>hello</b>"))
+	}))
+	defer srv.Close()
+
+	f := httpsim.Flow{
+		RequiredValues:  []string{"token"},
+		SensitiveValues: []string{"token"},
+		Steps: []httpsim.Step{{
+			Name:      "get",
+			Request:   httpsim.Request{URL: srv.URL + "/{{.token}}", Method: "GET"},
+			KeysInput: []string{"token"},
+			KeysOutput: []httpsim.Extracter{
+				httpsim.Extractable{Name: "greeting", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1},
+			},
+		}},
+	}
+	compiled, err := f.CompileFlow()
+	assert.Nil(t, err)
+
+	e := NewExecutor(b, 1)
+	e.Register("get", compiled)
+	e.Start(context.Background())
+	defer e.Stop()
+
+	_, err = e.Enqueue(Job{Flow: "nope"})
+	assert.NotNil(t, err)
+
+	id, err := e.Enqueue(Job{Flow: "get", Values: map[string]interface{}{"token": "x"}, MaxRetries: 1})
+	assert.Nil(t, err)
+	o := waitOutcome(t, b, id)
+	assert.Equal(t, "", o.Err)
+	assert.Equal(t, 2, o.Attempts)
+	assert.Equal(t, "hello", o.Values["greeting"])
+	assert.Nil(t, o.Values["token"])
+
+	id, err = e.Enqueue(Job{Flow: "get", Values: map[string]interface{}{"token": "x"}})
+	assert.Nil(t, err)
+	o = waitOutcome(t, b, id)
+	assert.Contains(t, o.Err, "500")
+	assert.Equal(t, 1, o.Attempts)
+
+	// missing values aren't retried
+	id, err = e.Enqueue(Job{Flow: "get", MaxRetries: 3})
+	assert.Nil(t, err)
+	o = waitOutcome(t, b, id)
+	assert.Contains(t, o.Err, "token")
+	assert.Equal(t, 1, o.Attempts)
+}
@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRedisPriority bounds the jobs' priorities in Redis, so that their scores
+// order them exactly
+const maxRedisPriority = 1 << 20
+
+// Redis is a Backend sharing the jobs and their outcomes between the processes
+// through a Redis server (5.0 or later). The jobs are kept in the sorted set
+// <Prefix>jobs, their Priority within ±1<<20, and the outcomes in the
+// <Prefix>outcome:<job ID> keys. The numbers of the jobs' and outcomes' Values
+// are read back as float64.
+type Redis struct {
+	// Addr is the host:port of the server
+	Addr string
+	// Password, if set, authenticates the connections
+	Password string
+	// DB is the database selected
+	DB int
+	// Prefix prefixes the keys, "httpsim:queue:" by default
+	Prefix string
+	// OutcomeTTL, if set, expires the outcomes after it
+	OutcomeTTL time.Duration
+	// PopTimeout is the time Pop waits on the server before checking its ctx, 1s
+	// by default
+	PopTimeout time.Duration
+
+	mu   sync.Mutex
+	idle []*redisConn
+}
+
+// NewRedis creates a Backend on the Redis server at addr
+func NewRedis(addr string) *Redis {
+	return &Redis{Addr: addr}
+}
+
+// Push queues the job
+func (r *Redis) Push(job Job) error {
+	if job.Priority > maxRedisPriority || job.Priority < -maxRedisPriority {
+		return fmt.Errorf("queue: priority %d out of ±%d", job.Priority, maxRedisPriority)
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	seq, err := r.do("INCR", r.key("seq"))
+	if err != nil {
+		return err
+	}
+	n, ok := seq.(int64)
+	if !ok {
+		return fmt.Errorf("queue: unexpected INCR reply %v", seq)
+	}
+	// the higher priorities first, then the lower sequence numbers
+	score := float64(job.Priority)*(1<<32) - float64(n%(1<<32))
+	_, err = r.do("ZADD", r.key("jobs"), strconv.FormatFloat(score, 'f', -1, 64), strconv.FormatInt(n, 10)+":"+string(b))
+	return err
+}
+
+// Pop dequeues the job with the highest priority
+func (r *Redis) Pop(ctx context.Context) (Job, error) {
+	timeout := r.PopTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return Job{}, err
+		}
+		reply, err := r.doTimeout(timeout, "BZPOPMAX", r.key("jobs"), strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64))
+		if err != nil {
+			return Job{}, err
+		}
+		popped, ok := reply.([]interface{})
+		if !ok {
+			continue // timed out
+		}
+		var member string
+		if len(popped) == 3 {
+			member, _ = popped[1].(string)
+		}
+		i := strings.IndexByte(member, ':')
+		if i == -1 {
+			return Job{}, fmt.Errorf("queue: unexpected BZPOPMAX reply %v", reply)
+		}
+		var job Job
+		return job, json.Unmarshal([]byte(member[i+1:]), &job)
+	}
+}
+
+// Save stores the outcome
+func (r *Redis) Save(outcome Outcome) error {
+	b, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+	args := []string{"SET", r.key("outcome:" + outcome.JobID), string(b)}
+	if r.OutcomeTTL > 0 {
+		args = append(args, "PX", strconv.FormatInt(int64(r.OutcomeTTL/time.Millisecond), 10))
+	}
+	_, err = r.do(args...)
+	return err
+}
+
+// Outcome returns the outcome of the job
+func (r *Redis) Outcome(jobID string) (Outcome, error) {
+	var o Outcome
+	reply, err := r.do("GET", r.key("outcome:"+jobID))
+	if err != nil {
+		return o, err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return o, ErrNoOutcome
+	}
+	return o, json.Unmarshal([]byte(s), &o)
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.idle {
+		c.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+func (r *Redis) key(name string) string {
+	if r.Prefix == "" {
+		return "httpsim:queue:" + name
+	}
+	return r.Prefix + name
+}
+
+// redisTimeout is the time a command's reply is waited for
+const redisTimeout = 10 * time.Second
+
+func (r *Redis) do(args ...string) (interface{}, error) {
+	return r.doTimeout(0, args...)
+}
+
+// doTimeout sends the command on an idle connection, waiting for its reply
+// blocking for up to block on the server
+func (r *Redis) doTimeout(block time.Duration, args ...string) (interface{}, error) {
+	c, err := r.conn()
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeadline(time.Now().Add(block + redisTimeout))
+	reply, err := c.do(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		c.Close()
+		return nil, err
+	}
+	r.mu.Lock()
+	r.idle = append(r.idle, c)
+	r.mu.Unlock()
+	return reply, err
+}
+
+// conn returns an idle connection, or a new one
+func (r *Redis) conn() (*redisConn, error) {
+	r.mu.Lock()
+	if n := len(r.idle); n != 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", r.Addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	c.SetDeadline(time.Now().Add(redisTimeout))
+	if r.Password != "" {
+		if _, err := c.do("AUTH", r.Password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if r.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(r.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// redisError is an error replied by the server
+type redisError string
+
+func (e redisError) Error() string { return "queue: redis: " + string(e) }
+
+// redisConn is a connection speaking RESP, the Redis protocol
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do sends the command and reads its reply: a string, an int64, a
+// []interface{}, or nil
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c, b.String()); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+func (c *redisConn) reply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("queue: redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		var itemErr error
+		for i := range items {
+			items[i], err = c.reply()
+			if _, ok := err.(redisError); ok {
+				itemErr = err
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return items, itemErr
+	}
+	return nil, fmt.Errorf("queue: redis: unexpected reply %q", line)
+}
@@ -0,0 +1,219 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves the commands of the Redis backend
+type fakeRedis struct {
+	net.Listener
+	password string
+
+	mu     sync.Mutex
+	keys   map[string]string
+	zsets  map[string]map[string]float64
+	pushed chan struct{}
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{Listener: l, password: password, keys: map[string]string{},
+		zsets: map[string]map[string]float64{}, pushed: make(chan struct{})}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			io.WriteString(c, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		switch cmd {
+		case "AUTH":
+			if args[1] != s.password {
+				io.WriteString(c, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+			io.WriteString(c, "+OK\r\n")
+		case "SELECT":
+			io.WriteString(c, "+OK\r\n")
+		case "INCR":
+			s.mu.Lock()
+			n, _ := strconv.Atoi(s.keys[args[1]])
+			s.keys[args[1]] = strconv.Itoa(n + 1)
+			s.mu.Unlock()
+			fmt.Fprintf(c, ":%d\r\n", n+1)
+		case "SET":
+			s.mu.Lock()
+			s.keys[args[1]] = args[2]
+			s.mu.Unlock()
+			io.WriteString(c, "+OK\r\n")
+		case "GET":
+			s.mu.Lock()
+			v, ok := s.keys[args[1]]
+			s.mu.Unlock()
+			if !ok {
+				io.WriteString(c, "$-1\r\n")
+				continue
+			}
+			fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+		case "ZADD":
+			score, _ := strconv.ParseFloat(args[2], 64)
+			s.mu.Lock()
+			if s.zsets[args[1]] == nil {
+				s.zsets[args[1]] = map[string]float64{}
+			}
+			s.zsets[args[1]][args[3]] = score
+			close(s.pushed)
+			s.pushed = make(chan struct{})
+			s.mu.Unlock()
+			io.WriteString(c, ":1\r\n")
+		case "BZPOPMAX":
+			timeout, _ := strconv.ParseFloat(args[2], 64)
+			s.bzpopmax(c, args[1], time.Duration(timeout*float64(time.Second)))
+		default:
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+func (s *fakeRedis) bzpopmax(c net.Conn, key string, timeout time.Duration) {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		var members []string
+		for m := range s.zsets[key] {
+			members = append(members, m)
+		}
+		if len(members) != 0 {
+			zset := s.zsets[key]
+			sort.Slice(members, func(i, j int) bool { return zset[members[i]] > zset[members[j]] })
+			m, score := members[0], zset[members[0]]
+			delete(zset, m)
+			s.mu.Unlock()
+			sc := strconv.FormatFloat(score, 'f', -1, 64)
+			fmt.Fprintf(c, "*3\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(key), key, len(m), m, len(sc), sc)
+			return
+		}
+		pushed := s.pushed
+		s.mu.Unlock()
+		select {
+		case <-pushed:
+		case <-deadline:
+			io.WriteString(c, "*-1\r\n")
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line)[1:])
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	srv := newFakeRedis(t, "s3cr3t")
+	defer srv.Close()
+
+	r := NewRedis(srv.Addr().String())
+	r.PopTimeout = 10 * time.Millisecond
+	defer r.Close()
+	assert.EqualError(t, r.Push(Job{ID: "x"}), "queue: redis: NOAUTH Authentication required.")
+
+	r.Password, r.DB = "s3cr3t", 1
+	r.Close()
+	assert.Nil(t, r.Push(Job{ID: "low", Values: map[string]interface{}{"n": 1}}))
+	assert.Nil(t, r.Push(Job{ID: "high", Priority: 2}))
+	assert.Nil(t, r.Push(Job{ID: "low2"}))
+	assert.Nil(t, r.Push(Job{ID: "mid", Priority: 1, MaxRetries: 3}))
+	assert.NotNil(t, r.Push(Job{ID: "huge", Priority: 1 << 21}))
+
+	var jobs []Job
+	for i := 0; i < 4; i++ {
+		job, err := r.Pop(context.Background())
+		assert.Nil(t, err)
+		jobs = append(jobs, job)
+	}
+	assert.Equal(t, []Job{{ID: "high", Priority: 2}, {ID: "mid", Priority: 1, MaxRetries: 3},
+		{ID: "low", Values: map[string]interface{}{"n": float64(1)}}, {ID: "low2"}}, jobs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := r.Pop(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	_, err = r.Outcome("low")
+	assert.Equal(t, ErrNoOutcome, err)
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	o := Outcome{JobID: "low", Flow: "f", Attempts: 1, Err: "boom", Started: started, Ended: started}
+	assert.Nil(t, r.Save(o))
+	got, err := r.Outcome("low")
+	assert.Nil(t, err)
+	assert.Equal(t, o, got)
+	srv.mu.Lock()
+	_, ok := srv.keys["httpsim:queue:outcome:low"]
+	srv.mu.Unlock()
+	assert.True(t, ok)
+}
+
+func TestExecutor_Redis(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	defer srv.Close()
+	r := NewRedis(srv.Addr().String())
+	r.PopTimeout = 10 * time.Millisecond
+	defer r.Close()
+	testExecutor(t, r)
+}
@@ -0,0 +1,182 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// RangeError is the error returned when a partial response doesn't match the
+// requested range
+type RangeError struct {
+	Step     int
+	StepName string
+	Reason   string
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because range response is invalid: %s", e.Step, e.StepName, e.Reason)
+}
+
+var (
+	rangeHeader  = regexp.MustCompile(`^bytes=(\d+)-(\d*)$`)
+	contentRange = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+|\*)$`)
+)
+
+// byteRange is a range of bytes, last is -1 for the end of the body
+type byteRange struct {
+	first, last int64
+}
+
+// parseRange parses a single range Range header ("bytes=100-" or "bytes=100-199")
+func parseRange(header string) (byteRange, bool) {
+	m := rangeHeader.FindStringSubmatch(header)
+	if m == nil {
+		return byteRange{}, false
+	}
+	r := byteRange{last: -1}
+	r.first, _ = strconv.ParseInt(m[1], 10, 64)
+	if m[2] != "" {
+		r.last, _ = strconv.ParseInt(m[2], 10, 64)
+	}
+	return r, true
+}
+
+// parseContentRange parses a Content-Range header, total is -1 when unknown
+func parseContentRange(header string) (r byteRange, total int64, ok bool) {
+	m := contentRange.FindStringSubmatch(header)
+	if m == nil {
+		return byteRange{}, 0, false
+	}
+	r.first, _ = strconv.ParseInt(m[1], 10, 64)
+	r.last, _ = strconv.ParseInt(m[2], 10, 64)
+	total = -1
+	if m[3] != "*" {
+		total, _ = strconv.ParseInt(m[3], 10, 64)
+	}
+	return r, total, r.first <= r.last && (total == -1 || r.last < total)
+}
+
+// checkPartial returns why the response doesn't serve the range starting at
+// first, empty when it does
+func checkPartial(resp *http.Response, first int64) string {
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Sprintf("status is %d (expected 206)", resp.StatusCode)
+	}
+	got, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok {
+		return fmt.Sprintf("Content-Range '%s' is invalid", resp.Header.Get("Content-Range"))
+	}
+	if got.first != first {
+		return fmt.Sprintf("Content-Range starts at %d (expected %d)", got.first, first)
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength != got.last-got.first+1 {
+		return fmt.Sprintf("Content-Length is %d but Content-Range has %d bytes", resp.ContentLength, got.last-got.first+1)
+	}
+	return ""
+}
+
+// CheckRange returns a *RangeError when the request asked for a range the
+// response doesn't serve. Servers may ignore ranges and answer the whole body
+// with a 200, that's no error.
+func (s *Step) CheckRange(resp *http.Response, stepNb int) error {
+	requested, ok := parseRange(s.Request.Header.Get("Range"))
+	if !ok || resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if reason := checkPartial(resp, requested.first); reason != "" {
+		return &RangeError{Step: stepNb, StepName: s.Name, Reason: reason}
+	}
+	return nil
+}
+
+// resumingBody resumes reading the body with range requests when the connection
+// fails, up to Step.Resume times
+type resumingBody struct {
+	io.ReadCloser
+	f    *Flow
+	i    int
+	step *Step
+	ctx  context.Context
+	cl   http.Client
+	// validator is the ETag or Last-Modified sent as If-Range, so the server
+	// sends the whole body again if it changed
+	validator string
+	// next is the offset of the next byte to read, last the last one of the range
+	next, last int64
+	resumes    int
+}
+
+func (b *resumingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.next += int64(n)
+	if err == nil || err == io.EOF || b.resumes >= b.step.Resume {
+		return n, err
+	}
+	if rerr := b.resume(); rerr != nil {
+		return n, rerr
+	}
+	return n, nil
+}
+
+// resume requests the rest of the body
+func (b *resumingBody) resume() error {
+	b.resumes++
+	b.ReadCloser.Close()
+	req := b.step.Request
+	req.Header = cloneHeader(req.Header)
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	last := ""
+	if b.last >= 0 {
+		last = strconv.FormatInt(b.last, 10)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%s", b.next, last))
+	if b.validator != "" {
+		req.Header.Set("If-Range", b.validator)
+	}
+	resp, err := req.DoContext(b.ctx, b.cl)
+	if err != nil {
+		return &RangeError{Step: b.i, StepName: b.step.Name, Reason: "resuming failed: " + err.Error()}
+	}
+	if reason := checkPartial(resp, b.next); reason != "" {
+		resp.Body.Close()
+		return &RangeError{Step: b.i, StepName: b.step.Name, Reason: reason}
+	}
+	b.ReadCloser = resp.Body
+	b.f.Warnings = append(b.f.Warnings, LintWarning{Step: b.i, StepName: b.step.Name,
+		Message: fmt.Sprintf("range: resumed the download at byte %d", b.next)})
+	return nil
+}
+
+// resumable makes the response's body resume when the connection fails, if the
+// step asks for it and the response can be resumed
+func (f *Flow) resumable(run *runState, i int, step *Step, resp *http.Response) io.ReadCloser {
+	if step.Resume <= 0 || resp.Uncompressed || resp.Header.Get("Accept-Ranges") == "none" {
+		return resp.Body
+	}
+	b := &resumingBody{ReadCloser: resp.Body, f: f, i: i, step: step, ctx: run.requestCtx, cl: run.clientFor(step),
+		last: -1}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		r, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok {
+			return resp.Body
+		}
+		b.next, b.last = r.first, r.last
+	default:
+		return resp.Body
+	}
+	// weak ETags can't be used for ranges
+	if etag := resp.Header.Get("ETag"); etag != "" && etag[0] == '"' {
+		b.validator = etag
+	} else {
+		b.validator = resp.Header.Get("Last-Modified")
+	}
+	return b
+}
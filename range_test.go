@@ -0,0 +1,85 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyServer serves the export, dropping the connection after cut bytes of the
+// full responses
+func flakyServer(t *testing.T, export string, cut int) (*httptest.Server, *[]string) {
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v1"`)
+		if rng, ok := parseRange(r.Header.Get("Range")); ok && r.Header.Get("If-Range") == `"v1"` {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.first, len(export)-1, len(export)))
+			w.Header().Set("Content-Length", fmt.Sprint(len(export)-int(rng.first)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(export[rng.first:]))
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(export)))
+		w.Write([]byte(export[:cut]))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	return srv, &ranges
+}
+
+func TestFlow_Resume(t *testing.T) {
+	export := strings.Repeat("0123456789", 100) + "<end>"
+	srv, ranges := flakyServer(t, export, 300)
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{Name: "export", Request: Request{URL: srv.URL, Method: "GET"}, Resume: 2}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, export, string(f.Steps[0].Response.Body))
+	assert.Equal(t, []string{" ", `bytes=300- "v1"`}, *ranges)
+	assert.Len(t, f.Warnings, 1)
+	assert.Equal(t, "Step 0.'export' range: resumed the download at byte 300", f.Warnings[0].String())
+
+	f.Steps[0].Resume = 0
+	assert.NotNil(t, f.Execute(nil))
+}
+
+func TestFlow_ResumeInvalid(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		if r.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-99/100")
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		w.Write([]byte(strings.Repeat("a", 10)))
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer srv.Close()
+	f := Flow{Steps: []Step{{Name: "export", Request: Request{URL: srv.URL, Method: "GET"}, Resume: 1}}}
+	err := f.Execute(nil)
+	assert.IsType(t, &RangeError{}, err)
+	assert.EqualError(t, err, "Step 0.'export' failed because range response is invalid: Content-Range starts at 0 (expected 10)")
+}
+
+func TestStep_CheckRange(t *testing.T) {
+	s := Step{Name: "part", Request: Request{Header: http.Header{"Range": {"bytes=100-199"}}}}
+	resp := func(status int, contentRange string, length int64) *http.Response {
+		return &http.Response{StatusCode: status, ContentLength: length, Header: http.Header{"Content-Range": {contentRange}}}
+	}
+	assert.Nil(t, s.CheckRange(resp(206, "bytes 100-199/1000", 100), 0))
+	assert.Nil(t, s.CheckRange(resp(200, "", 1000), 0))
+	assert.EqualError(t, s.CheckRange(resp(206, "bytes 0-99/1000", 100), 0),
+		"Step 0.'part' failed because range response is invalid: Content-Range starts at 0 (expected 100)")
+	assert.EqualError(t, s.CheckRange(resp(206, "bytes 100-199/1000", 50), 0),
+		"Step 0.'part' failed because range response is invalid: Content-Length is 50 but Content-Range has 100 bytes")
+	assert.EqualError(t, s.CheckRange(resp(206, "bytes 100-99/1000", -1), 0),
+		"Step 0.'part' failed because range response is invalid: Content-Range 'bytes 100-99/1000' is invalid")
+	assert.Nil(t, (&Step{}).CheckRange(resp(206, "", 0), 0))
+}
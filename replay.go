@@ -0,0 +1,87 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+)
+
+// Checkpoint is the session a step failed in, to replay the step alone (see
+// Flow.ReplayStep). It can be saved as JSON, the SensitiveValues aren't kept.
+type Checkpoint struct {
+	Step     int    `json:"step"`
+	StepName string `json:"step_name"`
+	// Values are the flow's values when the step failed
+	Values map[string]interface{} `json:"values"`
+	// Cookies are the jar's cookies for URL, the step's request URL
+	URL     string         `json:"url"`
+	Cookies []*http.Cookie `json:"cookies,omitempty"`
+}
+
+// checkpoint returns the checkpoint of the step failing with the flow's values
+func (f *Flow) checkpoint(i int, step *Step) *Checkpoint {
+	cp := &Checkpoint{Step: i, StepName: step.Name, Values: map[string]interface{}{}, URL: step.Request.URL}
+	for k, v := range f.Values {
+		cp.Values[k] = v
+	}
+	for _, k := range f.SensitiveValues {
+		delete(cp.Values, k)
+	}
+	if u, err := url.Parse(step.Request.URL); err == nil && f.CookieJar != nil && u.Host != "" {
+		cp.Cookies = f.CookieJar.Cookies(u)
+	}
+	return cp
+}
+
+// ReplayStep executes the checkpoint's step alone, with its values and cookies,
+// e.g. to check a fix of the step. values are added to the checkpoint's, the
+// missing SensitiveValues are resolved with the Credentials. The flow gets a new
+// CookieJar holding the checkpoint's cookies.
+func (f *Flow) ReplayStep(cp *Checkpoint, values map[string]interface{}) error {
+	var def *Step
+	switch {
+	case cp.Step >= 0 && cp.Step < len(f.Steps):
+		def = &f.Steps[cp.Step]
+	case cp.Step >= len(f.Steps) && cp.Step < len(f.Steps)+len(f.Teardown):
+		def = &f.Teardown[cp.Step-len(f.Steps)]
+	default:
+		return fmt.Errorf("checkpoint is of step %d, the flow has %d", cp.Step, len(f.Steps)+len(f.Teardown))
+	}
+	if def.Name != cp.StepName {
+		return fmt.Errorf("checkpoint is of step %d.'%s', the flow's is '%s'", cp.Step, cp.StepName, def.Name)
+	}
+
+	vals := make(map[string]interface{}, len(cp.Values)+len(values))
+	for k, v := range cp.Values {
+		vals[k] = v
+	}
+	for k, v := range values {
+		vals[k] = v
+	}
+	if err := f.resolveCredentials(vals); err != nil {
+		return err
+	}
+	f.Values = vals
+	f.Warnings = nil
+	f.StepResults = nil
+	f.failure = nil
+	f.result = f.newResult()
+	f.givenProvenance(vals)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	if u, err := url.Parse(cp.URL); err == nil && len(cp.Cookies) != 0 {
+		jar.SetCookies(u, cp.Cookies)
+	}
+	f.CookieJar = jar
+
+	run, err := f.newRun()
+	if err != nil {
+		return err
+	}
+	defer run.close()
+	return f.runStep(run, cp.Step, def)
+}
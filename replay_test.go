@@ -0,0 +1,71 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ReplayStep(t *testing.T) {
+	fixed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			w.Write([]byte(`<i>t42</i>`))
+		case "/transfer":
+			c, err := r.Cookie("session")
+			if err != nil || c.Value != "s1" || r.FormValue("token") != "t42" || r.FormValue("password") != "pw" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !fixed {
+				w.Write([]byte("<p>done</p>"))
+				return
+			}
+			w.Write([]byte(`<b>done</b>`))
+		}
+	}))
+	defer srv.Close()
+	f := Flow{
+		RequiredValues:  []string{"password"},
+		SensitiveValues: []string{"password"},
+		Steps: []Step{
+			{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "GET"},
+				KeysOutput: []Extracter{Extractable{Name: "token", AfterThis: "<i>", BeforeThis: "</i>", MaxLength: -1, MinLength: -1}}},
+			{Name: "transfer", Request: Request{URL: srv.URL + "/transfer?token={{.token}}&password={{.password}}", Method: "GET"},
+				KeysInput:  []string{"token", "password"},
+				KeysOutput: []Extracter{Extractable{Name: "status", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}}},
+		},
+	}
+	assert.NotNil(t, f.Execute(map[string]interface{}{"password": "pw"}))
+	cp := f.Failure().Checkpoint
+	assert.NotNil(t, cp)
+	assert.Equal(t, map[string]interface{}{"token": "t42"}, cp.Values)
+
+	// saved and loaded
+	b, err := json.Marshal(cp)
+	assert.Nil(t, err)
+	var loaded Checkpoint
+	assert.Nil(t, json.Unmarshal(b, &loaded))
+	assert.Equal(t, 1, loaded.Step)
+
+	// the password isn't saved
+	assert.NotNil(t, f.ReplayStep(&loaded, nil))
+	assert.Equal(t, []string{"password"}, f.Failure().ValuesMissing)
+	assert.Equal(t, 0, f.Failure().StatusCode)
+
+	assert.NotNil(t, f.ReplayStep(&loaded, map[string]interface{}{"password": "pw"}))
+	assert.Equal(t, PhaseExtraction, f.Failure().Phase)
+	fixed = true
+	assert.Nil(t, f.ReplayStep(&loaded, map[string]interface{}{"password": "pw"}))
+	assert.Equal(t, "done", f.Values["status"])
+
+	loaded.StepName = "renamed"
+	assert.EqualError(t, f.ReplayStep(&loaded, nil), "checkpoint is of step 1.'renamed', the flow's is 'transfer'")
+	loaded.Step = 5
+	assert.EqualError(t, f.ReplayStep(&loaded, nil), "checkpoint is of step 5, the flow has 2")
+}
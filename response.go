@@ -0,0 +1,60 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// lazyJSON is the body of a response parsed once, when it's first asked for
+type lazyJSON struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// JSON returns the body parsed as JSON, numbers as json.Number. It's parsed
+// once and may be called concurrently.
+func (r *Response) JSON() (interface{}, error) {
+	if r.json == nil {
+		return parseJSON(r.Body)
+	}
+	r.json.once.Do(func() {
+		r.json.value, r.json.err = parseJSON(r.Body)
+	})
+	return r.json.value, r.json.err
+}
+
+// parseJSON parses the JSON document, numbers as json.Number
+func parseJSON(body []byte) (interface{}, error) {
+	if body == nil {
+		return nil, errors.New("the response body wasn't read")
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Text returns the body as a string
+func (r *Response) Text() string {
+	return string(r.Body)
+}
+
+// Cookie returns the cookie the response set, nil if it didn't
+func (r *Response) Cookie(name string) *http.Cookie {
+	if r.Raw == nil {
+		return nil
+	}
+	for _, c := range r.Raw.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
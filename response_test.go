@@ -0,0 +1,53 @@
+package httpsim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponse_Accessors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order": {"id": 12, "total": "9.99"}}`))
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{Name: "order", Request: Request{URL: srv.URL, Method: "GET"}}}}
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	resp := f.Steps[0].Response
+	assert.Equal(t, `{"order": {"id": 12, "total": "9.99"}}`, resp.Text())
+	assert.Equal(t, "s1", resp.Cookie("session").Value)
+	assert.Nil(t, resp.Cookie("missing"))
+
+	// parsed once, concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := resp.JSON()
+			assert.Nil(t, err)
+			assert.Equal(t, json.Number("12"), v.(map[string]interface{})["order"].(map[string]interface{})["id"])
+		}()
+	}
+	wg.Wait()
+
+	_, err := (&Response{Body: []byte("<html>")}).JSON()
+	assert.NotNil(t, err)
+	_, err = (&Response{}).JSON()
+	assert.EqualError(t, err, "the response body wasn't read")
+}
+
+func TestFlowResult_Step(t *testing.T) {
+	r := &FlowResult{Steps: []StepSnapshot{{Name: "home", Response: &Response{Body: []byte("a")}}, {Name: "report"}}}
+	assert.Equal(t, "a", r.Step("home").Text())
+	assert.Nil(t, r.Step("report"))
+	assert.Nil(t, r.Step("missing"))
+}
@@ -0,0 +1,124 @@
+package httpsim
+
+import (
+	"context"
+	"time"
+)
+
+// FlowResult is the result of an execution, see ExecuteResult, or the partial
+// one of an execution halted at its Deadline (see TimeoutError)
+type FlowResult struct {
+	// Steps are the snapshots of the steps, by index
+	Steps []StepSnapshot
+	// Completed are the steps executed without error, in order. Skipped are the
+	// ones that weren't executed. The step that failed the execution (e.g. at
+	// the deadline) is in neither, see its Err.
+	Completed []int
+	Skipped   []int
+	// Values are the values at the end of the execution
+	Values map[string]interface{}
+	// Elapsed is the time the execution ran
+	Elapsed time.Duration
+
+	started time.Time
+}
+
+// StepSnapshot is what a step of an execution sent and received
+type StepSnapshot struct {
+	Step int
+	Name string
+	// Request is the request sent, rendered with the values
+	Request Request
+	// Response is nil when the step wasn't executed or was skipped (see
+	// Step.Condition)
+	Response *Response
+	// Values are the values the step extracted
+	Values   map[string]interface{}
+	Duration time.Duration
+	Err      error
+
+	executed bool
+}
+
+// Step returns the response of the first step with that name, nil when there's
+// none or it wasn't executed
+func (r *FlowResult) Step(name string) *Response {
+	for _, s := range r.Steps {
+		if s.Name == name {
+			return s.Response
+		}
+	}
+	return nil
+}
+
+// ExecuteResult executes a copy of the flow (see CompleteCopy) and returns its
+// result, the flow itself isn't modified. The result is returned with the
+// error of a failed execution, unless it failed before any step.
+func (f *Flow) ExecuteResult(ctx context.Context, values map[string]interface{}) (*FlowResult, error) {
+	run := f.CompleteCopy()
+	vals := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		vals[k] = v
+	}
+	err := run.ExecuteContext(ctx, vals)
+	if run.result == nil {
+		return nil, err
+	}
+	return run.result.finish(run.Values), err
+}
+
+// newResult starts the result of an execution of the flow
+func (f *Flow) newResult() *FlowResult {
+	r := &FlowResult{Steps: make([]StepSnapshot, len(f.Steps)), started: time.Now()}
+	for i, step := range f.Steps {
+		r.Steps[i] = StepSnapshot{Step: i, Name: step.Name, Request: step.Request}
+	}
+	return r
+}
+
+// record records the execution of the step i, run is the current run's state
+func (f *Flow) record(run *runState, i int, def *Step, start time.Time, err error) {
+	r := f.result
+	if r == nil || i >= len(r.Steps) {
+		// the Teardown steps
+		return
+	}
+	s := &r.Steps[i]
+	if run.current != nil && run.index == i {
+		s.Request = run.current.Request
+	}
+	s.Response, s.Duration, s.Err, s.executed = def.Response, time.Since(start), err, true
+	s.Values = map[string]interface{}{}
+	for name, p := range f.Provenance {
+		if !p.Given && p.Step == i && !p.Time.Before(start) {
+			s.Values[name] = f.Values[name]
+		}
+	}
+	if err == nil {
+		r.Completed = append(r.Completed, i)
+	}
+}
+
+// recorded returns whether the step i was executed
+func (r *FlowResult) recorded(i int) bool {
+	return r.Steps[i].executed
+}
+
+// finish returns a copy of the result with the values at the end of the execution
+func (r *FlowResult) finish(values map[string]interface{}) *FlowResult {
+	result := *r
+	result.Steps = append([]StepSnapshot(nil), r.Steps...)
+	result.Completed = append([]int(nil), r.Completed...)
+	result.Skipped = nil
+	for i := range r.Steps {
+		if !r.recorded(i) {
+			result.Skipped = append(result.Skipped, i)
+		}
+	}
+	result.Values = make(map[string]interface{}, len(values))
+	for k, v := range values {
+		result.Values[k] = v
+	}
+	result.Elapsed = time.Since(r.started)
+	return &result
+}
@@ -0,0 +1,64 @@
+package httpsim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte("<token>t1</token>"))
+		case "/orders":
+			if r.Header.Get("Authorization") != "Bearer t1" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	token := Extractable{Name: "token", AfterThis: "<token>", BeforeThis: "</token>", MaxLength: -1, MinLength: -1}
+	f := Flow{Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/login?user={{.user}}", Method: "GET"},
+			KeysInput: []string{"user"}, KeysOutput: []Extracter{token}},
+		{Name: "orders", Request: Request{URL: srv.URL + "/orders", Method: "GET",
+			Header: http.Header{"Authorization": []string{"Bearer {{.token}}"}}}, KeysInput: []string{"token"}},
+		{Name: "mfa", Request: Request{URL: srv.URL + "/mfa", Method: "GET"}, Condition: "false"},
+	}}
+	result, err := f.ExecuteResult(context.Background(), map[string]interface{}{"user": "ann"})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 2}, result.Completed)
+	assert.Empty(t, result.Skipped)
+	assert.Equal(t, map[string]interface{}{"user": "ann", "token": "t1"}, result.Values)
+	assert.Equal(t, srv.URL+"/login?user=ann", result.Steps[0].Request.URL)
+	assert.Equal(t, map[string]interface{}{"token": "t1"}, result.Steps[0].Values)
+	assert.Equal(t, "Bearer t1", result.Steps[1].Request.Header.Get("Authorization"))
+	assert.Empty(t, result.Steps[1].Values)
+	assert.Greater(t, int64(result.Steps[1].Duration), int64(0))
+	assert.Equal(t, "<token>t1</token>", result.Step("login").Text())
+	assert.Nil(t, result.Step("mfa"))
+
+	// the definition isn't modified
+	assert.Nil(t, f.Values)
+	assert.Nil(t, f.Steps[0].Response)
+	assert.Equal(t, "Bearer {{.token}}", f.Steps[1].Request.Header.Get("Authorization"))
+
+	// failed executions have a result too
+	f.Steps[0].KeysOutput = nil
+	result, err = f.ExecuteResult(context.Background(), map[string]interface{}{"user": "ann"})
+	assert.NotNil(t, err)
+	assert.Equal(t, []int{0}, result.Completed)
+	assert.Equal(t, []int{2}, result.Skipped)
+	assert.Equal(t, err, result.Steps[1].Err)
+
+	// no result without steps executed
+	f.RequiredValues = []string{"user"}
+	result, err = f.ExecuteResult(context.Background(), nil)
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,104 @@
+package httpsim
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Retry executes a step again when its request fails, or the server answers
+// 5xx or 429. Only the idempotent methods (GET, PUT, DELETE...) are retried
+// unless NonIdempotent is set.
+type Retry struct {
+	// Attempts is the max number of retries
+	Attempts int
+	// Delay is the time before each retry
+	Delay time.Duration
+	// NonIdempotent retries the POST, PATCH... requests too, which the server
+	// may then process twice
+	NonIdempotent bool
+	// RefreshOnRetry are the values drawn again from the flow's Generators
+	// before each retry, e.g. nonces the server rejects when replayed
+	RefreshOnRetry []string
+}
+
+// idempotentMethod returns whether sending the request twice has the same
+// effect as once (the safe methods, PUT, DELETE)
+func idempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return safeMethod(method)
+}
+
+// retryStep executes the step, again while its Retry allows it
+func (f *Flow) retryStep(run *runState, i int, def *Step) error {
+	err := f.executeStep(run, i, def)
+	retry := def.Retry
+	if retry == nil || !retry.NonIdempotent && !idempotentMethod(def.Request.Method) {
+		return err
+	}
+	for attempt := 1; attempt <= retry.Attempts && err != nil && run.retryable(err); attempt++ {
+		if werr := wait(retry.Delay, run.ctx, run.requestCtx); werr != nil {
+			return err
+		}
+		f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: def.Name,
+			Message: fmt.Sprintf("retry: attempt %d of %d after: %s", attempt, retry.Attempts, err.Error())})
+		f.refreshValues(run, retry.RefreshOnRetry)
+		err = f.executeStep(run, i, def)
+	}
+	return err
+}
+
+// retryable returns whether the step's error may not happen again: its request
+// failed, or the server answered 5xx or 429
+func (run *runState) retryable(err error) bool {
+	if run.ctx.Err() != nil || run.requestCtx.Err() != nil {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
+	}
+	var budget *BudgetError
+	return run.phase == PhaseRequest && !errors.As(err, &budget)
+}
+
+// refreshValues draws the values again from the Generators, with the rand of
+// the execution so every refresh draws new values, reproduced with the seed
+func (f *Flow) refreshValues(run *runState, names []string) {
+	if run.generator == nil {
+		return
+	}
+	for _, name := range names {
+		if gen, ok := f.Generators[name]; ok {
+			f.Values[name] = gen.Generate(run.generator, f.Values)
+		}
+	}
+}
+
+// lintRetries warns about the retries that can't happen
+func (f *Flow) lintRetries() []LintWarning {
+	var warnings []LintWarning
+	for i, step := range f.Steps {
+		if step.Retry == nil {
+			continue
+		}
+		warn := func(format string, a ...interface{}) {
+			warnings = append(warnings, LintWarning{Step: i, StepName: step.Name,
+				Message: "retry: " + fmt.Sprintf(format, a...)})
+		}
+		if !step.Retry.NonIdempotent && !idempotentMethod(step.Request.Method) {
+			warn("%s request isn't idempotent, it's only retried with NonIdempotent", strings.ToUpper(step.Request.Method))
+		}
+		for _, name := range step.Retry.RefreshOnRetry {
+			if _, ok := f.Generators[name]; !ok {
+				warn("'%s' can't be refreshed, it has no generator", name)
+			}
+		}
+	}
+	return warnings
+}
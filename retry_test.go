@@ -0,0 +1,113 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Retry(t *testing.T) {
+	var failures int
+	var nonces []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, r.URL.Query().Get("nonce"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var n int
+	nonce := GeneratorFunc(func(r *rand.Rand, values map[string]interface{}) interface{} {
+		n++
+		return string(rune('a' + n - 1))
+	})
+	f := Flow{Generators: map[string]Generator{"nonce": nonce}, Steps: []Step{
+		{Name: "status", Request: Request{URL: srv.URL + "/status?nonce={{.nonce}}", Method: "GET"},
+			KeysInput: []string{"nonce"}, Retry: &Retry{Attempts: 3, RefreshOnRetry: []string{"nonce"}}},
+	}}
+	failures = 2
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Equal(t, []string{"a", "b", "c"}, nonces)
+	assert.Len(t, f.Warnings, 2)
+	assert.Contains(t, f.Warnings[0].Message, "retry: attempt 1 of 3 after: Step 0.'status' failed because of unexpected status 503")
+
+	// the attempts are exhausted
+	failures, nonces = 10, nil
+	err := f.ExecuteContext(context.Background(), nil)
+	var status *StatusError
+	assert.True(t, errors.As(err, &status))
+	assert.Len(t, nonces, 4)
+
+	// POST is only retried on demand
+	failures, nonces = 1, nil
+	f.Steps[0].Request.Method = "POST"
+	assert.NotNil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Len(t, nonces, 1)
+	assert.Equal(t, []LintWarning{{Step: 0, StepName: "status",
+		Message: "retry: POST request isn't idempotent, it's only retried with NonIdempotent"}}, f.Lint())
+
+	failures, nonces = 1, nil
+	f.Steps[0].Retry.NonIdempotent = true
+	assert.Nil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Len(t, nonces, 2)
+
+	// client errors aren't retried
+	failures, nonces = 0, nil
+	f.Steps[0].ExpectStatus = []string{"404"}
+	assert.NotNil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Len(t, nonces, 1)
+}
+
+func TestFlow_RetryRefreshSeeded(t *testing.T) {
+	var nonces []string
+	failed := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, r.URL.Query().Get("nonce"))
+		// each step's first attempt fails
+		if !failed[r.URL.Path] {
+			failed[r.URL.Path] = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	retry := &Retry{Attempts: 1, RefreshOnRetry: []string{"nonce"}}
+	f := Flow{GeneratorSeed: 42, Generators: map[string]Generator{"nonce": RandomInt(0, 1<<30)}, Steps: []Step{
+		{Name: "a", Request: Request{URL: srv.URL + "/a?nonce={{.nonce}}", Method: "GET"}, KeysInput: []string{"nonce"}, Retry: retry},
+		{Name: "b", Request: Request{URL: srv.URL + "/b?nonce={{.nonce}}", Method: "GET"}, KeysInput: []string{"nonce"}, Retry: retry},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	if !assert.Len(t, nonces, 4) {
+		return
+	}
+	// the initial nonce, then a fresh one for each step's retry
+	assert.Equal(t, nonces[1], nonces[2])
+	assert.NotEqual(t, nonces[0], nonces[1])
+	assert.NotEqual(t, nonces[1], nonces[3])
+	assert.NotEqual(t, nonces[0], nonces[3])
+
+	// reproduced with the seed
+	first := nonces
+	nonces, failed = nil, map[string]bool{}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, first, nonces)
+}
+
+func TestFlow_RetryRequestFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	f := Flow{Steps: []Step{{Name: "down", Request: Request{URL: url, Method: "PUT"},
+		Retry: &Retry{Attempts: 2, RefreshOnRetry: []string{"missing"}}}}}
+	assert.NotNil(t, f.ExecuteContext(context.Background(), nil))
+	assert.Len(t, f.Warnings, 2)
+	assert.Equal(t, "retry: 'missing' can't be refreshed, it has no generator", f.Lint()[0].Message)
+}
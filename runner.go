@@ -0,0 +1,188 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrRunnerClosed is returned by Runner.Run once the runner is shut down
+var ErrRunnerClosed = errors.New("httpsim: runner closed")
+
+// StepEvent reports the progress of an execution to Flow.OnStep
+type StepEvent struct {
+	Step     int
+	StepName string
+	// Done is false when the step starts, true once it's executed
+	Done bool
+	// Err is the step's error, once done
+	Err      error
+	Duration time.Duration
+}
+
+// CanceledError is returned when an execution is canceled before all its steps
+// are executed. The Values and Responses of the executed steps are kept.
+type CanceledError struct {
+	// Step is the index of the first step not executed, or whose requests
+	// were canceled
+	Step     int
+	StepName string
+	Err      error
+	// TeardownErr is the error of the Teardown steps, if any
+	TeardownErr error
+}
+
+func (e *CanceledError) Error() string {
+	msg := fmt.Sprintf("Step %d.'%s' not executed: %s", e.Step, e.StepName, e.Err.Error())
+	if e.TeardownErr != nil {
+		msg += ", teardown failed: " + e.TeardownErr.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the context's error
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// teardown executes the Teardown steps after the execution was canceled before
+// step i
+func (f *Flow) teardown(run *runState, i int, err error) error {
+	cerr := &CanceledError{Step: i, StepName: f.Steps[i].Name, Err: err}
+	cerr.TeardownErr = f.runTeardown(run)
+	return cerr
+}
+
+// runTeardown executes the Teardown steps of a halted execution, they aren't
+// canceled nor bound by the Deadline
+func (f *Flow) runTeardown(run *runState) error {
+	run.ctx, run.requestCtx = context.Background(), context.Background()
+	run.deadline = time.Time{}
+	for j := range f.Teardown {
+		if err := f.runStep(run, len(f.Steps)+j, &f.Teardown[j]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inFlightKey marks the contexts whose cancellation lets the requests in
+// flight complete, see Runner.Shutdown
+type inFlightKey struct{}
+
+// completesInFlight returns whether the requests in flight complete when ctx
+// is canceled
+func completesInFlight(ctx context.Context) bool {
+	v, _ := ctx.Value(inFlightKey{}).(bool)
+	return v
+}
+
+// Copy returns a copy of the flow's definition, to execute with options of its
+// own (e.g. OnStep)
+func (c *CompiledFlow) Copy() Flow {
+	return c.def.CompleteCopy()
+}
+
+// RunContext is Run, canceled with ctx (see Flow.ExecuteContext)
+func (c *CompiledFlow) RunContext(ctx context.Context, values map[string]interface{}) (*Flow, error) {
+	run := c.def.CompleteCopy()
+	vals := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		vals[k] = v
+	}
+	err := run.ExecuteContext(ctx, vals)
+	return &run, err
+}
+
+// Runner runs a compiled flow for a service, and shuts down cleanly: once Shutdown
+// is called, no run is started and the running ones stop before their next step.
+type Runner struct {
+	// Experiments are applied to shares of the runs, their results are segmented
+	// in Results. They must be set before the first Run.
+	Experiments []Experiment
+	// Rand picks the experiment of each run, seeded with the time when nil
+	Rand *rand.Rand
+
+	flow   *CompiledFlow
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+	results map[string]*ExperimentResult
+	tenants map[string]*tenantState
+}
+
+// NewRunner creates a runner of the compiled flow
+func NewRunner(flow *CompiledFlow) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{flow: flow, ctx: context.WithValue(ctx, inFlightKey{}, true), cancel: cancel}
+}
+
+// Run runs the flow with the values, see CompiledFlow.Run, applying one of the
+// Experiments to some runs. It returns ErrRunnerClosed once the runner is shut
+// down, and a *CanceledError with the partially executed flow when shut down
+// while running.
+func (r *Runner) Run(values map[string]interface{}) (*Flow, error) {
+	return r.run(values, nil)
+}
+
+// run runs the flow for the tenant, if any
+func (r *Runner) run(values map[string]interface{}, tenant *tenantState) (*Flow, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrRunnerClosed
+	}
+	r.running.Add(1)
+	r.mu.Unlock()
+	defer r.running.Done()
+
+	run := r.flow.Copy()
+	vals := make(map[string]interface{}, len(values))
+	if tenant != nil {
+		if err := tenant.start(&run, vals); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range values {
+		vals[k] = v
+	}
+	name := "control"
+	if exp := r.pickExperiment(); exp != nil {
+		name = exp.Name
+		exp.apply(&run, vals)
+	}
+	start := time.Now()
+	err := run.ExecuteContext(r.ctx, vals)
+	r.record(name, err, time.Since(start))
+	if tenant != nil {
+		tenant.keep(&run)
+	}
+	return &run, err
+}
+
+// Shutdown stops starting runs and steps, and waits for the running ones to
+// complete their current step and Teardown, or for ctx to be done
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,160 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteContextCanceled(t *testing.T) {
+	var logout bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logout" {
+			logout = true
+		}
+		w.Write([]byte("<b>ok</b>"))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := Flow{
+		Steps: []Step{
+			{
+				Name:       "login",
+				Request:    Request{URL: srv.URL + "/login", Method: "GET"},
+				KeysOutput: []Extracter{Extractable{Name: "login", AfterThis: "<b>", BeforeThis: "<", MaxLength: -1, MinLength: -1}},
+				PostHook: func(int, http.Header, []byte) error {
+					cancel()
+					return nil
+				},
+			},
+			{Name: "export", Request: Request{URL: srv.URL + "/export", Method: "GET"}},
+		},
+		Teardown: []Step{{Name: "logout", Request: Request{URL: srv.URL + "/logout", Method: "GET"}}},
+	}
+	err := f.ExecuteContext(ctx, nil)
+	assert.EqualError(t, err, "Step 1.'export' not executed: context canceled")
+	assert.True(t, errors.Is(err, context.Canceled))
+	// partial results are kept
+	assert.Equal(t, "ok", f.Values["login"])
+	assert.NotNil(t, f.Steps[0].Response)
+	assert.Nil(t, f.Steps[1].Response)
+	assert.True(t, logout)
+	assert.NotNil(t, f.Teardown[0].Response)
+
+	// teardown failures are reported
+	f.Teardown[0].ExpectStatus = []string{"404"}
+	err = f.ExecuteContext(ctx, nil)
+	assert.IsType(t, &CanceledError{}, err)
+	assert.Contains(t, err.Error(), "teardown failed: Step 2.'logout'")
+}
+
+func TestFlow_ExecuteContextCancelsRequests(t *testing.T) {
+	started := make(chan struct{})
+	var logout bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/export":
+			close(started)
+			<-r.Context().Done()
+		case "/logout":
+			logout = true
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Steps: []Step{
+			{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "GET"}},
+			{Name: "export", Request: Request{URL: srv.URL + "/export", Method: "GET"}},
+		},
+		Teardown: []Step{{Name: "logout", Request: Request{URL: srv.URL + "/logout", Method: "GET"}}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	err := f.ExecuteContext(ctx, nil)
+	assert.EqualError(t, err, "Step 1.'export' not executed: context canceled")
+	assert.NotNil(t, f.Steps[0].Response)
+	assert.Nil(t, f.Steps[1].Response)
+	// the teardown isn't canceled
+	assert.True(t, logout)
+}
+
+func TestRunner_Shutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{
+		{Name: "slow", Request: Request{URL: srv.URL + "/slow", Method: "GET"}},
+		{Name: "next", Request: Request{URL: srv.URL + "/next", Method: "GET"}},
+	}}
+	compiled, err := f.CompileFlow()
+	assert.Nil(t, err)
+	r := NewRunner(compiled)
+
+	type result struct {
+		f   *Flow
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		run, err := r.Run(nil)
+		results <- result{run, err}
+	}()
+	<-started
+
+	// the in-flight step isn't done yet
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, r.Shutdown(ctx))
+	_, err = r.Run(nil)
+	assert.Equal(t, ErrRunnerClosed, err)
+
+	close(release)
+	res := <-results
+	assert.IsType(t, &CanceledError{}, res.err)
+	assert.NotNil(t, res.f.Steps[0].Response)
+	assert.Nil(t, res.f.Steps[1].Response)
+	assert.Nil(t, r.Shutdown(context.Background()))
+}
+
+func TestFlow_ExecuteOnStep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var events []StepEvent
+	f := Flow{
+		Steps: []Step{
+			{Name: "ok", Request: Request{URL: srv.URL, Method: "GET"}},
+			{Name: "fail", Request: Request{URL: srv.URL + "/fail", Method: "GET"}},
+		},
+		OnStep: func(e StepEvent) { events = append(events, e) },
+	}
+	assert.NotNil(t, f.Execute(nil))
+	assert.Len(t, events, 4)
+	if len(events) == 4 {
+		assert.Equal(t, StepEvent{Step: 0, StepName: "ok"}, events[0])
+		assert.True(t, events[1].Done)
+		assert.Nil(t, events[1].Err)
+		assert.False(t, events[2].Done)
+		assert.IsType(t, &StatusError{}, events[3].Err)
+	}
+}
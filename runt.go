@@ -0,0 +1,44 @@
+package httpsim
+
+import (
+	"context"
+	"testing"
+)
+
+// RunT executes a copy of the flow in a go test, each step in a subtest named
+// after it (e.g. "01-login"). A failing step fails its subtest with its error
+// and logs its Failure report, the next steps aren't executed. The copy is
+// returned, so the flow may be run by parallel tests.
+func RunT(t *testing.T, flow *Flow, values map[string]interface{}) *Flow {
+	t.Helper()
+	run := flow.CompleteCopy()
+	vals := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		vals[k] = v
+	}
+	var stepFailed bool
+	err := run.execute(context.Background(), vals, func(i int, step func() error) error {
+		var err error
+		t.Run(snapshotKey(i, run.Steps[i].Name), func(t *testing.T) {
+			err = step()
+			for _, w := range run.Warnings {
+				if w.Step == i {
+					t.Log(w.String())
+				}
+			}
+			if err != nil {
+				if report := run.Failure(); report != nil {
+					t.Log(report.String())
+				}
+				t.Fatal(err)
+			}
+		})
+		stepFailed = err != nil
+		return err
+	})
+	if err != nil && !stepFailed {
+		// e.g. a missing value, before any step
+		t.Fatal(err)
+	}
+	return &run
+}
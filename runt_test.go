@@ -0,0 +1,35 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunT(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>" + r.URL.Path + "</b>"))
+	}))
+	defer srv.Close()
+
+	f := &Flow{Steps: []Step{
+		{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "GET"},
+			KeysOutput: []Extracter{Extractable{Name: "page", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}}},
+		{Name: "home", Request: Request{URL: srv.URL + "/home?from={{.page}}", Method: "GET"}, KeysInput: []string{"page"}},
+	}}
+	t.Run("parallel", func(t *testing.T) {
+		for _, name := range []string{"a", "b"} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				run := RunT(t, f, nil)
+				assert.Equal(t, "/login", run.Values["page"])
+				assert.NotNil(t, run.Steps[1].Response)
+			})
+		}
+	})
+	// the definition isn't executed
+	assert.Nil(t, f.Values)
+	assert.Nil(t, f.Steps[0].Response)
+}
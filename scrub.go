@@ -0,0 +1,115 @@
+package httpsim
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ScrubRule masks the matches of a pattern
+type ScrubRule struct {
+	Regexp *regexp.Regexp
+	// Check, if set, only masks the matches it returns true for (e.g. checksums)
+	Check func(match string) bool
+	// Mask replaces the matches, "***" when empty
+	Mask string
+}
+
+// The common personal data
+var (
+	ScrubEmails = ScrubRule{
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		Mask:   "***@***",
+	}
+	ScrubIBANs = ScrubRule{
+		Regexp: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`),
+		Check:  validIBAN,
+	}
+	ScrubCardNumbers = ScrubRule{
+		Regexp: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		Check:  validLuhn,
+	}
+)
+
+// Scrubber masks personal data in the stored artifacts (responses, snapshots,
+// audit log) so they can be shared and retained safely. Extraction still sees
+// the original body.
+type Scrubber struct {
+	Rules []ScrubRule
+}
+
+// NewScrubber creates a scrubber with the rules, the common ones (emails, IBANs,
+// card numbers) when none are given
+func NewScrubber(rules ...ScrubRule) *Scrubber {
+	if len(rules) == 0 {
+		rules = []ScrubRule{ScrubEmails, ScrubIBANs, ScrubCardNumbers}
+	}
+	return &Scrubber{Rules: rules}
+}
+
+// Scrub returns a masked copy of b, b itself when there's nothing to mask
+func (s *Scrubber) Scrub(b []byte) []byte {
+	if s == nil {
+		return b
+	}
+	for _, rule := range s.Rules {
+		mask := []byte(rule.Mask)
+		if rule.Mask == "" {
+			mask = []byte("***")
+		}
+		b = rule.Regexp.ReplaceAllFunc(b, func(m []byte) []byte {
+			if rule.Check != nil && !rule.Check(string(m)) {
+				return m
+			}
+			return mask
+		})
+	}
+	return b
+}
+
+// ScrubString returns a masked copy of str
+func (s *Scrubber) ScrubString(str string) string {
+	if s == nil {
+		return str
+	}
+	return string(s.Scrub([]byte(str)))
+}
+
+// validLuhn returns whether the digits of the number pass the Luhn checksum
+func validLuhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN returns whether the IBAN passes the mod 97 checksum
+func validIBAN(iban string) bool {
+	iban = strings.Replace(iban, " ", "", -1)
+	if len(iban) < 15 {
+		return false
+	}
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(c-'A') + 10))
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,63 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubber_Scrub(t *testing.T) {
+	s := NewScrubber()
+	tests := []struct {
+		in, want string
+	}{
+		{"contact: john.doe+bank@example.co.uk.", "contact: ***@***."},
+		{"IBAN GB82 WEST 1234 5698 7654 32 ok", "IBAN *** ok"},
+		{"iban=DE89370400440532013000&x=1", "iban=***&x=1"},
+		// bad checksums are kept
+		{"ref GB00 WEST 1234 5698 7654 32", "ref GB00 WEST 1234 5698 7654 32"},
+		{"card 4111 1111 1111 1111 exp", "card *** exp"},
+		{"card 4111-1111-1111-1111", "card ***"},
+		{"order 4111111111111112", "order 4111111111111112"},
+		{"nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, s.ScrubString(tt.in), tt.in)
+	}
+
+	custom := NewScrubber(ScrubRule{Regexp: regexp.MustCompile(`client-\d+`), Mask: "client-?"})
+	assert.Equal(t, "id client-? / a@b.io", custom.ScrubString("id client-42 / a@b.io"))
+
+	var none *Scrubber
+	assert.Equal(t, "a@b.io", none.ScrubString("a@b.io"))
+}
+
+func TestFlow_ExecuteScrubber(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<p id="mail">jane@example.com</p>`))
+	}))
+	defer srv.Close()
+
+	snaps := &MemorySnapshotStore{}
+	f := Flow{
+		Scrubber:  NewScrubber(),
+		Snapshots: snaps,
+		Steps: []Step{{
+			Name:    "profile",
+			Request: Request{URL: srv.URL, Method: "GET"},
+			KeysOutput: []Extracter{
+				Extractable{Name: "mail", AfterThis: `id="mail">`, BeforeThis: "<", MaxLength: -1, MinLength: -1},
+			},
+		}},
+	}
+	assert.Nil(t, f.Execute(nil))
+	// extraction sees the real body, the artifacts don't
+	assert.Equal(t, "jane@example.com", f.Values["mail"])
+	assert.Equal(t, `<p id="mail">***@***</p>`, string(f.Steps[0].Response.Body))
+	snap, err := snaps.Load(snapshotKey(0, "profile"))
+	assert.Nil(t, err)
+	assert.Equal(t, `<p id="mail">***@***</p>`, string(snap))
+}
@@ -0,0 +1,289 @@
+package httpsim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cssSelector is a parsed group of CSS selectors, matching the elements any of
+// them matches. Type, universal, #id, .class, [attr] (=, ~=, |=, ^=, $=, *=)
+// selectors, the :first-child, :last-child and :nth-child(n) pseudo-classes and
+// the descendant, >, + and ~ combinators are supported.
+type cssSelector [][]cssCompound
+
+// cssCompound is a compound selector e.g. `input.big[name=csrf]`, along with the
+// combinator relating it to the previous one
+type cssCompound struct {
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	attrs      []cssAttr
+	// nth is the position among its parent's elements, 1-based, -1 the last
+	nth int
+}
+
+// cssAttr is an attribute selector, op is "" when testing the presence only
+type cssAttr struct {
+	name, op, value string
+}
+
+var selectorCache cache
+
+// compileSelector parses the selector once and returns it from cache afterwards
+func compileSelector(selector string) (cssSelector, error) {
+	if s, ok := selectorCache.get(selector); ok {
+		return s.(cssSelector), nil
+	}
+	s, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	selectorCache.put(selector, s)
+	return s, nil
+}
+
+// parseSelector parses the group of selectors
+func parseSelector(selector string) (cssSelector, error) {
+	fail := func(format string, args ...interface{}) (cssSelector, error) {
+		return nil, fmt.Errorf("invalid selector '%s': %s", selector, fmt.Sprintf(format, args...))
+	}
+	var (
+		group     cssSelector
+		compounds []cssCompound
+		pending   byte
+		s         = selector
+		readName  = func() string {
+			i := 0
+			for i < len(s) && (isHTMLNameChar(s[i]) && s[i] != ':' || s[i] >= 0x80) {
+				i++
+			}
+			name := s[:i]
+			s = s[i:]
+			return name
+		}
+	)
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f")
+		if s == "" || s[0] == ',' {
+			if len(compounds) == 0 || pending != 0 && pending != ' ' {
+				return fail("empty selector")
+			}
+			group = append(group, compounds)
+			if s == "" {
+				return group, nil
+			}
+			s = s[1:]
+			compounds, pending = nil, 0
+			continue
+		}
+		if s[0] == '>' || s[0] == '+' || s[0] == '~' {
+			if len(compounds) == 0 || pending != 0 && pending != ' ' {
+				return fail("unexpected '%c'", s[0])
+			}
+			pending = s[0]
+			s = s[1:]
+			continue
+		}
+
+		c := cssCompound{combinator: pending}
+		if s[0] == '*' {
+			s = s[1:]
+		} else {
+			c.tag = strings.ToLower(readName())
+		}
+		for s != "" && strings.IndexByte(" \t\r\n\f,>+~", s[0]) == -1 {
+			switch s[0] {
+			case '#', '.':
+				kind := s[0]
+				s = s[1:]
+				name := readName()
+				if name == "" {
+					return fail("missing name after '%c'", kind)
+				}
+				if kind == '#' {
+					c.id = name
+				} else {
+					c.classes = append(c.classes, name)
+				}
+			case '[':
+				end := strings.IndexByte(s, ']')
+				if end == -1 {
+					return fail("unclosed [")
+				}
+				attr, ok := parseCSSAttr(s[1:end])
+				if !ok {
+					return fail("invalid attribute selector '%s'", s[:end+1])
+				}
+				c.attrs = append(c.attrs, attr)
+				s = s[end+1:]
+			case ':':
+				s = s[1:]
+				switch name := strings.ToLower(readName()); name {
+				case "first-child":
+					c.nth = 1
+				case "last-child":
+					c.nth = -1
+				case "nth-child":
+					end := strings.IndexByte(s, ')')
+					if !strings.HasPrefix(s, "(") || end == -1 {
+						return fail("missing :nth-child position")
+					}
+					n, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+					if err != nil || n < 1 {
+						return fail("invalid :nth-child position '%s'", s[1:end])
+					}
+					c.nth = n
+					s = s[end+1:]
+				default:
+					return fail("unsupported pseudo-class ':%s'", name)
+				}
+			default:
+				return fail("unexpected '%c'", s[0])
+			}
+		}
+		compounds = append(compounds, c)
+		pending = ' '
+	}
+}
+
+// parseCSSAttr parses the inside of an attribute selector e.g. `name="csrf"`
+func parseCSSAttr(s string) (cssAttr, bool) {
+	eq := strings.IndexByte(s, '=')
+	if eq == -1 {
+		name := strings.ToLower(strings.TrimSpace(s))
+		return cssAttr{name: name}, name != ""
+	}
+	attr := cssAttr{op: "="}
+	name := s[:eq]
+	if eq > 0 && strings.IndexByte("~|^$*", s[eq-1]) != -1 {
+		attr.op = s[eq-1 : eq+1]
+		name = s[:eq-1]
+	}
+	attr.name = strings.ToLower(strings.TrimSpace(name))
+	value := strings.TrimSpace(s[eq+1:])
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	} else if strings.ContainsAny(value, "\"' ") {
+		return attr, false
+	}
+	attr.value = value
+	return attr, attr.name != ""
+}
+
+// match returns whether the element matches the selector
+func (s cssSelector) match(n *htmlNode) bool {
+	for _, compounds := range s {
+		if matchComplex(n, compounds) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchComplex matches the element against the last compound, and its
+// ancestors or siblings against the previous ones
+func matchComplex(n *htmlNode, compounds []cssCompound) bool {
+	last := compounds[len(compounds)-1]
+	if !last.match(n) {
+		return false
+	}
+	if len(compounds) == 1 {
+		return true
+	}
+	rest := compounds[:len(compounds)-1]
+	switch last.combinator {
+	case '>':
+		return n.Parent != nil && n.Parent.Tag != "#document" && matchComplex(n.Parent, rest)
+	case '+':
+		siblings, i := n.elementSiblings()
+		return i > 0 && matchComplex(siblings[i-1], rest)
+	case '~':
+		siblings, i := n.elementSiblings()
+		for _, sibling := range siblings[:i] {
+			if matchComplex(sibling, rest) {
+				return true
+			}
+		}
+		return false
+	}
+	for p := n.Parent; p != nil && p.Tag != "#document"; p = p.Parent {
+		if matchComplex(p, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns whether the element matches the compound selector
+func (c cssCompound) match(n *htmlNode) bool {
+	if c.tag != "" && n.Tag != c.tag || c.id != "" && n.Attrs["id"] != c.id {
+		return false
+	}
+	for _, class := range c.classes {
+		if !n.hasClass(class) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		if !a.match(n) {
+			return false
+		}
+	}
+	if c.nth != 0 {
+		siblings, i := n.elementSiblings()
+		if c.nth > 0 && i != c.nth-1 || c.nth < 0 && i != len(siblings)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns whether the element's attribute matches
+func (a cssAttr) match(n *htmlNode) bool {
+	v, ok := n.Attrs[a.name]
+	if !ok {
+		return false
+	}
+	switch a.op {
+	case "=":
+		return v == a.value
+	case "~=":
+		for _, f := range strings.Fields(v) {
+			if f == a.value {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return v == a.value || strings.HasPrefix(v, a.value+"-")
+	case "^=":
+		return a.value != "" && strings.HasPrefix(v, a.value)
+	case "$=":
+		return a.value != "" && strings.HasSuffix(v, a.value)
+	case "*=":
+		return a.value != "" && strings.Contains(v, a.value)
+	}
+	return true
+}
+
+// elementSiblings returns the elements of the node's parent and the node's index
+// among them
+func (n *htmlNode) elementSiblings() ([]*htmlNode, int) {
+	if n.Parent == nil {
+		return []*htmlNode{n}, 0
+	}
+	var siblings []*htmlNode
+	at := -1
+	for _, c := range n.Parent.Children {
+		if c.Tag == "" {
+			continue
+		}
+		if c == n {
+			at = len(siblings)
+		}
+		siblings = append(siblings, c)
+	}
+	return siblings, at
+}
@@ -0,0 +1,69 @@
+package httpsim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelector(t *testing.T) {
+	doc := parseHTML(`<html><body>
+		<div id="login" class="box main">
+			<form action="/login"><input type="hidden" name="csrf" value="tok"><input name="user" data-x="a-b"></form>
+			<p>first</p><p class="note">second</p><span>third</span>
+		</div>
+		<ul><li>one</li><li>two</li><li lang="en-US">three</li></ul>
+	</body></html>`)
+
+	cases := []struct {
+		selector string
+		matches  []string
+	}{
+		{"input", []string{"csrf", "user"}},
+		{"input[name=csrf]", []string{"csrf"}},
+		{`input[name="user"]`, []string{"user"}},
+		{"input[type]", []string{"csrf"}},
+		{"input[data-x^=a]", []string{"user"}},
+		{"input[data-x$='b']", []string{"user"}},
+		{"input[data-x*=-]", []string{"user"}},
+		{"#login form > input:first-child", []string{"csrf"}},
+		{"div.box.main input:last-child", []string{"user"}},
+		{"div > input", nil},
+		{"p.note", []string{"second"}},
+		{"p + p", []string{"second"}},
+		{"p ~ span", []string{"third"}},
+		{"li:nth-child(2), li[lang|=en]", []string{"two", "three"}},
+		{"*.main", []string{"first second third"}},
+		{"table td", nil},
+	}
+	for _, c := range cases {
+		s, err := parseSelector(c.selector)
+		assert.Nil(t, err, c.selector)
+		var matches []string
+		for _, n := range doc.find(s.match) {
+			if name, ok := n.Attrs["name"]; ok {
+				matches = append(matches, name)
+			} else {
+				matches = append(matches, n.text())
+			}
+		}
+		assert.Equal(t, c.matches, matches, c.selector)
+	}
+
+	for selector, msg := range map[string]string{
+		"":                "empty selector",
+		"a,":              "empty selector",
+		"a >":             "empty selector",
+		"> a":             "unexpected '>'",
+		"a > > b":         "unexpected '>'",
+		"input[name=a":    "unclosed [",
+		"input[=a]":       "invalid attribute selector '[=a]'",
+		"a:hover":         "unsupported pseudo-class ':hover'",
+		"li:nth-child(x)": "invalid :nth-child position 'x'",
+		"div.":            "missing name after '.'",
+		"a)":              "unexpected ')'",
+	} {
+		_, err := parseSelector(selector)
+		assert.EqualError(t, err, "invalid selector '"+selector+"': "+msg)
+	}
+}
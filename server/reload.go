@@ -0,0 +1,54 @@
+package server
+
+import (
+	"os"
+	"time"
+
+	"github.com/gee-m/httpsim"
+)
+
+// Loader loads and validates the flow definition file at path
+type Loader func(path string) (*httpsim.CompiledFlow, error)
+
+// Watch registers the flow loaded from the file under name, then reloads it
+// whenever the file changes (checked every interval) until Shutdown. Runs
+// already started keep the definition they started with. A definition that
+// fails to load keeps the previous one registered. OnReload, if set, is called
+// after every reload, it must be set before.
+func (s *Server) Watch(name, path string, load Loader, interval time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	flow, err := load(path)
+	if err != nil {
+		return err
+	}
+	s.Register(name, flow)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		modTime, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.ctx.Done():
+				return
+			}
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+			flow, err := load(path)
+			if err == nil {
+				s.Register(name, flow)
+			}
+			if s.OnReload != nil {
+				s.OnReload(name, err)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gee-m/httpsim"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadURL loads a flow getting the URL the file contains
+func loadURL(path string) (*httpsim.CompiledFlow, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(url, "http") {
+		return nil, errors.New("invalid URL")
+	}
+	f := httpsim.Flow{Steps: []httpsim.Step{{Name: "get", Request: httpsim.Request{URL: url, Method: "GET"}}}}
+	return f.CompileFlow()
+}
+
+func TestServer_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flow.txt")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("http://a.invalid"), 0644))
+
+	s := New()
+	defer s.Shutdown(context.Background())
+	reloads := make(chan error, 10)
+	s.OnReload = func(name string, err error) {
+		assert.Equal(t, "get", name)
+		reloads <- err
+	}
+	assert.NotNil(t, s.Watch("other", filepath.Join(dir, "nope"), loadURL, time.Millisecond))
+	assert.Nil(t, s.Watch("get", path, loadURL, time.Millisecond))
+	url := func() string {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.flows["get"].Copy().Steps[0].Request.URL
+	}
+	assert.Equal(t, "http://a.invalid", url())
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("http://bb.invalid"), 0644))
+	assert.Nil(t, <-reloads)
+	assert.Equal(t, "http://bb.invalid", url())
+
+	// an invalid definition keeps the previous one
+	assert.Nil(t, ioutil.WriteFile(path, []byte("not a flow"), 0644))
+	assert.NotNil(t, <-reloads)
+	assert.Equal(t, "http://bb.invalid", url())
+}
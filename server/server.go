@@ -0,0 +1,412 @@
+// Package server exposes httpsim flows over HTTP: list the registered flows,
+// trigger runs with values, stream their progress (server-sent events) and
+// fetch their results.
+//
+//	GET  /flows                 the flows and their descriptions
+//	POST /flows?name={name}     registers the flow of the YAML or JSON definition
+//	                            in the body (see httpsim.LoadFlow)
+//	POST /flows/{name}/runs     runs the flow with {"values": {...}}, returns {"id": ...}
+//	GET  /runs/{id}             the run's status and results
+//	GET  /runs/{id}/events      the run's progress, as server-sent events
+//	GET  /runs/{id}/har         the run's requests and responses, as a HAR
+//
+// Flows with extracters or hooks in code are registered programmatically.
+// Flows loaded from definition files can be watched and hot-reloaded between
+// runs, see Watch. The finished runs are kept up to RunTTL, and the MaxRuns
+// most recent ones.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gee-m/httpsim"
+)
+
+// Server runs the registered flows for HTTP clients, it's an http.Handler
+type Server struct {
+	// OnReload, if set, is called after a watched flow is reloaded, with the
+	// error that kept the previous definition if any (see Watch)
+	OnReload func(name string, err error)
+	// MaxRuns is the number of finished runs kept, the oldest ones are
+	// forgotten. 1000 by default, unlimited when negative.
+	MaxRuns int
+	// RunTTL, if set, is how long finished runs are kept
+	RunTTL time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	flows   map[string]*httpsim.CompiledFlow
+	runs    map[string]*run
+	lastID  int
+	closed  bool
+	running sync.WaitGroup
+}
+
+// New creates a server without flows
+func New() *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{ctx: ctx, cancel: cancel, flows: map[string]*httpsim.CompiledFlow{}, runs: map[string]*run{},
+		MaxRuns: defaultMaxRuns}
+}
+
+// defaultMaxRuns is the number of finished runs kept by default
+const defaultMaxRuns = 1000
+
+// maxDefinitionSize is the max size of the definitions and run values posted
+const maxDefinitionSize = 1 << 20
+
+// Register registers the flow under the name, replacing any flow of that name
+func (s *Server) Register(name string, flow *httpsim.CompiledFlow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flows[name] = flow
+}
+
+// Shutdown stops accepting runs, cancels the running ones (see
+// httpsim.Flow.ExecuteContext) and waits for them, or for ctx to be done
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Event is a progress event of a run
+type Event struct {
+	// Type is "step" for a step's progress, "done" once the run is over
+	Type     string `json:"type"`
+	Step     int    `json:"step"`
+	StepName string `json:"step_name,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Duration is in milliseconds
+	Duration int64 `json:"duration_ms,omitempty"`
+}
+
+// Result is the status and results of a run
+type Result struct {
+	ID   string `json:"id"`
+	Flow string `json:"flow"`
+	// Status is "running", "succeeded" or "failed"
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Values are the flow's values, without its SensitiveValues
+	Values   map[string]interface{} `json:"values,omitempty"`
+	Warnings []string               `json:"warnings,omitempty"`
+	Started  time.Time              `json:"started"`
+	Ended    *time.Time             `json:"ended,omitempty"`
+}
+
+// run is a run of a flow, its events are kept for late subscribers
+type run struct {
+	mu      sync.Mutex
+	result  Result
+	events  []Event
+	changed chan struct{}
+	// har is the exported HAR once the run is over, or the error exporting it
+	har    []byte
+	harErr error
+	id     int
+}
+
+func (r *run) publish(e Event, update func(*Result)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	if update != nil {
+		update(&r.result)
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// since returns the events from the nth, whether the run is over, and a channel
+// closed on the next event
+func (r *run) since(n int) ([]Event, bool, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events[n:]...), r.result.Status != "running", r.changed
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "flows" && req.Method == http.MethodGet:
+		s.listFlows(w)
+	case len(parts) == 1 && parts[0] == "flows" && req.Method == http.MethodPost:
+		s.registerDefinition(w, req)
+	case len(parts) == 3 && parts[0] == "flows" && parts[2] == "runs" && req.Method == http.MethodPost:
+		s.startRun(w, req, parts[1])
+	case len(parts) == 2 && parts[0] == "runs" && req.Method == http.MethodGet:
+		s.getRun(w, parts[1])
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "events" && req.Method == http.MethodGet:
+		s.streamEvents(w, req, parts[1])
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "har" && req.Method == http.MethodGet:
+		s.getHAR(w, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (s *Server) listFlows(w http.ResponseWriter) {
+	s.mu.Lock()
+	var names []string
+	for name := range s.flows {
+		names = append(names, name)
+	}
+	flows := make(map[string]*httpsim.CompiledFlow, len(s.flows))
+	for k, v := range s.flows {
+		flows[k] = v
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	type flowInfo struct {
+		Name        string                  `json:"name"`
+		Description httpsim.FlowDescription `json:"description"`
+	}
+	list := []flowInfo{}
+	for _, name := range names {
+		def := flows[name].Copy()
+		list = append(list, flowInfo{Name: name, Description: def.Describe()})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) registerDefinition(w http.ResponseWriter, req *http.Request) {
+	name := req.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "missing name")
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxDefinitionSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+	f, err := httpsim.LoadFlow(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	compiled, err := f.CompileFlow()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.Register(name, compiled)
+	writeJSON(w, http.StatusCreated, map[string]string{"name": name})
+}
+
+func (s *Server) startRun(w http.ResponseWriter, req *http.Request, name string) {
+	var body struct {
+		Values map[string]interface{} `json:"values"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxDefinitionSize)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid body: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	compiled, ok := s.flows[name]
+	if !ok {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no flow '%s'", name))
+		return
+	}
+	if s.closed {
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	s.lastID++
+	r := &run{
+		result:  Result{ID: strconv.Itoa(s.lastID), Flow: name, Status: "running", Started: time.Now().UTC()},
+		changed: make(chan struct{}),
+		id:      s.lastID,
+	}
+	s.evict(time.Now())
+	s.runs[r.result.ID] = r
+	s.running.Add(1)
+	s.mu.Unlock()
+
+	f := compiled.Copy()
+	f.OnStep = func(e httpsim.StepEvent) {
+		ev := Event{Type: "step", Step: e.Step, StepName: e.StepName, Done: e.Done}
+		if e.Err != nil {
+			ev.Error = e.Err.Error()
+		}
+		if e.Done {
+			ev.Duration = e.Duration.Nanoseconds() / int64(time.Millisecond)
+		}
+		r.publish(ev, nil)
+	}
+	go func() {
+		defer s.running.Done()
+		err := f.ExecuteContext(s.ctx, body.Values)
+		har, harErr := f.ExportHAR()
+		r.mu.Lock()
+		r.har, r.harErr = har, harErr
+		r.mu.Unlock()
+		ev := Event{Type: "done"}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		r.publish(ev, func(res *Result) {
+			res.Status = "succeeded"
+			if err != nil {
+				res.Status, res.Error = "failed", err.Error()
+			}
+			ended := time.Now().UTC()
+			res.Ended = &ended
+			res.Values = publicValues(&f)
+			for _, warning := range f.Warnings {
+				res.Warnings = append(res.Warnings, warning.String())
+			}
+		})
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": r.result.ID})
+}
+
+// publicValues returns the flow's values without its SensitiveValues
+func publicValues(f *httpsim.Flow) map[string]interface{} {
+	values := make(map[string]interface{}, len(f.Values))
+	for k, v := range f.Values {
+		values[k] = v
+	}
+	for _, k := range f.SensitiveValues {
+		delete(values, k)
+	}
+	return values
+}
+
+// evict forgets the finished runs older than RunTTL, and the oldest ones past
+// MaxRuns. s.mu is held.
+func (s *Server) evict(now time.Time) {
+	var finished []*run
+	for id, r := range s.runs {
+		r.mu.Lock()
+		ended := r.result.Ended
+		r.mu.Unlock()
+		if ended == nil {
+			continue
+		}
+		if s.RunTTL > 0 && now.Sub(*ended) > s.RunTTL {
+			delete(s.runs, id)
+			continue
+		}
+		finished = append(finished, r)
+	}
+	if s.MaxRuns < 0 || len(finished) <= s.MaxRuns {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].id < finished[j].id })
+	for _, r := range finished[:len(finished)-s.MaxRuns] {
+		delete(s.runs, r.result.ID)
+	}
+}
+
+func (s *Server) lookup(id string) *run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[id]
+}
+
+func (s *Server) getRun(w http.ResponseWriter, id string) {
+	r := s.lookup(id)
+	if r == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no run '%s'", id))
+		return
+	}
+	r.mu.Lock()
+	res := r.result
+	r.mu.Unlock()
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getHAR(w http.ResponseWriter, id string) {
+	r := s.lookup(id)
+	if r == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no run '%s'", id))
+		return
+	}
+	r.mu.Lock()
+	har, err, running := r.har, r.harErr, r.result.Status == "running"
+	r.mu.Unlock()
+	switch {
+	case running:
+		writeError(w, http.StatusConflict, "the run isn't over")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "couldn't export HAR: "+err.Error())
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(har)
+	}
+}
+
+func (s *Server) streamEvents(w http.ResponseWriter, req *http.Request, id string) {
+	r := s.lookup(id)
+	if r == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no run '%s'", id))
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	sent := 0
+	for {
+		events, over, changed := r.since(sent)
+		for _, e := range events {
+			b, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, b)
+		}
+		sent += len(events)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if over {
+			return
+		}
+		select {
+		case <-changed:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gee-m/httpsim"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T, target string) *httptest.Server {
+	f := httpsim.Flow{
+		RequiredValues:  []string{"user", "password"},
+		SensitiveValues: []string{"password"},
+		Steps: []httpsim.Step{
+			{
+				Name:      "login",
+				Request:   httpsim.Request{URL: target + "/login", Method: "POST", Body: "{{.user}}:{{.password}}"},
+				KeysInput: []string{"user", "password"},
+				KeysOutput: []httpsim.Extracter{
+					httpsim.Extractable{Name: "greeting", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1},
+				},
+			},
+		},
+	}
+	compiled, err := f.CompileFlow()
+	assert.Nil(t, err)
+	s := New()
+	s.Register("login", compiled)
+	return httptest.NewServer(s)
+}
+
+func TestServer_Run(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>hello</b>"))
+	}))
+	defer target.Close()
+	srv := newTestServer(t, target.URL)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/flows")
+	assert.Nil(t, err)
+	var flows []struct {
+		Name        string
+		Description httpsim.FlowDescription
+	}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&flows))
+	resp.Body.Close()
+	assert.Len(t, flows, 1)
+	assert.Equal(t, "login", flows[0].Name)
+	assert.Len(t, flows[0].Description.Required, 2)
+
+	resp, err = http.Post(srv.URL+"/flows/login/runs", "application/json",
+		strings.NewReader(`{"values":{"user":"bob","password":"secret"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var started struct{ ID string }
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&started))
+	resp.Body.Close()
+
+	// the events are streamed until the run is over
+	resp, err = http.Get(srv.URL + "/runs/" + started.ID + "/events")
+	assert.Nil(t, err)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	var types []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "event: ") {
+			types = append(types, strings.TrimPrefix(sc.Text(), "event: "))
+		}
+	}
+	resp.Body.Close()
+	assert.Equal(t, []string{"step", "step", "done"}, types)
+
+	resp, err = http.Get(srv.URL + "/runs/" + started.ID)
+	assert.Nil(t, err)
+	var res Result
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&res))
+	resp.Body.Close()
+	assert.Equal(t, "succeeded", res.Status)
+	assert.Equal(t, "hello", res.Values["greeting"])
+	assert.Equal(t, "bob", res.Values["user"])
+	_, leaked := res.Values["password"]
+	assert.False(t, leaked)
+	assert.NotNil(t, res.Ended)
+}
+
+func TestServer_Errors(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+	srv := newTestServer(t, target.URL)
+	defer srv.Close()
+
+	for path, status := range map[string]int{"/runs/42": 404, "/runs/42/events": 404, "/nope": 404} {
+		resp, err := http.Get(srv.URL + path)
+		assert.Nil(t, err)
+		assert.Equal(t, status, resp.StatusCode, path)
+		resp.Body.Close()
+	}
+	resp, err := http.Post(srv.URL+"/flows/nope/runs", "application/json", strings.NewReader(`{}`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, err = http.Post(srv.URL+"/flows/login/runs", "application/json", strings.NewReader(`{`))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	big := `{"values":{"user":"` + strings.Repeat("a", maxDefinitionSize) + `"}}`
+	resp, err = http.Post(srv.URL+"/flows/login/runs", "application/json", strings.NewReader(big))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var e struct{ Error string }
+	json.NewDecoder(resp.Body).Decode(&e)
+	resp.Body.Close()
+	assert.Contains(t, e.Error, "too large")
+
+	// failed run
+	resp, err = http.Post(srv.URL+"/flows/login/runs", "application/json", strings.NewReader(`{"values":{"user":"bob","password":"x"}}`))
+	assert.Nil(t, err)
+	var started struct{ ID string }
+	json.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	var res Result
+	for i := 0; i < 100 && res.Status != "failed"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		resp, err = http.Get(srv.URL + "/runs/" + started.ID)
+		assert.Nil(t, err)
+		json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+	}
+	assert.Equal(t, "failed", res.Status)
+	assert.Contains(t, res.Error, "unexpected status 500")
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	s := New()
+	assert.Nil(t, s.Shutdown(context.Background()))
+	f := httpsim.Flow{}
+	compiled, _ := f.CompileFlow()
+	s.Register("empty", compiled)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("POST", "/flows/empty/runs", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+// waitRun polls the run until it's over
+func waitRun(t *testing.T, url, id string) Result {
+	var res Result
+	for i := 0; i < 100 && res.Status != "succeeded" && res.Status != "failed"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Get(url + "/runs/" + id)
+		assert.Nil(t, err)
+		json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+	}
+	return res
+}
+
+func TestServer_Definition(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>hello</b>"))
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	for def, status := range map[string]int{
+		"steps:\n  - name: home\n    url: '{{.site'\n": http.StatusBadRequest,
+		"steps: [": http.StatusBadRequest,
+	} {
+		resp, err := http.Post(srv.URL+"/flows?name=home", "application/yaml", strings.NewReader(def))
+		assert.Nil(t, err)
+		assert.Equal(t, status, resp.StatusCode, def)
+		resp.Body.Close()
+	}
+	resp, err := http.Post(srv.URL+"/flows", "application/yaml", strings.NewReader("steps: []"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	def := `required_values: [site]
+steps:
+  - name: home
+    url: "{{.site}}/home"
+    inputs: [site]
+    extract:
+      - name: greeting
+        after: <b>
+        before: </b>
+`
+	resp, err = http.Post(srv.URL+"/flows?name=home", "application/yaml", strings.NewReader(def))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Post(srv.URL+"/flows/home/runs", "application/json",
+		strings.NewReader(`{"values":{"site":"`+target.URL+`"}}`))
+	assert.Nil(t, err)
+	var started struct{ ID string }
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&started))
+	resp.Body.Close()
+	res := waitRun(t, srv.URL, started.ID)
+	assert.Equal(t, "succeeded", res.Status)
+	assert.Equal(t, "hello", res.Values["greeting"])
+
+	resp, err = http.Get(srv.URL + "/runs/" + started.ID + "/har")
+	assert.Nil(t, err)
+	var har struct {
+		Log struct {
+			Entries []struct {
+				Request struct{ URL string }
+			}
+		}
+	}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&har))
+	resp.Body.Close()
+	if assert.Len(t, har.Log.Entries, 1) {
+		assert.Equal(t, target.URL+"/home", har.Log.Entries[0].Request.URL)
+	}
+}
+
+func TestServer_EvictRuns(t *testing.T) {
+	s := New()
+	s.MaxRuns = 1
+	f := httpsim.Flow{}
+	compiled, _ := f.CompileFlow()
+	s.Register("empty", compiled)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		resp, err := http.Post(srv.URL+"/flows/empty/runs", "application/json", strings.NewReader(`{}`))
+		assert.Nil(t, err)
+		var started struct{ ID string }
+		json.NewDecoder(resp.Body).Decode(&started)
+		resp.Body.Close()
+		assert.Equal(t, "succeeded", waitRun(t, srv.URL, started.ID).Status)
+		ids = append(ids, started.ID)
+	}
+	// the last finished run is kept along with the one just started
+	for id, status := range map[string]int{ids[0]: 404, ids[1]: 200, ids[2]: 200} {
+		resp, err := http.Get(srv.URL + "/runs/" + id)
+		assert.Nil(t, err)
+		assert.Equal(t, status, resp.StatusCode, id)
+		resp.Body.Close()
+	}
+
+	s.mu.Lock()
+	s.RunTTL = time.Nanosecond
+	s.mu.Unlock()
+	resp, err := http.Post(srv.URL+"/flows/empty/runs", "application/json", strings.NewReader(`{}`))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	resp, err = http.Get(srv.URL + "/runs/" + ids[2])
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+}
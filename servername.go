@@ -0,0 +1,108 @@
+package httpsim
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// PeerVerifier verifies the certificates presented by a server, see
+// tls.Config.VerifyPeerCertificate
+type PeerVerifier func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// VerifyCertificateFor returns a PeerVerifier accepting the chains valid (with
+// the system roots) for one of the names, e.g. the domain fronting the request's host
+func VerifyCertificateFor(names ...string) PeerVerifier {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no certificate")
+		}
+		var certs []*x509.Certificate
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		var err error
+		for _, name := range names {
+			if _, err = certs[0].Verify(x509.VerifyOptions{DNSName: name, Intermediates: intermediates}); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("certificate of %s isn't valid for %v: %v", certs[0].Subject, names, err)
+	}
+}
+
+// errTLSTransport is returned when the TLS of a transport that isn't an
+// *http.Transport has to be overridden
+var errTLSTransport = errors.New("TLSServerName and VerifyPeerCertificate need the flow's Transport to be an *http.Transport")
+
+// tlsOverride makes the transports of the server names overriding the URL hosts'
+type tlsOverride struct {
+	base       *http.Transport
+	serverName string
+	verify     PeerVerifier
+	// transports are by server name, "" for the URL host
+	transports map[string]*http.Transport
+}
+
+// newTLSOverride returns nil when the flow doesn't override the TLS of its requests
+func (f *Flow) newTLSOverride(transport http.RoundTripper) (*tlsOverride, error) {
+	overrides := f.TLSServerName != "" || f.VerifyPeerCertificate != nil
+	for _, steps := range [][]Step{f.Steps, f.Teardown} {
+		for _, step := range steps {
+			overrides = overrides || step.TLSServerName != ""
+		}
+	}
+	if !overrides {
+		return nil, nil
+	}
+	base, ok := transport.(*http.Transport)
+	if transport == nil {
+		base, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if !ok {
+		return nil, errTLSTransport
+	}
+	return &tlsOverride{base: base, serverName: f.TLSServerName, verify: f.VerifyPeerCertificate,
+		transports: map[string]*http.Transport{}}, nil
+}
+
+// transport returns the transport connecting with the server name
+func (o *tlsOverride) transport(serverName string) *http.Transport {
+	if t, ok := o.transports[serverName]; ok {
+		return t
+	}
+	t := o.base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	if serverName != "" {
+		t.TLSClientConfig.ServerName = serverName
+	}
+	if o.verify != nil {
+		// the hook replaces the standard verification
+		t.TLSClientConfig.InsecureSkipVerify = true
+		t.TLSClientConfig.VerifyPeerCertificate = o.verify
+	}
+	o.transports[serverName] = t
+	return t
+}
+
+// close closes the idle connections of the transports, they're the run's
+func (o *tlsOverride) close() {
+	if o == nil {
+		return
+	}
+	for _, t := range o.transports {
+		t.CloseIdleConnections()
+	}
+}
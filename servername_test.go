@@ -0,0 +1,63 @@
+package httpsim
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_TLSServerName(t *testing.T) {
+	var mu sync.Mutex
+	var names []string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		names = append(names, hello.ServerName)
+		return nil, nil
+	}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// the test certificate is for example.com and 127.0.0.1
+	f := Flow{
+		Transport:     srv.Client().Transport,
+		TLSServerName: "example.com",
+		Steps: []Step{
+			{Name: "fronted", Request: Request{URL: srv.URL, Method: "GET"}},
+			{Name: "wrong", Request: Request{URL: srv.URL, Method: "GET"}, TLSServerName: "wrong.example.org"},
+		},
+	}
+	err := f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "wrong.example.org")
+	assert.NotNil(t, f.Steps[0].Response)
+	assert.Equal(t, []string{"example.com", "wrong.example.org"}, names)
+
+	var verified int
+	f.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		verified++
+		return nil
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, 2, verified)
+
+	f.Transport = &fakeHTTP3{}
+	assert.Equal(t, errTLSTransport, f.Execute(nil))
+}
+
+func TestVerifyCertificateFor(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	raw := [][]byte{srv.Certificate().Raw}
+	// the test certificate isn't signed by the system roots
+	err := VerifyCertificateFor("example.com")(raw, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "isn't valid for [example.com]")
+	assert.EqualError(t, VerifyCertificateFor("example.com")(nil, nil), "no certificate")
+}
@@ -0,0 +1,219 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by SessionPool.Checkout once the pool is closed
+var ErrPoolClosed = errors.New("httpsim: session pool closed")
+
+// Session is a logged in session: the cookies and values of a login run
+type Session struct {
+	Jar    http.CookieJar
+	Values map[string]interface{}
+	// Expires is when the session is considered expired
+	Expires time.Time
+
+	slot int
+}
+
+// SessionPool keeps Size sessions logged in with the Login flow, refreshed in the
+// background before they expire, for the runs to check out: their latency then
+// excludes the login, and the target sees fewer logins. See RunSession. Its
+// fields must be set before Start.
+type SessionPool struct {
+	// Login is the login flow, run with Values
+	Login  *CompiledFlow
+	Values map[string]interface{}
+	Size   int
+	// TTL is how long a session lasts once logged in, it's refreshed
+	// RefreshBefore it expires when not checked out
+	TTL           time.Duration
+	RefreshBefore time.Duration
+	// Interval is how often the sessions are checked, RefreshBefore/2 when 0
+	Interval time.Duration
+	// OnError, if set, is called with the logins' errors
+	OnError func(error)
+
+	mu      sync.Mutex
+	slots   []poolSlot
+	changed chan struct{}
+	closed  bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// poolSlot is a session of the pool, busy when checked out or logging in
+type poolSlot struct {
+	session *Session
+	busy    bool
+}
+
+// Start logs the sessions in and keeps them fresh until Close
+func (p *SessionPool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.slots = make([]poolSlot, p.Size)
+	p.changed = make(chan struct{})
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	p.mu.Unlock()
+
+	interval := p.Interval
+	if interval <= 0 {
+		interval = p.RefreshBefore / 2
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.refresh(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// signal wakes up the checkouts waiting, with the pool locked
+func (p *SessionPool) signal() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// fresh returns whether the session doesn't need to be refreshed yet
+func (p *SessionPool) fresh(s *Session) bool {
+	return s != nil && time.Now().Before(s.Expires.Add(-p.RefreshBefore))
+}
+
+// refresh logs in the sessions missing or about to expire, concurrently
+func (p *SessionPool) refresh(ctx context.Context) {
+	var wg sync.WaitGroup
+	p.mu.Lock()
+	for i := range p.slots {
+		slot := &p.slots[i]
+		if slot.busy || p.fresh(slot.session) {
+			continue
+		}
+		slot.busy = true
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session, err := p.login(ctx, i)
+			if err != nil && p.OnError != nil {
+				p.OnError(err)
+			}
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.slots[i].busy = false
+			if err == nil {
+				p.slots[i].session = session
+				p.signal()
+			}
+		}(i)
+	}
+	p.mu.Unlock()
+	wg.Wait()
+}
+
+// login runs the login flow for the slot
+func (p *SessionPool) login(ctx context.Context, slot int) (*Session, error) {
+	values := make(map[string]interface{}, len(p.Values))
+	for k, v := range p.Values {
+		values[k] = v
+	}
+	run, err := p.Login.RunContext(ctx, values)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Jar: run.CookieJar, Values: run.Values, Expires: time.Now().Add(p.TTL), slot: slot}, nil
+}
+
+// Checkout returns a fresh session, waiting for one until ctx is done. It must
+// be given back with Return, or Discard when it turned out logged out.
+func (p *SessionPool) Checkout(ctx context.Context) (*Session, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+		for i := range p.slots {
+			slot := &p.slots[i]
+			if !slot.busy && slot.session != nil && time.Now().Before(slot.session.Expires) {
+				slot.busy = true
+				p.mu.Unlock()
+				return slot.session, nil
+			}
+		}
+		changed := p.changed
+		p.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Return gives the session back to the pool
+func (p *SessionPool) Return(s *Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s.slot < len(p.slots) && p.slots[s.slot].session == s {
+		p.slots[s.slot].busy = false
+		p.signal()
+	}
+}
+
+// Discard gives the session back to the pool to be logged in again, e.g. when
+// the site logged it out
+func (p *SessionPool) Discard(s *Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s.slot < len(p.slots) && p.slots[s.slot].session == s {
+		p.slots[s.slot] = poolSlot{}
+	}
+}
+
+// Close stops refreshing the sessions, the checkouts waiting fail with
+// ErrPoolClosed
+func (p *SessionPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	if p.changed != nil {
+		p.signal()
+	}
+	cancel, done := p.cancel, p.done
+	p.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// RunSession is RunContext with the session's cookies and values, the values
+// given override the session's
+func (c *CompiledFlow) RunSession(ctx context.Context, s *Session, values map[string]interface{}) (*Flow, error) {
+	run := c.def.CompleteCopy()
+	run.CookieJar = s.Jar
+	vals := make(map[string]interface{}, len(s.Values)+len(values))
+	for k, v := range s.Values {
+		vals[k] = v
+	}
+	for k, v := range values {
+		vals[k] = v
+	}
+	err := run.ExecuteContext(ctx, vals)
+	return &run, err
+}
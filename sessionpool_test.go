@@ -0,0 +1,97 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionPool(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/login" {
+			logins++
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: fmt.Sprint(logins), Path: "/"})
+			return
+		}
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "<me>%s %s</me>", c.Value, r.URL.Query().Get("user"))
+	}))
+	defer srv.Close()
+	loginCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return logins
+	}
+
+	login := Flow{RequiredValues: []string{"user"}, Steps: []Step{{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "POST"}}}}
+	compiledLogin, err := login.CompileFlow()
+	assert.Nil(t, err)
+	scenario := Flow{Steps: []Step{{Name: "me", Request: Request{URL: srv.URL + "/me?user={{.user}}", Method: "GET"}, KeysInput: []string{"user"},
+		KeysOutput: []Extracter{Extractable{Name: "me", AfterThis: "<me>", BeforeThis: "</me>", MaxLength: -1, MinLength: -1}}}}}
+	compiled, err := scenario.CompileFlow()
+	assert.Nil(t, err)
+
+	pool := &SessionPool{Login: compiledLogin, Values: map[string]interface{}{"user": "bob"}, Size: 2,
+		TTL: time.Hour, RefreshBefore: time.Minute, Interval: 10 * time.Millisecond}
+	pool.Start()
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	a, err := pool.Checkout(ctx)
+	assert.Nil(t, err)
+	b, err := pool.Checkout(ctx)
+	assert.Nil(t, err)
+	assert.NotEqual(t, a, b)
+	short, cancelShort := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancelShort()
+	_, err = pool.Checkout(short)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	run, err := compiled.RunSession(ctx, a, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, []string{"1 bob", "2 bob"}, run.Values["me"])
+	pool.Return(a)
+	again, err := pool.Checkout(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, a, again)
+	assert.Equal(t, 2, loginCount())
+
+	// a discarded session is logged in again
+	pool.Discard(again)
+	c, err := pool.Checkout(ctx)
+	assert.Nil(t, err)
+	assert.NotEqual(t, a, c)
+	run, err = compiled.RunSession(ctx, c, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "3 bob", run.Values["me"])
+	pool.Return(b)
+	pool.Return(c)
+
+	pool.Close()
+	_, err = pool.Checkout(ctx)
+	assert.Equal(t, ErrPoolClosed, err)
+
+	// the sessions about to expire are refreshed
+	before := loginCount()
+	refreshed := &SessionPool{Login: compiledLogin, Values: map[string]interface{}{"user": "bob"}, Size: 1,
+		TTL: 30 * time.Millisecond, RefreshBefore: 20 * time.Millisecond, Interval: 5 * time.Millisecond}
+	refreshed.Start()
+	time.Sleep(60 * time.Millisecond)
+	refreshed.Close()
+	assert.Greater(t, loginCount(), before+2)
+}
@@ -0,0 +1,151 @@
+package httpsim
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// SnapshotStore keeps the last known-good response body of each step. When a step
+// then fails extraction, its body is diffed against the snapshot to point at what
+// the site changed.
+type SnapshotStore interface {
+	// Load returns the snapshot saved under key, nil if there's none
+	Load(key string) ([]byte, error)
+	// Save replaces the snapshot saved under key
+	Save(key string, body []byte) error
+}
+
+// snapshotKey is the key of a step's snapshot
+func snapshotKey(stepNb int, name string) string {
+	return fmt.Sprintf("%02d-%s", stepNb, name)
+}
+
+// FileSnapshotStore is a SnapshotStore saving one file per step in Dir
+type FileSnapshotStore struct {
+	Dir string
+	// Compress gzips the snapshots (.snapshot.gz files). Both compressed and
+	// uncompressed snapshots are loaded, whatever Compress is.
+	Compress bool
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func (s *FileSnapshotStore) path(key string) string {
+	return filepath.Join(s.Dir, unsafeFileChars.ReplaceAllString(key, "_")+".snapshot")
+}
+
+// Load reads the snapshot file of key
+func (s *FileSnapshotStore) Load(key string) ([]byte, error) {
+	path, other := s.path(key), s.path(key)+".gz"
+	if s.Compress {
+		path, other = other, path
+	}
+	b, err := readArtifact(path)
+	if os.IsNotExist(err) {
+		// saved before Compress was changed
+		b, err = readArtifact(other)
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Save writes the snapshot file of key
+func (s *FileSnapshotStore) Save(key string, body []byte) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+	path, other := s.path(key), s.path(key)+".gz"
+	if s.Compress {
+		path, other = other, path
+	}
+	if err := writeArtifact(path, body, s.Compress); err != nil {
+		return err
+	}
+	if err := os.Remove(other); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readArtifact reads the file, decompressing it if it's gzipped
+func readArtifact(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil || len(b) < 2 || b[0] != 0x1f || b[1] != 0x8b {
+		return b, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// writeArtifact writes the file, gzipped if compress is set. It's written then
+// renamed so a crash never leaves a truncated file.
+func writeArtifact(path string, data []byte, compress bool) error {
+	if compress {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// MemorySnapshotStore is a SnapshotStore kept in memory, safe for concurrent use
+type MemorySnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string][]byte
+}
+
+// Load returns the snapshot of key
+func (s *MemorySnapshotStore) Load(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshots[key], nil
+}
+
+// Save saves a copy of body under key
+func (s *MemorySnapshotStore) Save(key string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshots == nil {
+		s.snapshots = map[string][]byte{}
+	}
+	s.snapshots[key] = append([]byte(nil), body...)
+	return nil
+}
+
+// diffSnapshot adds to the extraction error what changed since the step's snapshot
+func (f *Flow) diffSnapshot(i int, step *Step, body []byte, err error) {
+	if f.Snapshots == nil {
+		return
+	}
+	ee, ok := err.(*ExtractionError)
+	if errs, many := err.(ExtractionErrors); many {
+		ee, ok = errs[0], true
+	}
+	if !ok {
+		return
+	}
+	if snap, _ := f.Snapshots.Load(snapshotKey(i, step.Name)); snap != nil {
+		ee.Diff = diffSummary(snap, f.Scrubber.Scrub(body))
+	}
+}
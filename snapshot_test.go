@@ -0,0 +1,100 @@
+package httpsim
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSummary(t *testing.T) {
+	old := []byte("<form>\n  <input name=\"csrf\" value=\"1\">\n  <input name=\"user\">\n</form>")
+	new := []byte("<form>\n    <input name=\"_csrf_token\" value=\"1\">\n  <input name=\"user\">\n\n</form>")
+	assert.Equal(t, "- <input name=\"csrf\" value=\"1\">\n+ <input name=\"_csrf_token\" value=\"1\">", diffSummary(old, new))
+	assert.Equal(t, "", diffSummary(old, []byte("<form>\n<input name=\"csrf\" value=\"1\">\n<input name=\"user\">\n</form>\n")))
+
+	var many []byte
+	for i := 0; i < 30; i++ {
+		many = append(many, fmt.Sprintf("line %d\n", i)...)
+	}
+	summary := diffSummary(nil, many)
+	assert.Contains(t, summary, "+ line 19\n... 10 more changed lines")
+}
+
+func TestFileSnapshotStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpsim")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s := &FileSnapshotStore{Dir: dir}
+	b, err := s.Load("00-login page")
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+	assert.Nil(t, s.Save("00-login page", []byte("body")))
+	b, err = s.Load("00-login page")
+	assert.Nil(t, err)
+	assert.Equal(t, "body", string(b))
+}
+
+func TestFileSnapshotStore_Compress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpsim")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	body := []byte(strings.Repeat("<p>hello</p>\n", 100))
+	s := &FileSnapshotStore{Dir: dir}
+	assert.Nil(t, s.Save("00-page", body))
+
+	// the uncompressed snapshot is still loaded, and replaced when saved again
+	s.Compress = true
+	b, err := s.Load("00-page")
+	assert.Nil(t, err)
+	assert.Equal(t, body, b)
+	assert.Nil(t, s.Save("00-page", body))
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "00-page.snapshot.gz", files[0].Name())
+	assert.True(t, files[0].Size() < int64(len(body)/10))
+	b, err = s.Load("00-page")
+	assert.Nil(t, err)
+	assert.Equal(t, body, b)
+
+	s.Compress = false
+	b, err = s.Load("00-page")
+	assert.Nil(t, err)
+	assert.Equal(t, body, b)
+}
+
+func TestFlow_ExecuteSnapshotDiff(t *testing.T) {
+	page := "<h1>Login</h1>\n<input name=\"csrf\" value=\"abc\">\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Snapshots: &MemorySnapshotStore{},
+		Steps: []Step{{
+			Name:    "login page",
+			Request: Request{URL: srv.URL, Method: "GET"},
+			KeysOutput: []Extracter{
+				Extractable{Name: "csrf", AfterThis: `name="csrf" value="`, BeforeThis: `"`, MaxLength: -1, MinLength: -1},
+			},
+		}},
+	}
+	assert.Nil(t, f.Execute(nil))
+
+	page = "<h1>Login</h1>\n<input name=\"authenticity_token\" value=\"abc\">\n"
+	err := f.Execute(nil)
+	assert.IsType(t, &ExtractionError{}, err)
+	assert.EqualError(t, err, "Step 0.'login page' failed because couldn't extract 'csrf': not found\n"+
+		"changes since last success:\n"+
+		"- <input name=\"csrf\" value=\"abc\">\n"+
+		"+ <input name=\"authenticity_token\" value=\"abc\">")
+}
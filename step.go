@@ -2,17 +2,16 @@ package httpsim
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
-	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"net/url"
-
-	"regexp"
-
-	"github.com/gee-m/go-helpers/gstrings"
 )
 
 // Extracter is the interface that is used to extract potentially important Values
@@ -23,14 +22,27 @@ type Extracter interface {
 	Extract(body string, values map[string]interface{}) (name, value string, err error)
 }
 
+// ExtracterFunc is an adapter to use an ordinary function as an Extracter
+type ExtracterFunc func(body string, values map[string]interface{}) (name, value string, err error)
+
+// Extract calls f(body, values)
+func (f ExtracterFunc) Extract(body string, values map[string]interface{}) (string, string, error) {
+	return f(body, values)
+}
+
 // Extractable represents a string that's extractable given the response body
 // It implements the Extracter interface, and is a default you can use.
-// Extract() extracts the first string betwen afterthis and beforethis
+// Extract() extracts the first string betwen afterthis and beforethis.
 type Extractable struct {
 	AfterThis  string
 	BeforeThis string
 	// Name is the name of the string to be extracted
 	Name string
+	// Templated renders AfterThis, BeforeThis, MatchRegexp, the window markers and
+	// Anchor as templates (e.g. `id="{{.accountID}}-balance">`) with the values
+	// before extracting, with the step's Delims. Markers are literal otherwise,
+	// e.g. Angular's {{
+	Templated bool
 
 	// Custom parameters for smarter extraction, will keep trying until
 	// parameters' condition are met if iterate is true
@@ -48,30 +60,128 @@ type Extractable struct {
 	// IgnoreNotFound set to true if you want to ignore errors when not found
 	IgnoreNotFound bool
 
+	// Occurrence picks the occurrence of AfterThis to extract from: N is the Nth
+	// one (1-based), -1 the last one. 0 tries them in order (see Iterate).
+	Occurrence int
+	// SearchBackwards goes through the occurrences starting from the last one,
+	// Occurrence then counts from the end
+	SearchBackwards bool
+	// WindowStart and WindowEnd restrict the search to the part of the body between
+	// the first WindowStart and the following WindowEnd. Either can be left empty
+	// for the start/end of the body.
+	WindowStart string
+	WindowEnd   string
+	// AnchorValue is the name of a value previously extracted (e.g. an account number),
+	// the search then starts after its first occurrence in the body (or window).
+	// Useful to pick the right cell of repeated rows.
+	AnchorValue string
+	// Anchor is a marker the search starts after (after AnchorValue if both are set)
+	Anchor string
+
 	// Again reruns the Extract() (recursively) with the extracted content from the parent
 	Again *Extractable
+
+	// delims are the delimiters of the Templated markers, see withDelims
+	delims Delims
+}
+
+func (e Extractable) withDelims(d Delims) Extracter {
+	e.delims = d
+	return e
 }
 
+// stringBetweenN returns the string between the occ-th occurrence of bef and the
+// following aft
 func stringBetweenN(body, bef, aft string, occ int) (found bool, str string) {
-	if occ == 0 {
-		return gstrings.StringBetween(body, bef, aft)
+	return newBodyIndex(body).between(bef, aft, occ)
+}
+
+// render renders the templated delimiters and regexp with the values
+func (e *Extractable) render(v map[string]interface{}) error {
+	if !e.Templated {
+		return nil
 	}
-	befIndex := strings.Index(body, bef)
-	if befIndex == -1 {
-		return false, ""
+	for _, s := range []*string{&e.AfterThis, &e.BeforeThis, &e.MatchRegexp, &e.WindowStart, &e.WindowEnd, &e.Anchor} {
+		if !strings.Contains(*s, e.delims.left()) {
+			continue
+		}
+		out, err := e.delims.replace(v, *s)
+		if err != nil {
+			return err
+		}
+		*s = out
 	}
-	return stringBetweenN(body[befIndex+len(bef)+1:], bef, aft, occ-1)
+	return nil
 }
 
 // Extract extracts the string between the extractable delimiters
 func (e Extractable) Extract(body string, v map[string]interface{}) (string, string, error) {
-	for i := 0; ; i++ {
-		found, bet := stringBetweenN(body, e.AfterThis, e.BeforeThis, i)
-		if !found {
-			if e.IgnoreNotFound {
-				return e.Name, "", nil
+	return e.extractIndexed(newBodyIndex(body), v)
+}
+
+// extractIndexed extracts the string using the body's index, which may be shared
+// with other extracters of the same body
+func (e Extractable) extractIndexed(idx *bodyIndex, v map[string]interface{}) (string, string, error) {
+	if err := e.render(v); err != nil {
+		return e.Name, "", fmt.Errorf("couldn't render delimiters: %s", err.Error())
+	}
+	if e.WindowStart != "" || e.WindowEnd != "" {
+		start := idx.after(e.WindowStart, 0)
+		if start == -1 {
+			return e.notFound(errors.New("window not found"))
+		}
+		start += len(e.WindowStart)
+		end := len(idx.body)
+		if e.WindowEnd != "" {
+			if end = idx.after(e.WindowEnd, start); end == -1 {
+				return e.notFound(errors.New("window not found"))
+			}
+		}
+		idx = newBodyIndex(idx.body[start:end])
+	}
+	if e.AnchorValue != "" || e.Anchor != "" {
+		pos := 0
+		if e.AnchorValue != "" {
+			anchor, ok := v[e.AnchorValue]
+			if !ok || anchor == "" {
+				return e.Name, "", fmt.Errorf("anchor value '%s' is missing", e.AnchorValue)
+			}
+			val := fmt.Sprint(anchor)
+			if pos = idx.after(val, 0); pos == -1 {
+				return e.notFound(fmt.Errorf("anchor value '%s' (%s) not found", e.AnchorValue, val))
+			}
+			pos += len(val)
+		}
+		if e.Anchor != "" {
+			if pos = idx.after(e.Anchor, pos); pos == -1 {
+				return e.notFound(fmt.Errorf("anchor '%s' not found", e.Anchor))
 			}
-			return e.Name, "", errors.New("not found")
+			pos += len(e.Anchor)
+		}
+		idx = newBodyIndex(idx.body[pos:])
+	}
+
+	// the occurrences of AfterThis to try, in order
+	n := len(idx.find(e.AfterThis))
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+		if e.SearchBackwards {
+			order[i] = n - 1 - i
+		}
+	}
+	if e.Occurrence > 0 && e.Occurrence <= n {
+		order = order[e.Occurrence-1 : e.Occurrence]
+	} else if e.Occurrence < 0 && -e.Occurrence <= n {
+		order = order[n+e.Occurrence : n+e.Occurrence+1]
+	} else if e.Occurrence != 0 {
+		order = nil
+	}
+
+	for _, i := range order {
+		found, bet := idx.between(e.AfterThis, e.BeforeThis, i)
+		if !found {
+			continue
 		}
 
 		var err error
@@ -81,36 +191,38 @@ func (e Extractable) Extract(body string, v map[string]interface{}) (string, str
 		} else if e.MinLength != -1 && len(bet) < e.MinLength {
 			err = fmt.Errorf("min length of %d reached: %s", e.MinLength, bet)
 		} else if e.MatchRegexp != "" {
-			if e.MatchRegexp[0] != '^' {
-				e.MatchRegexp = "^" + e.MatchRegexp
+			re, err2 := compileRegexp(anchorRegexp(e.MatchRegexp))
+			if err2 != nil {
+				// iterating won't make it valid
+				return e.Name, "", err2
 			}
-			if e.MatchRegexp[len(e.MatchRegexp)-1] != '$' {
-				e.MatchRegexp += "$"
-			}
-			mat, err2 := regexp.Match(e.MatchRegexp, []byte(bet))
-			if err != nil {
-				err = err2
-			} else if !mat {
-				err = fmt.Errorf("regex '%s' not matched: %s", e.MatchRegexp, bet)
+			if !re.MatchString(bet) {
+				err = fmt.Errorf("regex '%s' not matched: %s", re.String(), bet)
 			}
 		}
 
 		if err != nil {
 			if e.Iterate {
 				continue
-			} else {
-				if e.IgnoreNotFound {
-					return e.Name, "", nil
-				}
-				return e.Name, "", err
-			}
-		} else {
-			if e.Again != nil {
-				return e.Again.Extract(bet, v)
 			}
-			return e.Name, bet, nil
+			return e.notFound(err)
 		}
+		if e.Again != nil {
+			again := *e.Again
+			again.delims = e.delims
+			return again.Extract(bet, v)
+		}
+		return e.Name, bet, nil
 	}
+	return e.notFound(errors.New("not found"))
+}
+
+// notFound returns the error, unless IgnoreNotFound is set
+func (e Extractable) notFound(err error) (string, string, error) {
+	if e.IgnoreNotFound {
+		return e.Name, "", nil
+	}
+	return e.Name, "", err
 }
 
 // Request defines an http request to be executed
@@ -123,6 +235,13 @@ type Request struct {
 	Body interface{}
 	// IgnoreRedirects is whether the redirects should be ignored (302)
 	IgnoreRedirects bool
+	// Cookies are sent along with the jar's, see CookieTemplate
+	Cookies []CookieTemplate
+	// ExpectContinue is the body size from which the request is sent with
+	// Expect: 100-continue, 0 never sends it. The body waits for the server's
+	// interim response, or the transport's ExpectContinueTimeout (sent right away
+	// when it's 0).
+	ExpectContinue int64
 }
 
 // Response is a respones to the http request. If body is filled, raw.Body
@@ -131,6 +250,36 @@ type Response struct {
 	Raw    *http.Response
 	Body   []byte
 	Header http.Header
+	// Recovered lists the values that had to be extracted with the step's Recovery
+	Recovered []RecoveredValue
+	// Certificates is the chain presented by the server, leaf first, nil without TLS
+	Certificates []CertificateInfo
+	// DNS is what the request's host resolved to
+	DNS *DNSInfo
+	// Encodings are the responses to the request re-sent with the step's
+	// CompareEncodings
+	Encodings []EncodingResult
+	// Continue is how the server answered Expect: 100-continue, nil when the
+	// request wasn't sent with it (see Request.ExpectContinue)
+	Continue *ContinueInfo
+	// Protocol is the protocol the response was received with e.g. "HTTP/1.1",
+	// "HTTP/2.0", "HTTP/3.0"
+	Protocol string
+
+	// json is the body parsed once, see JSON
+	json *lazyJSON
+	// runID is the run the response is of, started when its request was sent
+	// and duration the time to its headers
+	runID    string
+	started  time.Time
+	duration time.Duration
+}
+
+// RecoveredValue is a value extracted by a Recovery extracter, with the error of
+// the KeysOutput extracter that failed
+type RecoveredValue struct {
+	Name string
+	Err  error
 }
 
 // Step is an http request to be executed when needed
@@ -152,6 +301,133 @@ type Step struct {
 	// a map[string]string to be used for later steps (as KeysInput).
 	// The Ouputs are extracted in the order given, and put in the Flow.values.
 	KeysOutput []Extracter
+	// CaptureHost is the name of the value set to the host (with its port, if
+	// any) the server migrates the client to, for the next steps' URLs e.g.
+	// "https://{{.api}}/orders": the redirect's Location, or the alternative
+	// host of the Alt-Svc, else the request's host
+	CaptureHost string
+	// Overwrite are the values the step overwrites on purpose, see Flow.DuplicateWrites
+	Overwrite []string
+	// Recovery maps a value name to the fallback extracter (e.g. a broader regexp or a
+	// callback asking a smarter service) to try when its KeysOutput extracter fails.
+	// Recovered values are listed in the Response.
+	Recovery map[string]Extracter
+	// ParallelExtract runs the KeysOutput concurrently, for pages with many outputs.
+	// The values are stored in order once all are extracted, so extracters don't
+	// see the values extracted by this step.
+	ParallelExtract bool
+	// Delims override the flow's Delims of the step's request, CacheKey and
+	// Condition templates
+	Delims Delims
+	// NoTemplating sends the request body as is, e.g. when it contains literal
+	// {{ (Handlebars templates...). The URL, header and cookies are still
+	// rendered. []byte bodies that aren't UTF-8 text (images...) are never
+	// templated.
+	NoTemplating bool
+	// StreamTemplate renders the templated string or []byte request body while
+	// it's sent (chunked), instead of in memory, for multi-MB payloads e.g. bulk
+	// imports ranging over values. The body isn't in the wire dumps, and isn't
+	// sent again on redirects.
+	StreamTemplate bool
+	// StreamBody doesn't read the body in memory: it's given to the KeysOutput, which
+	// must all be StreamExtracters, while it's read. Response.Body and the body
+	// given to the PostHook are then nil, and Forbid isn't checked.
+	StreamBody bool
+	// Archive, when set, unpacks the (zip, tar) response and runs the KeysOutput
+	// against one of its files instead of the response body
+	Archive *Archive
+	// Normalize maps output value names to the canonical form (number, date) their
+	// locale-formatted value is normalized into before being stored, see Flow.Locale
+	Normalize map[string]Normalization
+	// CompareEncodings re-sends the request with each of these Accept-Encoding
+	// (e.g. "gzip", "identity") and warns when the responses differ from the
+	// step's body, to validate the compression configuration of the site
+	CompareEncodings []string
+	// CORS, if set, sends a preflight before the request and checks the CORS
+	// headers like a browser would
+	CORS *CORS
+	// TLS are the assertions on the server's certificates, overriding the flow's
+	TLS *TLSCheck
+	// DNS are the assertions on what the host resolves to, overriding the flow's
+	DNS *DNSCheck
+	// MaxBodySize overrides the flow's MaxBodySize when not 0, -1 is no limit
+	MaxBodySize int64
+	// Resume is the number of times the download of the body is resumed with a
+	// range request when the connection fails, 0 never resumes it. The partial
+	// responses must be 206s continuing the body, see RangeError.
+	Resume int
+	// Proxy overrides the flow's Proxy and ProxyProvider for the step's
+	// requests, see Flow.Proxy
+	Proxy string
+	// TLSServerName overrides the flow's TLSServerName for the step
+	TLSServerName string
+	// HTTP3 sends the request with the flow's HTTP3 transport, for QUIC-only
+	// endpoints. Ignored when the flow has none.
+	HTTP3 bool
+	// NoCookies sends the request without the jar's cookies and ignores the
+	// cookies it sets e.g. for third-party hosts. Request.Cookies are still sent.
+	NoCookies bool
+	// CacheKey, if set, memoizes the step's outputs in the flow's StepCache for
+	// CacheTTL: the step isn't executed while they're cached. It's a template,
+	// e.g. "guest-token-{{.region}}".
+	CacheKey string
+	CacheTTL time.Duration
+	// HiddenFields adds to the form body the hidden fields (e.g. view state,
+	// tokens) of the form of the previous step's page submitted to this step,
+	// unless the body sets them. New hidden fields then don't break the flow.
+	HiddenFields bool
+	// AllowWrite lets the step send its request even though the flow is
+	// ReadOnly, e.g. a login
+	AllowWrite bool
+	// Optional is set when the flow doesn't need the step (e.g. a tracking
+	// beacon), the flow's Chaos may drop it
+	Optional bool
+	// OnlyIf, if set, executes the step only when it returns true, e.g. when the
+	// login page asked for a 2FA code. The step is skipped when SkipIf, if set,
+	// returns true. A skipped step's Response is nil.
+	OnlyIf func(values map[string]interface{}) bool
+	SkipIf func(values map[string]interface{}) bool
+	// Condition is a template executing the step only when it renders "true",
+	// e.g. `{{eq .mfa "required"}}`. A missing value renders "<no value>".
+	Condition string
+	// Timeout, if not 0, is the max time of the step's requests and the reading
+	// of their responses, including its Loop: the step fails with a
+	// *TimeoutError, its requests are canceled
+	Timeout time.Duration
+	// Retry, if set, executes the step again when its request fails or the
+	// server answers 5xx or 429
+	Retry *Retry
+	// Loop, if set, re-executes the step until its response passes Loop.Until,
+	// e.g. to poll a job's status
+	Loop *Loop
+	// Unordered is set when the step may be executed in any order among the
+	// consecutive Unordered steps, like the parallel requests of a browser. The
+	// flow's Chaos may shuffle them.
+	Unordered bool
+
+	// ExpectStatus lists the status codes ("200") or classes ("2xx") considered a success.
+	// When empty, only 2xx are (and 3xx too when the request IgnoreRedirects).
+	ExpectStatus []string
+	// AnyStatus disables the status check e.g. when the PostHook handles it
+	AnyStatus bool
+
+	// Forbid are strings that must NOT appear in the response body (e.g. "incorrect password",
+	// stack traces), the step fails with the matched excerpt when one does. Sites often
+	// render an error page with a 200.
+	Forbid []string
+	// ForbidRegexp is the same as Forbid with regular expressions
+	ForbidRegexp []string
+
+	// ExpectBodySHA256 is the hex SHA-256 the body must have (e.g. static assets),
+	// the step fails with a *BodyHashError when the content changed. Not checked
+	// when the body is streamed.
+	ExpectBodySHA256 string
+	// NormalizeBodyHash hashes the body with its line endings and whitespace runs
+	// normalized (see BodySHA256), ignoring formatting changes
+	NormalizeBodyHash bool
+	// Invariants are checked once the step's values are extracted, they may use
+	// the values of the previous steps
+	Invariants []Invariant
 
 	// PostHook is mostly used as a sanity check, and thus should fail if
 	// something went wrong during this step. It can also let you store special
@@ -179,37 +455,83 @@ func countBody(v interface{}, c string) int {
 	}
 }
 
-// Do executes the http step with the client
+// Do executes the http step with the client. The client isn't modified: the
+// per-request options (redirects) are set on a copy, so it may be shared by
+// concurrent executions.
+//
+// Deprecated: use DoContext, Do can't be canceled.
 func (r *Request) Do(cl http.Client) (*http.Response, error) {
-	var bod []byte
-	if r.Body != nil {
-		bod = r.Body.([]byte)
+	deprecated("Request.Do", "Request.DoContext")
+	return r.DoContext(context.Background(), cl)
+}
+
+// DoContext is Do with the context of the request
+func (r *Request) DoContext(ctx context.Context, cl http.Client) (*http.Response, error) {
+	bod := requestBody(r.Body)
+	var body io.Reader = bytes.NewReader(bod)
+	if stream, ok := r.Body.(*templateStream); ok {
+		body = stream.reader()
 	}
-	req, err := http.NewRequest(r.Method, r.URL, bytes.NewReader(bod))
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header = r.Header
+	client := cl
+	contentType := r.defaultContentType(bod)
+	expect := r.expectsContinue(len(bod))
+	if len(r.Cookies) != 0 || contentType != "" || expect {
+		// the header is the step's, don't modify it
+		req.Header = r.Header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if expect {
+			req.Header.Set("Expect", "100-continue")
+		}
+		r.addCookies(req, &client)
+	}
 	if r.IgnoreRedirects {
-		cl.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		}
 	}
-	return cl.Do(req)
+	resp, err := client.Do(req)
+	if err != nil || !expect {
+		return resp, err
+	}
+	return retryExpectation(client, req, resp, bod)
+}
+
+// templatedBody returns whether the request body is a template, see
+// NoTemplating
+func (s *Step) templatedBody() bool {
+	if s.NoTemplating {
+		return false
+	}
+	b, ok := s.Request.Body.([]byte)
+	return !ok || utf8.Valid(b) && bytes.IndexByte(b, 0) == -1
 }
 
 // SanityCheck performs simple sanity checks on the step
 func (s *Step) SanityCheck(stepNb int) error {
-	if countBody(s.Request.Body, "{{")+strings.Count(
-		fmt.Sprintf("%v", s.Request.Header)+s.Request.URL, "{{") < len(s.KeysInput) {
+	templates := 0
+	if s.templatedBody() {
+		templates = countBody(s.Request.Body, s.Delims.left())
+	}
+	if templates+strings.Count(
+		fmt.Sprintf("%v%v", s.Request.Header, s.Request.Cookies)+s.Request.URL, s.Delims.left()) < len(s.KeysInput) {
 		return fmt.Errorf("Step %d.'%s' request appears to not contain enough replacements",
 			stepNb, s.Name)
 	}
 	return nil
 }
 
-func replaceInBytes(vals map[string]interface{}, bod []byte) ([]byte, error) {
-	tpl, err := template.New("replacement").Parse(string(bod))
+func replaceInBytes(delims Delims, vals map[string]interface{}, bod []byte) ([]byte, error) {
+	tpl, err := delims.parse(string(bod))
 	if err != nil {
 		return nil, err
 	}
@@ -226,7 +548,12 @@ func replaceInBytes(vals map[string]interface{}, bod []byte) ([]byte, error) {
 }
 
 func replaceInString(vals map[string]interface{}, str string) (string, error) {
-	tpl, err := template.New("replacement").Parse(str)
+	return Delims{}.replace(vals, str)
+}
+
+// replace renders the template with the delimiters, see replaceInString
+func (d Delims) replace(vals map[string]interface{}, str string) (string, error) {
+	tpl, err := d.parse(str)
 	if err != nil {
 		return "", err
 	}
@@ -244,32 +571,46 @@ func (s *Step) ReplaceInBody(vals map[string]interface{}, stepNb int) error {
 		bod []byte
 		err error
 	)
+	if !s.templatedBody() {
+		return nil
+	}
+	if len(s.KeysInput) != 0 && s.StreamTemplate {
+		if stream, err := newTemplateStream(s.Delims, vals, s.Request.Body); err != nil || stream != nil {
+			if err != nil {
+				return fmt.Errorf("Step %d.'%s' %s", stepNb, s.Name, err.Error())
+			}
+			s.Request.Body = stream
+			return nil
+		}
+	}
 	if len(s.KeysInput) != 0 && s.Request.Body != nil {
 		switch t := s.Request.Body.(type) {
 		case string:
-			bod, err = replaceInBytes(vals, []byte(t))
+			bod, err = replaceInBytes(s.Delims, vals, []byte(t))
 			if err != nil {
 				return fmt.Errorf("Step %d.'%s' %s", stepNb, s.Name, err.Error())
 			}
 		case []byte:
-			bod, err = replaceInBytes(vals, t)
+			bod, err = replaceInBytes(s.Delims, vals, t)
 			if err != nil {
 				return fmt.Errorf("Step %d.'%s' %s", stepNb, s.Name, err.Error())
 			}
 		case url.Values:
 			tmp := url.Values{}
 			for k, v := range t {
-				newK, err := replaceInString(vals, k)
+				newK, err := s.Delims.replace(vals, k)
 				if err != nil {
 					return fmt.Errorf("Step %d.'%s' %s", stepNb, s.Name, err.Error())
 				}
-				newV, err := replaceInString(vals, v[len(v)-1])
+				newV, err := s.Delims.replace(vals, v[len(v)-1])
 				if err != nil {
 					return fmt.Errorf("Step %d.'%s' %s", stepNb, s.Name, err.Error())
 				}
 				tmp[newK] = []string{newV}
-				bod = []byte(tmp.Encode())
 			}
+			// kept a form, for the hidden fields and CSRF token to be added
+			s.Request.Body = tmp
+			return nil
 		case nil:
 			return nil
 		default:
@@ -286,7 +627,7 @@ func (s *Step) ReplaceInBody(vals map[string]interface{}, stepNb int) error {
 // ReplaceInHeader replaces the KeysInput in the request header
 func (s *Step) ReplaceInHeader(vals map[string]interface{}, stepNb int) error {
 	for k := range s.Request.Header {
-		tpl, err := template.New(s.Name).Parse(s.Request.Header.Get(k))
+		tpl, err := s.Delims.parse(s.Request.Header.Get(k))
 		if err != nil {
 			return err
 		}
@@ -301,7 +642,7 @@ func (s *Step) ReplaceInHeader(vals map[string]interface{}, stepNb int) error {
 
 // ReplaceInURL replaces needed values in url
 func (s *Step) ReplaceInURL(vals map[string]interface{}, stepNB int) error {
-	tpl, err := template.New(s.Name).Parse(s.Request.URL)
+	tpl, err := s.Delims.parse(s.Request.URL)
 	if err != nil {
 		return err
 	}
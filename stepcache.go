@@ -0,0 +1,112 @@
+package httpsim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StepCache keeps the outputs of the steps with a CacheKey across executions,
+// e.g. an app config or a guest token, so they're not requested every run
+type StepCache interface {
+	// Load returns the outputs saved under key, nil if there's none or they
+	// expired
+	Load(key string) (map[string]interface{}, error)
+	// Save saves the outputs under key for ttl
+	Save(key string, outputs map[string]interface{}, ttl time.Duration) error
+}
+
+// MemoryStepCache is a StepCache kept in memory, safe for concurrent use e.g.
+// by the runs of a Runner
+type MemoryStepCache struct {
+	mu      sync.Mutex
+	entries map[string]stepCacheEntry
+}
+
+type stepCacheEntry struct {
+	outputs map[string]interface{}
+	expires time.Time
+}
+
+// Load returns the outputs of key, nil once expired
+func (c *MemoryStepCache) Load(key string) (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || !time.Now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, nil
+	}
+	outputs := make(map[string]interface{}, len(e.outputs))
+	for k, v := range e.outputs {
+		outputs[k] = v
+	}
+	return outputs, nil
+}
+
+// Save saves a copy of the outputs under key
+func (c *MemoryStepCache) Save(key string, outputs map[string]interface{}, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]stepCacheEntry{}
+	}
+	e := stepCacheEntry{outputs: make(map[string]interface{}, len(outputs)), expires: time.Now().Add(ttl)}
+	for k, v := range outputs {
+		e.outputs[k] = v
+	}
+	c.entries[key] = e
+	return nil
+}
+
+// stepCacheKey returns the step's rendered CacheKey, "" when it isn't cached
+func (f *Flow) stepCacheKey(i int, step *Step, vals map[string]interface{}) (string, error) {
+	if f.StepCache == nil || step.CacheKey == "" {
+		return "", nil
+	}
+	key, err := step.Delims.replace(vals, step.CacheKey)
+	if err != nil {
+		return "", fmt.Errorf("Step %d.'%s' invalid cache key: %s", i, step.Name, err.Error())
+	}
+	return key, nil
+}
+
+// loadCachedStep stores the step's cached outputs, it returns whether there
+// were some
+func (f *Flow) loadCachedStep(i int, step *Step, key string) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+	outputs, err := f.StepCache.Load(key)
+	if err != nil {
+		return false, fmt.Errorf("Step %d.'%s' couldn't load cache: %s", i, step.Name, err.Error())
+	}
+	if outputs == nil {
+		return false, nil
+	}
+	for k, v := range outputs {
+		f.Values[k] = v
+		f.provenance(i, step, k, false)
+		p := f.Provenance[k]
+		p.Extracter = "cache"
+		f.Provenance[k] = p
+	}
+	return true, nil
+}
+
+// saveCachedStep saves the outputs the step just extracted
+func (f *Flow) saveCachedStep(i int, step *Step, key string) error {
+	if key == "" {
+		return nil
+	}
+	outputs := map[string]interface{}{}
+	for k, p := range f.Provenance {
+		if !p.Given && p.Step == i && p.StepName == step.Name {
+			outputs[k] = f.Values[k]
+		}
+	}
+	if err := f.StepCache.Save(key, outputs, step.CacheTTL); err != nil {
+		return fmt.Errorf("Step %d.'%s' couldn't save cache: %s", i, step.Name, err.Error())
+	}
+	return nil
+}
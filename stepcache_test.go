@@ -0,0 +1,50 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_StepCache(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/guest" {
+			calls++
+			fmt.Fprintf(w, "<t>%s-%d</t>", r.URL.Query().Get("region"), calls)
+		}
+	}))
+	defer srv.Close()
+
+	token := Extractable{Name: "token", AfterThis: "<t>", BeforeThis: "</t>", MaxLength: -1, MinLength: -1}
+	f := Flow{
+		RequiredValues: []string{"region"},
+		StepCache:      &MemoryStepCache{},
+		Steps: []Step{
+			{Name: "guest", Request: Request{URL: srv.URL + "/guest?region={{.region}}", Method: "GET"}, KeysInput: []string{"region"},
+				KeysOutput: []Extracter{token}, CacheKey: "guest-{{.region}}", CacheTTL: 50 * time.Millisecond},
+			{Name: "search", Request: Request{URL: srv.URL + "/search?t={{.token}}", Method: "GET"}, KeysInput: []string{"token"}},
+		},
+	}
+	assert.Nil(t, f.Compile())
+	run := func(region string) string {
+		assert.Nil(t, f.Execute(map[string]interface{}{"region": region}))
+		return f.Values["token"].(string)
+	}
+	assert.Equal(t, "eu-1", run("eu"))
+	assert.NotNil(t, f.Steps[0].Response)
+	assert.Equal(t, "eu-1", run("eu"))
+	assert.Nil(t, f.Steps[0].Response)
+	assert.Equal(t, "cache", f.Provenance["token"].Extracter)
+	assert.Equal(t, "us-2", run("us"))
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "eu-3", run("eu"))
+	assert.Equal(t, 3, calls)
+
+	f.Steps[0].CacheKey = "{{"
+	assert.NotNil(t, f.Compile())
+}
@@ -1,6 +1,14 @@
 package httpsim
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
 	"strconv"
 	"testing"
 
@@ -55,3 +63,178 @@ func TestExtractable_Extract(t *testing.T) {
 	assert.Equal(t, "some string", name)
 	assert.Equal(t, "asdfff", value)
 }
+
+func TestExtractable_ExtractTemplated(t *testing.T) {
+	body := `
+		<span id="111-balance">10.00</span>
+		<span id="222-balance">20.00</span>
+	`
+	ex := Extractable{
+		AfterThis:  `id="{{.accountID}}-balance">`,
+		BeforeThis: "<",
+		Name:       "balance",
+		Templated:  true,
+
+		MaxLength:   -1,
+		MinLength:   -1,
+		MatchRegexp: `{{.intPart}}\.[0-9]+`,
+	}
+	_, value, err := ex.Extract(body, map[string]interface{}{"accountID": "222", "intPart": "20"})
+	assert.Nil(t, err)
+	assert.Equal(t, "20.00", value)
+	assert.Equal(t, `id="{{.accountID}}-balance">`, ex.AfterThis)
+
+	_, _, err = ex.Extract(body, map[string]interface{}{"accountID": "222", "intPart": "10"})
+	assert.NotNil(t, err)
+}
+
+func TestFlow_ExecuteRecovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<input name="csrf_token" value="abc123">`)
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:    "login page",
+		Request: Request{URL: srv.URL, Method: "GET"},
+		KeysOutput: []Extracter{
+			Extractable{Name: "csrf", AfterThis: `name="csrf" value="`, BeforeThis: `"`, MaxLength: -1, MinLength: -1},
+		},
+		Recovery: map[string]Extracter{
+			"csrf": ExtracterFunc(func(body string, v map[string]interface{}) (string, string, error) {
+				m := regexp.MustCompile(`name="csrf\w*" value="(\w+)"`).FindStringSubmatch(body)
+				if m == nil {
+					return "csrf", "", errors.New("not found")
+				}
+				return "csrf", m[1], nil
+			}),
+		},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "abc123", f.Values["csrf"])
+	recovered := f.Steps[0].Response.Recovered
+	assert.Len(t, recovered, 1)
+	assert.Equal(t, "csrf", recovered[0].Name)
+	assert.EqualError(t, recovered[0].Err, "not found")
+}
+
+func TestExtractable_ExtractOccurrence(t *testing.T) {
+	body := `
+		<ul id="savings"><li>[S1]</li><li>[S2]</li><li>[S3]</li></ul>
+		<ul id="checking"><li>[C1]</li><li>[C2]</li></ul>
+	`
+	ex := Extractable{AfterThis: "[", BeforeThis: "]", Name: "x", MaxLength: -1, MinLength: -1}
+	cases := []struct {
+		occurrence int
+		backwards  bool
+		start, end string
+		exp        string
+	}{
+		{0, false, "", "", "S1"},
+		{2, false, "", "", "S2"},
+		{-1, false, "", "", "C2"},
+		{0, true, "", "", "C2"},
+		{2, true, "", "", "C1"},
+		{-1, true, "", "", "S1"},
+		{0, false, `id="checking"`, "", "C1"},
+		{-1, false, `id="savings"`, "</ul>", "S3"},
+		{0, true, "", `id="checking"`, "S3"},
+	}
+	for _, c := range cases {
+		ex.Occurrence, ex.SearchBackwards, ex.WindowStart, ex.WindowEnd = c.occurrence, c.backwards, c.start, c.end
+		_, value, err := ex.Extract(body, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, c.exp, value, fmt.Sprintf("%+v", c))
+	}
+
+	ex = Extractable{AfterThis: "[", BeforeThis: "]", Name: "x", MaxLength: -1, MinLength: -1, Occurrence: 6}
+	_, _, err := ex.Extract(body, nil)
+	assert.EqualError(t, err, "not found")
+
+	ex = Extractable{AfterThis: "[", BeforeThis: "]", Name: "x", MaxLength: -1, MinLength: -1,
+		WindowStart: `id="{{.account}}"`, WindowEnd: "</ul>", Iterate: true, Templated: true, SearchBackwards: true, MatchRegexp: "C[0-9]"}
+	_, value, err := ex.Extract(body, map[string]interface{}{"account": "checking"})
+	assert.Nil(t, err)
+	assert.Equal(t, "C2", value)
+
+	ex.WindowStart = `id="credit"`
+	_, _, err = ex.Extract(body, map[string]interface{}{})
+	assert.EqualError(t, err, "window not found")
+}
+
+func TestExtractable_ExtractAnchor(t *testing.T) {
+	body := `
+		<tr><td>FR76 1111</td><td class="balance">10.00</td></tr>
+		<tr><td>FR76 2222</td><td class="note">x</td><td class="balance">20.00</td></tr>
+	`
+	ex := Extractable{
+		AfterThis: `class="balance">`, BeforeThis: "<", Name: "balance", MaxLength: -1, MinLength: -1,
+		AnchorValue: "iban",
+	}
+	_, value, err := ex.Extract(body, map[string]interface{}{"iban": "FR76 2222"})
+	assert.Nil(t, err)
+	assert.Equal(t, "20.00", value)
+
+	_, _, err = ex.Extract(body, map[string]interface{}{"iban": "FR76 3333"})
+	assert.EqualError(t, err, "anchor value 'iban' (FR76 3333) not found")
+	_, _, err = ex.Extract(body, nil)
+	assert.EqualError(t, err, "anchor value 'iban' is missing")
+
+	ex = Extractable{
+		AfterThis: `">`, BeforeThis: "<", Name: "note", MaxLength: -1, MinLength: -1,
+		AnchorValue: "iban", Anchor: `class="{{.class}}`, Templated: true,
+	}
+	_, value, err = ex.Extract(body, map[string]interface{}{"iban": "FR76 2222", "class": "note"})
+	assert.Nil(t, err)
+	assert.Equal(t, "x", value)
+}
+
+func TestStep_NoTemplating(t *testing.T) {
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, r.URL.Path+" "+string(b))
+	}))
+	defer srv.Close()
+
+	handlebars := `<p>{{#each items}}{{name}}{{/each}}</p>`
+	binary := []byte("\x89PNG\x00{{\xff}}")
+	f := Flow{Steps: []Step{
+		{Name: "template", Request: Request{URL: srv.URL + "/{{.dir}}/template", Method: "POST", Body: handlebars},
+			KeysInput: []string{"dir"}, NoTemplating: true},
+		{Name: "image", Request: Request{URL: srv.URL + "/{{.dir}}/image", Method: "POST", Body: binary},
+			KeysInput: []string{"dir"}},
+	}}
+	assert.Nil(t, f.Compile())
+	assert.Nil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"dir": "upload"}))
+	assert.Equal(t, []string{"/upload/template " + handlebars, "/upload/image " + string(binary)}, received)
+
+	// templated, the Handlebars body is rejected
+	f.Steps[0].NoTemplating = false
+	assert.NotNil(t, f.Compile())
+	assert.NotNil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"dir": "upload"}))
+}
+
+func TestExtractable_ExtractLiteralBraces(t *testing.T) {
+	body := `<span>{{ user.name }}</span><b>{{x}}</b>`
+	ex := Extractable{Name: "b", AfterThis: "<b>{{", BeforeThis: "}}</b>", MaxLength: -1, MinLength: -1}
+	_, value, err := ex.Extract(body, map[string]interface{}{"x": "y"})
+	assert.Nil(t, err)
+	assert.Equal(t, "x", value)
+
+	ex = Extractable{Name: "name", AfterThis: "<span>{{ ", BeforeThis: " }}", MaxLength: -1, MinLength: -1}
+	_, value, err = ex.Extract(body, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "user.name", value)
+}
+
+func TestStep_ReplaceInBodyForm(t *testing.T) {
+	s := Step{Name: "login", KeysInput: []string{"user"},
+		Request: Request{Method: "POST", Body: url.Values{"user": {"{{.user}}"}, "lang": {"en"}}}}
+	assert.Nil(t, s.ReplaceInBody(map[string]interface{}{"user": "bob&co"}, 0))
+	// still a form, for the hidden fields and CSRF field to be added
+	assert.Equal(t, url.Values{"user": {"bob&co"}, "lang": {"en"}}, s.Request.Body)
+
+	s.Request.Body = url.Values{"user": {"{{.user"}}
+	assert.NotNil(t, s.ReplaceInBody(map[string]interface{}{"user": "bob"}, 0))
+}
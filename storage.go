@@ -0,0 +1,151 @@
+package httpsim
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// StorageKind is the kind of web storage, see Storage
+type StorageKind int
+
+const (
+	// LocalStorage persists across the executions of the flow, and is shared by
+	// its copies
+	LocalStorage StorageKind = iota
+	// SessionStorage is cleared when an execution starts, like a new tab's, and
+	// each copy of the flow has its own
+	SessionStorage
+)
+
+// Storage emulates the browsers' web storage (localStorage, sessionStorage), by
+// origin, for the tokens sites keep there rather than in cookies. Whatever
+// drives the browser parts of a flow populates it, and the steps' templates
+// read the storage of their URL's origin: {{.localStorage.token}},
+// {{.sessionStorage.token}}. It's safe for concurrent use.
+type Storage struct {
+	areas [2]*storageArea
+}
+
+// storageArea holds the items of a kind of storage, by origin
+type storageArea struct {
+	mu      sync.Mutex
+	origins map[string]map[string]string
+}
+
+// NewStorage creates an empty storage
+func NewStorage() *Storage {
+	return &Storage{areas: [2]*storageArea{newStorageArea(), newStorageArea()}}
+}
+
+func newStorageArea() *storageArea {
+	return &storageArea{origins: map[string]map[string]string{}}
+}
+
+// StorageOrigin returns the origin (scheme://host:port) of the URL, the key of
+// the storages
+func StorageOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// SetItem stores the item in the origin's storage, the origin may be any of its
+// URLs
+func (s *Storage) SetItem(kind StorageKind, origin, key, value string) {
+	a := s.areas[kind]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	origin = StorageOrigin(origin)
+	items := a.origins[origin]
+	if items == nil {
+		items = map[string]string{}
+		a.origins[origin] = items
+	}
+	items[key] = value
+}
+
+// GetItem returns the item of the origin's storage, and whether it's set
+func (s *Storage) GetItem(kind StorageKind, origin, key string) (string, bool) {
+	a := s.areas[kind]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v, ok := a.origins[StorageOrigin(origin)][key]
+	return v, ok
+}
+
+// RemoveItem removes the item from the origin's storage
+func (s *Storage) RemoveItem(kind StorageKind, origin, key string) {
+	a := s.areas[kind]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.origins[StorageOrigin(origin)], key)
+}
+
+// Items returns a copy of the items of the origin's storage
+func (s *Storage) Items(kind StorageKind, origin string) map[string]string {
+	a := s.areas[kind]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	items := map[string]string{}
+	for k, v := range a.origins[StorageOrigin(origin)] {
+		items[k] = v
+	}
+	return items
+}
+
+// copy returns a storage sharing the localStorage with s, and with a copy of its
+// sessionStorage, nil when s is
+func (s *Storage) copy() *Storage {
+	if s == nil {
+		return nil
+	}
+	session := s.areas[SessionStorage]
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	c := newStorageArea()
+	for origin, items := range session.origins {
+		copied := make(map[string]string, len(items))
+		for k, v := range items {
+			copied[k] = v
+		}
+		c.origins[origin] = copied
+	}
+	return &Storage{areas: [2]*storageArea{s.areas[LocalStorage], c}}
+}
+
+// clearSession clears the sessionStorage of all the origins
+func (s *Storage) clearSession() {
+	a := s.areas[SessionStorage]
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.origins = map[string]map[string]string{}
+}
+
+// templateValues returns the values the step's templates are executed with:
+// the flow's, and the storage of the step's origin when the flow has a Storage
+func (f *Flow) templateValues(step *Step) map[string]interface{} {
+	if f.Storage == nil {
+		return f.Values
+	}
+	vals := make(map[string]interface{}, len(f.Values)+2)
+	for k, v := range f.Values {
+		vals[k] = v
+	}
+	// the origin is the rendered URL's, or its static prefix's when the URL
+	// reads the storage itself
+	origin := step.Request.URL
+	delims := f.delims(step)
+	if i := strings.Index(origin, delims.left()); i != -1 {
+		if rendered, err := delims.replace(f.Values, origin); err == nil {
+			origin = rendered
+		} else {
+			origin = origin[:i]
+		}
+	}
+	vals["localStorage"] = f.Storage.Items(LocalStorage, origin)
+	vals["sessionStorage"] = f.Storage.Items(SessionStorage, origin)
+	return vals
+}
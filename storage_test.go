@@ -0,0 +1,101 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage(t *testing.T) {
+	s := NewStorage()
+	s.SetItem(LocalStorage, "https://App.example.com/login", "token", "t1")
+	v, ok := s.GetItem(LocalStorage, "https://app.example.com", "token")
+	assert.True(t, ok)
+	assert.Equal(t, "t1", v)
+	_, ok = s.GetItem(LocalStorage, "https://app.example.com:8443", "token")
+	assert.False(t, ok)
+	_, ok = s.GetItem(SessionStorage, "https://app.example.com", "token")
+	assert.False(t, ok)
+
+	s.SetItem(SessionStorage, "https://app.example.com", "tab", "1")
+	assert.Equal(t, map[string]string{"tab": "1"}, s.Items(SessionStorage, "https://app.example.com/x"))
+	s.clearSession()
+	assert.Empty(t, s.Items(SessionStorage, "https://app.example.com"))
+	s.RemoveItem(LocalStorage, "https://app.example.com", "token")
+	assert.Empty(t, s.Items(LocalStorage, "https://app.example.com"))
+}
+
+func TestFlow_Storage(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Storage: NewStorage(),
+		Steps: []Step{{Name: "me", Request: Request{URL: srv.URL + "/api/me", Method: "GET",
+			Header: http.Header{"Authorization": {"Bearer {{.localStorage.token}}"}}}}},
+	}
+	// e.g. set by the browser part of the flow
+	f.Storage.SetItem(LocalStorage, srv.URL, "token", "t1")
+	f.Storage.SetItem(SessionStorage, srv.URL, "tab", "1")
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, []string{"Bearer t1"}, auth)
+	// an execution is a new tab
+	_, ok := f.Storage.GetItem(SessionStorage, srv.URL, "tab")
+	assert.False(t, ok)
+	assert.Equal(t, "Bearer {{.localStorage.token}}", f.Steps[0].Request.Header.Get("Authorization"))
+}
+
+func TestFlow_StorageTemplatedURL(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Storage: NewStorage(),
+		Steps: []Step{
+			{Name: "me", Request: Request{URL: "{{.site}}/api/me", Method: "GET",
+				Header: http.Header{"Authorization": {"Bearer {{.localStorage.token}}"}}}, KeysInput: []string{"site"}},
+			{Name: "stored", Request: Request{URL: srv.URL + "/{{.localStorage.path}}", Method: "GET",
+				Header: http.Header{"Authorization": {"Bearer {{.localStorage.token}}"}}}},
+		},
+	}
+	f.Storage.SetItem(LocalStorage, srv.URL, "token", "t1")
+	f.Storage.SetItem(LocalStorage, srv.URL, "path", "api/me")
+	assert.Nil(t, f.Execute(map[string]interface{}{"site": srv.URL}))
+	assert.Equal(t, []string{"Bearer t1", "Bearer t1"}, auth)
+}
+
+func TestCompiledFlow_Storage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	f := Flow{Storage: NewStorage(), Steps: []Step{{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}}}}
+	f.Storage.SetItem(SessionStorage, srv.URL, "tab", "1")
+	c, err := f.CompileFlow()
+	assert.Nil(t, err)
+	run, err := c.Run(nil)
+	assert.Nil(t, err)
+	run.Storage.SetItem(LocalStorage, srv.URL, "token", "t1")
+
+	run.Storage.SetItem(SessionStorage, srv.URL, "tab", "2")
+
+	// the runs share the localStorage, not the sessionStorage
+	v, ok := f.Storage.GetItem(LocalStorage, srv.URL, "token")
+	assert.True(t, ok)
+	assert.Equal(t, "t1", v)
+	next, err := c.Run(nil)
+	assert.Nil(t, err)
+	v, _ = next.Storage.GetItem(LocalStorage, srv.URL, "token")
+	assert.Equal(t, "t1", v)
+	v, _ = f.Storage.GetItem(SessionStorage, srv.URL, "tab")
+	assert.Equal(t, "1", v)
+	_, ok = next.Storage.GetItem(SessionStorage, srv.URL, "tab")
+	assert.False(t, ok)
+}
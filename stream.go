@@ -0,0 +1,269 @@
+package httpsim
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// StreamExtracter is an Extracter that extracts its value while the body is being
+// read, with bounded memory, for steps with StreamBody set (multi-hundred-MB exports)
+type StreamExtracter interface {
+	Extracter
+	ExtractStream(r io.Reader, values map[string]interface{}) (name, value string, err error)
+}
+
+// defaultStreamMaxLength is the default max length of a streamed value
+const defaultStreamMaxLength = 64 * 1024
+
+// StreamExtractable extracts the first string between AfterThis and BeforeThis while
+// reading the body. Only the value being extracted is kept in memory.
+type StreamExtractable struct {
+	AfterThis  string
+	BeforeThis string
+	// Name is the name of the string to be extracted
+	Name string
+	// MaxLength is the max length of the value, 64KiB when 0
+	MaxLength int
+	// IgnoreNotFound set to true if you want to ignore errors when not found
+	IgnoreNotFound bool
+}
+
+// Extract extracts the value out of the body string
+func (e StreamExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	return e.ExtractStream(strings.NewReader(body), v)
+}
+
+// ExtractStream extracts the value while reading r
+func (e StreamExtractable) ExtractStream(r io.Reader, v map[string]interface{}) (string, string, error) {
+	if e.AfterThis == "" || e.BeforeThis == "" {
+		return e.Name, "", errors.New("AfterThis and BeforeThis are required")
+	}
+	max := e.MaxLength
+	if max == 0 {
+		max = defaultStreamMaxLength
+	}
+	br := bufio.NewReader(r)
+	after, before := newKMP(e.AfterThis), newKMP(e.BeforeThis)
+
+	found := false
+	for !found {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return e.notFound()
+		} else if err != nil {
+			return e.Name, "", err
+		}
+		found = after.feed(b)
+	}
+	var value []byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return e.notFound()
+		} else if err != nil {
+			return e.Name, "", err
+		}
+		value = append(value, b)
+		if before.feed(b) {
+			return e.Name, string(value[:len(value)-len(e.BeforeThis)]), nil
+		}
+		if len(value) > max+len(e.BeforeThis) {
+			return e.Name, "", fmt.Errorf("max length of %d reached", max)
+		}
+	}
+}
+
+func (e StreamExtractable) notFound() (string, string, error) {
+	if e.IgnoreNotFound {
+		return e.Name, "", nil
+	}
+	return e.Name, "", errors.New("not found")
+}
+
+// StreamRegexpExtractable extracts the first submatch (or the whole match) of Regexp
+// while reading the body, searching windows of Window bytes overlapping by Window
+// bytes: matches must be shorter than Window to be found, the search fails once
+// the match read reaches it. A match is only taken once the Window bytes
+// following it are read, so it isn't cut at a read.
+type StreamRegexpExtractable struct {
+	Regexp string
+	// Name is the name of the string to be extracted
+	Name string
+	// Window is the size of the windows searched, 64KiB when 0
+	Window int
+	// IgnoreNotFound set to true if you want to ignore errors when not found
+	IgnoreNotFound bool
+}
+
+// Extract extracts the value out of the body string
+func (e StreamRegexpExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	return e.ExtractStream(strings.NewReader(body), v)
+}
+
+// ExtractStream extracts the value while reading r
+func (e StreamRegexpExtractable) ExtractStream(r io.Reader, v map[string]interface{}) (string, string, error) {
+	re, err := compileRegexp(e.Regexp)
+	if err != nil {
+		return e.Name, "", err
+	}
+	window := e.Window
+	if window == 0 {
+		window = defaultStreamMaxLength
+	}
+	buf := make([]byte, 0, 3*window)
+	chunk := make([]byte, window)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		buf = append(buf, chunk[:n]...)
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		// keep the last window for matches spanning two reads
+		keep := len(buf) - window
+		if loc := re.FindSubmatchIndex(buf); loc != nil {
+			// a match ending in the last window may go on in the next read
+			if eof || loc[1] <= len(buf)-window {
+				if g := len(loc) - 2; loc[g] >= 0 {
+					return e.Name, string(buf[loc[g]:loc[g+1]]), nil
+				}
+				return e.Name, "", nil
+			}
+			// the match is kept until it ends, up to the Window
+			if loc[1]-loc[0] >= window {
+				return e.notFound()
+			}
+			if loc[0] < keep {
+				keep = loc[0]
+			}
+		}
+		if eof {
+			return e.notFound()
+		} else if err != nil {
+			return e.Name, "", err
+		}
+		if keep > 0 {
+			buf = append(buf[:0], buf[keep:]...)
+		}
+	}
+}
+
+func (e StreamRegexpExtractable) notFound() (string, string, error) {
+	if e.IgnoreNotFound {
+		return e.Name, "", nil
+	}
+	return e.Name, "", errors.New("not found")
+}
+
+// kmp finds a pattern in a stream of bytes (Knuth-Morris-Pratt)
+type kmp struct {
+	pattern []byte
+	fail    []int
+	matched int
+}
+
+func newKMP(pattern string) *kmp {
+	k := &kmp{pattern: []byte(pattern), fail: make([]int, len(pattern))}
+	for i, j := 1, 0; i < len(k.pattern); i++ {
+		for j > 0 && k.pattern[i] != k.pattern[j] {
+			j = k.fail[j-1]
+		}
+		if k.pattern[i] == k.pattern[j] {
+			j++
+		}
+		k.fail[i] = j
+	}
+	return k
+}
+
+// feed returns true when b completes an occurrence of the pattern
+func (k *kmp) feed(b byte) bool {
+	for k.matched > 0 && b != k.pattern[k.matched] {
+		k.matched = k.fail[k.matched-1]
+	}
+	if b == k.pattern[k.matched] {
+		k.matched++
+	}
+	if k.matched == len(k.pattern) {
+		k.matched = k.fail[k.matched-1]
+		return true
+	}
+	return false
+}
+
+// extractStream reads the body once, giving it to all the step's StreamExtracters
+// concurrently, and stores their values in order
+func (f *Flow) extractStream(i int, step *Step, body io.Reader) error {
+	type result struct {
+		n, s string
+		err  error
+	}
+	var (
+		wg      sync.WaitGroup
+		writers []io.Writer
+		pipes   []*io.PipeWriter
+		results = make([]result, len(step.KeysOutput))
+	)
+	// checked before any extracter starts reading
+	for _, e := range step.KeysOutput {
+		if _, ok := e.(StreamExtracter); !ok {
+			return fmt.Errorf("Step %d.'%s' streams its body but %T isn't a StreamExtracter", i, step.Name, e)
+		}
+	}
+	for j, e := range step.KeysOutput {
+		se := e.(StreamExtracter)
+		pr, pw := io.Pipe()
+		writers = append(writers, pw)
+		pipes = append(pipes, pw)
+		wg.Add(1)
+		go func(j int, se StreamExtracter) {
+			defer wg.Done()
+			defer func() {
+				if p := recovered(recover()); p != nil {
+					results[j].err = p
+				}
+				// keep reading so the other extracters aren't blocked
+				io.Copy(ioutil.Discard, pr)
+			}()
+			n, s, err := se.ExtractStream(pr, f.Values)
+			results[j] = result{n, s, err}
+		}(j, se)
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), body)
+	for _, pw := range pipes {
+		pw.CloseWithError(err)
+	}
+	wg.Wait()
+	if lerr, ok := err.(*BodyLimitError); ok {
+		return lerr
+	} else if err != nil {
+		return fmt.Errorf("Step %d.'%s' failed reading body: %s", i, step.Name, err.Error())
+	}
+
+	for _, r := range results {
+		if p, ok := r.err.(*panicError); ok {
+			return p
+		}
+	}
+	for _, r := range results {
+		if r.err != nil {
+			return &ExtractionError{Step: i, StepName: step.Name, Value: r.n, Err: r.err}
+		}
+		if r.n == "" {
+			return fmt.Errorf("Step %d.'%s' failed because extracted value has no index %s",
+				i, step.Name, r.s)
+		}
+		s, err := f.normalize(step, r.n, r.s)
+		if err != nil {
+			return &ExtractionError{Step: i, StepName: step.Name, Value: r.n, Err: err}
+		}
+		if err := f.checkWrite(i, step, r.n); err != nil {
+			return err
+		}
+		f.Values[r.n] = s
+		f.provenance(i, step, r.n, false)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package httpsim
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamExtractable_ExtractStream(t *testing.T) {
+	body := strings.Repeat("noise ", 100000) + `"total": "1234.56", ` + strings.Repeat("more ", 100000)
+
+	ex := StreamExtractable{Name: "total", AfterThis: `"total": "`, BeforeThis: `"`}
+	_, value, err := ex.ExtractStream(strings.NewReader(body), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1234.56", value)
+
+	// overlapping prefixes
+	ex = StreamExtractable{Name: "x", AfterThis: "aab", BeforeThis: "bba"}
+	_, value, err = ex.Extract("aaaabxyzbbbbba", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "xyzbbb", value)
+
+	ex = StreamExtractable{Name: "x", AfterThis: "noise", BeforeThis: "never", MaxLength: 10}
+	_, _, err = ex.ExtractStream(strings.NewReader(body), nil)
+	assert.EqualError(t, err, "max length of 10 reached")
+
+	ex = StreamExtractable{Name: "x", AfterThis: "absent", BeforeThis: "x"}
+	_, _, err = ex.ExtractStream(strings.NewReader(body), nil)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestStreamRegexpExtractable_ExtractStream(t *testing.T) {
+	body := strings.Repeat("x", 1000) + "id=98765;" + strings.Repeat("y", 1000)
+	ex := StreamRegexpExtractable{Name: "id", Regexp: `id=(\d+);`, Window: 16}
+	_, value, err := ex.ExtractStream(strings.NewReader(body), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "98765", value)
+
+	ex.Regexp = `id=(\d+)!`
+	_, _, err = ex.ExtractStream(strings.NewReader(body), nil)
+	assert.EqualError(t, err, "not found")
+
+	// greedy matches aren't cut at the end of a read
+	ex.Regexp = `\d+`
+	_, value, err = ex.ExtractStream(strings.NewReader(strings.Repeat("x", 14)+"12345678;"+strings.Repeat("y", 40)), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "12345678", value)
+	_, value, err = ex.ExtractStream(strings.NewReader(strings.Repeat("x", 30)+"1234"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", value)
+
+	// a match going on doesn't grow the buffer past the Window
+	_, _, err = ex.ExtractStream(endless('7'), nil)
+	assert.EqualError(t, err, "not found")
+	ex.IgnoreNotFound = true
+	_, value, err = ex.ExtractStream(endless('7'), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+}
+
+// endless is a reader of the byte, never ending
+type endless byte
+
+func (b endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestFlow_ExecuteStreamBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(w, "row %d;", i)
+		}
+		fmt.Fprint(w, "<total>42</total><count>1000</count>")
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:       "export",
+		Request:    Request{URL: srv.URL, Method: "GET"},
+		StreamBody: true,
+		KeysOutput: []Extracter{
+			StreamExtractable{Name: "first", AfterThis: "row ", BeforeThis: ";"},
+			StreamExtractable{Name: "total", AfterThis: "<total>", BeforeThis: "</total>"},
+			StreamRegexpExtractable{Name: "count", Regexp: `<count>(\d+)</count>`},
+		},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "0", f.Values["first"])
+	assert.Equal(t, "42", f.Values["total"])
+	assert.Equal(t, "1000", f.Values["count"])
+	assert.Nil(t, f.Steps[0].Response.Body)
+
+	f.Steps[0].KeysOutput = append(f.Steps[0].KeysOutput, Extractable{Name: "x"})
+	assert.EqualError(t, f.Execute(nil), "Step 0.'export' streams its body but httpsim.Extractable isn't a StreamExtracter")
+
+	// checked before any stream extracter starts, so none is left blocked
+	goroutines := runtime.NumGoroutine()
+	step := &Step{Name: "export", KeysOutput: []Extracter{StreamExtractable{Name: "a", AfterThis: "<", BeforeThis: ">"},
+		Extractable{Name: "b"}}}
+	assert.NotNil(t, f.extractStream(0, step, strings.NewReader("<a>")))
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, runtime.NumGoroutine() <= goroutines, "leaked goroutine")
+}
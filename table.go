@@ -0,0 +1,183 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValueExtracter is an Extracter producing a non string value (records, lists...)
+// which is stored as is in the values, usable with templates e.g. {{range .accounts}}
+type ValueExtracter interface {
+	Extracter
+	ExtractValue(body string, values map[string]interface{}) (name string, value interface{}, err error)
+}
+
+// TableExtractable parses an HTML table, or repeated blocks, into records: a
+// []map[string]string stored in the values under Name (e.g. accounts lists,
+// transactions). Columns are keyed by their header text.
+type TableExtractable struct {
+	// Name is the name of the value extracted
+	Name string
+	// Table is the id or a class of the table, the first table of the body is used
+	// when empty
+	Table string
+	// Templated renders the Table as a template with the values, with the
+	// step's Delims
+	Templated bool
+	// Columns maps header texts (case insensitive) to record keys, only these columns
+	// are kept. All columns are kept under their header text when nil.
+	Columns map[string]string
+	// SkipEmpty drops the records whose cells are all empty
+	SkipEmpty bool
+
+	// Block, instead of a table, is the class of repeated elements (e.g. "account")
+	// each giving a record. Fields then maps the class of an element in the block
+	// to its key in the record.
+	Block  string
+	Fields map[string]string
+
+	delims Delims
+}
+
+func (e TableExtractable) withDelims(d Delims) Extracter {
+	e.delims = d
+	return e
+}
+
+// Extract extracts the records, JSON encoded
+func (e TableExtractable) Extract(body string, v map[string]interface{}) (string, string, error) {
+	n, records, err := e.ExtractValue(body, v)
+	if err != nil {
+		return n, "", err
+	}
+	b, err := json.Marshal(records)
+	return n, string(b), err
+}
+
+// ExtractValue extracts the records as []map[string]string
+func (e TableExtractable) ExtractValue(body string, v map[string]interface{}) (string, interface{}, error) {
+	doc := parseHTML(body)
+	var (
+		records []map[string]string
+		err     error
+	)
+	if e.Block != "" {
+		records = e.blocks(doc)
+	} else if records, err = e.table(doc, v); err != nil {
+		return e.Name, nil, err
+	}
+
+	if e.SkipEmpty {
+		kept := records[:0]
+		for _, r := range records {
+			for _, cell := range r {
+				if cell != "" {
+					kept = append(kept, r)
+					break
+				}
+			}
+		}
+		records = kept
+	}
+	if records == nil {
+		records = []map[string]string{}
+	}
+	return e.Name, records, nil
+}
+
+func (e TableExtractable) table(doc *htmlNode, v map[string]interface{}) ([]map[string]string, error) {
+	selector := e.Table
+	if e.Templated && strings.Contains(selector, e.delims.left()) {
+		var err error
+		if selector, err = e.delims.replace(v, selector); err != nil {
+			return nil, err
+		}
+	}
+	tables := doc.find(func(n *htmlNode) bool {
+		return n.Tag == "table" && (selector == "" || n.Attrs["id"] == selector || n.hasClass(selector))
+	})
+	if len(tables) == 0 {
+		return nil, errors.New("table not found")
+	}
+	table := tables[0]
+
+	// the rows of this table, not of nested ones
+	var rows [][]*htmlNode
+	for _, tr := range table.findTag("tr") {
+		if closest(tr, "table") != table {
+			continue
+		}
+		var cells []*htmlNode
+		for _, c := range tr.Children {
+			if c.Tag == "td" || c.Tag == "th" {
+				cells = append(cells, c)
+			}
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("table has no rows")
+	}
+
+	var headers []string
+	for _, cell := range rows[0] {
+		headers = append(headers, cell.text())
+	}
+	keys := make([]string, len(headers))
+	for i, h := range headers {
+		keys[i] = h
+		if e.Columns != nil {
+			keys[i] = ""
+			for header, key := range e.Columns {
+				if strings.EqualFold(header, h) {
+					keys[i] = key
+				}
+			}
+		}
+	}
+	for header := range e.Columns {
+		if csvColumn(headers, header) == -1 {
+			return nil, fmt.Errorf("no column '%s'", header)
+		}
+	}
+
+	var records []map[string]string
+	for _, row := range rows[1:] {
+		record := map[string]string{}
+		for i, cell := range row {
+			if i < len(keys) && keys[i] != "" {
+				record[keys[i]] = cell.text()
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (e TableExtractable) blocks(doc *htmlNode) []map[string]string {
+	var records []map[string]string
+	for _, block := range doc.find(func(n *htmlNode) bool { return n.hasClass(e.Block) }) {
+		record := map[string]string{}
+		for class, key := range e.Fields {
+			if found := block.find(func(n *htmlNode) bool { return n.hasClass(class) }); len(found) != 0 {
+				record[key] = found[0].text()
+			} else {
+				record[key] = ""
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// closest returns the closest ancestor with the tag
+func closest(n *htmlNode, tag string) *htmlNode {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Tag == tag {
+			return p
+		}
+	}
+	return nil
+}
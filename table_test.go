@@ -0,0 +1,71 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testAccountsPage = `
+<table id="layout"><tr><td>
+	<table id="accounts" class="data">
+		<thead><tr><th>Account</th><th>IBAN</th><th> Balance </th></tr></thead>
+		<tbody>
+		<tr><td>Checking</td><td>FR76 1111</td><td>1 234,56 &euro;</td>
+		<tr><td>Savings</td><td>FR76 2222</td><td><span>10,00</span> &euro;</td>
+		<tr><td></td><td></td><td></td>
+		</tbody>
+	</table>
+</td></tr></table>
+<div class="card account"><h3 class="name">Checking</h3><p class="balance">1 234,56</p></div>
+<div class="card account"><h3 class="name">Savings</h3></div>
+`
+
+func TestTableExtractable_ExtractValue(t *testing.T) {
+	ex := TableExtractable{Name: "accounts", Table: "data", SkipEmpty: true}
+	_, records, err := ex.ExtractValue(testAccountsPage, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{
+		{"Account": "Checking", "IBAN": "FR76 1111", "Balance": "1 234,56 €"},
+		{"Account": "Savings", "IBAN": "FR76 2222", "Balance": "10,00 €"},
+	}, records)
+
+	ex = TableExtractable{Name: "accounts", Table: "{{.table}}", Templated: true, Columns: map[string]string{"iban": "iban", "balance": "balance"}}
+	_, records, err = ex.ExtractValue(testAccountsPage, map[string]interface{}{"table": "accounts"})
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, map[string]string{"iban": "FR76 1111", "balance": "1 234,56 €"}, records.([]map[string]string)[0])
+
+	ex.Columns = map[string]string{"Currency": "currency"}
+	_, _, err = ex.ExtractValue(testAccountsPage, map[string]interface{}{"table": "accounts"})
+	assert.EqualError(t, err, "no column 'Currency'")
+
+	ex = TableExtractable{Name: "accounts", Block: "account", Fields: map[string]string{"name": "name", "balance": "balance"}}
+	_, records, err = ex.ExtractValue(testAccountsPage, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "Checking", "balance": "1 234,56"},
+		{"name": "Savings", "balance": ""},
+	}, records)
+}
+
+func TestFlow_ExecuteTableExtractable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testAccountsPage))
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{
+		Name:       "accounts",
+		Request:    Request{URL: srv.URL, Method: "GET"},
+		KeysOutput: []Extracter{TableExtractable{Name: "accounts", Table: "accounts", SkipEmpty: true}},
+	}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Len(t, f.Values["accounts"], 2)
+
+	out, err := replaceInString(f.Values, "{{range .accounts}}{{.IBAN}};{{end}}")
+	assert.Nil(t, err)
+	assert.Equal(t, "FR76 1111;FR76 2222;", out)
+}
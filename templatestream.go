@@ -0,0 +1,48 @@
+package httpsim
+
+import (
+	"io"
+	"text/template"
+)
+
+// templateStream is a request body rendered while it's sent, see
+// Step.StreamTemplate
+type templateStream struct {
+	tpl  *template.Template
+	vals map[string]interface{}
+}
+
+// newTemplateStream returns the stream of the templated string or []byte body,
+// nil for the other bodies
+func newTemplateStream(delims Delims, vals map[string]interface{}, body interface{}) (*templateStream, error) {
+	var text string
+	switch t := body.(type) {
+	case string:
+		text = t
+	case []byte:
+		text = string(t)
+	default:
+		return nil, nil
+	}
+	tpl, err := delims.parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// the rendering may outlive the request, e.g. when the server responds
+	// before reading the whole body, while the next steps change the values
+	copied := make(map[string]interface{}, len(vals))
+	for k, v := range vals {
+		copied[k] = v
+	}
+	return &templateStream{tpl: tpl, vals: copied}, nil
+}
+
+// reader executes the template into a pipe read by the request. The
+// transport closes the body, stopping the rendering, when the request fails.
+func (s *templateStream) reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.tpl.Execute(pw, s.vals))
+	}()
+	return pr
+}
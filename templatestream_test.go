@@ -0,0 +1,63 @@
+package httpsim
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStep_StreamTemplate(t *testing.T) {
+	var (
+		length int64
+		got    int64
+		sum    string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		length = r.ContentLength
+		h := sha256.New()
+		got, _ = io.Copy(h, r.Body)
+		sum = fmt.Sprintf("%x", h.Sum(nil))
+	}))
+	defer srv.Close()
+
+	rows := make([]int, 20000)
+	for i := range rows {
+		rows[i] = i
+	}
+	body := `[{{range $i, $r := .rows}}{{if $i}},{{end}}{"id": {{$r}}, "owner": "{{$.owner}}"}{{end}}]`
+	var want strings.Builder
+	want.WriteString("[")
+	for i := range rows {
+		if i > 0 {
+			want.WriteString(",")
+		}
+		fmt.Fprintf(&want, `{"id": %d, "owner": "bob"}`, i)
+	}
+	want.WriteString("]")
+
+	f := Flow{WireDumps: 1, Steps: []Step{{Name: "import", Request: Request{URL: srv.URL + "/bulk", Method: "POST", Body: body},
+		KeysInput: []string{"rows", "owner"}, StreamTemplate: true}}}
+	assert.Nil(t, f.ExecuteContext(context.Background(), map[string]interface{}{"rows": rows, "owner": "bob"}))
+	assert.Equal(t, int64(-1), length)
+	assert.Equal(t, int64(want.Len()), got)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(want.String()))), sum)
+	// the definition isn't modified
+	assert.Equal(t, body, f.Steps[0].Request.Body)
+
+	// the rendering failing fails the request
+	f.Steps[0].Request.Body = `{{range .rows}}{{.missing}}{{end}}`
+	err := f.ExecuteContext(context.Background(), map[string]interface{}{"rows": rows, "owner": "bob"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "can't evaluate field missing")
+
+	f.Steps[0].Request.Body = `{{.rows}}{{.owner}`
+	err = f.ExecuteContext(context.Background(), map[string]interface{}{"rows": rows, "owner": "bob"})
+	assert.Contains(t, err.Error(), "Step 0.'import' template: ")
+}
@@ -0,0 +1,132 @@
+package httpsim
+
+import (
+	"errors"
+	"fmt"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+)
+
+// ErrUnknownTenant is returned by Runner.RunTenant for the tenants never added
+var ErrUnknownTenant = errors.New("httpsim: unknown tenant")
+
+// Tenant is an account a Runner runs the flow for, see Runner.RunTenant. Its
+// runs share its cookie jar and values, and never another tenant's.
+type Tenant struct {
+	// Values are given to the tenant's runs (e.g. its credentials), the values
+	// given to RunTenant override them
+	Values map[string]interface{}
+	// Keep are the values output by the tenant's runs kept for its next runs,
+	// e.g. a session token
+	Keep []string
+	// MaxRuns is the max number of the tenant's runs started within Per, no
+	// limit when 0
+	MaxRuns int
+	Per     time.Duration
+}
+
+// TenantRateLimitError is returned by Runner.RunTenant when the tenant started
+// its MaxRuns runs already
+type TenantRateLimitError struct {
+	Tenant string
+	// RetryAfter is the time until the next run can start
+	RetryAfter time.Duration
+}
+
+func (e *TenantRateLimitError) Error() string {
+	return fmt.Sprintf("Tenant '%s' rate limited, retry after %s", e.Tenant, e.RetryAfter)
+}
+
+// tenantState is a tenant's config, values, cookie jar and runs started
+type tenantState struct {
+	id     string
+	config Tenant
+	jar    *cookiejar.Jar
+
+	mu      sync.Mutex
+	values  map[string]interface{}
+	started []time.Time
+}
+
+// AddTenant adds the tenant, or replaces its config. A replaced tenant keeps its
+// cookies and kept values.
+func (r *Runner) AddTenant(id string, t Tenant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.tenants == nil {
+		r.tenants = map[string]*tenantState{}
+	}
+	if state, ok := r.tenants[id]; ok {
+		state.mu.Lock()
+		state.config = t
+		state.mu.Unlock()
+		return nil
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	r.tenants[id] = &tenantState{id: id, config: t, jar: jar, values: map[string]interface{}{}}
+	return nil
+}
+
+// RemoveTenant forgets the tenant, its cookies and kept values
+func (r *Runner) RemoveTenant(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, id)
+}
+
+// RunTenant is Run for the tenant: the run gets the tenant's values and cookie
+// jar, and counts in its rate limit. It returns ErrUnknownTenant for the
+// tenants not added, and a *TenantRateLimitError when the tenant is rate
+// limited.
+func (r *Runner) RunTenant(id string, values map[string]interface{}) (*Flow, error) {
+	r.mu.Lock()
+	tenant, ok := r.tenants[id]
+	r.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownTenant
+	}
+	return r.run(values, tenant)
+}
+
+// start checks the tenant's rate limit, and gives the run its values and jar
+func (t *tenantState) start(f *Flow, values map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if t.config.MaxRuns > 0 {
+		recent := t.started[:0]
+		for _, s := range t.started {
+			if now.Sub(s) < t.config.Per {
+				recent = append(recent, s)
+			}
+		}
+		t.started = recent
+		if len(recent) >= t.config.MaxRuns {
+			return &TenantRateLimitError{Tenant: t.id, RetryAfter: t.config.Per - now.Sub(recent[0])}
+		}
+		t.started = append(t.started, now)
+	}
+	for k, v := range t.config.Values {
+		values[k] = v
+	}
+	for k, v := range t.values {
+		values[k] = v
+	}
+	f.CookieJar = t.jar
+	return nil
+}
+
+// keep stores the run's values the tenant keeps
+func (t *tenantState) keep(f *Flow) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range t.config.Keep {
+		if v, ok := f.Values[k]; ok && v != "" {
+			t.values[k] = v
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner_Tenants(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte("<u>" + c.Value + "</u><t>token-" + c.Value + "</t>"))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.URL.Query().Get("user"), Path: "/"})
+		w.Write([]byte("<u>anonymous</u><t>none</t>"))
+	}))
+	defer srv.Close()
+
+	user := Extractable{Name: "seen", AfterThis: "<u>", BeforeThis: "</u>", MaxLength: -1, MinLength: -1}
+	token := Extractable{Name: "token", AfterThis: "<t>", BeforeThis: "</t>", MaxLength: -1, MinLength: -1}
+	f := Flow{RequiredValues: []string{"user"}, Steps: []Step{{Name: "home", KeysInput: []string{"user"},
+		Request: Request{URL: srv.URL + "/?user={{.user}}", Method: "GET"}, KeysOutput: []Extracter{user, token}}}}
+	compiled, err := f.CompileFlow()
+	assert.Nil(t, err)
+	r := NewRunner(compiled)
+	assert.Nil(t, r.AddTenant("acme", Tenant{Values: map[string]interface{}{"user": "alice"}, Keep: []string{"token"}}))
+	assert.Nil(t, r.AddTenant("globex", Tenant{Values: map[string]interface{}{"user": "bob"}, MaxRuns: 2, Per: time.Hour}))
+
+	run, err := r.RunTenant("acme", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "anonymous", run.Values["seen"])
+	run, err = r.RunTenant("globex", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "anonymous", run.Values["seen"])
+
+	// each tenant gets its own session back
+	run, err = r.RunTenant("acme", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "alice", run.Values["seen"])
+	assert.Equal(t, "token-alice", run.Values["token"])
+	run, err = r.RunTenant("globex", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", run.Values["seen"])
+
+	// the token is kept for the tenant's next runs only
+	assert.Equal(t, map[string]interface{}{"token": "token-alice"}, r.tenants["acme"].values)
+	assert.Empty(t, r.tenants["globex"].values)
+
+	_, err = r.RunTenant("globex", nil)
+	assert.IsType(t, &TenantRateLimitError{}, err)
+	assert.Contains(t, err.Error(), "Tenant 'globex' rate limited, retry after")
+
+	_, err = r.RunTenant("initech", nil)
+	assert.Equal(t, ErrUnknownTenant, err)
+
+	// the runs without a tenant share nothing with them
+	run, err = r.Run(map[string]interface{}{"user": "carol"})
+	assert.Nil(t, err)
+	assert.Equal(t, "anonymous", run.Values["seen"])
+
+	r.RemoveTenant("acme")
+	_, err = r.RunTenant("acme", nil)
+	assert.Equal(t, ErrUnknownTenant, err)
+}
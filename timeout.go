@@ -0,0 +1,81 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TimeoutError is returned when a step exceeds its Timeout, or the execution
+// its Flow.Deadline. The requests in flight are canceled.
+type TimeoutError struct {
+	Step     int
+	StepName string
+	// Timeout is the step's Timeout, or the flow's Deadline when Deadline is set
+	Timeout  time.Duration
+	Deadline bool
+	// Partial is what the execution got done before its Deadline, the Teardown
+	// steps are then run and TeardownErr is their error, if any
+	Partial     *FlowResult
+	TeardownErr error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("Step %d.'%s' failed because it timed out after %s", e.Step, e.StepName, e.Timeout)
+	if e.Deadline {
+		msg = fmt.Sprintf("Step %d.'%s' failed because the execution exceeded its deadline of %s",
+			e.Step, e.StepName, e.Timeout)
+	}
+	if e.TeardownErr != nil {
+		msg += ", teardown failed: " + e.TeardownErr.Error()
+	}
+	return msg
+}
+
+// halt ends the execution halted at its deadline, returning the *TimeoutError
+// with the partial result
+func (f *Flow) halt(run *runState, err *TimeoutError) error {
+	for i := range f.Steps {
+		if !f.result.recorded(i) {
+			f.Steps[i].Response = nil
+		}
+	}
+	err.Partial = f.result.finish(f.Values)
+	err.TeardownErr = f.runTeardown(run)
+	return err
+}
+
+// timeoutStep executes the step with its requests canceled once the step's
+// Timeout or the execution's Deadline is exceeded
+func (f *Flow) timeoutStep(run *runState, i int, def *Step) error {
+	if def.Timeout <= 0 && run.deadline.IsZero() {
+		return f.loopStep(run, i, def)
+	}
+	deadline := &TimeoutError{Step: i, StepName: def.Name, Timeout: f.Deadline, Deadline: true}
+	if !run.deadline.IsZero() && !time.Now().Before(run.deadline) {
+		def.Response = nil
+		return deadline
+	}
+	parent, ctx := run.requestCtx, run.requestCtx
+	if !run.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, run.deadline)
+		defer cancel()
+	}
+	if def.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, def.Timeout)
+		defer cancel()
+	}
+	run.requestCtx = ctx
+	defer func() { run.requestCtx = parent }()
+
+	err := f.loopStep(run, i, def)
+	if err == nil || ctx.Err() != context.DeadlineExceeded || parent.Err() != nil {
+		return err
+	}
+	if run.deadline.IsZero() || time.Now().Before(run.deadline) {
+		return &TimeoutError{Step: i, StepName: def.Name, Timeout: def.Timeout}
+	}
+	return deadline
+}
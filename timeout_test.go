@@ -0,0 +1,107 @@
+package httpsim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Timeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{
+		{Name: "home", Request: Request{URL: srv.URL + "/", Method: "GET"}, Timeout: time.Second},
+		{Name: "report", Request: Request{URL: srv.URL + "/slow", Method: "GET"}, Timeout: 20 * time.Millisecond},
+	}}
+	start := time.Now()
+	err := f.ExecuteContext(context.Background(), nil)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	var timeout *TimeoutError
+	assert.True(t, errors.As(err, &timeout))
+	assert.Equal(t, 1, timeout.Step)
+	assert.False(t, timeout.Deadline)
+	assert.EqualError(t, err, "Step 1.'report' failed because it timed out after 20ms")
+	assert.NotNil(t, f.Steps[0].Response)
+
+	// the execution's deadline
+	f.Steps[1].Timeout = 0
+	f.Deadline = 30 * time.Millisecond
+	err = f.ExecuteContext(context.Background(), nil)
+	assert.EqualError(t, err, "Step 1.'report' failed because the execution exceeded its deadline of 30ms")
+
+	// the steps after the deadline aren't executed
+	f.Steps[1].Request.URL = srv.URL + "/"
+	f.Steps[0].PostHook = func(int, http.Header, []byte) error {
+		time.Sleep(40 * time.Millisecond)
+		return nil
+	}
+	err = f.ExecuteContext(context.Background(), nil)
+	assert.True(t, errors.As(err, &timeout))
+	assert.True(t, timeout.Deadline)
+	assert.Nil(t, f.Steps[1].Response)
+}
+
+func TestFlow_DeadlinePartialResult(t *testing.T) {
+	// the canceled requests' handlers may still be running
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		w.Write([]byte("<b>42</b>"))
+	}))
+	defer srv.Close()
+
+	total := Extractable{Name: "total", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}
+	f := Flow{Deadline: 50 * time.Millisecond, Steps: []Step{
+		{Name: "cart", Request: Request{URL: srv.URL + "/cart", Method: "GET"}, KeysOutput: []Extracter{total}},
+		{Name: "report", Request: Request{URL: srv.URL + "/slow", Method: "GET"}},
+		{Name: "checkout", Request: Request{URL: srv.URL + "/checkout", Method: "GET"}},
+	}, Teardown: []Step{{Name: "logout", Request: Request{URL: srv.URL + "/logout", Method: "GET"}}}}
+	// a previous run's responses aren't kept
+	f.Steps[2].Response = &Response{}
+
+	err := f.ExecuteContext(context.Background(), nil)
+	var timeout *TimeoutError
+	assert.True(t, errors.As(err, &timeout))
+	assert.True(t, timeout.Deadline)
+	assert.Equal(t, []int{0}, timeout.Partial.Completed)
+	assert.Equal(t, []int{2}, timeout.Partial.Skipped)
+	assert.Equal(t, err, timeout.Partial.Steps[1].Err)
+	assert.Equal(t, "42", timeout.Partial.Values["total"])
+	assert.True(t, timeout.Partial.Elapsed >= 50*time.Millisecond)
+	assert.Nil(t, f.Steps[1].Response)
+	assert.Nil(t, f.Steps[2].Response)
+	assert.Equal(t, "<b>42</b>", timeout.Partial.Step("cart").Text())
+	assert.Nil(t, timeout.Partial.Step("report"))
+	// the teardown isn't bound by the deadline
+	mu.Lock()
+	assert.Equal(t, []string{"/cart", "/slow", "/logout"}, paths)
+	mu.Unlock()
+	assert.NotNil(t, f.Teardown[0].Response)
+
+	f.Teardown[0].ExpectStatus = []string{"404"}
+	err = f.ExecuteContext(context.Background(), nil)
+	assert.Contains(t, err.Error(), "exceeded its deadline of 50ms, teardown failed: Step 3.'logout'")
+}
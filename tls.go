@@ -0,0 +1,135 @@
+package httpsim
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CertificateInfo is the metadata of a certificate presented by a server
+type CertificateInfo struct {
+	Subject   string
+	Issuer    string
+	DNSNames  []string
+	NotBefore time.Time
+	NotAfter  time.Time
+	// SPKISHA256 is the base64 SHA-256 of the certificate's public key info, as
+	// used by pins
+	SPKISHA256 string
+}
+
+func newCertificateInfo(cert *x509.Certificate) CertificateInfo {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return CertificateInfo{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		DNSNames:   cert.DNSNames,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		SPKISHA256: base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+// TLSCheck are assertions on the certificate chain presented by the server, so
+// flows double as TLS monitors of the endpoints they use
+type TLSCheck struct {
+	// Pins are base64 SHA-256 of public key infos (e.g. "sha256/AbC...=" or
+	// "AbC...="), one of the chain's certificates must match one
+	Pins []string
+	// Issuer must be contained in the leaf certificate's issuer
+	// (e.g. "CN=R3,O=Let's Encrypt")
+	Issuer string
+	// MinValidity fails the step when the leaf certificate expires sooner
+	MinValidity time.Duration
+}
+
+// TLSError is the error returned when the server's certificates fail a TLSCheck
+type TLSError struct {
+	Step     int
+	StepName string
+	Reason   string
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("Step %d.'%s' failed because TLS check failed: %s", e.Step, e.StepName, e.Reason)
+}
+
+// certificates returns the metadata of the chain the server presented, nil when
+// the response isn't over TLS
+func certificates(resp *http.Response) []CertificateInfo {
+	if resp.TLS == nil {
+		return nil
+	}
+	var certs []CertificateInfo
+	for _, c := range resp.TLS.PeerCertificates {
+		certs = append(certs, newCertificateInfo(c))
+	}
+	return certs
+}
+
+// CheckTLS checks the server's certificates against the step's TLS check, or
+// the flow's one given when the step has none
+func (s *Step) CheckTLS(certs []CertificateInfo, flowCheck *TLSCheck, stepNb int) error {
+	check := s.TLS
+	if check == nil {
+		check = flowCheck
+	}
+	if check == nil {
+		return nil
+	}
+	fail := func(format string, a ...interface{}) error {
+		return &TLSError{Step: stepNb, StepName: s.Name, Reason: fmt.Sprintf(format, a...)}
+	}
+	if len(certs) == 0 {
+		return fail("no certificate, the connection isn't TLS")
+	}
+	leaf := certs[0]
+
+	if len(check.Pins) != 0 {
+		pinned := false
+		for _, pin := range check.Pins {
+			pin = strings.TrimPrefix(pin, "sha256/")
+			for _, c := range certs {
+				pinned = pinned || c.SPKISHA256 == pin
+			}
+		}
+		if !pinned {
+			return fail("no certificate matches the pins, leaf is %s", leaf.SPKISHA256)
+		}
+	}
+	if check.Issuer != "" && !strings.Contains(leaf.Issuer, check.Issuer) {
+		return fail("issuer is '%s'", leaf.Issuer)
+	}
+	if check.MinValidity != 0 {
+		if left := time.Until(leaf.NotAfter); left < check.MinValidity {
+			return fail("certificate of %s expires on %s", leaf.Subject, leaf.NotAfter.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// warnCertExpiry warns, once per host of the run, when the certificate the host
+// presented expires within the flow's CertExpiryWarning
+func (f *Flow) warnCertExpiry(run *runState, i int, step *Step, resp *http.Response, certs []CertificateInfo) {
+	if f.CertExpiryWarning <= 0 || len(certs) == 0 {
+		return
+	}
+	host := resp.Request.URL.Hostname()
+	if run.certHosts[host] {
+		return
+	}
+	if run.certHosts == nil {
+		run.certHosts = map[string]bool{}
+	}
+	run.certHosts[host] = true
+
+	if left := time.Until(certs[0].NotAfter); left < f.CertExpiryWarning {
+		f.Warnings = append(f.Warnings, LintWarning{Step: i, StepName: step.Name,
+			Message: fmt.Sprintf("certificate of %s expires in %d days (%s)",
+				host, int(left.Hours()/24), certs[0].NotAfter.Format("2006-01-02"))})
+	}
+}
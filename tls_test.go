@@ -0,0 +1,90 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_ExecuteTLSCheck(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	f := Flow{
+		Transport: srv.Client().Transport,
+		Steps:     []Step{{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}}},
+	}
+	assert.Nil(t, f.Execute(nil))
+	certs := f.Steps[0].Response.Certificates
+	assert.Len(t, certs, 1)
+	leaf := certs[0]
+	assert.Contains(t, leaf.Issuer, "O=Acme Co")
+	assert.NotEmpty(t, leaf.SPKISHA256)
+	assert.True(t, leaf.NotAfter.After(time.Now()))
+
+	// passing checks
+	f.TLS = &TLSCheck{Pins: []string{"sha256/nope=", "sha256/" + leaf.SPKISHA256}, Issuer: "Acme Co", MinValidity: 24 * time.Hour}
+	assert.Nil(t, f.Execute(nil))
+
+	f.TLS = &TLSCheck{Pins: []string{"nope="}}
+	err := f.Execute(nil)
+	assert.IsType(t, &TLSError{}, err)
+	assert.EqualError(t, err, "Step 0.'home' failed because TLS check failed: no certificate matches the pins, leaf is "+leaf.SPKISHA256)
+
+	f.TLS = &TLSCheck{Issuer: "Let's Encrypt"}
+	assert.IsType(t, &TLSError{}, f.Execute(nil))
+
+	f.TLS = &TLSCheck{MinValidity: time.Until(leaf.NotAfter) + time.Hour}
+	err = f.Execute(nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "expires on")
+
+	// the step's check overrides the flow's
+	f.Steps[0].TLS = &TLSCheck{Issuer: "Acme"}
+	assert.Nil(t, f.Execute(nil))
+}
+
+func TestStep_CheckTLSPlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	f := Flow{Steps: []Step{{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}}}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Nil(t, f.Steps[0].Response.Certificates)
+
+	f.TLS = &TLSCheck{Issuer: "Acme"}
+	assert.EqualError(t, f.Execute(nil), "Step 0.'home' failed because TLS check failed: no certificate, the connection isn't TLS")
+}
+
+func TestFlow_ExecuteCertExpiryWarning(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	f := Flow{
+		Transport: srv.Client().Transport,
+		Steps: []Step{
+			{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}},
+			{Name: "again", Request: Request{URL: srv.URL + "/again", Method: "GET"}},
+			// same host (127.0.0.1), another port
+			{Name: "other", Request: Request{URL: other.URL, Method: "GET"}},
+		},
+	}
+	assert.Nil(t, f.Execute(nil))
+	assert.Len(t, f.Warnings, 0)
+
+	// the test certificate expires in decades
+	f.CertExpiryWarning = 200 * 365 * 24 * time.Hour
+	assert.Nil(t, f.Execute(nil))
+	assert.Len(t, f.Warnings, 1)
+	if len(f.Warnings) == 1 {
+		assert.Equal(t, 0, f.Warnings[0].Step)
+		assert.Contains(t, f.Warnings[0].String(), "Step 0.'home' certificate of 127.0.0.1 expires in ")
+	}
+}
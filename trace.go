@@ -0,0 +1,70 @@
+package httpsim
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TraceHeaders are the headers added to the requests so the target's logs can
+// be correlated to the runs and steps of the flow
+type TraceHeaders struct {
+	// RunIDHeader is the header of the run's ID (see Flow.RunID),
+	// "X-Flow-Run-ID" when empty
+	RunIDHeader string
+	// StepHeader is the header of the step's name, "X-Flow-Step" when empty
+	StepHeader string
+	// NoTraceparent doesn't send the W3C traceparent header, whose trace ID is
+	// the run's ID and parent ID new for each step
+	NoTraceparent bool
+	// Tags are the scenario's tags (e.g. "scenario": "checkout"), sent in the
+	// W3C baggage header
+	Tags map[string]string
+}
+
+// newTraceID returns a random W3C trace ID, 32 hex digits
+func newTraceID() string {
+	return randomHex(16)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// baggage returns the tags as a W3C baggage header value
+func (t *TraceHeaders) baggage() string {
+	var members []string
+	for k, v := range t.Tags {
+		members = append(members, url.QueryEscape(k)+"="+url.PathEscape(v))
+	}
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
+
+// traceHeaders adds the flow's trace headers to the step's request
+func (f *Flow) traceHeaders(step *Step) {
+	t := f.Trace
+	if t == nil {
+		return
+	}
+	runID, stepHeader := t.RunIDHeader, t.StepHeader
+	if runID == "" {
+		runID = "X-Flow-Run-ID"
+	}
+	if stepHeader == "" {
+		stepHeader = "X-Flow-Step"
+	}
+	h := step.Request.Header
+	h.Set(runID, f.RunID)
+	h.Set(stepHeader, step.Name)
+	if !t.NoTraceparent {
+		h.Set("Traceparent", "00-"+f.RunID+"-"+randomHex(8)+"-01")
+	}
+	if len(t.Tags) != 0 {
+		h.Set("Baggage", t.baggage())
+	}
+}
@@ -0,0 +1,57 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_Trace(t *testing.T) {
+	var headers []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+	}))
+	defer srv.Close()
+	f := Flow{Steps: []Step{
+		{Name: "home", Request: Request{URL: srv.URL, Method: "GET"}},
+		{Name: "search", Request: Request{URL: srv.URL + "/search", Method: "GET"}},
+	}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Len(t, f.RunID, 32)
+	assert.Empty(t, headers[0].Get("X-Flow-Run-ID"))
+	assert.Empty(t, headers[0].Get("Traceparent"))
+
+	headers = nil
+	f.Trace = &TraceHeaders{StepHeader: "X-Scenario-Step", Tags: map[string]string{"scenario": "checkout", "env": "staging eu"}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, f.RunID, headers[0].Get("X-Flow-Run-ID"))
+	assert.Equal(t, f.RunID, headers[1].Get("X-Flow-Run-ID"))
+	assert.Equal(t, "home", headers[0].Get("X-Scenario-Step"))
+	assert.Equal(t, "search", headers[1].Get("X-Scenario-Step"))
+	assert.Equal(t, "env=staging%20eu,scenario=checkout", headers[0].Get("Baggage"))
+	traceparent := regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-01$`)
+	m0, m1 := traceparent.FindStringSubmatch(headers[0].Get("Traceparent")), traceparent.FindStringSubmatch(headers[1].Get("Traceparent"))
+	assert.NotNil(t, m0)
+	assert.NotNil(t, m1)
+	assert.Equal(t, f.RunID, m0[1])
+	assert.NotEqual(t, m0[2], m1[2])
+	// the definition isn't modified
+	assert.Empty(t, f.Steps[0].Request.Header.Get("X-Flow-Run-ID"))
+
+	previous := f.RunID
+	f.Trace.NoTraceparent = true
+	headers = nil
+	assert.Nil(t, f.Execute(nil))
+	assert.NotEqual(t, previous, f.RunID)
+	assert.Empty(t, headers[0].Get("Traceparent"))
+
+	headers = nil
+	f.Steps[1].Name = "search {{.q}"
+	f.Trace.Tags = map[string]string{"scenario": "{{.q}}"}
+	assert.Nil(t, f.Execute(map[string]interface{}{"q": "x"}))
+	assert.Equal(t, "search {{.q}", headers[1].Get("X-Scenario-Step"))
+	assert.Equal(t, "scenario=%7B%7B.q%7D%7D", headers[1].Get("Baggage"))
+}
@@ -0,0 +1,88 @@
+package httpsim
+
+import (
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// UserAgent is a browser identity: its User-Agent with the matching client hints
+type UserAgent struct {
+	UserAgent string
+	// SecCHUA, SecCHUAMobile and SecCHUAPlatform are the Sec-CH-UA client hints,
+	// to be left empty for browsers not sending them (Firefox, Safari)
+	SecCHUA         string
+	SecCHUAMobile   string
+	SecCHUAPlatform string
+}
+
+// apply sets the identity headers, removing the hints it doesn't have. The
+// headers are left alone by the zero UserAgent (an empty pool's).
+func (ua UserAgent) apply(h http.Header) {
+	if ua == (UserAgent{}) {
+		return
+	}
+	h.Set("User-Agent", ua.UserAgent)
+	for k, v := range map[string]string{
+		"Sec-Ch-Ua":          ua.SecCHUA,
+		"Sec-Ch-Ua-Mobile":   ua.SecCHUAMobile,
+		"Sec-Ch-Ua-Platform": ua.SecCHUAPlatform,
+	} {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
+
+// UserAgentRotation is how often a UserAgentPolicy changes identity
+type UserAgentRotation int
+
+const (
+	// FixedUserAgent always uses the first UserAgent of the pool
+	FixedUserAgent UserAgentRotation = iota
+	// RotatePerRun picks a UserAgent from the pool for each Execute
+	RotatePerRun
+	// RotatePerStep picks a UserAgent from the pool for each step
+	RotatePerStep
+)
+
+// UserAgentPolicy sets the User-Agent (and client hints) of the requests of a flow
+// from a pool. Steps setting their own User-Agent header are left alone. It's safe
+// to share a policy between flows.
+type UserAgentPolicy struct {
+	Rotation UserAgentRotation
+	Pool     []UserAgent
+	// Rand picks from the pool, a time seeded source is used if nil
+	Rand *rand.Rand
+
+	mu sync.Mutex
+}
+
+// pick returns a random UserAgent of the pool (the first one if fixed)
+func (p *UserAgentPolicy) pick() UserAgent {
+	if len(p.Pool) == 0 {
+		return UserAgent{}
+	}
+	if p.Rotation == FixedUserAgent {
+		return p.Pool[0]
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Rand == nil {
+		p.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return p.Pool[p.Rand.Intn(len(p.Pool))]
+}
+
+// cloneHeader returns a deep copy of the header, so a step's definition isn't
+// modified when its request is prepared
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
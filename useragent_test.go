@@ -0,0 +1,75 @@
+package httpsim
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testUserAgents = []UserAgent{
+	{
+		UserAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		SecCHUA:         `"Chromium";v="120", "Google Chrome";v="120", "Not?A_Brand";v="99"`,
+		SecCHUAMobile:   "?0",
+		SecCHUAPlatform: `"Windows"`,
+	},
+	{
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	},
+}
+
+func TestFlow_ExecuteUserAgents(t *testing.T) {
+	var got []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header)
+	}))
+	defer srv.Close()
+
+	steps := []Step{
+		{Name: "a", Request: Request{URL: srv.URL, Method: "GET"}},
+		{Name: "b", Request: Request{URL: srv.URL, Method: "GET", Header: http.Header{"Sec-Ch-Ua": {"stale"}}}},
+		{Name: "c", Request: Request{URL: srv.URL, Method: "GET", Header: http.Header{"User-Agent": {"custom"}}}},
+	}
+	f := Flow{Steps: steps, UserAgents: &UserAgentPolicy{Pool: testUserAgents}}
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, testUserAgents[0].UserAgent, got[0].Get("User-Agent"))
+	assert.Equal(t, `"Windows"`, got[0].Get("Sec-Ch-Ua-Platform"))
+	assert.Equal(t, testUserAgents[0].SecCHUA, got[1].Get("Sec-Ch-Ua"))
+	assert.Equal(t, "custom", got[2].Get("User-Agent"))
+	// the step definitions aren't modified
+	assert.Equal(t, "stale", f.Steps[1].Request.Header.Get("Sec-Ch-Ua"))
+
+	got = nil
+	f.UserAgents = &UserAgentPolicy{Rotation: RotatePerStep, Pool: testUserAgents, Rand: rand.New(rand.NewSource(1))}
+	f.Steps = f.Steps[:2]
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, f.Execute(nil))
+	}
+	seen := map[string]bool{}
+	for _, h := range got {
+		seen[h.Get("User-Agent")] = true
+		if h.Get("User-Agent") == testUserAgents[1].UserAgent {
+			assert.Equal(t, "", h.Get("Sec-Ch-Ua"))
+		}
+	}
+	assert.Len(t, seen, 2)
+}
+
+func TestFlow_UserAgentsEmptyPool(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+	defer srv.Close()
+
+	f := Flow{UserAgents: &UserAgentPolicy{}, Steps: []Step{{Name: "a", Request: Request{URL: srv.URL, Method: "GET",
+		Header: http.Header{"Sec-Ch-Ua": {"mine"}}}}}}
+	assert.EqualError(t, f.Compile(), "Flow invalid user agents: the pool is empty")
+	// the headers are left alone when the flow isn't compiled
+	assert.Nil(t, f.Execute(nil))
+	assert.Equal(t, "Go-http-client/1.1", got.Get("User-Agent"))
+	assert.Equal(t, "mine", got.Get("Sec-Ch-Ua"))
+}
@@ -0,0 +1,70 @@
+package httpsim
+
+import (
+	"reflect"
+	"sort"
+)
+
+// StepResult records what a step did to the values, for post-mortem debugging
+type StepResult struct {
+	Step     int
+	StepName string
+	// ValuesBefore and ValuesAfter are copies of the values before and after the
+	// step, with the SensitiveValues redacted
+	ValuesBefore map[string]interface{}
+	ValuesAfter  map[string]interface{}
+	Err          error
+}
+
+// ValueChange is a value produced, changed or removed by a step
+type ValueChange struct {
+	Name string
+	// Before is nil when the value was produced, After when it was removed
+	Before interface{}
+	After  interface{}
+}
+
+// Changes returns the values the step produced, changed or removed, by name
+func (r StepResult) Changes() []ValueChange {
+	var changes []ValueChange
+	for k, after := range r.ValuesAfter {
+		if before, ok := r.ValuesBefore[k]; !ok || !reflect.DeepEqual(before, after) {
+			changes = append(changes, ValueChange{Name: k, Before: before, After: after})
+		}
+	}
+	for k, before := range r.ValuesBefore {
+		if _, ok := r.ValuesAfter[k]; !ok {
+			changes = append(changes, ValueChange{Name: k, Before: before})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// ValueHistory returns the changes made to the value by the steps, in order,
+// with the results of the steps that made them
+func (f *Flow) ValueHistory(name string) []StepResult {
+	var history []StepResult
+	for _, r := range f.StepResults {
+		for _, c := range r.Changes() {
+			if c.Name == name {
+				history = append(history, r)
+			}
+		}
+	}
+	return history
+}
+
+// redactedValues returns a copy of the values, the SensitiveValues redacted
+func (f *Flow) redactedValues() map[string]interface{} {
+	values := make(map[string]interface{}, len(f.Values))
+	for k, v := range f.Values {
+		values[k] = v
+	}
+	for _, k := range f.SensitiveValues {
+		if _, ok := values[k]; ok {
+			values[k] = "REDACTED"
+		}
+	}
+	return values
+}
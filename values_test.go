@@ -0,0 +1,43 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_RecordValues(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<b>" + r.URL.Path[1:] + "</b>"))
+	}))
+	defer srv.Close()
+
+	get := func(name, path string) Step {
+		return Step{Name: name, Request: Request{URL: srv.URL + path, Method: "GET"},
+			KeysOutput: []Extracter{Extractable{Name: "id", AfterThis: "<b>", BeforeThis: "</b>", MaxLength: -1, MinLength: -1}}}
+	}
+	f := Flow{
+		SensitiveValues: []string{"password"},
+		RecordValues:    true,
+		Steps:           []Step{get("first", "/1"), {Name: "noop", Request: Request{URL: srv.URL, Method: "GET"}}, get("clobber", "/2")},
+	}
+	assert.Nil(t, f.Execute(map[string]interface{}{"password": "secret"}))
+	assert.Len(t, f.StepResults, 3)
+
+	first := f.StepResults[0]
+	assert.Equal(t, map[string]interface{}{"password": "REDACTED"}, first.ValuesBefore)
+	assert.Equal(t, map[string]interface{}{"password": "REDACTED", "id": "1"}, first.ValuesAfter)
+	assert.Equal(t, []ValueChange{{Name: "id", After: "1"}}, first.Changes())
+	assert.Empty(t, f.StepResults[1].Changes())
+
+	history := f.ValueHistory("id")
+	assert.Len(t, history, 2)
+	assert.Equal(t, "clobber", history[1].StepName)
+	assert.Equal(t, []ValueChange{{Name: "id", Before: "1", After: "2"}}, history[1].Changes())
+
+	f.RecordValues = false
+	assert.Nil(t, f.Execute(map[string]interface{}{"password": "secret"}))
+	assert.Nil(t, f.StepResults)
+}
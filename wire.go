@@ -0,0 +1,168 @@
+package httpsim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// maxWireDump is the max size of a dump, the rest of the body is truncated
+const maxWireDump = 64 << 10
+
+// wireHeaders are the headers whose values are redacted in wire dumps, they
+// hold the session
+var wireHeaders = []string{"authorization", "proxy-authorization", "cookie", "set-cookie"}
+
+// WireDump is a request made by a step and its response, as sent and received
+type WireDump struct {
+	Step     int
+	StepName string
+	Request  string
+	// Response is empty when the request failed, Err is then set
+	Response string
+	Err      string
+}
+
+// wireRecorder dumps the requests of the run's current step
+type wireRecorder struct {
+	rt   http.RoundTripper
+	f    *Flow
+	run  *runState
+	i    int
+	step *Step
+}
+
+func (w *wireRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	dump := WireDump{Step: w.i, StepName: w.step.Name}
+	// the dump makes a fake round trip, it mustn't be traced
+	// the streamed templates aren't rendered in memory
+	_, streamed := w.step.Request.Body.(*templateStream)
+	if b, err := httputil.DumpRequestOut(req.WithContext(context.Background()), !streamed); err == nil {
+		dump.Request = w.f.redactWire(b, 0)
+	}
+	resp, err := w.rt.RoundTrip(req)
+	if err != nil {
+		dump.Err = w.f.redact(err.Error())
+	} else if head, err := httputil.DumpResponse(resp, false); err == nil {
+		dump.Response = w.f.redactWire(head, 0)
+		// the body is dumped as the step reads it, the streamed bodies are read
+		// by the extracters
+		if !w.step.StreamBody && !w.step.noResponseBody() && resp.Body != nil {
+			resp.Body = &wireBody{ReadCloser: resp.Body, f: w.f, dump: &dump, head: head}
+		}
+	}
+	w.run.wire = append(w.run.wire, &dump)
+	return resp, err
+}
+
+// wireBody keeps the first maxWireDump bytes of the response body read, in the
+// dump of its response
+type wireBody struct {
+	io.ReadCloser
+	f    *Flow
+	dump *WireDump
+	head []byte
+	body []byte
+	// read is the number of bytes read
+	read int
+	done bool
+}
+
+func (b *wireBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += n
+	if keep := maxWireDump - len(b.body); keep > 0 && n > 0 {
+		if n < keep {
+			keep = n
+		}
+		b.body = append(b.body, p[:keep]...)
+		b.update()
+	}
+	if err != nil {
+		b.complete()
+	}
+	return n, err
+}
+
+func (b *wireBody) Close() error {
+	b.complete()
+	return b.ReadCloser.Close()
+}
+
+// update dumps the body read so far
+func (b *wireBody) update() {
+	if !b.done {
+		b.dump.Response = b.f.redactWire(append(b.head[:len(b.head):len(b.head)], b.body...), b.read-len(b.body))
+	}
+}
+
+// complete dumps the body read once the step is done with it
+func (b *wireBody) complete() {
+	b.update()
+	b.done = true
+}
+
+// recordWire makes the client dump the step's requests when the flow keeps wire dumps
+func (run *runState) recordWire(client *http.Client, step *Step) {
+	if run.flow == nil || run.flow.WireDumps <= 0 {
+		return
+	}
+	rt := client.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	client.Transport = &wireRecorder{rt: rt, f: run.flow, run: run, i: run.index, step: step}
+}
+
+// rotateWire starts the dumps of the next step, keeping the previous step's
+func (run *runState) rotateWire() {
+	run.prevWire, run.wire = run.wire, nil
+}
+
+// failureWire returns the dumps attached to the report of the failing step
+func (f *Flow) failureWire(run *runState) []WireDump {
+	if f.WireDumps <= 0 {
+		return nil
+	}
+	var dumps []WireDump
+	if f.WireDumps > 1 {
+		for _, d := range run.prevWire {
+			dumps = append(dumps, *d)
+		}
+	}
+	for _, d := range run.wire {
+		dumps = append(dumps, *d)
+	}
+	return dumps
+}
+
+// redactWire redacts the session headers and SensitiveValues of the dump, and
+// truncates its body. dropped is the number of bytes of the body not in the dump.
+func (f *Flow) redactWire(b []byte, dropped int) string {
+	s := string(b)
+	truncated := dropped
+	if len(s) > maxWireDump {
+		truncated, s = truncated+len(s)-maxWireDump, s[:maxWireDump]
+	}
+	head, body := s, ""
+	if i := strings.Index(s, "\r\n\r\n"); i != -1 {
+		head, body = s[:i], s[i:]
+	}
+	lines := strings.Split(head, "\r\n")
+	for j, line := range lines {
+		name := strings.SplitN(line, ":", 2)[0]
+		for _, h := range wireHeaders {
+			if j != 0 && strings.EqualFold(name, h) {
+				lines[j] = name + ": " + redacted
+			}
+		}
+	}
+	s = f.redact(strings.Join(lines, "\r\n") + body)
+	if truncated != 0 {
+		s += fmt.Sprintf("\n... (%d bytes truncated)", truncated)
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package httpsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlow_WireDumps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("missing X-Token"))
+			return
+		}
+		w.Write([]byte("welcome"))
+	}))
+	defer srv.Close()
+	f := Flow{
+		RequiredValues:  []string{"password"},
+		SensitiveValues: []string{"password"},
+		Steps: []Step{
+			{Name: "login", Request: Request{URL: srv.URL + "/login", Method: "POST", Body: "password={{.password}}"}, KeysInput: []string{"password"}},
+			{Name: "fail", Request: Request{URL: srv.URL + "/fail", Method: "GET"}},
+		},
+	}
+	values := map[string]interface{}{"password": "hunter2"}
+	assert.NotNil(t, f.Execute(values))
+	assert.Empty(t, f.Failure().Wire)
+
+	f.WireDumps = 1
+	assert.NotNil(t, f.Execute(values))
+	wire := f.Failure().Wire
+	assert.Len(t, wire, 1)
+	assert.Equal(t, 1, wire[0].Step)
+	assert.Contains(t, wire[0].Request, "GET /fail HTTP/1.1\r\n")
+	assert.Contains(t, wire[0].Request, "Cookie: REDACTED\r\n")
+	assert.Contains(t, wire[0].Response, "HTTP/1.1 400 Bad Request\r\n")
+	assert.Contains(t, wire[0].Response, "Set-Cookie: REDACTED\r\n")
+	assert.True(t, strings.HasSuffix(wire[0].Response, "missing X-Token"))
+	assert.NotContains(t, wire[0].Response, "s3cr3t")
+
+	f.WireDumps = 2
+	assert.NotNil(t, f.Execute(values))
+	wire = f.Failure().Wire
+	assert.Len(t, wire, 2)
+	assert.Equal(t, "login", wire[0].StepName)
+	assert.Contains(t, wire[0].Request, "password=REDACTED")
+	assert.NotContains(t, wire[0].Request, "hunter2")
+	assert.Contains(t, f.Failure().String(), "--- Step 1.'fail' request\n")
+}
+
+func TestFlow_RedactWire(t *testing.T) {
+	f := Flow{}
+	head := "HTTP/1.1 200 OK\r\nAuthorization: Bearer x\r\nX-Cookie: kept\r\n\r\n"
+	s := f.redactWire([]byte(head+strings.Repeat("a", maxWireDump+10-len(head))), 0)
+	assert.True(t, strings.HasPrefix(s, "HTTP/1.1 200 OK\r\nAuthorization: REDACTED\r\nX-Cookie: kept\r\n\r\n"))
+	assert.True(t, strings.HasSuffix(s, "\n... (10 bytes truncated)"))
+}
+
+func TestFlow_WireDumpsBodyRead(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", 1<<20)))
+	}))
+	defer srv.Close()
+	f := Flow{
+		WireDumps: 1,
+		Steps:     []Step{{Request: Request{URL: srv.URL, Method: "GET"}, MaxBodySize: 100}},
+	}
+	assert.NotNil(t, f.Execute(nil))
+	wire := f.Failure().Wire
+	if assert.Len(t, wire, 1) {
+		assert.Contains(t, wire[0].Response, "HTTP/1.1 200 OK\r\n")
+		assert.Contains(t, wire[0].Response, "\r\n\r\naaaa")
+		assert.Less(t, len(wire[0].Response), 1<<10)
+	}
+}
@@ -0,0 +1,499 @@
+package httpsim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document, text being its content without the
+// indentation and comment
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+	raw    string
+}
+
+// yamlParser parses the subset of YAML flow definitions are written in: block
+// mappings and sequences, quoted and plain scalars, literal (|) and folded (>)
+// block scalars, and flow sequences of scalars ([a, b]). Anchors, aliases, tags
+// and multi-line plain scalars aren't supported. Plain numbers are parsed to
+// json.Number so they're kept as written.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses the YAML document into maps, slices and scalars
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't indent", i+1)
+		}
+		if i == 0 && strings.HasPrefix(text, "---") || strings.HasPrefix(text, "%") {
+			text = ""
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text),
+			text: strings.TrimSpace(stripYAMLComment(text)), raw: raw})
+	}
+	v, err := p.block(0)
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := p.next(); ok {
+		return nil, fmt.Errorf("line %d: unexpected content", l.num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes the comment ending the line, outside of quotes
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// quotes only start scalars, not e.g. "it's"
+			if prev := strings.TrimRight(s[:i], " "); prev == "" || strings.ContainsAny(prev[len(prev)-1:], ":-[,{") {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// next returns the next line with content, skipping the blank ones
+func (p *yamlParser) next() (yamlLine, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		if p.lines[p.pos].text != "" {
+			return p.lines[p.pos], true
+		}
+	}
+	return yamlLine{}, false
+}
+
+// block parses the node starting at the next line, nil when it's indented
+// less than indent
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	l, ok := p.next()
+	if !ok || l.indent < indent {
+		return nil, nil
+	}
+	if isYAMLItem(l.text) {
+		return p.sequence(l.indent)
+	}
+	if _, _, ok, err := splitYAMLKey(l.text); err != nil {
+		return nil, fmt.Errorf("line %d: %s", l.num, err.Error())
+	} else if ok {
+		return p.mapping(l.indent)
+	}
+	p.pos++
+	return p.value(l, l.text, indent-1)
+}
+
+// isYAMLItem returns whether the line is an item of a sequence
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// sequence parses the sequence whose items are at indent
+func (p *yamlParser) sequence(indent int) ([]interface{}, error) {
+	items := []interface{}{}
+	for {
+		l, ok := p.next()
+		if !ok || l.indent < indent || l.indent == indent && !isYAMLItem(l.text) {
+			return items, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: expected an item of the sequence", l.num)
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var item interface{}
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			item, err = p.block(indent + 1)
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			p.pos++
+			item, err = p.value(l, rest, indent)
+		default:
+			// the item's content is parsed as if it started the line, so the
+			// keys of a mapping item line up with the next lines'
+			p.lines[p.pos].indent += len(l.text) - len(rest)
+			p.lines[p.pos].text = rest
+			item, err = p.block(p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// mapping parses the mapping whose keys are at indent
+func (p *yamlParser) mapping(indent int) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		l, ok := p.next()
+		if !ok || l.indent < indent {
+			return m, nil
+		}
+		key, rest, ok, err := splitYAMLKey(l.text)
+		if err == nil && (l.indent > indent || !ok) {
+			err = fmt.Errorf("expected a key of the mapping")
+		}
+		if err == nil {
+			if _, dup := m[key]; dup {
+				err = fmt.Errorf("duplicate key '%s'", key)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", l.num, err.Error())
+		}
+		p.pos++
+		if rest != "" {
+			m[key], err = p.value(l, rest, indent)
+		} else if n, ok := p.next(); ok && n.indent == indent && isYAMLItem(n.text) {
+			// the items of a key's sequence can line up with the key
+			m[key], err = p.sequence(indent)
+		} else {
+			m[key], err = p.block(indent + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// splitYAMLKey splits the "key: value" line, ok is false when it isn't one
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	i := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end := quotedYAMLEnd(text)
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated quoted scalar")
+		}
+		i = end
+	}
+	for ; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			break
+		}
+	}
+	if i == len(text) {
+		return "", "", false, nil
+	}
+	k, err := yamlScalar(strings.TrimSpace(text[:i]))
+	if err != nil {
+		return "", "", false, err
+	}
+	return fmt.Sprint(k), strings.TrimSpace(text[i+1:]), true, nil
+}
+
+// quotedYAMLEnd returns the index following the quoted scalar starting text, -1
+// when it's unterminated
+func quotedYAMLEnd(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q && q == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// value parses the value text of the line l, a block scalar's lines being
+// indented more than parent
+func (p *yamlParser) value(l yamlLine, text string, parent int) (interface{}, error) {
+	var v interface{}
+	var err error
+	if text[0] == '|' || text[0] == '>' {
+		v, err = p.blockScalar(text, parent)
+	} else {
+		v, err = yamlScalar(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s", l.num, err.Error())
+	}
+	return v, nil
+}
+
+// blockScalar parses the lines of the literal (|) or folded (>) scalar with the
+// header, e.g. "|-"
+func (p *yamlParser) blockScalar(header string, parent int) (string, error) {
+	chomp := header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return "", fmt.Errorf("unsupported block scalar header '%s'", header)
+	}
+	var lines []string
+	indent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		raw := p.lines[p.pos].raw
+		content := strings.TrimLeft(raw, " ")
+		if content == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(raw) - len(content)
+		if indent < 0 {
+			indent = n
+		}
+		if n <= parent || n < indent {
+			break
+		}
+		lines = append(lines, raw[indent:])
+	}
+	// the trailing blank lines are the scalar's only when kept
+	blanks := 0
+	for blanks < len(lines) && lines[len(lines)-1-blanks] == "" {
+		blanks++
+	}
+	if chomp != "+" {
+		p.pos -= blanks
+	}
+	lines = lines[:len(lines)-blanks]
+	if len(lines) == 0 {
+		return "", nil
+	}
+	var s string
+	if header[0] == '|' {
+		s = strings.Join(lines, "\n")
+	} else {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "":
+				b.WriteByte('\n')
+			case lines[i-1] != "":
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		s = b.String()
+	}
+	switch chomp {
+	case "-":
+		return s, nil
+	case "+":
+		return s + strings.Repeat("\n", blanks+1), nil
+	}
+	return s + "\n", nil
+}
+
+// yamlScalar parses the scalar, or flow sequence of scalars
+func yamlScalar(text string) (interface{}, error) {
+	switch {
+	case text == "" || text == "~" || text == "null":
+		return nil, nil
+	case text == "true" || text == "false":
+		return text == "true", nil
+	case text == "{}":
+		return map[string]interface{}{}, nil
+	case text[0] == '"':
+		if quotedYAMLEnd(text) != len(text) {
+			return nil, fmt.Errorf("invalid quoted scalar %s", text)
+		}
+		return strconv.Unquote(text)
+	case text[0] == '\'':
+		if quotedYAMLEnd(text) != len(text) {
+			return nil, fmt.Errorf("invalid quoted scalar %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text[0] == '[':
+		return yamlFlowSequence(text)
+	case strings.ContainsAny(text[:1], "{&*!"):
+		return nil, fmt.Errorf("flow mappings, anchors, aliases and tags aren't supported")
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil && json.Valid([]byte(text)) {
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+// yamlFlowSequence parses the flow sequence of scalars e.g. [200, "302"]
+func yamlFlowSequence(text string) ([]interface{}, error) {
+	if !strings.HasSuffix(text, "]") {
+		return nil, fmt.Errorf("unterminated flow sequence")
+	}
+	items := []interface{}{}
+	rest := strings.TrimSpace(text[1 : len(text)-1])
+	for rest != "" {
+		end := strings.IndexByte(rest, ',')
+		if rest[0] == '"' || rest[0] == '\'' {
+			if end = quotedYAMLEnd(rest); end < 0 {
+				return nil, fmt.Errorf("unterminated quoted scalar")
+			}
+			if next := strings.TrimLeft(rest[end:], " "); next != "" && next[0] != ',' {
+				return nil, fmt.Errorf("expected ',' after %s", rest[:end])
+			}
+			end += strings.IndexByte(rest[end:]+",", ',')
+		}
+		if end < 0 {
+			end = len(rest)
+		}
+		item := strings.TrimSpace(rest[:end])
+		if item == "" {
+			return nil, fmt.Errorf("empty item in flow sequence")
+		}
+		if strings.ContainsAny(item[:1], "[{") {
+			return nil, fmt.Errorf("nested flow collections aren't supported")
+		}
+		v, err := yamlScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		if end == len(rest) {
+			break
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	return items, nil
+}
+
+// jsonToYAML converts the JSON document to YAML, keeping its keys' order
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var b bytes.Buffer
+	if err := writeYAML(&b, dec, "", 0); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeYAML writes the next JSON value of dec. head starts its line: the
+// indented "key:", or "- " of an item. indent is the level of its content.
+func writeYAML(b *bytes.Buffer, dec *json.Decoder, head string, indent int) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	pad := strings.Repeat("  ", indent)
+	switch tok {
+	case json.Delim('{'), json.Delim('['):
+		if !dec.More() {
+			empty := "{}"
+			if tok == json.Delim('[') {
+				empty = "[]"
+			}
+			_, err := dec.Token()
+			b.WriteString(joinYAML(head, empty) + "\n")
+			return err
+		}
+		if strings.HasSuffix(head, ":") {
+			b.WriteString(head + "\n")
+			head = ""
+		}
+		for dec.More() {
+			// the first key or item of an item continues its line
+			if head == "" {
+				head = pad
+			}
+			if tok == json.Delim('[') {
+				head += "- "
+			} else {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				head += yamlString(key.(string)) + ":"
+			}
+			if err := writeYAML(b, dec, head, indent+1); err != nil {
+				return err
+			}
+			head = ""
+		}
+		_, err := dec.Token()
+		return err
+	}
+	var s string
+	switch v := tok.(type) {
+	case nil:
+		s = "null"
+	case bool:
+		s = strconv.FormatBool(v)
+	case json.Number:
+		s = v.String()
+	case string:
+		if block, ok := yamlBlock(v, pad); ok {
+			b.WriteString(joinYAML(head, block))
+			return nil
+		}
+		s = yamlString(v)
+	}
+	b.WriteString(joinYAML(head, s) + "\n")
+	return nil
+}
+
+// joinYAML returns the value following the prefix on its line
+func joinYAML(prefix, value string) string {
+	if strings.HasSuffix(prefix, ":") {
+		return prefix + " " + value
+	}
+	return prefix + value
+}
+
+// yamlBlock returns the multi-line string as a literal block scalar indented
+// with pad, false when it can't be one
+func yamlBlock(s, pad string) (string, bool) {
+	body := strings.TrimSuffix(s, "\n")
+	if !strings.Contains(body, "\n") || strings.HasSuffix(body, "\n") || strings.Contains(body, "\r") {
+		return "", false
+	}
+	lines := strings.Split(body, "\n")
+	first := true
+	for _, line := range lines {
+		content := strings.TrimLeft(line, " ")
+		// the first line sets the indentation, blank lines lose their spaces
+		if line != "" && (content == "" || first && content != line || strings.HasPrefix(content, "\t")) {
+			return "", false
+		}
+		first = first && line == ""
+	}
+	header := "|-"
+	if body != s {
+		header = "|"
+	}
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	for _, line := range lines {
+		if line != "" {
+			b.WriteString(pad + line)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String(), true
+}
+
+// yamlString returns the string as a plain scalar, quoted when it would be
+// parsed as something else
+func yamlString(s string) string {
+	v, err := yamlScalar(s)
+	if err != nil || v != s || s != strings.TrimSpace(s) || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
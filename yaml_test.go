@@ -0,0 +1,110 @@
+package httpsim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYAML(t *testing.T) {
+	v, err := parseYAML([]byte(`---
+# comment
+plain: it's a value # comment
+quoted: "a # b: \"c\"\n"
+single: 'it''s'
+"quoted key": 1.50
+empty:
+none: ~
+yes: true
+items:
+- [1, "a, b", 'c']
+- - nested
+  - 2
+- key: v
+  other: w
+-
+  indented: x
+literal: |
+  line 1
+
+    line 2
+strip: |-
+  text
+keep: |+
+  text
+
+folded: >
+  a
+  b
+
+  c
+last: end
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"plain":      "it's a value",
+		"quoted":     "a # b: \"c\"\n",
+		"single":     "it's",
+		"quoted key": json.Number("1.50"),
+		"empty":      nil,
+		"none":       nil,
+		"yes":        true,
+		"items": []interface{}{
+			[]interface{}{json.Number("1"), "a, b", "c"},
+			[]interface{}{"nested", json.Number("2")},
+			map[string]interface{}{"key": "v", "other": "w"},
+			map[string]interface{}{"indented": "x"},
+		},
+		"literal": "line 1\n\n  line 2\n",
+		"strip":   "text",
+		"keep":    "text\n\n",
+		"folded":  "a b\nc\n",
+		"last":    "end",
+	}, v)
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	for doc, msg := range map[string]string{
+		"a: 1\na: 2\n":       "line 2: duplicate key 'a'",
+		"a:\n\t- b\n":        "line 2: tabs can't indent",
+		"a: 1\n  b: 2\n":     "line 2: expected a key of the mapping",
+		"  a: 1\nb: 2\n":     "line 2: unexpected content",
+		"a: 'b\n":            "line 1: invalid quoted scalar 'b",
+		"a: [b, [c]]\n":      "line 1: nested flow collections aren't supported",
+		"a: &anchor b\n":     "line 1: flow mappings, anchors, aliases and tags aren't supported",
+		"a: |2\n  b\n":       "line 1: unsupported block scalar header '|2'",
+		"a:\n- b\nc\n":       "line 3: expected a key of the mapping",
+		"'a: 1\n":            "line 1: unterminated quoted scalar",
+		"a: [\"b\" c]\n":     `line 1: expected ',' after "b"`,
+		"a: \"\\q\"\n":       "line 1: invalid syntax",
+		"a: [b, c\n":         "line 1: unterminated flow sequence",
+		"a: [,]\n":           "line 1: empty item in flow sequence",
+		"a: [200, , 302]\n":  "line 1: empty item in flow sequence",
+		"- a\n  - b\n":       "line 2: expected an item of the sequence",
+		"a:\n  - b\n   c: 1": "line 3: expected an item of the sequence",
+	} {
+		_, err := parseYAML([]byte(doc))
+		assert.EqualError(t, err, msg, doc)
+	}
+}
+
+func TestJSONToYAML(t *testing.T) {
+	doc := `{"s": ["", "a: b", "- a", "123", "true", "null", " a", "a #b", "tab\there", "multi\nline", "trailing\n\n",
+		"\n  indented\nbody\n", "  first\nline"], "n": [1.50, -2, true, null], "e": {}, "l": [], "m": [{"a": {"b": [[1]]}, "c": 2}],
+		"weird: key": "v"}`
+	b, err := jsonToYAML([]byte(doc))
+	if !assert.Nil(t, err) {
+		return
+	}
+	v, err := parseYAML(b)
+	if !assert.Nil(t, err, string(b)) {
+		return
+	}
+	var expected interface{}
+	assert.Nil(t, json.Unmarshal([]byte(doc), &expected))
+	got, _ := json.Marshal(v)
+	var actual interface{}
+	assert.Nil(t, json.Unmarshal(got, &actual))
+	assert.Equal(t, expected, actual, string(b))
+}